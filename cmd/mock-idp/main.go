@@ -0,0 +1,44 @@
+// Mock OpenID Connect provider for trying tenant single sign-on locally.
+//
+// Configure a tenant with issuer http://localhost:9400 and the client ID and
+// secret below, then sign in through /auth/oidc/<tenant_id>/authorize.
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/aethra/genesis/internal/auth/oidctest"
+)
+
+func main() {
+	addr := getEnv("MOCK_IDP_ADDR", ":9400")
+	issuer := getEnv("MOCK_IDP_ISSUER", "http://localhost:9400")
+
+	provider, err := oidctest.New(issuer, getEnv("MOCK_IDP_CLIENT_ID", "genesis"), getEnv("MOCK_IDP_CLIENT_SECRET", "genesis-secret"), oidctest.User{
+		Subject:       getEnv("MOCK_IDP_SUBJECT", "mock-user-1"),
+		Email:         getEnv("MOCK_IDP_EMAIL", "jane@example.com"),
+		EmailVerified: true,
+		GivenName:     "Jane",
+		FamilyName:    "Doe",
+		Groups:        strings.Fields(getEnv("MOCK_IDP_GROUPS", "staff")),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	provider.Interactive = true
+
+	log.Printf("Mock identity provider listening on %s (issuer %s, client %s)", addr, provider.Issuer, provider.ClientID)
+	if err := http.ListenAndServe(addr, provider.Handler()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
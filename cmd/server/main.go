@@ -9,15 +9,19 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/aethra/genesis/internal/api"
 	"github.com/aethra/genesis/internal/auth"
+	"github.com/aethra/genesis/internal/crypto"
 	"github.com/aethra/genesis/internal/database"
 	"github.com/aethra/genesis/internal/engine"
+	"github.com/aethra/genesis/internal/generator"
+	"github.com/aethra/genesis/internal/mail"
 	"github.com/aethra/genesis/internal/models"
 	_ "github.com/lib/pq"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -45,16 +49,40 @@ func startServer() {
 	log.Println("Migrations complete")
 
 	schemaEngine := engine.NewSchemaEngine(db)
-	dataEngine := engine.NewDataEngine(db, schemaEngine)
 	permissionService := auth.NewPermissionService(db)
+	dataEngine := engine.NewDataEngineWithPermissions(db, schemaEngine, permissionService)
 
-	handler := api.NewHandlerWithPermissions(schemaEngine, dataEngine, permissionService)
-	adminHandler := api.NewAdminHandler(db)
-	authHandler := api.NewAuthHandler(db)
+	// Token signing keys are stored encrypted with it; without it anyone
+	// who can read the database could sign tokens
+	cryptoService := crypto.NewService(requireEnv("ENCRYPTION_KEY"))
+
+	// One token service so every handler signs and verifies with the same keys
+	signingKeys, err := auth.NewSigningKeyService(db, cryptoService)
+	if err != nil {
+		log.Fatalf("Signing keys failed: %v", err)
+	}
+	tokenService := auth.NewTokenService(db, auth.NewJWTService(signingKeys))
+	// Expired revocation entries, refresh tokens and sessions are never
+	// presented again; keep the tables checked on every request small
+	go tokenService.RunPurge(time.Hour)
+	apiKeyService := auth.NewAPIKeyService(db)
+	twoFactorService := auth.NewTwoFactorService(db, cryptoService, getEnv("TOTP_ISSUER", "Genesis"))
+	appURL := strings.TrimSuffix(getEnv("APP_BASE_URL", "http://localhost:8080"), "/")
+	oidcService := auth.NewOIDCService(db, cryptoService, appURL+"/app/sso/callback")
+
+	mailer, err := mail.NewFromEnv()
+	if err != nil {
+		log.Fatalf("Mail configuration failed: %v", err)
+	}
+
+	handler := api.NewHandlerWithPermissions(schemaEngine, dataEngine, permissionService, tokenService, apiKeyService)
+	adminHandler := api.NewAdminHandler(db, tokenService, apiKeyService, twoFactorService, oidcService, permissionService)
+	authHandler := api.NewAuthHandler(db, tokenService, twoFactorService, oidcService, permissionService, mailer, appURL)
 	setupHandler := api.NewSetupHandler(db)
 	adminPanelHandler := api.NewAdminPanelHandler(db)
 	uiHandler := api.NewUIHandler(db)
 	generatorHandler := api.NewGeneratorHandler(db)
+	graphqlHandler := api.NewGraphQLHandler(schemaEngine, dataEngine, permissionService)
 
 	// Connect to Aethra database for serving articles (optional)
 	var contentHandler *api.ContentHandler
@@ -72,7 +100,7 @@ func startServer() {
 		log.Printf("Warning: Component warmup failed: %v", err)
 	}
 
-	router := api.SetupRouter(handler, adminHandler, authHandler, setupHandler, adminPanelHandler, uiHandler, generatorHandler, contentHandler)
+	router := api.SetupRouter(handler, adminHandler, authHandler, setupHandler, adminPanelHandler, uiHandler, generatorHandler, contentHandler, graphqlHandler)
 
 	port := getEnv("PORT", "8090")
 	log.Printf("Server starting on port %s", port)
@@ -170,6 +198,10 @@ func runCLI() {
 		runTenantCmd()
 	case "user":
 		runUserCmd()
+	case "codegen":
+		runCodegenCmd()
+	case "eject":
+		runEjectCmd()
 	default:
 		printUsage()
 	}
@@ -184,7 +216,9 @@ Commands:
   tenant list                   List tenants
   tenant create --code= --name= Create tenant
   user list --tenant=           List users
-  user create --tenant= --email= --password= Create user`)
+  user create --tenant= --email= --password= Create user
+  codegen --tenant= --lang=ts|go --out= [--package=] Generate a typed API client
+  eject --tenant= --module= --out= [--go-module=] Eject a module into a standalone Go service`)
 }
 
 func runTenantCmd() {
@@ -255,11 +289,11 @@ func runUserCmd() {
 		if db.Where("code = ?", tenantCode).First(&tenant).Error != nil {
 			log.Fatal("Tenant not found")
 		}
-		hash, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		hash, _ := auth.HashPassword(password)
 		if err := db.Create(&models.User{
 			TenantID:     tenant.ID,
 			Email:        email,
-			PasswordHash: string(hash),
+			PasswordHash: hash,
 			FirstName:    getFlag("--first"),
 			LastName:     getFlag("--last"),
 			IsActive:     true,
@@ -270,6 +304,75 @@ func runUserCmd() {
 	}
 }
 
+func runCodegenCmd() {
+	tenantCode, lang, out := getFlag("--tenant"), getFlag("--lang"), getFlag("--out")
+	if tenantCode == "" || lang == "" || out == "" {
+		printUsage()
+		return
+	}
+	db := connectDB()
+	var tenant models.Tenant
+	if db.Where("code = ?", tenantCode).First(&tenant).Error != nil {
+		log.Fatal("Tenant not found")
+	}
+	schema, err := engine.NewSchemaEngine(db).GetFullSchema(tenant.ID)
+	if err != nil {
+		log.Fatalf("Failed to load schema: %v", err)
+	}
+	files, err := generator.GenerateClient(schema, generator.ClientOptions{Lang: lang, Package: getFlag("--package")})
+	if err != nil {
+		log.Fatalf("Failed: %v", err)
+	}
+	if err := os.MkdirAll(out, 0755); err != nil {
+		log.Fatalf("Failed: %v", err)
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(out, f.Path), []byte(f.Content), 0644); err != nil {
+			log.Fatalf("Failed: %v", err)
+		}
+		fmt.Printf("Wrote %s\n", filepath.Join(out, f.Path))
+	}
+}
+
+func runEjectCmd() {
+	tenantCode, moduleCode, out := getFlag("--tenant"), getFlag("--module"), getFlag("--out")
+	if tenantCode == "" || moduleCode == "" || out == "" {
+		printUsage()
+		return
+	}
+	if entries, err := os.ReadDir(out); err == nil && len(entries) > 0 {
+		log.Fatalf("Output directory %s is not empty", out)
+	}
+	db := connectDB()
+	var tenant models.Tenant
+	if db.Where("code = ?", tenantCode).First(&tenant).Error != nil {
+		log.Fatal("Tenant not found")
+	}
+	schemaEngine := engine.NewSchemaEngine(db)
+	schema, err := schemaEngine.GetFullSchema(tenant.ID)
+	if err != nil {
+		log.Fatalf("Failed to load schema: %v", err)
+	}
+	files, err := generator.Eject(schemaEngine, schema, generator.EjectOptions{
+		ModuleCode: moduleCode,
+		GoModule:   getFlag("--go-module"),
+	})
+	if err != nil {
+		log.Fatalf("Failed: %v", err)
+	}
+	for _, f := range files {
+		path := filepath.Join(out, f.Path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			log.Fatalf("Failed: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(f.Content), 0644); err != nil {
+			log.Fatalf("Failed: %v", err)
+		}
+	}
+	fmt.Printf("Module %s ejected to %s (%d files)\n", moduleCode, out, len(files))
+	fmt.Printf("Next: cd %s && go mod tidy && go test ./...\n", out)
+}
+
 func getFlag(name string) string {
 	prefix := name + "="
 	for _, arg := range os.Args {
@@ -283,7 +386,7 @@ func getFlag(name string) string {
 // Interactive Setup
 func runSetup() {
 	reader := bufio.NewReader(os.Stdin)
-	fmt.Println("\n=== Genesis Setup Wizard ===\n")
+	fmt.Print("\n=== Genesis Setup Wizard ===\n\n")
 
 	// Database configuration
 	fmt.Println("Database Configuration:")
@@ -329,11 +432,11 @@ func runSetup() {
 	adminFirst := prompt(reader, "  First Name", "Admin")
 	adminLast := prompt(reader, "  Last Name", "User")
 
-	hash, _ := bcrypt.GenerateFromPassword([]byte(adminPassword), bcrypt.DefaultCost)
+	hash, _ := auth.HashPassword(adminPassword)
 	user := models.User{
 		TenantID:     tenant.ID,
 		Email:        adminEmail,
-		PasswordHash: string(hash),
+		PasswordHash: hash,
 		FirstName:    adminFirst,
 		LastName:     adminLast,
 		IsActive:     true,
@@ -343,8 +446,8 @@ func runSetup() {
 	}
 	fmt.Printf("Admin user '%s' created!\n", adminEmail)
 
-	// Generate JWT secret
-	jwtSecret := generateSecret(32)
+	// Tokens are signed with keys generated at first start and stored
+	// encrypted with this key
 	encryptionKey := generateSecret(32)
 
 	// Server config
@@ -360,7 +463,6 @@ func runSetup() {
 	fmt.Printf("DB_USER=%s\n", dbUser)
 	fmt.Printf("DB_PASSWORD=%s\n", dbPassword)
 	fmt.Printf("DB_NAME=%s\n", dbName)
-	fmt.Printf("JWT_SECRET=%s\n", jwtSecret)
 	fmt.Printf("ENCRYPTION_KEY=%s\n", encryptionKey)
 	fmt.Printf("PORT=%s\n", port)
 	fmt.Println("----------------------------------------")
@@ -2,9 +2,14 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/aethra/genesis/internal/auth"
+	"github.com/aethra/genesis/internal/engine"
+	"github.com/aethra/genesis/internal/generator"
 	"github.com/aethra/genesis/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -13,12 +18,144 @@ import (
 
 // AdminHandler contains admin API handlers
 type AdminHandler struct {
-	db *gorm.DB
+	db           *gorm.DB
+	schemaEngine *engine.SchemaEngine
+	dataEngine   *engine.DataEngine
+	tokens       *auth.TokenService
+	apiKeys      *auth.APIKeyService
+	twoFactor    *auth.TwoFactorService
+	oidc         *auth.OIDCService
+	throttle     *auth.LoginThrottle
+	passwords    *auth.PasswordService
+	permissions  *auth.PermissionService
+	memberships  *auth.MembershipService
 }
 
 // NewAdminHandler creates a new admin handler
-func NewAdminHandler(db *gorm.DB) *AdminHandler {
-	return &AdminHandler{db: db}
+func NewAdminHandler(db *gorm.DB, tokens *auth.TokenService, apiKeys *auth.APIKeyService, twoFactor *auth.TwoFactorService, oidc *auth.OIDCService, permissions *auth.PermissionService) *AdminHandler {
+	schemaEngine := engine.NewSchemaEngine(db)
+	// Scoped like the API's data engine, so explanations match enforcement
+	dataEngine := engine.NewDataEngine(db, schemaEngine)
+	if permissions != nil {
+		dataEngine = engine.NewDataEngineWithPermissions(db, schemaEngine, permissions)
+	}
+	return &AdminHandler{
+		db:           db,
+		schemaEngine: schemaEngine,
+		dataEngine:   dataEngine,
+		tokens:       tokens,
+		apiKeys:      apiKeys,
+		twoFactor:    twoFactor,
+		oidc:         oidc,
+		throttle:     auth.NewLoginThrottle(db),
+		passwords:    auth.NewPasswordService(db),
+		permissions:  permissions,
+		memberships:  auth.NewMembershipService(db),
+	}
+}
+
+// currentUserID returns the authenticated admin's id, if any
+func (h *AdminHandler) currentUserID(c *gin.Context) *uuid.UUID {
+	if userID, ok := c.Get("user_id"); ok {
+		if id, ok := userID.(uuid.UUID); ok {
+			return &id
+		}
+	}
+	return nil
+}
+
+// isSuperAdmin reports whether the caller may manage every tenant
+func (h *AdminHandler) isSuperAdmin(c *gin.Context) bool {
+	roles, _ := c.Get("user_roles")
+	roleList, _ := roles.([]string)
+	for _, role := range roleList {
+		if role == "super_admin" {
+			return true
+		}
+	}
+	return false
+}
+
+// callerTenantID returns the tenant the caller's token was issued for
+func (h *AdminHandler) callerTenantID(c *gin.Context) uuid.UUID {
+	if tenantID, ok := c.Get("user_tenant_id"); ok {
+		if id, ok := tenantID.(uuid.UUID); ok {
+			return id
+		}
+	}
+	return uuid.Nil
+}
+
+// authorizeTenant checks that the caller may act on the given tenant.
+// Tenant admins are limited to their own tenant; anything else is rejected
+// and recorded in the audit log.
+func (h *AdminHandler) authorizeTenant(c *gin.Context, tenantID uuid.UUID) bool {
+	if h.isSuperAdmin(c) || tenantID == h.callerTenantID(c) {
+		return true
+	}
+
+	h.audit(c, h.callerTenantID(c), nil, "cross_tenant_denied", nil, map[string]interface{}{
+		"target_tenant_id": tenantID,
+		"method":           c.Request.Method,
+		"path":             c.Request.URL.Path,
+	})
+	c.JSON(http.StatusForbidden, gin.H{"error": "access to another tenant is not allowed"})
+	return false
+}
+
+// scopeTenant restricts a list query to the caller's tenant. Super admins
+// see every tenant unless they filter with ?tenant_id=.
+func (h *AdminHandler) scopeTenant(c *gin.Context, query *gorm.DB) (*gorm.DB, bool) {
+	if tenantIDStr := c.Query("tenant_id"); tenantIDStr != "" {
+		tenantID, err := uuid.Parse(tenantIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tenant_id"})
+			return nil, false
+		}
+		if !h.authorizeTenant(c, tenantID) {
+			return nil, false
+		}
+		return query.Where("tenant_id = ?", tenantID), true
+	}
+
+	if h.isSuperAdmin(c) {
+		return query, true
+	}
+	return query.Where("tenant_id = ?", h.callerTenantID(c)), true
+}
+
+// audit records an administrative action in the audit log
+func (h *AdminHandler) audit(c *gin.Context, tenantID uuid.UUID, entity *models.Entity, action string, oldValues, newValues map[string]interface{}) {
+	h.db.Create(h.auditEntry(c, tenantID, entity, action, oldValues, newValues))
+}
+
+// auditTx records an administrative action within a transaction, so the
+// change and its audit entry are committed together or not at all
+func (h *AdminHandler) auditTx(tx *gorm.DB, c *gin.Context, tenantID uuid.UUID, entity *models.Entity, action string, oldValues, newValues map[string]interface{}) error {
+	if err := tx.Create(h.auditEntry(c, tenantID, entity, action, oldValues, newValues)).Error; err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	return nil
+}
+
+// auditEntry builds the audit entry of an administrative action
+func (h *AdminHandler) auditEntry(c *gin.Context, tenantID uuid.UUID, entity *models.Entity, action string, oldValues, newValues map[string]interface{}) *models.AuditLog {
+	entry := &models.AuditLog{
+		ID:             uuid.New(),
+		TenantID:       tenantID,
+		UserID:         h.currentUserID(c),
+		ImpersonatorID: impersonatorID(c),
+		Action:         action,
+		OldValues:      models.JSONB(oldValues),
+		NewValues:      models.JSONB(newValues),
+		IPAddress:      c.ClientIP(),
+		UserAgent:      c.Request.UserAgent(),
+		CreatedAt:      time.Now(),
+	}
+	if entity != nil {
+		entry.EntityCode = entity.Code
+	}
+	return entry
 }
 
 // =============================================================================
@@ -36,9 +173,21 @@ func (h *AdminHandler) ListTenants(c *gin.Context) {
 	c.JSON(http.StatusOK, tenants)
 }
 
-// ListTenantsPublic returns active tenants (public - for login page)
+// ListTenantsPublic returns active tenants (public - for login page). An
+// authenticated user gets only the tenants they are a member of, with their
+// roles in each.
 // GET /api/tenants
 func (h *AdminHandler) ListTenantsPublic(c *gin.Context) {
+	if userID := h.currentUserID(c); userID != nil {
+		tenants, err := h.memberships.Tenants(*userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"tenants": tenants})
+		return
+	}
+
 	var tenants []struct {
 		ID   uuid.UUID `json:"id"`
 		Name string    `json:"name"`
@@ -64,6 +213,9 @@ func (h *AdminHandler) GetTenant(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "tenant not found"})
 		return
 	}
+	if !h.authorizeTenant(c, tenant.ID) {
+		return
+	}
 	c.JSON(http.StatusOK, tenant)
 }
 
@@ -178,13 +330,9 @@ func (h *AdminHandler) ListModules(c *gin.Context) {
 	var modules []models.Module
 	query := h.db.Order("display_order")
 
-	if tenantIDStr := c.Query("tenant_id"); tenantIDStr != "" {
-		tenantID, err := uuid.Parse(tenantIDStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tenant_id"})
-			return
-		}
-		query = query.Where("tenant_id = ?", tenantID)
+	query, ok := h.scopeTenant(c, query)
+	if !ok {
+		return
 	}
 
 	if err := query.Find(&modules).Error; err != nil {
@@ -208,6 +356,9 @@ func (h *AdminHandler) GetModule(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "module not found"})
 		return
 	}
+	if !h.authorizeTenant(c, module.TenantID) {
+		return
+	}
 	c.JSON(http.StatusOK, module)
 }
 
@@ -234,6 +385,9 @@ func (h *AdminHandler) CreateModule(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tenant_id"})
 		return
 	}
+	if !h.authorizeTenant(c, tenantID) {
+		return
+	}
 
 	module := models.Module{
 		ID:           uuid.New(),
@@ -269,6 +423,9 @@ func (h *AdminHandler) UpdateModule(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "module not found"})
 		return
 	}
+	if !h.authorizeTenant(c, module.TenantID) {
+		return
+	}
 
 	var input struct {
 		Code         *string `json:"code"`
@@ -324,11 +481,22 @@ func (h *AdminHandler) DeleteModule(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Delete(&models.Module{}, "id = ?", id).Error; err != nil {
+	var module models.Module
+	if err := h.db.First(&module, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "module not found"})
+		return
+	}
+	if !h.authorizeTenant(c, module.TenantID) {
+		return
+	}
+
+	if err := h.db.Delete(&module).Error; err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Module permissions go with the module
+	h.permissions.InvalidateTenant(module.TenantID)
 	c.JSON(http.StatusOK, gin.H{"message": "module deleted"})
 }
 
@@ -342,6 +510,11 @@ func (h *AdminHandler) ListEntities(c *gin.Context) {
 	var entities []models.Entity
 	query := h.db.Order("display_order")
 
+	query, ok := h.scopeTenant(c, query)
+	if !ok {
+		return
+	}
+
 	if moduleIDStr := c.Query("module_id"); moduleIDStr != "" {
 		moduleID, err := uuid.Parse(moduleIDStr)
 		if err != nil {
@@ -372,6 +545,9 @@ func (h *AdminHandler) GetEntity(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "entity not found"})
 		return
 	}
+	if !h.authorizeTenant(c, entity.TenantID) {
+		return
+	}
 
 	// Get fields for this entity
 	var fields []models.Field
@@ -415,6 +591,9 @@ func (h *AdminHandler) CreateEntity(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "module not found"})
 		return
 	}
+	if !h.authorizeTenant(c, module.TenantID) {
+		return
+	}
 
 	// Generate table name if not provided
 	tableName := input.TableName
@@ -437,6 +616,16 @@ func (h *AdminHandler) CreateEntity(c *gin.Context) {
 		IsActive:     true,
 	}
 
+	// The data table is physical; it must not reach core or other tenants' tables
+	if err := h.schemaEngine.CheckTableName(&entity); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, engine.ErrTableNameTaken) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
 	if err := h.db.Create(&entity).Error; err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -459,6 +648,9 @@ func (h *AdminHandler) UpdateEntity(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "entity not found"})
 		return
 	}
+	if !h.authorizeTenant(c, entity.TenantID) {
+		return
+	}
 
 	var input struct {
 		Code         *string `json:"code"`
@@ -506,11 +698,45 @@ func (h *AdminHandler) UpdateEntity(c *gin.Context) {
 		return
 	}
 
+	// Permissions are resolved by entity code and module
+	h.permissions.InvalidateTenant(entity.TenantID)
 	c.JSON(http.StatusOK, entity)
 }
 
-// DeleteEntity deletes an entity
-// DELETE /admin/entities/:id
+// GetEntityDeletionImpact previews what deleting an entity would remove
+// GET /admin/entities/:id/deletion-impact
+func (h *AdminHandler) GetEntityDeletionImpact(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var entity models.Entity
+	if err := h.db.First(&entity, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "entity not found"})
+		return
+	}
+	if !h.authorizeTenant(c, entity.TenantID) {
+		return
+	}
+
+	impact, err := h.schemaEngine.GetDeletionImpact(&entity)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, impact)
+}
+
+// DeleteEntity deletes an entity together with its fields, views,
+// permissions, relations and menu items. By default the data table is
+// archived so it can be restored; mode=drop removes it permanently and
+// requires confirm=<entity code>. Entities other entities still reference
+// are refused with 409 and the referencing entity codes, as are entities
+// whose data table other entities or tenants share.
+// DELETE /admin/entities/:id?mode=archive|drop&confirm=code
 func (h *AdminHandler) DeleteEntity(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -518,12 +744,119 @@ func (h *AdminHandler) DeleteEntity(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Delete(&models.Entity{}, "id = ?", id).Error; err != nil {
+	var entity models.Entity
+	if err := h.db.First(&entity, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "entity not found"})
+		return
+	}
+	if !h.authorizeTenant(c, entity.TenantID) {
+		return
+	}
+
+	mode := engine.DeletionMode(c.DefaultQuery("mode", string(engine.DeletionArchive)))
+	if mode != engine.DeletionArchive && mode != engine.DeletionDrop {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be 'archive' or 'drop'"})
+		return
+	}
+	if mode == engine.DeletionDrop && c.Query("confirm") != entity.Code {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "dropping an entity deletes its data permanently; pass confirm=<entity code> to proceed"})
+		return
+	}
+
+	impact, err := h.schemaEngine.GetDeletionImpact(&entity)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// Their belongs_to fields would point at nothing and reject every write
+	if len(impact.ReferencedBy) > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":         "entity is referenced by other entities; remove their relations to it first",
+			"referenced_by": impact.ReferencedBy,
+		})
+		return
+	}
+	// Deletion drops or renames the whole table, other tenants' rows included
+	if impact.SharedEntities > 0 || impact.OtherTenantRecords > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "entity's data table is shared with other entities or tenants; it cannot be dropped or archived"})
+		return
+	}
+
+	archive, err := h.schemaEngine.DeleteEntity(&entity, mode, h.currentUserID(c), func(tx *gorm.DB, archive *models.EntityArchive) error {
+		details := map[string]interface{}{"mode": string(mode), "impact": impact}
+		if archive != nil {
+			details["archive_id"] = archive.ID
+			details["archived_table"] = archive.ArchivedTable
+		}
+		return h.auditTx(tx, c, entity.TenantID, &entity, "entity_"+string(mode), details, nil)
+	})
+	if errors.Is(err, engine.ErrSharedTable) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	h.permissions.InvalidateTenant(entity.TenantID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "entity deleted",
+		"mode":    mode,
+		"impact":  impact,
+		"archive": archive,
+	})
+}
+
+// ListEntityArchives returns archived entity tables
+// GET /admin/entity-archives?tenant_id=xxx
+func (h *AdminHandler) ListEntityArchives(c *gin.Context) {
+	var archives []models.EntityArchive
+	query := h.db.Order("archived_at DESC")
+
+	query, ok := h.scopeTenant(c, query)
+	if !ok {
+		return
+	}
+
+	if err := query.Find(&archives).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, archives)
+}
+
+// RestoreEntityArchive restores an archived entity and its data table
+// POST /admin/entity-archives/:id/restore
+func (h *AdminHandler) RestoreEntityArchive(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var archive models.EntityArchive
+	if err := h.db.First(&archive, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "archive not found"})
+		return
+	}
+	if !h.authorizeTenant(c, archive.TenantID) {
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "entity deleted"})
+	entity, err := h.schemaEngine.RestoreEntity(&archive, func(tx *gorm.DB, entity *models.Entity) error {
+		return h.auditTx(tx, c, entity.TenantID, entity, "entity_restore", nil, map[string]interface{}{
+			"archive_id": archive.ID,
+			"table_name": archive.OriginalTable,
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	h.permissions.InvalidateTenant(entity.TenantID)
+
+	c.JSON(http.StatusOK, entity)
 }
 
 // =============================================================================
@@ -547,6 +880,11 @@ func (h *AdminHandler) ListFields(c *gin.Context) {
 	var fields []models.Field
 	query := h.db.Order("display_order")
 
+	query, ok := h.scopeTenant(c, query)
+	if !ok {
+		return
+	}
+
 	if entityIDStr := c.Query("entity_id"); entityIDStr != "" {
 		entityID, err := uuid.Parse(entityIDStr)
 		if err != nil {
@@ -577,6 +915,9 @@ func (h *AdminHandler) GetField(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "field not found"})
 		return
 	}
+	if !h.authorizeTenant(c, field.TenantID) {
+		return
+	}
 	c.JSON(http.StatusOK, field)
 }
 
@@ -623,6 +964,9 @@ func (h *AdminHandler) CreateField(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "entity not found"})
 		return
 	}
+	if !h.authorizeTenant(c, entity.TenantID) {
+		return
+	}
 
 	fieldTypeID, err := uuid.Parse(input.FieldTypeID)
 	if err != nil {
@@ -684,6 +1028,9 @@ func (h *AdminHandler) UpdateField(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "field not found"})
 		return
 	}
+	if !h.authorizeTenant(c, field.TenantID) {
+		return
+	}
 
 	var input struct {
 		Code         *string                `json:"code"`
@@ -779,7 +1126,16 @@ func (h *AdminHandler) DeleteField(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Delete(&models.Field{}, "id = ?", id).Error; err != nil {
+	var field models.Field
+	if err := h.db.First(&field, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "field not found"})
+		return
+	}
+	if !h.authorizeTenant(c, field.TenantID) {
+		return
+	}
+
+	if err := h.db.Delete(&field).Error; err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -797,13 +1153,9 @@ func (h *AdminHandler) ListUsers(c *gin.Context) {
 	var users []models.User
 	query := h.db
 
-	if tenantIDStr := c.Query("tenant_id"); tenantIDStr != "" {
-		tenantID, err := uuid.Parse(tenantIDStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tenant_id"})
-			return
-		}
-		query = query.Where("tenant_id = ?", tenantID)
+	query, ok := h.scopeTenant(c, query)
+	if !ok {
+		return
 	}
 
 	if err := query.Find(&users).Error; err != nil {
@@ -825,7 +1177,7 @@ func (h *AdminHandler) CreateUser(c *gin.Context) {
 	var input struct {
 		TenantID  string                 `json:"tenant_id" binding:"required"`
 		Email     string                 `json:"email" binding:"required,email"`
-		Password  string                 `json:"password" binding:"required,min=8"`
+		Password  string                 `json:"password" binding:"required"`
 		FirstName string                 `json:"first_name"`
 		LastName  string                 `json:"last_name"`
 		Settings  map[string]interface{} `json:"settings"`
@@ -841,11 +1193,14 @@ func (h *AdminHandler) CreateUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tenant_id"})
 		return
 	}
+	if !h.authorizeTenant(c, tenantID) {
+		return
+	}
 
-	// Hash password with bcrypt
-	passwordHash, err := auth.HashPassword(input.Password)
+	// Hash password with bcrypt, subject to the tenant's policy
+	passwordHash, err := h.passwords.HashNew(tenantID, "password", input.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process password"})
+		respondPasswordError(c, err)
 		return
 	}
 
@@ -869,3 +1224,41 @@ func (h *AdminHandler) CreateUser(c *gin.Context) {
 	user.PasswordHash = ""
 	c.JSON(http.StatusCreated, user)
 }
+
+// =============================================================================
+// CLIENT CODE GENERATION
+// =============================================================================
+
+// GenerateClient returns typed API client sources for a tenant's schema
+// GET /admin/codegen?lang=ts|go&package=&tenant_id=
+func (h *AdminHandler) GenerateClient(c *gin.Context) {
+	tenantID := h.callerTenantID(c)
+	if tenantIDStr := c.Query("tenant_id"); tenantIDStr != "" {
+		var err error
+		tenantID, err = uuid.Parse(tenantIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tenant_id"})
+			return
+		}
+	}
+	if !h.authorizeTenant(c, tenantID) {
+		return
+	}
+
+	schema, err := h.schemaEngine.GetFullSchema(tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	files, err := generator.GenerateClient(schema, generator.ClientOptions{
+		Lang:    c.DefaultQuery("lang", "ts"),
+		Package: c.Query("package"),
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"files": files})
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aethra/genesis/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// testAdminHandler returns an admin handler on a database that builds
+// statements without running them, recording the audit entries it writes
+func testAdminHandler(t *testing.T) (*AdminHandler, *[]*models.AuditLog) {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1"}), &gorm.Config{
+		DryRun:                 true,
+		SkipDefaultTransaction: true,
+		DisableAutomaticPing:   true,
+	})
+	if err != nil {
+		t.Fatalf("failed to open dry-run database: %v", err)
+	}
+	var audited []*models.AuditLog
+	err = db.Callback().Create().After("gorm:create").Register("test:audit", func(tx *gorm.DB) {
+		if entry, ok := tx.Statement.Dest.(*models.AuditLog); ok {
+			audited = append(audited, entry)
+		}
+	})
+	if err != nil {
+		t.Fatalf("failed to register callback: %v", err)
+	}
+	return &AdminHandler{db: db}, &audited
+}
+
+// adminContext returns a request context for a caller of a tenant
+func adminContext(method, target string, tenantID uuid.UUID, roles ...string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, target, nil)
+	c.Set("user_id", uuid.New())
+	c.Set("user_tenant_id", tenantID)
+	c.Set("user_roles", roles)
+	return c, w
+}
+
+func TestAuthorizeTenant(t *testing.T) {
+	home, other := uuid.New(), uuid.New()
+	tests := []struct {
+		name   string
+		roles  []string
+		target uuid.UUID
+		want   bool
+	}{
+		{"admin in own tenant", []string{"admin"}, home, true},
+		{"admin in another tenant", []string{"admin"}, other, false},
+		{"super_admin in another tenant", []string{"super_admin"}, other, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, audited := testAdminHandler(t)
+			c, w := adminContext(http.MethodGet, "/admin/tenants/"+tt.target.String(), home, tt.roles...)
+
+			if got := h.authorizeTenant(c, tt.target); got != tt.want {
+				t.Fatalf("authorizeTenant() = %v, want %v", got, tt.want)
+			}
+			if tt.want {
+				if len(*audited) != 0 {
+					t.Error("allowed access was audited as denied")
+				}
+				return
+			}
+			if w.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+			}
+			if len(*audited) != 1 || (*audited)[0].Action != "cross_tenant_denied" || (*audited)[0].TenantID != home {
+				t.Fatalf("audit entries = %+v, want one cross_tenant_denied in the caller's tenant", *audited)
+			}
+			if (*audited)[0].NewValues["target_tenant_id"] != other {
+				t.Errorf("audited target = %v, want %v", (*audited)[0].NewValues["target_tenant_id"], other)
+			}
+		})
+	}
+}
+
+func TestScopeTenantRejectsOtherTenant(t *testing.T) {
+	h, _ := testAdminHandler(t)
+	home, other := uuid.New(), uuid.New()
+
+	c, w := adminContext(http.MethodGet, "/admin/roles?tenant_id="+other.String(), home, "admin")
+	if _, ok := h.scopeTenant(c, h.db); ok || w.Code != http.StatusForbidden {
+		t.Errorf("filtering by another tenant: ok = %v, status = %d", ok, w.Code)
+	}
+
+	// Without a filter, tenant admins only see their own tenant
+	c, _ = adminContext(http.MethodGet, "/admin/roles", home, "admin")
+	query, ok := h.scopeTenant(c, h.db)
+	if !ok {
+		t.Fatal("scopeTenant() refused the caller's own tenant")
+	}
+	var roles []models.Role
+	sql := query.Find(&roles).Statement.SQL.String()
+	if !strings.Contains(sql, "tenant_id = $1") {
+		t.Errorf("query = %s, want it limited to the caller's tenant", sql)
+	}
+}
+
+func TestCreateRoleInOtherTenant(t *testing.T) {
+	h, audited := testAdminHandler(t)
+	home, other := uuid.New(), uuid.New()
+
+	router := gin.New()
+	router.POST("/admin/roles", func(c *gin.Context) {
+		c.Set("user_id", uuid.New())
+		c.Set("user_tenant_id", home)
+		c.Set("user_roles", []string{"admin"})
+	}, h.CreateRole)
+
+	body := `{"tenant_id": "` + other.String() + `", "code": "intruder", "name": "Intruder"}`
+	req := httptest.NewRequest(http.MethodPost, "/admin/roles", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d: %s", w.Code, http.StatusForbidden, w.Body.String())
+	}
+	for _, entry := range *audited {
+		if entry.Action == "role_create" {
+			t.Error("role was created in another tenant")
+		}
+	}
+}
@@ -0,0 +1,266 @@
+// Package api - API key and service account administration
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aethra/genesis/internal/auth"
+	"github.com/aethra/genesis/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// =============================================================================
+// SERVICE ACCOUNTS
+// =============================================================================
+
+// ListServiceAccounts returns the service accounts of the caller's tenant
+// GET /admin/service-accounts
+func (h *AdminHandler) ListServiceAccounts(c *gin.Context) {
+	query, ok := h.scopeTenant(c, h.db.Preload("Roles").Where("is_service_account = true"))
+	if !ok {
+		return
+	}
+
+	var accounts []models.User
+	if err := query.Order("created_at").Find(&accounts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, accounts)
+}
+
+// CreateServiceAccount creates a user that can only authenticate with API keys
+// POST /admin/service-accounts
+func (h *AdminHandler) CreateServiceAccount(c *gin.Context) {
+	var input struct {
+		TenantID string   `json:"tenant_id"`
+		Name     string   `json:"name" binding:"required,max=100"`
+		Roles    []string `json:"roles"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenantID := h.callerTenantID(c)
+	if input.TenantID != "" {
+		var err error
+		if tenantID, err = uuid.Parse(input.TenantID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tenant_id"})
+			return
+		}
+	}
+	if !h.authorizeTenant(c, tenantID) {
+		return
+	}
+
+	var roles []models.Role
+	if len(input.Roles) > 0 {
+		if err := h.db.Where("tenant_id = ? AND code IN ?", tenantID, input.Roles).Find(&roles).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(roles) != len(input.Roles) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown role in roles"})
+			return
+		}
+		for i := range roles {
+			if !h.canManageRole(c, &roles[i]) {
+				return
+			}
+		}
+	}
+
+	// Service accounts have no password, so /auth/login never succeeds for them
+	account := models.User{
+		ID:               uuid.New(),
+		TenantID:         tenantID,
+		FirstName:        input.Name,
+		Settings:         models.JSONB{},
+		IsActive:         true,
+		IsServiceAccount: true,
+	}
+	account.Email = fmt.Sprintf("svc-%s@service-accounts.invalid", strings.SplitN(account.ID.String(), "-", 2)[0])
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Roles").Create(&account).Error; err != nil {
+			return err
+		}
+		for _, role := range roles {
+			if err := tx.Exec("INSERT INTO user_roles (id, user_id, role_id) VALUES (?, ?, ?)",
+				uuid.New(), account.ID, role.ID).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	account.Roles = roles
+	h.audit(c, tenantID, nil, "service_account_create", nil, map[string]interface{}{
+		"user_id": account.ID,
+		"name":    account.FirstName,
+		"roles":   input.Roles,
+	})
+	c.JSON(http.StatusCreated, account)
+}
+
+// =============================================================================
+// API KEYS
+// =============================================================================
+
+// ListAPIKeys returns API keys, optionally for one user
+// GET /admin/api-keys?user_id=&tenant_id=
+func (h *AdminHandler) ListAPIKeys(c *gin.Context) {
+	query, ok := h.scopeTenant(c, h.db.Preload("User"))
+	if !ok {
+		return
+	}
+	if userIDStr := c.Query("user_id"); userIDStr != "" {
+		userID, err := uuid.Parse(userIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+			return
+		}
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var keys []models.APIKey
+	if err := query.Order("created_at DESC").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// CreateAPIKey issues a key for the caller or a service account. The key
+// itself is only included in this response.
+// POST /admin/api-keys
+func (h *AdminHandler) CreateAPIKey(c *gin.Context) {
+	var input struct {
+		UserID    string       `json:"user_id" binding:"required"`
+		Name      string       `json:"name" binding:"required,max=100"`
+		Scopes    models.JSONB `json:"scopes"`
+		ExpiresAt *time.Time   `json:"expires_at"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := uuid.Parse(input.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	var user models.User
+	if err := h.db.Select("id, tenant_id, is_service_account").Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if !h.authorizeTenant(c, user.TenantID) || !h.canIssueAPIKey(c, &user) {
+		return
+	}
+
+	scopes, err := auth.ParseAPIKeyScopes(input.Scopes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, plaintext, err := h.apiKeys.Create(auth.CreateAPIKeyInput{
+		UserID:    userID,
+		Name:      input.Name,
+		Scopes:    scopes,
+		ExpiresAt: input.ExpiresAt,
+		CreatedBy: h.currentUserID(c),
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "validation") {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, key.TenantID, nil, "api_key_create", nil, map[string]interface{}{
+		"api_key_id": key.ID,
+		"user_id":    key.UserID,
+		"name":       key.Name,
+		"prefix":     key.Prefix,
+		"scopes":     key.Scopes,
+		"expires_at": key.ExpiresAt,
+	})
+	c.JSON(http.StatusCreated, gin.H{"api_key": key, "key": plaintext})
+}
+
+// canIssueAPIKey checks that the caller may issue a key for a user. A key
+// acts with its user's roles, never expires unless told to and needs no
+// second factor, so keys are only issued to the caller and to service
+// accounts, and only super admins issue them for admins.
+func (h *AdminHandler) canIssueAPIKey(c *gin.Context, user *models.User) bool {
+	if callerID := h.currentUserID(c); !user.IsServiceAccount && (callerID == nil || *callerID != user.ID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API keys can only be issued to yourself or to service accounts"})
+		return false
+	}
+
+	var roles []models.Role
+	err := h.db.Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ? AND roles.tenant_id = ?", user.ID, user.TenantID).
+		Find(&roles).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	for i := range roles {
+		if (roles[i].Code == "admin" || roles[i].Code == "super_admin") && !h.isSuperAdmin(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only super_admin can issue API keys for users with the " + roles[i].Code + " role"})
+			return false
+		}
+		if !h.canManageRole(c, &roles[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// RevokeAPIKey disables an API key
+// DELETE /admin/api-keys/:id
+func (h *AdminHandler) RevokeAPIKey(c *gin.Context) {
+	keyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var key models.APIKey
+	if err := h.db.Where("id = ?", keyID).First(&key).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	if !h.authorizeTenant(c, key.TenantID) {
+		return
+	}
+
+	if err := h.apiKeys.Revoke(key.ID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, key.TenantID, nil, "api_key_revoke", map[string]interface{}{
+		"api_key_id": key.ID,
+		"user_id":    key.UserID,
+		"name":       key.Name,
+		"prefix":     key.Prefix,
+	}, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
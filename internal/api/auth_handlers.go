@@ -2,127 +2,60 @@
 package api
 
 import (
+	"math"
 	"net/http"
-	"sync"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aethra/genesis/internal/auth"
 	"github.com/aethra/genesis/internal/errors"
+	"github.com/aethra/genesis/internal/mail"
+	"github.com/aethra/genesis/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// LoginRateLimiter implements rate limiting for login attempts
-type LoginRateLimiter struct {
-	attempts map[string]*loginAttempt
-	mu       sync.RWMutex
-}
-
-type loginAttempt struct {
-	count     int
-	firstTry  time.Time
-	blockedAt *time.Time
-}
-
-// NewLoginRateLimiter creates a new rate limiter
-func NewLoginRateLimiter() *LoginRateLimiter {
-	rl := &LoginRateLimiter{
-		attempts: make(map[string]*loginAttempt),
-	}
-	// Start cleanup goroutine
-	go rl.cleanup()
-	return rl
-}
-
-// Allow checks if a login attempt is allowed
-func (rl *LoginRateLimiter) Allow(key string) (bool, int, time.Duration) {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-
-	now := time.Now()
-	attempt, exists := rl.attempts[key]
-
-	if !exists {
-		rl.attempts[key] = &loginAttempt{count: 1, firstTry: now}
-		return true, 4, 0 // 5 attempts allowed, 4 remaining
-	}
-
-	// If blocked, check if block has expired (15 minutes)
-	if attempt.blockedAt != nil {
-		blockDuration := 15 * time.Minute
-		if now.Sub(*attempt.blockedAt) < blockDuration {
-			remaining := blockDuration - now.Sub(*attempt.blockedAt)
-			return false, 0, remaining
-		}
-		// Block expired, reset
-		attempt.count = 1
-		attempt.firstTry = now
-		attempt.blockedAt = nil
-		return true, 4, 0
-	}
-
-	// Reset if window (5 minutes) has passed
-	if now.Sub(attempt.firstTry) > 5*time.Minute {
-		attempt.count = 1
-		attempt.firstTry = now
-		return true, 4, 0
-	}
-
-	// Increment and check
-	attempt.count++
-	if attempt.count > 5 {
-		attempt.blockedAt = &now
-		return false, 0, 15 * time.Minute
-	}
-
-	return true, 5 - attempt.count, 0
-}
-
-// RecordFailure records a failed login attempt
-func (rl *LoginRateLimiter) RecordFailure(key string) {
-	// Already counted in Allow(), this is for tracking
-}
-
-// Reset resets the attempts for a key (on successful login)
-func (rl *LoginRateLimiter) Reset(key string) {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-	delete(rl.attempts, key)
-}
-
-// cleanup removes old entries periodically
-func (rl *LoginRateLimiter) cleanup() {
-	ticker := time.NewTicker(10 * time.Minute)
-	for range ticker.C {
-		rl.mu.Lock()
-		now := time.Now()
-		for key, attempt := range rl.attempts {
-			// Remove entries older than 30 minutes
-			if now.Sub(attempt.firstTry) > 30*time.Minute {
-				delete(rl.attempts, key)
-			}
-		}
-		rl.mu.Unlock()
-	}
-}
-
 // AuthHandler handles authentication endpoints
 type AuthHandler struct {
-	db          *gorm.DB
-	jwtService  *auth.JWTService
-	rateLimiter *LoginRateLimiter
+	db           *gorm.DB
+	tokenService *auth.TokenService
+	twoFactor    *auth.TwoFactorService
+	userTokens   *auth.UserTokenService
+	oidc         *auth.OIDCService
+	permissions  *auth.PermissionService
+	mailer       mail.Mailer
+	appURL       string
+	throttle     *auth.LoginThrottle
+	passwords    *auth.PasswordService
+	memberships  *auth.MembershipService
 }
 
-// NewAuthHandler creates a new auth handler
-func NewAuthHandler(db *gorm.DB) *AuthHandler {
+// NewAuthHandler creates a new auth handler. appURL is the default base URL
+// for links in emails; tenants can override it in their branding.
+func NewAuthHandler(db *gorm.DB, tokens *auth.TokenService, twoFactor *auth.TwoFactorService, oidc *auth.OIDCService, permissions *auth.PermissionService, mailer mail.Mailer, appURL string) *AuthHandler {
 	return &AuthHandler{
-		db:          db,
-		jwtService:  auth.NewJWTService(),
-		rateLimiter: NewLoginRateLimiter(),
+		db:           db,
+		tokenService: tokens,
+		twoFactor:    twoFactor,
+		userTokens:   auth.NewUserTokenService(db),
+		oidc:         oidc,
+		permissions:  permissions,
+		mailer:       mailer,
+		appURL:       strings.TrimSuffix(appURL, "/"),
+		throttle:     auth.NewLoginThrottle(db),
+		passwords:    auth.NewPasswordService(db),
+		memberships:  auth.NewMembershipService(db),
 	}
 }
 
+// clientInfo returns the IP and user agent recorded with issued tokens
+func clientInfo(c *gin.Context) auth.ClientInfo {
+	return auth.ClientInfo{IPAddress: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+}
+
 // LoginRequest represents login credentials
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
@@ -133,7 +66,7 @@ type LoginRequest struct {
 // RegisterRequest represents registration data
 type RegisterRequest struct {
 	Email     string `json:"email" binding:"required,email"`
-	Password  string `json:"password" binding:"required,min=8"`
+	Password  string `json:"password" binding:"required"`
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
 	TenantID  string `json:"tenant_id" binding:"required"`
@@ -164,48 +97,32 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// Rate limiting key: IP + email combination
-	clientIP := c.ClientIP()
-	rateLimitKey := clientIP + ":" + req.Email
-
-	// Check rate limit
-	allowed, remaining, retryAfter := h.rateLimiter.Allow(rateLimitKey)
-	if !allowed {
-		c.Header("Retry-After", retryAfter.String())
-		c.JSON(http.StatusTooManyRequests, gin.H{
-			"error":       "too many login attempts",
-			"retry_after": retryAfter.Seconds(),
-			"message":     "Please wait before trying again",
-		})
-		return
-	}
-
 	tenantID, err := uuid.Parse(req.TenantID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tenant_id"})
 		return
 	}
 
-	// Find user
-	var user struct {
-		ID           uuid.UUID
-		TenantID     uuid.UUID
-		Email        string
-		PasswordHash string
-		FirstName    string
-		LastName     string
-		AvatarURL    *string
-		IsActive     bool
+	// Failures count against both the account and the client address, so
+	// neither guessing one password nor spraying many accounts is cheap
+	account := auth.AccountKey(req.Email)
+	throttleKeys := []auth.ThrottleKey{account, auth.IPKey(c.ClientIP())}
+	decision, err := h.throttle.Check(tenantID, throttleKeys...)
+	if err != nil {
+		status, response := errors.ToHTTPError(errors.NewInternalError(err))
+		c.JSON(status, response)
+		return
+	}
+	if !decision.Allowed {
+		respondThrottled(c, decision, "too many login attempts")
+		return
 	}
 
-	err = h.db.Table("users").
-		Where("email = ? AND tenant_id = ?", req.Email, tenantID).
-		First(&user).Error
-
+	// Find user
+	user, err := h.findLoginUser(tenantID, "users.email = ?", req.Email)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.Header("X-RateLimit-Remaining", string(rune(remaining)))
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+			h.loginFailed(c, tenantID, throttleKeys)
 		} else {
 			status, response := errors.ToHTTPError(errors.NewInternalError(err))
 			c.JSON(status, response)
@@ -220,25 +137,157 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	// Verify password
 	if !auth.CheckPassword(req.Password, user.PasswordHash) {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error":               "invalid credentials",
-			"attempts_remaining":  remaining,
+		h.loginFailed(c, tenantID, throttleKeys)
+		return
+	}
+
+	// Successful login clears the account's failures; the address keeps its
+	// count so one valid account cannot mask spraying of others
+	h.throttle.Reset(tenantID, account)
+
+	// Hashes from before a change of BCRYPT_COST are upgraded while the
+	// password is at hand
+	if auth.PasswordNeedsRehash(user.PasswordHash) {
+		if hash, err := auth.HashPassword(req.Password); err == nil {
+			h.db.Table("users").Where("id = ?", user.ID).Update("password_hash", hash)
+		}
+	}
+
+	// Self-registered accounts must confirm their email first
+	if user.EmailVerifiedAt == nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":                 "email not verified",
+			"verification_required": true,
+			"message":               "Please open the verification link sent to your email",
 		})
 		return
 	}
 
-	// Successful login - reset rate limiter
-	h.rateLimiter.Reset(rateLimitKey)
+	// Expired passwords must be replaced before the login can continue
+	if h.passwords.Policy(user.TenantID).Expired(user.PasswordChangedAt) {
+		token, expiresAt, err := h.tokenService.IssuePasswordChange(user.ID, user.TenantID, user.Email)
+		if err != nil {
+			status, response := errors.ToHTTPError(errors.NewInternalError(err))
+			c.JSON(status, response)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"password_change_required": true,
+			"change_token":             token,
+			"expires_at":               expiresAt,
+		})
+		return
+	}
+
+	h.continueLogin(c, user, nil)
+}
+
+// loginFailed records a failed password attempt and responds with the
+// attempts left, or 429 when it locked the account or address
+func (h *AuthHandler) loginFailed(c *gin.Context, tenantID uuid.UUID, keys []auth.ThrottleKey) {
+	decision, err := h.throttle.RecordFailure(tenantID, clientInfo(c), keys...)
+	if err != nil {
+		status, response := errors.ToHTTPError(errors.NewInternalError(err))
+		c.JSON(status, response)
+		return
+	}
+	if decision.Locked {
+		respondThrottled(c, decision, "too many login attempts")
+		return
+	}
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"error":              "invalid credentials",
+		"attempts_remaining": decision.Remaining,
+	})
+}
+
+// respondThrottled rejects an attempt that must wait for a delay or lockout
+func respondThrottled(c *gin.Context, decision *auth.ThrottleDecision, message string) {
+	seconds := int(math.Ceil(decision.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       message,
+		"locked":      decision.Locked,
+		"retry_after": seconds,
+		"message":     "Please wait before trying again",
+	})
+}
+
+// loginUser is the user row read during login
+type loginUser struct {
+	ID           uuid.UUID
+	TenantID     uuid.UUID
+	Email        string
+	PasswordHash string
+	FirstName    string
+	LastName     string
+	AvatarURL    *string
+	IsActive     bool
+
+	EmailVerifiedAt   *time.Time
+	PasswordChangedAt *time.Time
+}
+
+// findLoginUser finds the user matching a condition who is logging in to a
+// tenant they are a member of. The tenant becomes the user's TenantID, even
+// when their account was created in another. Should an email have accounts
+// in several tenants, the one created in the tenant is preferred.
+func (h *AuthHandler) findLoginUser(tenantID uuid.UUID, condition string, args ...interface{}) (*loginUser, error) {
+	var user loginUser
+	err := h.db.Table("users").
+		Where(condition, args...).
+		Where("users.id IN (SELECT user_id FROM tenant_memberships WHERE tenant_id = ?)", tenantID).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: "users.tenant_id = ? DESC", Vars: []interface{}{tenantID}}}).
+		Take(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	user.TenantID = tenantID
+	return &user, nil
+}
+
+// continueLogin finishes a login whose first factor succeeded. Users with 2FA
+// enrolled, or required by tenant policy, get a challenge instead of tokens;
+// password and single sign-on logins share this policy.
+func (h *AuthHandler) continueLogin(c *gin.Context, user *loginUser, extra gin.H) {
+	roles := h.memberships.RoleCodes(user.ID, user.TenantID)
+
+	// Second factor: required once enrolled, or by tenant policy for admins
+	enabled, err := h.twoFactor.IsEnabled(user.ID)
+	if err != nil {
+		status, response := errors.ToHTTPError(errors.NewInternalError(err))
+		c.JSON(status, response)
+		return
+	}
+	if enabled || h.requiresTwoFactor(user.TenantID, roles) {
+		challenge, expiresAt, err := h.tokenService.IssueChallenge(user.ID, user.TenantID, user.Email)
+		if err != nil {
+			status, response := errors.ToHTTPError(errors.NewInternalError(err))
+			c.JSON(status, response)
+			return
+		}
+		response := gin.H{
+			"mfa_required":        true,
+			"enrollment_required": !enabled,
+			"challenge_token":     challenge,
+			"expires_at":          expiresAt,
+		}
+		for key, value := range extra {
+			response[key] = value
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
 
-	// Get user roles
-	var roles []string
-	h.db.Table("user_roles").
-		Joins("JOIN roles ON roles.id = user_roles.role_id").
-		Where("user_roles.user_id = ?", user.ID).
-		Pluck("roles.code", &roles)
+	h.completeLogin(c, user, roles, extra)
+}
 
+// completeLogin issues tokens for an authenticated user and writes the login
+// response, with any extra fields merged in
+func (h *AuthHandler) completeLogin(c *gin.Context, user *loginUser, roles []string, extra gin.H) {
 	// Generate tokens
-	tokens, err := h.jwtService.GenerateTokenPair(user.ID, user.TenantID, user.Email, roles)
+	tokens, err := h.tokenService.Issue(user.ID, user.TenantID, user.Email, roles, clientInfo(c))
 	if err != nil {
 		status, response := errors.ToHTTPError(errors.NewInternalError(err))
 		c.JSON(status, response)
@@ -254,7 +303,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		avatarURL = *user.AvatarURL
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"user": UserResponse{
 			ID:        user.ID,
 			TenantID:  user.TenantID,
@@ -266,6 +315,42 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		},
 		"tokens": tokens,
 		"roles":  roles,
+	}
+	// Users of several tenants pick where to work with /auth/switch-tenant
+	if tenants, err := h.memberships.Tenants(user.ID); err == nil {
+		response["tenants"] = tenants
+	}
+	for key, value := range extra {
+		response[key] = value
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// requiresTwoFactor reports whether the tenant's policy makes a user with
+// these roles use two-factor authentication
+func (h *AuthHandler) requiresTwoFactor(tenantID uuid.UUID, roles []string) bool {
+	if !auth.HasAdminRole(roles) {
+		return false
+	}
+	var tenant models.Tenant
+	if err := h.db.Select("settings").Where("id = ?", tenantID).First(&tenant).Error; err != nil {
+		return false
+	}
+	return auth.TenantSecuritySettings(tenant.Settings).Require2FAForAdmins
+}
+
+// audit records an authentication event in the audit log
+func (h *AuthHandler) audit(c *gin.Context, tenantID, userID uuid.UUID, action string, values map[string]interface{}) {
+	h.db.Create(&models.AuditLog{
+		ID:             uuid.New(),
+		TenantID:       tenantID,
+		UserID:         &userID,
+		ImpersonatorID: impersonatorID(c),
+		Action:         action,
+		NewValues:      models.JSONB(values),
+		IPAddress:      c.ClientIP(),
+		UserAgent:      c.Request.UserAgent(),
+		CreatedAt:      time.Now(),
 	})
 }
 
@@ -308,18 +393,17 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 
 	// Hash password
-	passwordHash, err := auth.HashPassword(req.Password)
+	passwordHash, err := h.passwords.HashNew(tenantID, "password", req.Password)
 	if err != nil {
-		status, response := errors.ToHTTPError(errors.NewInternalError(err))
-		c.JSON(status, response)
+		respondPasswordError(c, err)
 		return
 	}
 
 	// Create user
 	userID := uuid.New()
 	err = h.db.Exec(`
-		INSERT INTO users (id, tenant_id, email, password_hash, first_name, last_name, is_active)
-		VALUES (?, ?, ?, ?, ?, ?, true)
+		INSERT INTO users (id, tenant_id, email, password_hash, first_name, last_name, is_active, email_verified_at)
+		VALUES (?, ?, ?, ?, ?, ?, true, NULL)
 	`, userID, tenantID, req.Email, passwordHash, req.FirstName, req.LastName).Error
 
 	if err != nil {
@@ -340,9 +424,8 @@ func (h *AuthHandler) Register(c *gin.Context) {
 			uuid.New(), userID, defaultRoleID)
 	}
 
-	// Generate tokens
-	tokens, err := h.jwtService.GenerateTokenPair(userID, tenantID, req.Email, []string{"user"})
-	if err != nil {
+	// Tokens are issued at login, once the email is verified
+	if err := h.sendVerificationEmail(c, userID); err != nil {
 		status, response := errors.ToHTTPError(errors.NewInternalError(err))
 		c.JSON(status, response)
 		return
@@ -357,7 +440,8 @@ func (h *AuthHandler) Register(c *gin.Context) {
 			LastName:  req.LastName,
 			IsActive:  true,
 		},
-		"tokens": tokens,
+		"verification_required": true,
+		"message":               "Check your email to verify your account",
 	})
 }
 
@@ -370,40 +454,21 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	// Validate refresh token and get claims
-	claims, err := h.jwtService.ValidateToken(req.RefreshToken)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
-		return
-	}
-
-	// Get user info (to get latest email and roles)
-	var user struct {
-		Email    string
-		IsActive bool
-	}
-	err = h.db.Table("users").
-		Select("email, is_active").
-		Where("id = ?", claims.UserID).
-		First(&user).Error
-
-	if err != nil || !user.IsActive {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found or disabled"})
-		return
-	}
-
-	// Get current roles
-	var roles []string
-	h.db.Table("user_roles").
-		Joins("JOIN roles ON roles.id = user_roles.role_id").
-		Where("user_roles.user_id = ?", claims.UserID).
-		Pluck("roles.code", &roles)
-
-	// Generate new token pair
-	tokens, err := h.jwtService.GenerateTokenPair(claims.UserID, claims.TenantID, user.Email, roles)
+	// Rotate: the presented token is consumed and its successor returned
+	tokens, err := h.tokenService.Refresh(req.RefreshToken, clientInfo(c))
 	if err != nil {
-		status, response := errors.ToHTTPError(errors.NewInternalError(err))
-		c.JSON(status, response)
+		switch err {
+		case auth.ErrRefreshTokenReused:
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":   "refresh token reuse detected",
+				"message": "This session has been revoked, please log in again",
+			})
+		case auth.ErrInvalidRefreshToken, auth.ErrTokenRevoked:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		default:
+			status, response := errors.ToHTTPError(errors.NewInternalError(err))
+			c.JSON(status, response)
+		}
 		return
 	}
 
@@ -438,19 +503,17 @@ func (h *AuthHandler) GetMe(c *gin.Context) {
 		return
 	}
 
-	// Get roles
-	var roles []string
-	h.db.Table("user_roles").
-		Joins("JOIN roles ON roles.id = user_roles.role_id").
-		Where("user_roles.user_id = ?", userID).
-		Pluck("roles.code", &roles)
+	// The user acts in the tenant their token was issued for, with their
+	// roles there
+	user.TenantID = c.MustGet("user_tenant_id").(uuid.UUID)
+	roles := h.memberships.RoleCodes(user.ID, user.TenantID)
 
 	avatarURL := ""
 	if user.AvatarURL != nil {
 		avatarURL = *user.AvatarURL
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"user": UserResponse{
 			ID:        user.ID,
 			TenantID:  user.TenantID,
@@ -461,7 +524,22 @@ func (h *AuthHandler) GetMe(c *gin.Context) {
 			IsActive:  user.IsActive,
 		},
 		"roles": roles,
-	})
+	}
+	if tenants, err := h.memberships.Tenants(user.ID); err == nil {
+		response["tenants"] = tenants
+	}
+
+	// Let the UI show that an admin is acting as the user
+	if value, ok := c.Get("token_claims"); ok {
+		if claims := value.(*auth.Claims); claims.ImpersonatorID != nil {
+			response["impersonator"] = gin.H{
+				"id":         claims.ImpersonatorID,
+				"email":      claims.ImpersonatorEmail,
+				"expires_at": claims.ExpiresAt.Time,
+			}
+		}
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // ChangePassword changes the user's password
@@ -475,7 +553,7 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 
 	var req struct {
 		CurrentPassword string `json:"current_password" binding:"required"`
-		NewPassword     string `json:"new_password" binding:"required,min=8"`
+		NewPassword     string `json:"new_password" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -501,49 +579,193 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
-	// Hash new password
-	newHash, err := auth.HashPassword(req.NewPassword)
+	// Update password, subject to the tenant's policy
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		return h.passwords.SetPassword(tx, userID.(uuid.UUID), "new_password", req.NewPassword)
+	})
 	if err != nil {
-		status, response := errors.ToHTTPError(errors.NewInternalError(err))
-		c.JSON(status, response)
+		respondPasswordError(c, err)
 		return
 	}
 
-	// Update password
-	err = h.db.Table("users").
-		Where("id = ?", userID).
-		Update("password_hash", newHash).Error
+	h.audit(c, c.MustGet("user_tenant_id").(uuid.UUID), userID.(uuid.UUID), "password_change", nil)
+	c.JSON(http.StatusOK, gin.H{"message": "password changed successfully"})
+}
 
+// ChangeExpiredPassword sets a new password for a user whose password expired
+// under the tenant's policy, then continues the login as /auth/login would
+// POST /auth/login/change-password
+func (h *AuthHandler) ChangeExpiredPassword(c *gin.Context) {
+	var req struct {
+		ChangeToken string `json:"change_token" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "change_token and new_password are required"})
+		return
+	}
+
+	claims, err := h.tokenService.ValidatePasswordChange(req.ChangeToken)
 	if err != nil {
-		status, response := errors.ToHTTPError(errors.NewInternalError(err))
-		c.JSON(status, response)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired change token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "password changed successfully"})
+	user, err := h.findLoginUser(claims.TenantID, "users.id = ?", claims.UserID)
+	if err != nil || !user.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired change token"})
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		return h.passwords.SetPassword(tx, user.ID, "new_password", req.NewPassword)
+	})
+	if err != nil {
+		respondPasswordError(c, err)
+		return
+	}
+
+	h.audit(c, user.TenantID, user.ID, "password_change", map[string]interface{}{"expired": true})
+	h.continueLogin(c, user, gin.H{"password_changed": true})
 }
 
-// Logout invalidates the current session
+// respondPasswordError reports password policy violations rule by rule
+func respondPasswordError(c *gin.Context, err error) {
+	if _, ok := err.(errors.ValidationErrors); !ok {
+		err = errors.NewInternalError(err)
+	}
+	status, response := errors.ToHTTPError(err)
+	c.JSON(status, response)
+}
+
+// Logout revokes the current session: the refresh token family of this login
+// and the access token used for the request
 // POST /auth/logout
-// Note: For stateless JWT, we return success and let the client discard the token
-// For production, consider implementing a token blacklist in Redis
 func (h *AuthHandler) Logout(c *gin.Context) {
-	// Get user info for audit logging
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+	value, _ := c.Get("token_claims")
+	claims, ok := value.(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "logout requires a session token, API keys are revoked by an admin"})
 		return
 	}
 
-	// Update last activity (optional audit)
-	h.db.Table("users").Where("id = ?", userID).Update("updated_at", gorm.Expr("CURRENT_TIMESTAMP"))
+	if claims.FamilyID != uuid.Nil {
+		if err := h.tokenService.RevokeFamily(claims.FamilyID, auth.RevokeReasonLogout); err != nil {
+			status, response := errors.ToHTTPError(errors.NewInternalError(err))
+			c.JSON(status, response)
+			return
+		}
+	}
+	if err := h.tokenService.RevokeAccessToken(claims, auth.RevokeReasonLogout); err != nil {
+		status, response := errors.ToHTTPError(errors.NewInternalError(err))
+		c.JSON(status, response)
+		return
+	}
 
-	// In a production system, you would:
-	// 1. Add the token to a blacklist (Redis with TTL matching token expiry)
-	// 2. Or use short-lived access tokens with refresh token rotation
+	c.JSON(http.StatusOK, gin.H{"message": "logged out successfully"})
+}
+
+// SwitchTenantRequest names the tenant to continue in
+type SwitchTenantRequest struct {
+	TenantID string `json:"tenant_id" binding:"required"`
+}
+
+// SwitchTenant moves the current login to another tenant the user is a
+// member of. The session ends and a token pair for the new tenant, carrying
+// the user's roles there, is issued in its place.
+// POST /auth/switch-tenant
+func (h *AuthHandler) SwitchTenant(c *gin.Context) {
+	value, _ := c.Get("token_claims")
+	claims, ok := value.(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "switching tenants requires a session token, API keys belong to one tenant"})
+		return
+	}
+
+	var req SwitchTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tenant_id is required"})
+		return
+	}
+	tenantID, err := uuid.Parse(req.TenantID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tenant_id"})
+		return
+	}
+
+	member, err := h.memberships.IsMember(claims.UserID, tenantID)
+	if err != nil {
+		status, response := errors.ToHTTPError(errors.NewInternalError(err))
+		c.JSON(status, response)
+		return
+	}
+	if !member {
+		c.JSON(http.StatusForbidden, gin.H{"error": auth.ErrNotMember.Error()})
+		return
+	}
+
+	user, err := h.findLoginUser(tenantID, "users.id = ?", claims.UserID)
+	if err != nil || !user.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "account is disabled"})
+		return
+	}
+	roles := h.memberships.RoleCodes(user.ID, tenantID)
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "logged out successfully",
-		"note":    "please discard your tokens client-side",
+	// Users who enrolled already passed their second factor at login, but the
+	// new tenant may require one they do not have
+	if h.requiresTwoFactor(tenantID, roles) {
+		enabled, err := h.twoFactor.IsEnabled(user.ID)
+		if err != nil {
+			status, response := errors.ToHTTPError(errors.NewInternalError(err))
+			c.JSON(status, response)
+			return
+		}
+		if !enabled {
+			c.JSON(http.StatusForbidden, gin.H{"error": "the tenant requires two-factor authentication, enable it before switching"})
+			return
+		}
+	}
+
+	if claims.FamilyID != uuid.Nil {
+		if err := h.tokenService.RevokeFamily(claims.FamilyID, auth.RevokeReasonTenantSwitch); err != nil {
+			status, response := errors.ToHTTPError(errors.NewInternalError(err))
+			c.JSON(status, response)
+			return
+		}
+	}
+	if err := h.tokenService.RevokeAccessToken(claims, auth.RevokeReasonTenantSwitch); err != nil {
+		status, response := errors.ToHTTPError(errors.NewInternalError(err))
+		c.JSON(status, response)
+		return
+	}
+
+	h.audit(c, tenantID, user.ID, "tenant_switch", map[string]interface{}{
+		"from_tenant_id": claims.TenantID,
 	})
+	h.completeLogin(c, user, roles, gin.H{"switched_from": claims.TenantID})
+}
+
+// LogoutAll revokes every session of the current user
+// POST /auth/logout-all
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	value, _ := c.Get("token_claims")
+	claims, ok := value.(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "logout requires a session token, API keys are revoked by an admin"})
+		return
+	}
+
+	if err := h.tokenService.RevokeUser(claims.UserID, auth.RevokeReasonLogoutAll); err != nil {
+		status, response := errors.ToHTTPError(errors.NewInternalError(err))
+		c.JSON(status, response)
+		return
+	}
+	// Tokens issued before refresh tokens were tracked have no record to revoke
+	if err := h.tokenService.RevokeAccessToken(claims, auth.RevokeReasonLogoutAll); err != nil {
+		status, response := errors.ToHTTPError(errors.NewInternalError(err))
+		c.JSON(status, response)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out of all sessions"})
 }
@@ -0,0 +1,268 @@
+// Package api - Password reset and email verification handlers
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aethra/genesis/internal/auth"
+	"github.com/aethra/genesis/internal/errors"
+	"github.com/aethra/genesis/internal/mail"
+	"github.com/aethra/genesis/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Lifetimes of links sent by email
+const (
+	passwordResetTTL     = time.Hour
+	emailVerificationTTL = 48 * time.Hour
+)
+
+// EmailRequest identifies an account by email within a tenant
+type EmailRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	TenantID string `json:"tenant_id" binding:"required"`
+}
+
+// mailUser is the user row needed to address an email
+type mailUser struct {
+	ID               uuid.UUID
+	TenantID         uuid.UUID
+	Email            string
+	FirstName        string
+	IsActive         bool
+	IsServiceAccount bool
+	EmailVerifiedAt  *time.Time
+}
+
+// ForgotPassword emails a password reset link. The response is the same
+// whether or not the account exists, so it cannot be used to probe emails.
+// POST /auth/forgot-password
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	user, ok := h.lookupMailUser(c)
+	if !ok {
+		return
+	}
+
+	if user != nil && user.IsActive && !user.IsServiceAccount {
+		token, err := h.userTokens.Create(user.ID, auth.PurposePasswordReset, passwordResetTTL, c.ClientIP())
+		if err != nil {
+			status, response := errors.ToHTTPError(errors.NewInternalError(err))
+			c.JSON(status, response)
+			return
+		}
+		if err := h.sendTemplate(user, mail.TemplatePasswordReset, "/app/reset-password", token, "1 hour"); err != nil {
+			status, response := errors.ToHTTPError(errors.NewInternalError(err))
+			c.JSON(status, response)
+			return
+		}
+		h.audit(c, user.TenantID, user.ID, "password_reset_requested", nil)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the account exists, a reset link has been sent"})
+}
+
+// ResetPassword sets a new password from a reset link and ends every session
+// POST /auth/reset-password
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req struct {
+		Token       string `json:"token" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	var userID, tenantID uuid.UUID
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		userID, err = h.userTokens.ConsumeTx(tx, auth.PurposePasswordReset, req.Token)
+		if err != nil {
+			return err
+		}
+		if err := tx.Table("users").Select("tenant_id").Where("id = ?", userID).Scan(&tenantID).Error; err != nil {
+			return err
+		}
+		// A rejected password rolls back, leaving the link usable
+		if err := h.passwords.SetPassword(tx, userID, "new_password", req.NewPassword); err != nil {
+			return err
+		}
+		// Receiving the link proves the address
+		return tx.Table("users").
+			Where("id = ? AND email_verified_at IS NULL", userID).
+			Update("email_verified_at", time.Now()).Error
+	})
+	if err == auth.ErrInvalidUserToken {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		respondPasswordError(c, err)
+		return
+	}
+
+	if err := h.tokenService.RevokeUser(userID, auth.RevokeReasonPasswordReset); err != nil {
+		status, response := errors.ToHTTPError(errors.NewInternalError(err))
+		c.JSON(status, response)
+		return
+	}
+	h.audit(c, tenantID, userID, "password_reset", nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "password has been reset, please log in"})
+}
+
+// VerifyEmail confirms an email address from a verification link
+// POST /auth/verify-email
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	userID, err := h.userTokens.Consume(auth.PurposeEmailVerification, req.Token)
+	if err == auth.ErrInvalidUserToken {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		status, response := errors.ToHTTPError(errors.NewInternalError(err))
+		c.JSON(status, response)
+		return
+	}
+
+	var tenantID uuid.UUID
+	h.db.Table("users").Select("tenant_id").Where("id = ?", userID).Scan(&tenantID)
+	err = h.db.Table("users").
+		Where("id = ? AND email_verified_at IS NULL", userID).
+		Update("email_verified_at", time.Now()).Error
+	if err != nil {
+		status, response := errors.ToHTTPError(errors.NewInternalError(err))
+		c.JSON(status, response)
+		return
+	}
+	h.audit(c, tenantID, userID, "email_verified", nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "email verified, you can now log in"})
+}
+
+// ResendVerification emails a new verification link to an unverified account.
+// Like ForgotPassword, the response does not reveal whether the account exists.
+// POST /auth/resend-verification
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	user, ok := h.lookupMailUser(c)
+	if !ok {
+		return
+	}
+
+	if user != nil && user.IsActive && user.EmailVerifiedAt == nil {
+		if err := h.sendVerificationEmail(c, user.ID); err != nil {
+			status, response := errors.ToHTTPError(errors.NewInternalError(err))
+			c.JSON(status, response)
+			return
+		}
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the account needs verification, a new link has been sent"})
+}
+
+// lookupMailUser binds an EmailRequest, applies the mail throttle and
+// loads the account. It returns a nil user when none matches; false means a
+// response was already written.
+func (h *AuthHandler) lookupMailUser(c *gin.Context) (*mailUser, bool) {
+	var req EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
+		return nil, false
+	}
+	tenantID, err := uuid.Parse(req.TenantID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tenant_id"})
+		return nil, false
+	}
+
+	// Every request counts, so links cannot be used to flood an inbox
+	throttleKey := auth.ThrottleKey{Scope: auth.ThrottleMail, Value: strings.ToLower(req.Email)}
+	decision, err := h.throttle.Check(tenantID, throttleKey)
+	if err == nil && decision.Allowed {
+		_, err = h.throttle.RecordFailure(tenantID, clientInfo(c), throttleKey)
+	}
+	if err != nil {
+		status, response := errors.ToHTTPError(errors.NewInternalError(err))
+		c.JSON(status, response)
+		return nil, false
+	}
+	if !decision.Allowed {
+		respondThrottled(c, decision, "too many requests")
+		return nil, false
+	}
+
+	var user mailUser
+	err = h.db.Table("users").
+		Where("email = ? AND tenant_id = ?", req.Email, tenantID).
+		First(&user).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, true
+	}
+	if err != nil {
+		status, response := errors.ToHTTPError(errors.NewInternalError(err))
+		c.JSON(status, response)
+		return nil, false
+	}
+	return &user, true
+}
+
+// sendVerificationEmail issues a verification token and emails the link
+func (h *AuthHandler) sendVerificationEmail(c *gin.Context, userID uuid.UUID) error {
+	var user mailUser
+	if err := h.db.Table("users").Where("id = ?", userID).First(&user).Error; err != nil {
+		return err
+	}
+	token, err := h.userTokens.Create(user.ID, auth.PurposeEmailVerification, emailVerificationTTL, c.ClientIP())
+	if err != nil {
+		return err
+	}
+	return h.sendTemplate(&user, mail.TemplateVerifyEmail, "/app/verify-email", token, "48 hours")
+}
+
+// sendTemplate renders a tenant-branded email with a link carrying token and
+// sends it in the background, so slow mail servers do not delay responses or
+// reveal whether an account exists
+func (h *AuthHandler) sendTemplate(user *mailUser, name, path, token, expiresIn string) error {
+	var tenant models.Tenant
+	if err := h.db.Where("id = ?", user.TenantID).First(&tenant).Error; err != nil {
+		return err
+	}
+
+	brand := mail.TenantBranding(&tenant, h.appURL)
+	tmpl, err := mail.TenantTemplate(&tenant, name)
+	if err != nil {
+		return err
+	}
+	msg, err := tmpl.Render(mail.TemplateData{
+		Brand:     brand,
+		Email:     user.Email,
+		FirstName: user.FirstName,
+		Link:      strings.TrimSuffix(brand.AppURL, "/") + path + "?token=" + url.QueryEscape(token),
+		ExpiresIn: expiresIn,
+	})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if err := h.mailer.Send(context.Background(), msg); err != nil {
+			log.Printf("mail: failed to send %s to %s: %v", name, user.Email, err)
+		}
+	}()
+	return nil
+}
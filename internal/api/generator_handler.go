@@ -31,7 +31,13 @@ func (h *GeneratorHandler) GetCompiler() *generator.Compiler {
 
 // GenerateAll generates components for all entities
 func (h *GeneratorHandler) GenerateAll(c *gin.Context) {
+	// Admins generate for the tenant their token was issued for
 	tenantIDStr := c.GetString("tenant_id")
+	if callerTenant, ok := c.Get("user_tenant_id"); ok {
+		if id, ok := callerTenant.(uuid.UUID); ok {
+			tenantIDStr = id.String()
+		}
+	}
 	if tenantIDStr == "" {
 		// Get first tenant
 		var tenantID uuid.UUID
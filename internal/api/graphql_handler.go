@@ -0,0 +1,52 @@
+// Package api - GraphQL API Handler
+package api
+
+import (
+	"net/http"
+
+	"github.com/aethra/genesis/internal/auth"
+	"github.com/aethra/genesis/internal/engine"
+	"github.com/aethra/genesis/internal/errors"
+	"github.com/aethra/genesis/internal/gql"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GraphQLHandler serves the per-tenant GraphQL API
+type GraphQLHandler struct {
+	service *gql.Service
+}
+
+// NewGraphQLHandler creates a new GraphQL handler
+func NewGraphQLHandler(schemaEngine *engine.SchemaEngine, dataEngine *engine.DataEngine, permissionService *auth.PermissionService) *GraphQLHandler {
+	return &GraphQLHandler{
+		service: gql.NewService(schemaEngine, dataEngine, permissionService),
+	}
+}
+
+// Query executes a GraphQL query or mutation
+// POST /api/graphql
+func (h *GraphQLHandler) Query(c *gin.Context) {
+	tenantID := c.MustGet("tenant_id").(uuid.UUID)
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var request gql.Request
+	if err := c.ShouldBindJSON(&request); err != nil {
+		status, response := errors.ToHTTPError(errors.NewBadRequestError("invalid request body"))
+		c.JSON(status, response)
+		return
+	}
+
+	// Resolvers narrow permissions to the API key's scopes, if any, and audit
+	// the admin impersonating the user
+	ctx := auth.ContextWithScopes(c.Request.Context(), apiKeyScopes(c))
+	ctx = auth.ContextWithImpersonator(ctx, impersonatorID(c))
+	result, err := h.service.Execute(ctx, tenantID, userID, request)
+	if err != nil {
+		status, response := errors.ToHTTPError(err)
+		c.JSON(status, response)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
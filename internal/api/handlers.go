@@ -17,26 +17,32 @@ import (
 type Handler struct {
 	schemaEngine      *engine.SchemaEngine
 	dataEngine        *engine.DataEngine
-	jwtService        *auth.JWTService
+	tokenService      *auth.TokenService
+	apiKeyService     *auth.APIKeyService
 	permissionService *auth.PermissionService
+	openAPI           *engine.OpenAPIGenerator
 }
 
 // NewHandler creates a new API handler
-func NewHandler(schemaEngine *engine.SchemaEngine, dataEngine *engine.DataEngine) *Handler {
+func NewHandler(schemaEngine *engine.SchemaEngine, dataEngine *engine.DataEngine, tokens *auth.TokenService, apiKeys *auth.APIKeyService) *Handler {
 	return &Handler{
-		schemaEngine: schemaEngine,
-		dataEngine:   dataEngine,
-		jwtService:   auth.NewJWTService(),
+		schemaEngine:  schemaEngine,
+		dataEngine:    dataEngine,
+		tokenService:  tokens,
+		apiKeyService: apiKeys,
+		openAPI:       engine.NewOpenAPIGenerator(schemaEngine),
 	}
 }
 
 // NewHandlerWithPermissions creates a new API handler with permission checking
-func NewHandlerWithPermissions(schemaEngine *engine.SchemaEngine, dataEngine *engine.DataEngine, permService *auth.PermissionService) *Handler {
+func NewHandlerWithPermissions(schemaEngine *engine.SchemaEngine, dataEngine *engine.DataEngine, permService *auth.PermissionService, tokens *auth.TokenService, apiKeys *auth.APIKeyService) *Handler {
 	return &Handler{
 		schemaEngine:      schemaEngine,
 		dataEngine:        dataEngine,
-		jwtService:        auth.NewJWTService(),
+		tokenService:      tokens,
+		apiKeyService:     apiKeys,
 		permissionService: permService,
+		openAPI:           engine.NewOpenAPIGenerator(schemaEngine),
 	}
 }
 
@@ -72,42 +78,58 @@ func (h *Handler) TenantMiddleware() gin.HandlerFunc {
 	}
 }
 
-// UserMiddleware extracts user from JWT token
+// UserMiddleware extracts user from a JWT or an API key. API keys may be sent
+// in the X-API-Key header or as a bearer token.
 func (h *Handler) UserMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get Authorization header
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			// No auth header - continue without user context (for optional auth)
-			c.Next()
-			return
-		}
+		credential := c.GetHeader("X-API-Key")
+		if credential == "" {
+			// Get Authorization header
+			authHeader := c.GetHeader("Authorization")
+			if authHeader == "" {
+				// No auth header - continue without user context (for optional auth)
+				c.Next()
+				return
+			}
 
-		// Check Bearer prefix
-		if !strings.HasPrefix(authHeader, "Bearer ") {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header format"})
-			c.Abort()
-			return
+			// Check Bearer prefix
+			if !strings.HasPrefix(authHeader, "Bearer ") {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header format"})
+				c.Abort()
+				return
+			}
+
+			credential = strings.TrimPrefix(authHeader, "Bearer ")
 		}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+		if auth.IsAPIKey(credential) {
+			if !h.authenticateAPIKey(c, credential) {
+				return
+			}
+		} else {
+			// Validate token and check it has not been revoked
+			claims, err := h.tokenService.ValidateAccessToken(credential)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+				c.Abort()
+				return
+			}
 
-		// Validate token
-		claims, err := h.jwtService.ValidateToken(tokenString)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
-			c.Abort()
-			return
+			// Set user context
+			c.Set("user_id", claims.UserID)
+			c.Set("user_email", claims.Email)
+			c.Set("user_roles", claims.Roles)
+			c.Set("user_tenant_id", claims.TenantID)
+			c.Set("token_claims", claims)
+			if claims.ImpersonatorID != nil {
+				c.Set("impersonator_id", *claims.ImpersonatorID)
+			}
 		}
 
-		// Set user context
-		c.Set("user_id", claims.UserID)
-		c.Set("user_email", claims.Email)
-		c.Set("user_roles", claims.Roles)
-
-		// Validate tenant matches (if tenant_id is set)
+		// Validate tenant matches (if tenant_id is set). Tokens are issued for
+		// one tenant; members of several switch with /auth/switch-tenant.
 		if tenantID, exists := c.Get("tenant_id"); exists {
-			if tid, ok := tenantID.(uuid.UUID); ok && tid != claims.TenantID {
+			if tid, ok := tenantID.(uuid.UUID); ok && tid != c.MustGet("user_tenant_id").(uuid.UUID) {
 				c.JSON(http.StatusForbidden, gin.H{"error": "user does not belong to this tenant"})
 				c.Abort()
 				return
@@ -118,6 +140,61 @@ func (h *Handler) UserMiddleware() gin.HandlerFunc {
 	}
 }
 
+// authenticateAPIKey sets the user context for an API key, aborting the
+// request if the key is not valid
+func (h *Handler) authenticateAPIKey(c *gin.Context, key string) bool {
+	if h.apiKeyService == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API keys are not enabled"})
+		c.Abort()
+		return false
+	}
+
+	identity, err := h.apiKeyService.Authenticate(key, c.ClientIP())
+	if err != nil {
+		message := "invalid API key"
+		if err == auth.ErrAPIKeyExpired {
+			message = "API key has expired"
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": message})
+		c.Abort()
+		return false
+	}
+
+	c.Set("user_id", identity.UserID)
+	c.Set("user_email", identity.Email)
+	c.Set("user_roles", identity.Roles)
+	c.Set("user_tenant_id", identity.TenantID)
+	c.Set("api_key_id", identity.KeyID)
+	if identity.Scopes != nil {
+		c.Set("api_key_scopes", identity.Scopes)
+	}
+	return true
+}
+
+// apiKeyScopes returns the scopes of the API key used for the request, or nil
+// for JWTs and unscoped keys
+func apiKeyScopes(c *gin.Context) auth.APIKeyScopes {
+	scopes, _ := c.Get("api_key_scopes")
+	result, _ := scopes.(auth.APIKeyScopes)
+	return result
+}
+
+// impersonatorID returns the admin impersonating the caller, or nil when the
+// caller is acting as themselves
+func impersonatorID(c *gin.Context) *uuid.UUID {
+	if value, exists := c.Get("impersonator_id"); exists {
+		id := value.(uuid.UUID)
+		return &id
+	}
+	return nil
+}
+
+// data returns the data engine for a request, auditing the admin
+// impersonating the caller, if any
+func (h *Handler) data(c *gin.Context) *engine.DataEngine {
+	return h.dataEngine.Impersonated(impersonatorID(c))
+}
+
 // RequireAuthMiddleware requires authentication (must be used after UserMiddleware)
 func (h *Handler) RequireAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -130,9 +207,31 @@ func (h *Handler) RequireAuthMiddleware() gin.HandlerFunc {
 	}
 }
 
-// RequireAdminMiddleware requires user to have admin or super_admin role
+// RejectImpersonationMiddleware keeps an admin impersonating a user from
+// acting on the user's credentials and sessions, or impersonating anyone
+// else (must be used after UserMiddleware)
+func (h *Handler) RejectImpersonationMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if impersonatorID(c) != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "not allowed while impersonating a user"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAdminMiddleware requires user to have admin or super_admin role.
+// Tenant admins are further limited to their own tenant by AdminHandler.
 func (h *Handler) RequireAdminMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// Scoped API keys are limited to data access
+		if apiKeyScopes(c) != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "scoped API keys cannot access the admin API"})
+			c.Abort()
+			return
+		}
+
 		roles, exists := c.Get("user_roles")
 		if !exists {
 			c.JSON(http.StatusForbidden, gin.H{"error": "admin access required"})
@@ -169,9 +268,42 @@ func (h *Handler) RequireAdminMiddleware() gin.HandlerFunc {
 	}
 }
 
+// RequireSuperAdminMiddleware requires the super_admin role (must be used after UserMiddleware)
+func (h *Handler) RequireSuperAdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roles, _ := c.Get("user_roles")
+		roleList, _ := roles.([]string)
+
+		for _, role := range roleList {
+			if role == "super_admin" {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "super_admin access required",
+			"message": "Only super_admin can manage tenants",
+		})
+		c.Abort()
+	}
+}
+
 // PermissionMiddleware checks if user has permission for the requested action
 func (h *Handler) PermissionMiddleware(action auth.Action) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// API key scopes narrow whatever the user's roles allow
+		if !apiKeyScopes(c).Allows(c.Param("entity"), action) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "permission denied",
+				"action":  string(action),
+				"entity":  c.Param("entity"),
+				"message": "The API key's scopes do not allow this action",
+			})
+			c.Abort()
+			return
+		}
+
 		// Skip if no permission service configured
 		if h.permissionService == nil {
 			c.Next()
@@ -236,6 +368,49 @@ func (h *Handler) GetSchema(c *gin.Context) {
 	c.JSON(http.StatusOK, schema)
 }
 
+// GetEntityJSONSchema returns draft 2020-12 JSON Schemas for an entity's
+// create and update payloads. DataEngine validates writes against the same
+// schemas after normalizing form input (blank values to null, numeric strings
+// to numbers).
+// GET /api/schema/:entity/jsonschema
+func (h *Handler) GetEntityJSONSchema(c *gin.Context) {
+	tenantID := c.MustGet("tenant_id").(uuid.UUID)
+	entityCode := c.Param("entity")
+
+	schema, err := h.schemaEngine.GetEntitySchema(tenantID, entityCode)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			h.handleError(c, errors.NewNotFoundError("entity"))
+		} else {
+			h.handleError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"create": engine.PayloadSchema(schema.Entity, true),
+		"update": engine.PayloadSchema(schema.Entity, false),
+	})
+}
+
+// GetOpenAPI returns the OpenAPI 3.1 document for the tenant's data API
+// GET /api/openapi.json
+func (h *Handler) GetOpenAPI(c *gin.Context) {
+	tenantID := c.MustGet("tenant_id").(uuid.UUID)
+
+	document, err := h.openAPI.Document(tenantID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			h.handleError(c, errors.NewNotFoundError("tenant"))
+		} else {
+			h.handleError(c, err)
+		}
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", document)
+}
+
 // GetEntitySchema returns the schema for a specific entity
 // GET /api/schema/:entity
 func (h *Handler) GetEntitySchema(c *gin.Context) {
@@ -275,6 +450,12 @@ func (h *Handler) List(c *gin.Context) {
 		Filters:  make(map[string]interface{}),
 	}
 
+	// Related records to embed
+	// Format: include=field1,field2
+	if include := c.Query("include"); include != "" {
+		params.Include = strings.Split(include, ",")
+	}
+
 	// Parse filters from query params
 	// Format: filter[field]=value
 	for key, values := range c.Request.URL.Query() {
@@ -286,7 +467,18 @@ func (h *Handler) List(c *gin.Context) {
 		}
 	}
 
-	result, err := h.dataEngine.List(tenantID, entityCode, params)
+	// Only the records the user may see
+	if uid, exists := c.Get("user_id"); exists {
+		id := uid.(uuid.UUID)
+		params.UserID = &id
+	}
+	if scopes := apiKeyScopes(c); scopes != nil {
+		params.IncludeAllowed = func(entityCode string) bool {
+			return scopes.Allows(entityCode, auth.ActionView)
+		}
+	}
+
+	result, err := h.data(c).List(tenantID, entityCode, params)
 	if err != nil {
 		h.handleError(c, err)
 		return
@@ -306,7 +498,14 @@ func (h *Handler) Get(c *gin.Context) {
 		return
 	}
 
-	record, err := h.dataEngine.Get(tenantID, entityCode, recordID)
+	// Get user ID if available
+	var userID *uuid.UUID
+	if uid, exists := c.Get("user_id"); exists {
+		id := uid.(uuid.UUID)
+		userID = &id
+	}
+
+	record, err := h.data(c).Get(tenantID, entityCode, recordID, userID)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			h.handleError(c, errors.NewNotFoundError("record"))
@@ -338,7 +537,7 @@ func (h *Handler) Create(c *gin.Context) {
 		userID = &id
 	}
 
-	record, err := h.dataEngine.Create(tenantID, entityCode, data, userID)
+	record, err := h.data(c).Create(tenantID, entityCode, data, userID)
 	if err != nil {
 		if strings.Contains(err.Error(), "validation") || strings.Contains(err.Error(), "required") {
 			h.handleError(c, errors.NewValidationError("", err.Error()))
@@ -351,6 +550,40 @@ func (h *Handler) Create(c *gin.Context) {
 	c.JSON(http.StatusCreated, record)
 }
 
+// BulkCreate creates multiple records in one transaction
+// POST /api/data/:entity/bulk-create
+func (h *Handler) BulkCreate(c *gin.Context) {
+	tenantID := c.MustGet("tenant_id").(uuid.UUID)
+	entityCode := c.Param("entity")
+
+	var request struct {
+		Records []map[string]interface{} `json:"records"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || len(request.Records) == 0 {
+		h.handleError(c, errors.NewBadRequestError("invalid request body"))
+		return
+	}
+
+	// Get user ID if available
+	var userID *uuid.UUID
+	if uid, exists := c.Get("user_id"); exists {
+		id := uid.(uuid.UUID)
+		userID = &id
+	}
+
+	records, err := h.data(c).BulkCreate(tenantID, entityCode, request.Records, userID)
+	if err != nil {
+		if strings.Contains(err.Error(), "validation") || strings.Contains(err.Error(), "required") {
+			h.handleError(c, errors.NewValidationError("", err.Error()))
+		} else {
+			h.handleError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": records, "count": len(records)})
+}
+
 // Update updates an existing record
 // PUT /api/data/:entity/:id
 func (h *Handler) Update(c *gin.Context) {
@@ -375,7 +608,7 @@ func (h *Handler) Update(c *gin.Context) {
 		userID = &id
 	}
 
-	record, err := h.dataEngine.Update(tenantID, entityCode, recordID, data, userID)
+	record, err := h.data(c).Update(tenantID, entityCode, recordID, data, userID)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			h.handleError(c, errors.NewNotFoundError("record"))
@@ -408,7 +641,7 @@ func (h *Handler) Delete(c *gin.Context) {
 		userID = &id
 	}
 
-	if err := h.dataEngine.Delete(tenantID, entityCode, recordID, userID); err != nil {
+	if err := h.data(c).Delete(tenantID, entityCode, recordID, userID); err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			h.handleError(c, errors.NewNotFoundError("record"))
 		} else {
@@ -451,14 +684,59 @@ func (h *Handler) BulkDelete(c *gin.Context) {
 		userID = &id
 	}
 
-	if err := h.dataEngine.BulkDelete(tenantID, entityCode, recordIDs, userID); err != nil {
-		h.handleError(c, err)
+	if err := h.data(c).BulkDelete(tenantID, entityCode, recordIDs, userID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			h.handleError(c, errors.NewNotFoundError("record"))
+		} else {
+			h.handleError(c, err)
+		}
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "deleted successfully", "count": len(recordIDs)})
 }
 
+// TransferOwnership makes another user of the tenant the owner of a record
+// POST /api/data/:entity/:id/transfer
+func (h *Handler) TransferOwnership(c *gin.Context) {
+	tenantID := c.MustGet("tenant_id").(uuid.UUID)
+	entityCode := c.Param("entity")
+	recordID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.handleError(c, errors.NewBadRequestError("invalid id"))
+		return
+	}
+
+	var request struct {
+		OwnerID uuid.UUID `json:"owner_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.handleError(c, errors.NewBadRequestError("owner_id is required"))
+		return
+	}
+
+	// Get user ID if available
+	var userID *uuid.UUID
+	if uid, exists := c.Get("user_id"); exists {
+		id := uid.(uuid.UUID)
+		userID = &id
+	}
+
+	record, err := h.data(c).TransferOwnership(tenantID, entityCode, recordID, request.OwnerID, userID)
+	if err != nil {
+		if strings.Contains(err.Error(), "validation") {
+			h.handleError(c, errors.NewValidationError("owner_id", err.Error()))
+		} else if strings.Contains(err.Error(), "not found") {
+			h.handleError(c, errors.NewNotFoundError("record"))
+		} else {
+			h.handleError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, record)
+}
+
 // =============================================================================
 // HEALTH CHECK
 // =============================================================================
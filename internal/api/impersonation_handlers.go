@@ -0,0 +1,174 @@
+// Package api - Impersonation handlers
+package api
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aethra/genesis/internal/auth"
+	"github.com/aethra/genesis/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Impersonate issues a short-lived token that lets the caller act as a user
+// and see exactly what they see. Super admins may impersonate anyone but
+// another super admin; tenant admins may impersonate the users of their
+// tenant who are not admins.
+// POST /admin/impersonate/:user_id
+func (h *AdminHandler) Impersonate(c *gin.Context) {
+	var input struct {
+		Reason string `json:"reason" binding:"max=500"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok := c.Get("token_claims"); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "impersonation requires a session token, not an API key"})
+		return
+	}
+	impersonator := models.User{ID: *h.currentUserID(c), Email: c.GetString("user_email")}
+
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	if userID == impersonator.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot impersonate yourself"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if !h.authorizeTenant(c, user.TenantID) {
+		return
+	}
+	if !user.IsActive || user.IsServiceAccount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "only active users can be impersonated"})
+		return
+	}
+
+	roles := h.memberships.RoleCodes(user.ID, user.TenantID)
+
+	// Impersonating must never give the caller more power than they have
+	for _, role := range roles {
+		if role == "super_admin" || (role == "admin" && !h.isSuperAdmin(c)) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "cannot impersonate a user with the " + role + " role"})
+			return
+		}
+	}
+
+	token, err := h.tokens.Impersonate(&impersonator, &user, roles, input.Reason, auth.ClientInfo{
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Recorded as the impersonated user, like everything done with the token
+	h.db.Create(&models.AuditLog{
+		ID:             uuid.New(),
+		TenantID:       user.TenantID,
+		UserID:         &user.ID,
+		ImpersonatorID: &impersonator.ID,
+		Action:         "impersonation_start",
+		NewValues: models.JSONB{
+			"session_id":         token.SessionID,
+			"email":              user.Email,
+			"impersonator_email": impersonator.Email,
+			"reason":             input.Reason,
+			"expires_at":         token.ExpiresAt,
+		},
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		CreatedAt: time.Now(),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"token": token,
+		"user": gin.H{
+			"id":        user.ID,
+			"tenant_id": user.TenantID,
+			"email":     user.Email,
+			"roles":     roles,
+		},
+	})
+}
+
+// ListImpersonations returns impersonation sessions, newest first. Filter
+// with ?user_id=, ?impersonator_id= and ?active=true.
+// GET /admin/impersonations
+func (h *AdminHandler) ListImpersonations(c *gin.Context) {
+	query, ok := h.scopeTenant(c, h.db.Preload("User").Preload("Impersonator"))
+	if !ok {
+		return
+	}
+	query = query.Where("impersonator_id IS NOT NULL")
+
+	for _, param := range []string{"user_id", "impersonator_id"} {
+		if value := c.Query(param); value != "" {
+			id, err := uuid.Parse(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + param})
+				return
+			}
+			query = query.Where(param+" = ?", id)
+		}
+	}
+	if c.Query("active") == "true" {
+		query = query.Where("revoked_at IS NULL AND expires_at > ?", time.Now())
+	}
+
+	var sessions []models.Session
+	if err := query.Order("created_at DESC").Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeImpersonation ends an impersonation session, rejecting its token
+// immediately
+// DELETE /admin/impersonations/:id
+func (h *AdminHandler) RevokeImpersonation(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var session models.Session
+	err = h.db.Where("id = ? AND impersonator_id IS NOT NULL", sessionID).First(&session).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "impersonation session not found"})
+		return
+	}
+	if !h.authorizeTenant(c, session.TenantID) {
+		return
+	}
+	if session.RevokedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "impersonation session already ended"})
+		return
+	}
+
+	if err := h.tokens.RevokeFamily(session.ID, auth.RevokeReasonImpersonationEnded); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, session.TenantID, nil, "impersonation_revoke", nil, map[string]interface{}{
+		"session_id":      session.ID,
+		"user_id":         session.UserID,
+		"impersonator_id": session.ImpersonatorID,
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "impersonation session revoked"})
+}
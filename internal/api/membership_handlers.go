@@ -0,0 +1,206 @@
+// Package api - Tenant membership handlers
+package api
+
+import (
+	"net/http"
+
+	"github.com/aethra/genesis/internal/auth"
+	"github.com/aethra/genesis/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// TenantMemberResponse is a member of a tenant with their roles there
+type TenantMemberResponse struct {
+	models.TenantMembership
+	Roles []models.Role `json:"roles"`
+}
+
+// ListTenantMembers returns the users who belong to a tenant, including those
+// whose account was created in another
+// GET /admin/tenants/:id/members
+func (h *AdminHandler) ListTenantMembers(c *gin.Context) {
+	tenantID, ok := h.memberTenant(c)
+	if !ok {
+		return
+	}
+
+	members, err := h.memberships.Members(tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var assigned []struct {
+		models.Role `gorm:"embedded"`
+		MemberID    uuid.UUID
+	}
+	err = h.db.Table("roles").
+		Select("roles.*, user_roles.user_id AS member_id").
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("roles.tenant_id = ?", tenantID).
+		Order("roles.code").
+		Scan(&assigned).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	roles := make(map[uuid.UUID][]models.Role)
+	for _, role := range assigned {
+		roles[role.MemberID] = append(roles[role.MemberID], role.Role)
+	}
+
+	response := make([]TenantMemberResponse, len(members))
+	for i, member := range members {
+		response[i] = TenantMemberResponse{TenantMembership: member, Roles: roles[member.UserID]}
+		if response[i].Roles == nil {
+			response[i].Roles = []models.Role{}
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// AddTenantMember lets a user of any tenant log in to this one. They have no
+// roles in it until some are assigned. Only super_admin may add members, as
+// it looks up users of other tenants; the router enforces this.
+// POST /admin/tenants/:id/members
+func (h *AdminHandler) AddTenantMember(c *gin.Context) {
+	tenantID, ok := h.memberTenant(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		UserID string `json:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, err := uuid.Parse(input.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.Select("id, tenant_id, email, is_service_account").Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	// Service accounts act for their own tenant through API keys
+	if user.IsServiceAccount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "service accounts cannot join other tenants"})
+		return
+	}
+
+	membership, err := h.memberships.Add(tenantID, user.ID, h.currentUserID(c))
+	if err == auth.ErrAlreadyMember {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, tenantID, nil, "member_add", nil, map[string]interface{}{
+		"user_id":        user.ID,
+		"email":          user.Email,
+		"home_tenant_id": user.TenantID,
+	})
+	c.JSON(http.StatusCreated, membership)
+}
+
+// SetTenantMemberRoles replaces a member's roles in the tenant
+// PUT /admin/tenants/:id/members/:user_id/roles
+func (h *AdminHandler) SetTenantMemberRoles(c *gin.Context) {
+	tenantID, ok := h.memberTenant(c)
+	if !ok {
+		return
+	}
+	user, ok := h.tenantMember(c, tenantID)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		RoleIDs []string `json:"role_ids"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	roleIDs, ok := parseRoleIDs(c, input.RoleIDs)
+	if !ok {
+		return
+	}
+	h.assignRoles(c, user, tenantID, roleIDs)
+}
+
+// RemoveTenantMember takes a user out of a tenant other than their own,
+// unassigning their roles there and ending their sessions in it
+// DELETE /admin/tenants/:id/members/:user_id
+func (h *AdminHandler) RemoveTenantMember(c *gin.Context) {
+	tenantID, ok := h.memberTenant(c)
+	if !ok {
+		return
+	}
+	user, ok := h.tenantMember(c, tenantID)
+	if !ok {
+		return
+	}
+
+	err := h.memberships.Remove(tenantID, user.ID)
+	switch {
+	case err == auth.ErrHomeTenant:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err == auth.ErrNotMember:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.permissions.InvalidateUser(user.ID)
+	h.audit(c, tenantID, nil, "member_remove", map[string]interface{}{
+		"user_id": user.ID,
+		"email":   user.Email,
+	}, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "member removed"})
+}
+
+// memberTenant returns the tenant in the path, checking the caller may manage
+// its members
+func (h *AdminHandler) memberTenant(c *gin.Context) (uuid.UUID, bool) {
+	tenantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return uuid.Nil, false
+	}
+	if !h.authorizeTenant(c, tenantID) {
+		return uuid.Nil, false
+	}
+	return tenantID, true
+}
+
+// tenantMember loads the user in the path, who must be a member of the tenant
+func (h *AdminHandler) tenantMember(c *gin.Context, tenantID uuid.UUID) (*models.User, bool) {
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return nil, false
+	}
+
+	var user models.User
+	err = h.db.Select("id, tenant_id, email").
+		Where("id = ? AND id IN (SELECT user_id FROM tenant_memberships WHERE tenant_id = ?)", userID, tenantID).
+		First(&user).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": auth.ErrNotMember.Error()})
+		return nil, false
+	}
+	return &user, true
+}
@@ -0,0 +1,298 @@
+// Package api - OpenID Connect single sign-on handlers
+package api
+
+import (
+	stderrors "errors"
+	"net/http"
+	"strings"
+
+	"github.com/aethra/genesis/internal/auth"
+	"github.com/aethra/genesis/internal/errors"
+	"github.com/aethra/genesis/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SSOCallbackRequest carries the parameters the identity provider appended to
+// the redirect URI
+type SSOCallbackRequest struct {
+	State            string `json:"state" binding:"required"`
+	Code             string `json:"code"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// =============================================================================
+// SIGN-ON
+// =============================================================================
+
+// GetSSOStatus tells the login page whether to offer single sign-on
+// GET /auth/oidc/:tenant_id
+func (h *AuthHandler) GetSSOStatus(c *gin.Context) {
+	tenantID, err := uuid.Parse(c.Param("tenant_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tenant_id"})
+		return
+	}
+
+	provider, err := h.oidc.Provider(tenantID)
+	if err != nil && err != auth.ErrOIDCNotConfigured {
+		status, response := errors.ToHTTPError(errors.NewInternalError(err))
+		c.JSON(status, response)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": provider != nil && provider.IsEnabled})
+}
+
+// AuthorizeSSO redirects the browser to the tenant's identity provider. The
+// optional return_to path is handed back when the sign-on completes.
+// GET /auth/oidc/:tenant_id/authorize
+func (h *AuthHandler) AuthorizeSSO(c *gin.Context) {
+	tenantID, err := uuid.Parse(c.Param("tenant_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tenant_id"})
+		return
+	}
+
+	authURL, err := h.oidc.Begin(c.Request.Context(), tenantID, safeReturnPath(c.Query("return_to")))
+	if err != nil {
+		h.respondSSOError(c, err)
+		return
+	}
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// CompleteSSO finishes a sign-on. The page at the redirect URI posts the
+// state and code it received; the response is the same as /auth/login.
+// POST /auth/oidc/callback
+func (h *AuthHandler) CompleteSSO(c *gin.Context) {
+	var req SSOCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+	if req.Error != "" || req.Code == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   auth.ErrOIDCLoginFailed.Error(),
+			"details": strings.TrimSpace(req.Error + " " + req.ErrorDescription),
+		})
+		return
+	}
+
+	login, err := h.oidc.Complete(c.Request.Context(), req.State, req.Code)
+	if err != nil {
+		h.respondSSOError(c, err)
+		return
+	}
+	// The provider's role mapping may have changed the user's roles
+	h.permissions.InvalidateUser(login.UserID)
+
+	user, err := h.findLoginUser(login.TenantID, "users.id = ?", login.UserID)
+	if err != nil {
+		status, response := errors.ToHTTPError(errors.NewInternalError(err))
+		c.JSON(status, response)
+		return
+	}
+	if !user.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "account is disabled"})
+		return
+	}
+
+	h.audit(c, login.TenantID, login.UserID, "sso_login", map[string]interface{}{
+		"provider_id": login.ProviderID,
+		"subject":     login.Subject,
+		"provisioned": login.Created,
+	})
+	h.continueLogin(c, user, gin.H{"sso": true, "return_to": login.ReturnTo})
+}
+
+// respondSSOError maps sign-on errors to responses
+func (h *AuthHandler) respondSSOError(c *gin.Context, err error) {
+	switch {
+	case err == auth.ErrOIDCNotConfigured:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err == auth.ErrInvalidOIDCState:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err == auth.ErrOIDCSignupDisabled, err == auth.ErrOIDCEmailNotVerified:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case stderrors.Is(err, auth.ErrOIDCLoginFailed):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": auth.ErrOIDCLoginFailed.Error(), "details": err.Error()})
+	case stderrors.Is(err, auth.ErrOIDCProviderUnavailable):
+		c.JSON(http.StatusBadGateway, gin.H{"error": auth.ErrOIDCProviderUnavailable.Error()})
+	default:
+		status, response := errors.ToHTTPError(errors.NewInternalError(err))
+		c.JSON(status, response)
+	}
+}
+
+// safeReturnPath keeps return_to to a local path so sign-on cannot be used
+// as an open redirect
+func safeReturnPath(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return ""
+	}
+	return path
+}
+
+// =============================================================================
+// ADMINISTRATION
+// =============================================================================
+
+// OIDCProviderRequest configures a tenant's identity provider. Omitting
+// client_secret keeps the stored one.
+type OIDCProviderRequest struct {
+	Issuer       string              `json:"issuer" binding:"required"`
+	ClientID     string              `json:"client_id" binding:"required,max=255"`
+	ClientSecret *string             `json:"client_secret"`
+	Scopes       string              `json:"scopes"`
+	RedirectURL  string              `json:"redirect_url"`
+	RoleClaim    string              `json:"role_claim" binding:"max=100"`
+	RoleMapping  map[string][]string `json:"role_mapping"`
+	DefaultRole  string              `json:"default_role"`
+	AllowSignup  *bool               `json:"allow_signup"`
+	IsEnabled    *bool               `json:"is_enabled"`
+}
+
+// GetOIDCProvider returns a tenant's identity provider configuration
+// GET /admin/tenants/:id/oidc
+func (h *AdminHandler) GetOIDCProvider(c *gin.Context) {
+	tenantID, ok := h.oidcTenant(c)
+	if !ok {
+		return
+	}
+
+	provider, err := h.oidc.Provider(tenantID)
+	if err == auth.ErrOIDCNotConfigured {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, oidcProviderResponse(provider))
+}
+
+// SaveOIDCProvider creates or replaces a tenant's identity provider
+// PUT /admin/tenants/:id/oidc
+func (h *AdminHandler) SaveOIDCProvider(c *gin.Context) {
+	tenantID, ok := h.oidcTenant(c)
+	if !ok {
+		return
+	}
+
+	var req OIDCProviderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Only super admins may let an identity provider grant super_admin
+	if !h.isSuperAdmin(c) {
+		roles := []string{req.DefaultRole}
+		for _, mapped := range req.RoleMapping {
+			roles = append(roles, mapped...)
+		}
+		for _, role := range roles {
+			if role == "super_admin" {
+				c.JSON(http.StatusForbidden, gin.H{"error": "only super_admin can map the super_admin role"})
+				return
+			}
+		}
+	}
+
+	var old map[string]interface{}
+	if existing, err := h.oidc.Provider(tenantID); err == nil {
+		old = oidcProviderResponse(existing)
+	}
+
+	input := auth.OIDCProviderInput{
+		Issuer:       strings.TrimSpace(req.Issuer),
+		ClientID:     req.ClientID,
+		ClientSecret: req.ClientSecret,
+		Scopes:       req.Scopes,
+		RedirectURL:  req.RedirectURL,
+		RoleClaim:    req.RoleClaim,
+		RoleMapping:  req.RoleMapping,
+		DefaultRole:  req.DefaultRole,
+		AllowSignup:  req.AllowSignup == nil || *req.AllowSignup,
+		IsEnabled:    req.IsEnabled == nil || *req.IsEnabled,
+	}
+	provider, err := h.oidc.SaveProvider(c.Request.Context(), tenantID, input)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "validation") {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := oidcProviderResponse(provider)
+	newValues := make(map[string]interface{}, len(response)+1)
+	for key, value := range response {
+		newValues[key] = value
+	}
+	newValues["client_secret_changed"] = req.ClientSecret != nil
+	h.audit(c, tenantID, nil, "oidc_provider_update", old, newValues)
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteOIDCProvider removes a tenant's identity provider. Users created
+// through it keep their accounts but can no longer sign on with it.
+// DELETE /admin/tenants/:id/oidc
+func (h *AdminHandler) DeleteOIDCProvider(c *gin.Context) {
+	tenantID, ok := h.oidcTenant(c)
+	if !ok {
+		return
+	}
+
+	existing, err := h.oidc.Provider(tenantID)
+	if err == auth.ErrOIDCNotConfigured {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.oidc.DeleteProvider(tenantID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, tenantID, nil, "oidc_provider_delete", oidcProviderResponse(existing), nil)
+	c.JSON(http.StatusOK, gin.H{"message": "identity provider removed"})
+}
+
+// oidcTenant parses the tenant in the path and checks the caller may manage it
+func (h *AdminHandler) oidcTenant(c *gin.Context) (uuid.UUID, bool) {
+	tenantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return uuid.Nil, false
+	}
+	if !h.authorizeTenant(c, tenantID) {
+		return uuid.Nil, false
+	}
+	return tenantID, true
+}
+
+// oidcProviderResponse shows a provider without its secret
+func oidcProviderResponse(provider *models.OIDCProvider) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                provider.ID,
+		"tenant_id":         provider.TenantID,
+		"issuer":            provider.Issuer,
+		"client_id":         provider.ClientID,
+		"has_client_secret": provider.ClientSecret != "",
+		"scopes":            provider.Scopes,
+		"redirect_url":      provider.RedirectURL,
+		"role_claim":        provider.RoleClaim,
+		"role_mapping":      auth.OIDCRoleMapping(provider.RoleMapping),
+		"default_role":      provider.DefaultRole,
+		"allow_signup":      provider.AllowSignup,
+		"is_enabled":        provider.IsEnabled,
+		"updated_at":        provider.UpdatedAt,
+	}
+}
@@ -0,0 +1,1265 @@
+// Package api - Role and permission administration handlers
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aethra/genesis/internal/auth"
+	"github.com/aethra/genesis/internal/engine"
+	"github.com/aethra/genesis/internal/errors"
+	"github.com/aethra/genesis/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// roleCodePattern keeps role codes usable in tokens and identity provider
+// role mappings
+var roleCodePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,49}$`)
+
+// RoleResponse is a role with its permissions and number of members
+type RoleResponse struct {
+	models.Role
+	Permissions []PermissionEntry `json:"permissions"`
+	UserCount   int64             `json:"user_count"`
+}
+
+// PermissionEntry is what a role may, or may not, do on one entity or on
+// every entity of a module. The permission matrix is read and written as a
+// list of entries; see auth.PermissionService for how they combine.
+type PermissionEntry struct {
+	Role string `json:"role"`
+	// Entity or Module names what the entry applies to. Module entries are
+	// defaults for entities the role has no entry of its own for.
+	Entity string `json:"entity,omitempty"`
+	Module string `json:"module,omitempty"`
+	// Effect is allow (the default) or deny. A deny entry's actions and
+	// field flags are taken away, whatever other entries allow.
+	Effect  string        `json:"effect,omitempty"`
+	Actions []auth.Action `json:"actions"`
+	// Scopes limits view, edit or delete to records the user owns ("own")
+	// or their team owns ("team"). Unlisted actions apply to all records.
+	Scopes           map[auth.Action]string          `json:"scopes,omitempty"`
+	FieldPermissions map[string]auth.FieldPermission `json:"field_permissions,omitempty"`
+	RowFilter        map[string]interface{}          `json:"row_filter,omitempty"`
+	// Remove deletes the role's permission on the entity when saving
+	Remove bool `json:"remove,omitempty"`
+}
+
+// PermissionMatrix is every role × entity × action of a tenant
+type PermissionMatrix struct {
+	TenantID    uuid.UUID         `json:"tenant_id"`
+	Actions     []auth.Action     `json:"actions"`
+	Roles       []models.Role     `json:"roles"`
+	Modules     []MatrixTarget    `json:"modules"`
+	Entities    []MatrixTarget    `json:"entities"`
+	Permissions []PermissionEntry `json:"permissions"`
+}
+
+// MatrixTarget is a module or entity as listed in the permission matrix
+type MatrixTarget struct {
+	ID     uuid.UUID `json:"id"`
+	Code   string    `json:"code"`
+	Name   string    `json:"name"`
+	Module string    `json:"module,omitempty"` // Code of an entity's module
+}
+
+// PermissionExplainResponse explains whether a user may perform an action on
+// an entity, and on one of its records, as enforcement would decide it
+type PermissionExplainResponse struct {
+	UserID  uuid.UUID   `json:"user_id"`
+	Email   string      `json:"email"`
+	Entity  string      `json:"entity"`
+	Action  auth.Action `json:"action"`
+	Allowed bool        `json:"allowed"`
+	*auth.PermissionExplanation
+	Record *RecordExplanation `json:"record,omitempty"`
+}
+
+// RecordExplanation tells whether a record is within the user's row filter,
+// ownership scope and shares for the action
+type RecordExplanation struct {
+	ID uuid.UUID `json:"id"`
+	engine.RecordAccess
+}
+
+// permissionTarget is the entity or the module a permission entry applies to
+type permissionTarget struct {
+	entity *models.Entity
+	module *models.Module
+}
+
+// permissionChange is a permission as it was and is after a matrix update;
+// nil means there was or is none
+type permissionChange struct {
+	target   permissionTarget
+	old, new *PermissionEntry
+}
+
+// =============================================================================
+// ROLES
+// =============================================================================
+
+// ListRoles returns all roles (optionally filtered by tenant_id query param)
+// GET /admin/roles?tenant_id=xxx
+func (h *AdminHandler) ListRoles(c *gin.Context) {
+	query, ok := h.scopeTenant(c, h.db.Order("code"))
+	if !ok {
+		return
+	}
+
+	var roles []models.Role
+	if err := query.Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, roles)
+}
+
+// GetRole returns a role with its permissions
+// GET /admin/roles/:id
+func (h *AdminHandler) GetRole(c *gin.Context) {
+	role, ok := h.adminRole(c)
+	if !ok {
+		return
+	}
+
+	var permissions []models.Permission
+	err := h.db.Preload("Entity").Preload("Module").Where("role_id = ?", role.ID).Find(&permissions).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response := RoleResponse{Role: *role, Permissions: []PermissionEntry{}}
+	for _, permission := range permissions {
+		target := permissionTarget{entity: permission.Entity, module: permission.Module}
+		if target.valid() {
+			response.Permissions = append(response.Permissions, permissionEntry(role, target, &permission))
+		}
+	}
+	sortPermissionEntries(response.Permissions)
+	h.db.Table("user_roles").Where("role_id = ?", role.ID).Count(&response.UserCount)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateRole creates a new role
+// POST /admin/roles
+func (h *AdminHandler) CreateRole(c *gin.Context) {
+	var input struct {
+		TenantID    string `json:"tenant_id" binding:"required"`
+		Code        string `json:"code" binding:"required"`
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description"`
+		ParentID    string `json:"parent_id"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenantID, err := uuid.Parse(input.TenantID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tenant_id"})
+		return
+	}
+	if !h.authorizeTenant(c, tenantID) {
+		return
+	}
+
+	role := models.Role{
+		ID:          uuid.New(),
+		TenantID:    tenantID,
+		Code:        input.Code,
+		Name:        strings.TrimSpace(input.Name),
+		Description: input.Description,
+		IsActive:    true,
+	}
+	if !h.canManageRole(c, &role) || !h.validateRole(c, &role, true) || !h.setRoleParent(c, &role, input.ParentID) {
+		return
+	}
+
+	if err := h.db.Create(&role).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, tenantID, nil, "role_create", nil, roleValues(&role))
+	c.JSON(http.StatusCreated, role)
+}
+
+// UpdateRole updates a role. System roles keep their code and stay active.
+// PUT /admin/roles/:id
+func (h *AdminHandler) UpdateRole(c *gin.Context) {
+	role, ok := h.adminRole(c)
+	if !ok || !h.canManageRole(c, role) {
+		return
+	}
+
+	var input struct {
+		Code        *string `json:"code"`
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+		ParentID    *string `json:"parent_id"` // "" removes the parent
+		IsActive    *bool   `json:"is_active"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	old := roleValues(role)
+	codeChanged := input.Code != nil && *input.Code != role.Code
+	if role.IsSystem && codeChanged {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "system role codes cannot be changed"})
+		return
+	}
+	if role.IsSystem && input.IsActive != nil && !*input.IsActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "system roles cannot be deactivated"})
+		return
+	}
+
+	if input.Code != nil {
+		role.Code = *input.Code
+	}
+	if input.Name != nil {
+		role.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Description != nil {
+		role.Description = *input.Description
+	}
+	if input.IsActive != nil {
+		role.IsActive = *input.IsActive
+	}
+	if !h.canManageRole(c, role) || !h.validateRole(c, role, codeChanged) {
+		return
+	}
+	if input.ParentID != nil && !h.setRoleParent(c, role, *input.ParentID) {
+		return
+	}
+
+	if err := h.db.Save(role).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.permissions.InvalidateTenant(role.TenantID)
+	h.audit(c, role.TenantID, nil, "role_update", old, roleValues(role))
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole deletes a role along with its permissions and assignments.
+// Roles that inherited from it no longer inherit anything.
+// DELETE /admin/roles/:id
+func (h *AdminHandler) DeleteRole(c *gin.Context) {
+	role, ok := h.adminRole(c)
+	if !ok || !h.canManageRole(c, role) {
+		return
+	}
+	if role.IsSystem {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "system roles cannot be deleted"})
+		return
+	}
+
+	var users, children int64
+	h.db.Table("user_roles").Where("role_id = ?", role.ID).Count(&users)
+	h.db.Model(&models.Role{}).Where("parent_id = ?", role.ID).Count(&children)
+
+	// Permissions and user_roles rows are removed, and children's parent_id
+	// cleared, by their foreign keys
+	if err := h.db.Delete(role).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.permissions.InvalidateTenant(role.TenantID)
+
+	old := roleValues(role)
+	old["user_count"] = users
+	old["child_roles"] = children
+	h.audit(c, role.TenantID, nil, "role_delete", old, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "role deleted"})
+}
+
+// adminRole loads the role named by the :id parameter, checking the caller
+// may act on its tenant
+func (h *AdminHandler) adminRole(c *gin.Context) (*models.Role, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return nil, false
+	}
+
+	var role models.Role
+	if err := h.db.First(&role, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "role not found"})
+		return nil, false
+	}
+	if !h.authorizeTenant(c, role.TenantID) {
+		return nil, false
+	}
+	return &role, true
+}
+
+// canManageRole checks that the caller may change a role or who holds it.
+// Only super admins manage super_admin, as it grants access to every tenant.
+func (h *AdminHandler) canManageRole(c *gin.Context, role *models.Role) bool {
+	if role.Code == "super_admin" && !h.isSuperAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only super_admin can manage the super_admin role"})
+		return false
+	}
+	return true
+}
+
+// validateRole checks a role's code and name, and when checkCode is set that
+// no other role of the tenant has the code
+func (h *AdminHandler) validateRole(c *gin.Context, role *models.Role, checkCode bool) bool {
+	var errs errors.ValidationErrors
+	if !roleCodePattern.MatchString(role.Code) {
+		errs.Add("code", "INVALID_ROLE_CODE", "code must start with a lowercase letter and contain only lowercase letters, digits and underscores (2-50 characters)")
+	}
+	if role.Name == "" {
+		errs.Add("name", "REQUIRED", "name is required")
+	}
+	if err := errs.Err(); err != nil {
+		status, response := errors.ToHTTPError(err)
+		c.JSON(status, response)
+		return false
+	}
+
+	if checkCode {
+		var count int64
+		h.db.Model(&models.Role{}).
+			Where("tenant_id = ? AND code = ? AND id <> ?", role.TenantID, role.Code, role.ID).
+			Count(&count)
+		if count > 0 {
+			status, response := errors.ToHTTPError(errors.NewConflictError("role"))
+			c.JSON(status, response)
+			return false
+		}
+	}
+	return true
+}
+
+// setRoleParent sets the role a role inherits from, or clears it when value
+// is empty. The parent must belong to the same tenant and must not already
+// inherit from the role.
+func (h *AdminHandler) setRoleParent(c *gin.Context, role *models.Role, value string) bool {
+	if value == "" {
+		role.ParentID = nil
+		return true
+	}
+	parentID, err := uuid.Parse(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid parent_id"})
+		return false
+	}
+
+	var parent models.Role
+	if err := h.db.First(&parent, "id = ? AND tenant_id = ?", parentID, role.TenantID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "parent role does not exist in this tenant"})
+		return false
+	}
+	if !h.canManageRole(c, &parent) {
+		return false
+	}
+
+	// Walk up from the parent; reaching the role would close a cycle
+	seen := make(map[uuid.UUID]bool)
+	for current := parent; !seen[current.ID]; {
+		if current.ID == role.ID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a role cannot inherit from itself or a role that inherits from it"})
+			return false
+		}
+		seen[current.ID] = true
+		if current.ParentID == nil {
+			break
+		}
+		var next models.Role
+		if err := h.db.Select("id, parent_id").First(&next, "id = ?", *current.ParentID).Error; err != nil {
+			break
+		}
+		current = next
+	}
+
+	role.ParentID = &parent.ID
+	return true
+}
+
+// roleValues returns the audited attributes of a role
+func roleValues(role *models.Role) map[string]interface{} {
+	return map[string]interface{}{
+		"role_id":     role.ID,
+		"parent_id":   role.ParentID,
+		"code":        role.Code,
+		"name":        role.Name,
+		"description": role.Description,
+		"is_system":   role.IsSystem,
+		"is_active":   role.IsActive,
+	}
+}
+
+// =============================================================================
+// USER ROLES
+// =============================================================================
+
+// ListUserRoles returns the roles assigned to a user
+// GET /admin/users/:id/roles
+func (h *AdminHandler) ListUserRoles(c *gin.Context) {
+	user, ok := h.sessionUser(c)
+	if !ok {
+		return
+	}
+
+	roles, err := h.userRoleList(user.ID, user.TenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, roles)
+}
+
+// SetUserRoles replaces the roles assigned to a user. Roles are read into
+// tokens when they are issued, so changes apply from the user's next login
+// or token refresh.
+// PUT /admin/users/:id/roles
+func (h *AdminHandler) SetUserRoles(c *gin.Context) {
+	user, ok := h.sessionUser(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		RoleIDs []string `json:"role_ids"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	roleIDs, ok := parseRoleIDs(c, input.RoleIDs)
+	if !ok {
+		return
+	}
+	h.assignRoles(c, user, user.TenantID, roleIDs)
+}
+
+// parseRoleIDs parses the role_ids of a request, responding with a
+// validation error for any that is not a uuid
+func parseRoleIDs(c *gin.Context, values []string) ([]uuid.UUID, bool) {
+	var errs errors.ValidationErrors
+	roleIDs := make([]uuid.UUID, 0, len(values))
+	for i, value := range values {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			errs.Add(fmt.Sprintf("role_ids[%d]", i), "INVALID_ID", "invalid role id")
+			continue
+		}
+		roleIDs = append(roleIDs, id)
+	}
+	if err := errs.Err(); err != nil {
+		status, response := errors.ToHTTPError(err)
+		c.JSON(status, response)
+		return nil, false
+	}
+	return roleIDs, true
+}
+
+// AddUserRole assigns one more role to a user
+// POST /admin/users/:id/roles/:role_id
+func (h *AdminHandler) AddUserRole(c *gin.Context) {
+	user, roleID, current, ok := h.userRoleChange(c)
+	if !ok {
+		return
+	}
+	h.assignRoles(c, user, user.TenantID, append(roleIDs(current), roleID))
+}
+
+// RemoveUserRole takes a role away from a user
+// DELETE /admin/users/:id/roles/:role_id
+func (h *AdminHandler) RemoveUserRole(c *gin.Context) {
+	user, roleID, current, ok := h.userRoleChange(c)
+	if !ok {
+		return
+	}
+
+	remaining := make([]uuid.UUID, 0, len(current))
+	for _, id := range roleIDs(current) {
+		if id != roleID {
+			remaining = append(remaining, id)
+		}
+	}
+	if len(remaining) == len(current) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "role is not assigned to the user"})
+		return
+	}
+	h.assignRoles(c, user, user.TenantID, remaining)
+}
+
+// userRoleChange resolves the user and role of a single assignment change
+func (h *AdminHandler) userRoleChange(c *gin.Context) (*models.User, uuid.UUID, []models.Role, bool) {
+	user, ok := h.sessionUser(c)
+	if !ok {
+		return nil, uuid.Nil, nil, false
+	}
+	roleID, err := uuid.Parse(c.Param("role_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid role_id"})
+		return nil, uuid.Nil, nil, false
+	}
+
+	current, err := h.userRoleList(user.ID, user.TenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, uuid.Nil, nil, false
+	}
+	return user, roleID, current, true
+}
+
+// assignRoles makes roleIDs the complete set of a user's roles in a tenant.
+// Roles must belong to the tenant, and admins cannot take away their own
+// admin access.
+func (h *AdminHandler) assignRoles(c *gin.Context, user *models.User, tenantID uuid.UUID, ids []uuid.UUID) {
+	current, err := h.userRoleList(user.ID, tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var requested []models.Role
+	if len(ids) > 0 {
+		if err := h.db.Where("id IN ?", ids).Order("code").Find(&requested).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	found := make(map[uuid.UUID]bool, len(requested))
+	for _, role := range requested {
+		if role.TenantID == tenantID {
+			found[role.ID] = true
+		}
+	}
+	var errs errors.ValidationErrors
+	for i, id := range ids {
+		if !found[id] {
+			errs.Add(fmt.Sprintf("role_ids[%d]", i), "UNKNOWN_ROLE", "role does not exist in the tenant")
+		}
+	}
+	if err := errs.Err(); err != nil {
+		status, response := errors.ToHTTPError(err)
+		c.JSON(status, response)
+		return
+	}
+
+	added := roleDifference(requested, current)
+	removed := roleDifference(current, requested)
+	for _, role := range append(append([]models.Role{}, added...), removed...) {
+		if !h.canManageRole(c, &role) {
+			return
+		}
+	}
+	if callerID := h.currentUserID(c); callerID != nil && *callerID == user.ID &&
+		hasAdminRole(current) && !hasAdminRole(requested) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot remove your own admin access"})
+		return
+	}
+
+	if len(added) == 0 && len(removed) == 0 {
+		c.JSON(http.StatusOK, current)
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if len(removed) > 0 {
+			err := tx.Exec("DELETE FROM user_roles WHERE user_id = ? AND role_id IN ?", user.ID, roleIDs(removed)).Error
+			if err != nil {
+				return err
+			}
+		}
+		for _, role := range added {
+			err := tx.Exec("INSERT INTO user_roles (user_id, role_id) VALUES (?, ?) ON CONFLICT DO NOTHING", user.ID, role.ID).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.permissions.InvalidateUser(user.ID)
+	h.audit(c, tenantID, nil, "user_roles_update", map[string]interface{}{
+		"user_id": user.ID,
+		"email":   user.Email,
+		"roles":   roleCodes(current),
+	}, map[string]interface{}{
+		"user_id": user.ID,
+		"email":   user.Email,
+		"roles":   roleCodes(requested),
+	})
+	c.JSON(http.StatusOK, requested)
+}
+
+// userRoleList returns a user's roles in a tenant ordered by code
+func (h *AdminHandler) userRoleList(userID, tenantID uuid.UUID) ([]models.Role, error) {
+	roles := []models.Role{}
+	err := h.db.Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ? AND roles.tenant_id = ?", userID, tenantID).
+		Order("roles.code").
+		Find(&roles).Error
+	return roles, err
+}
+
+// roleDifference returns the roles of a that are not in b
+func roleDifference(a, b []models.Role) []models.Role {
+	in := make(map[uuid.UUID]bool, len(b))
+	for _, role := range b {
+		in[role.ID] = true
+	}
+	var diff []models.Role
+	for _, role := range a {
+		if !in[role.ID] {
+			diff = append(diff, role)
+		}
+	}
+	return diff
+}
+
+func hasAdminRole(roles []models.Role) bool {
+	for _, role := range roles {
+		if role.Code == "admin" || role.Code == "super_admin" {
+			return true
+		}
+	}
+	return false
+}
+
+func roleIDs(roles []models.Role) []uuid.UUID {
+	ids := make([]uuid.UUID, len(roles))
+	for i, role := range roles {
+		ids[i] = role.ID
+	}
+	return ids
+}
+
+func roleCodes(roles []models.Role) []string {
+	codes := make([]string, len(roles))
+	for i, role := range roles {
+		codes[i] = role.Code
+	}
+	return codes
+}
+
+// =============================================================================
+// PERMISSION MATRIX
+// =============================================================================
+
+// GetPermissionMatrix returns every role's permissions on the entities and
+// modules of a tenant, defaulting to the caller's
+// GET /admin/permissions?tenant_id=xxx
+func (h *AdminHandler) GetPermissionMatrix(c *gin.Context) {
+	tenantID, ok := h.matrixTenant(c, c.Query("tenant_id"))
+	if !ok {
+		return
+	}
+
+	matrix := PermissionMatrix{
+		TenantID:    tenantID,
+		Actions:     auth.Actions,
+		Roles:       []models.Role{},
+		Modules:     []MatrixTarget{},
+		Entities:    []MatrixTarget{},
+		Permissions: []PermissionEntry{},
+	}
+	var modules []models.Module
+	var entities []models.Entity
+	var permissions []models.Permission
+	err := h.db.Where("tenant_id = ?", tenantID).Order("code").Find(&matrix.Roles).Error
+	if err == nil {
+		err = h.db.Select("id, code, name").Where("tenant_id = ?", tenantID).Order("code").Find(&modules).Error
+	}
+	if err == nil {
+		err = h.db.Select("id, code, name, module_id").Where("tenant_id = ?", tenantID).Order("code").Find(&entities).Error
+	}
+	if err == nil {
+		err = h.db.Where("tenant_id = ?", tenantID).Find(&permissions).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	roles := make(map[uuid.UUID]*models.Role, len(matrix.Roles))
+	for i := range matrix.Roles {
+		roles[matrix.Roles[i].ID] = &matrix.Roles[i]
+	}
+	moduleByID := make(map[uuid.UUID]*models.Module, len(modules))
+	for i := range modules {
+		moduleByID[modules[i].ID] = &modules[i]
+		matrix.Modules = append(matrix.Modules, MatrixTarget{ID: modules[i].ID, Code: modules[i].Code, Name: modules[i].Name})
+	}
+	entityByID := make(map[uuid.UUID]*models.Entity, len(entities))
+	for i := range entities {
+		entityByID[entities[i].ID] = &entities[i]
+		target := MatrixTarget{ID: entities[i].ID, Code: entities[i].Code, Name: entities[i].Name}
+		if entities[i].ModuleID != nil && moduleByID[*entities[i].ModuleID] != nil {
+			target.Module = moduleByID[*entities[i].ModuleID].Code
+		}
+		matrix.Entities = append(matrix.Entities, target)
+	}
+	for i := range permissions {
+		var target permissionTarget
+		if permissions[i].EntityID != nil {
+			target.entity = entityByID[*permissions[i].EntityID]
+		} else if permissions[i].ModuleID != nil {
+			target.module = moduleByID[*permissions[i].ModuleID]
+		}
+		if role := roles[permissions[i].RoleID]; role != nil && target.valid() {
+			matrix.Permissions = append(matrix.Permissions, permissionEntry(role, target, &permissions[i]))
+		}
+	}
+	sortPermissionEntries(matrix.Permissions)
+
+	c.JSON(http.StatusOK, matrix)
+}
+
+// UpdatePermissionMatrix creates, replaces or removes permissions in bulk.
+// Each entry replaces the role's whole allow or deny entry on the entity or
+// module; entries not listed are left as they are. Every entry is validated
+// against the tenant's roles, modules, entities and fields before anything
+// is written.
+// PUT /admin/permissions
+func (h *AdminHandler) UpdatePermissionMatrix(c *gin.Context) {
+	var input struct {
+		TenantID    string            `json:"tenant_id"`
+		Permissions []PermissionEntry `json:"permissions" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenantID, ok := h.matrixTenant(c, input.TenantID)
+	if !ok {
+		return
+	}
+
+	var roleList []models.Role
+	var moduleList []models.Module
+	var entityList []models.Entity
+	err := h.db.Where("tenant_id = ?", tenantID).Find(&roleList).Error
+	if err == nil {
+		err = h.db.Where("tenant_id = ?", tenantID).Find(&moduleList).Error
+	}
+	if err == nil {
+		err = h.db.Preload("Fields.FieldType").Where("tenant_id = ?", tenantID).Find(&entityList).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	roles := make(map[string]*models.Role, len(roleList))
+	for i := range roleList {
+		roles[roleList[i].Code] = &roleList[i]
+	}
+	modules := make(map[string]*models.Module, len(moduleList))
+	for i := range moduleList {
+		modules[moduleList[i].Code] = &moduleList[i]
+	}
+	entities := make(map[string]*models.Entity, len(entityList))
+	for i := range entityList {
+		entities[entityList[i].Code] = &entityList[i]
+	}
+
+	var errs errors.ValidationErrors
+	targets := make([]permissionTarget, len(input.Permissions))
+	seen := make(map[string]bool, len(input.Permissions))
+	for i := range input.Permissions {
+		entry := &input.Permissions[i]
+		path := fmt.Sprintf("permissions[%d]", i)
+		if entry.Effect == "" {
+			entry.Effect = auth.EffectAllow
+		}
+
+		role := roles[entry.Role]
+		if role == nil {
+			errs.Add(path+".role", "UNKNOWN_ROLE", fmt.Sprintf("role '%s' does not exist in this tenant", entry.Role))
+		}
+		switch {
+		case (entry.Entity == "") == (entry.Module == ""):
+			errs.Add(path, "INVALID_TARGET", "set exactly one of entity or module")
+		case entry.Entity != "" && entities[entry.Entity] == nil:
+			errs.Add(path+".entity", "UNKNOWN_ENTITY", fmt.Sprintf("entity '%s' does not exist in this tenant", entry.Entity))
+		case entry.Module != "" && modules[entry.Module] == nil:
+			errs.Add(path+".module", "UNKNOWN_MODULE", fmt.Sprintf("module '%s' does not exist in this tenant", entry.Module))
+		default:
+			targets[i] = permissionTarget{entity: entities[entry.Entity], module: modules[entry.Module]}
+		}
+		if entry.Effect != auth.EffectAllow && entry.Effect != auth.EffectDeny {
+			errs.Add(path+".effect", "INVALID_EFFECT", "effect must be allow or deny")
+			continue
+		}
+		if role == nil || !targets[i].valid() {
+			continue
+		}
+
+		key := entry.Role + "/" + targets[i].key() + "/" + entry.Effect
+		if seen[key] {
+			errs.Add(path, "DUPLICATE_ENTRY", fmt.Sprintf("role '%s' has more than one %s entry for %s", entry.Role, entry.Effect, targets[i].key()))
+		}
+		seen[key] = true
+		if !entry.Remove {
+			validatePermissionEntry(&errs, path, targets[i], *entry)
+		}
+	}
+	if err := errs.Err(); err != nil {
+		status, response := errors.ToHTTPError(err)
+		c.JSON(status, response)
+		return
+	}
+	for _, entry := range input.Permissions {
+		if !h.canManageRole(c, roles[entry.Role]) {
+			return
+		}
+	}
+
+	var changes []permissionChange
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		for i, entry := range input.Permissions {
+			change, err := savePermissionEntry(tx, tenantID, roles[entry.Role], targets[i], entry)
+			if err != nil {
+				return err
+			}
+			if change != nil {
+				changes = append(changes, *change)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(changes) > 0 {
+		h.permissions.InvalidateTenant(tenantID)
+	}
+	for _, change := range changes {
+		h.audit(c, tenantID, change.target.entity, "permission_update", permissionEntryValues(change.old), permissionEntryValues(change.new))
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "permissions updated", "changed": len(changes)})
+}
+
+// ExplainPermission shows how a user's permission on an entity is resolved:
+// the roles considered, the entries that matched, how field permissions
+// merged and which row filter applies, optionally checked against a record.
+// It uses the same resolution as enforcement, read fresh from the database;
+// the record is checked through the same scope as data requests, including
+// ownership and shares.
+// GET /admin/permissions/explain?user=xxx&entity=xxx&action=view&record=xxx
+func (h *AdminHandler) ExplainPermission(c *gin.Context) {
+	userID, err := uuid.Parse(c.Query("user"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user must be a user id"})
+		return
+	}
+	entityCode := c.Query("entity")
+	if entityCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "entity is required"})
+		return
+	}
+	action := auth.Action(c.DefaultQuery("action", string(auth.ActionView)))
+	if !isAction(action) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown action '%s'", action)})
+		return
+	}
+	var recordID uuid.UUID
+	if value := c.Query("record"); value != "" {
+		if recordID, err = uuid.Parse(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "record must be a record id"})
+			return
+		}
+	}
+
+	var user models.User
+	if err := h.db.Select("id, tenant_id, email").Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if !h.authorizeTenant(c, user.TenantID) {
+		return
+	}
+	var entity models.Entity
+	if err := h.db.Select("id").Where("tenant_id = ? AND code = ?", user.TenantID, entityCode).First(&entity).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "entity not found"})
+		return
+	}
+
+	explanation, err := h.permissions.Explain(user.TenantID, user.ID, entityCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response := PermissionExplainResponse{
+		UserID:                user.ID,
+		Email:                 user.Email,
+		Entity:                entityCode,
+		Action:                action,
+		Allowed:               explanation.Result.Allows(action),
+		PermissionExplanation: explanation,
+	}
+
+	if recordID != uuid.Nil {
+		access, err := h.dataEngine.ExplainRecordAccess(user.TenantID, entityCode, recordID, user.ID, string(action))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		response.Record = &RecordExplanation{ID: recordID, RecordAccess: *access}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// matrixTenant resolves the tenant of a permission matrix request, defaulting
+// to the caller's
+func (h *AdminHandler) matrixTenant(c *gin.Context, value string) (uuid.UUID, bool) {
+	if value == "" {
+		return h.callerTenantID(c), true
+	}
+	tenantID, err := uuid.Parse(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tenant_id"})
+		return uuid.Nil, false
+	}
+	return tenantID, h.authorizeTenant(c, tenantID)
+}
+
+// valid reports whether the target names a known entity or module
+func (t permissionTarget) valid() bool {
+	return t.entity != nil || t.module != nil
+}
+
+// key names the target in messages and duplicate checks
+func (t permissionTarget) key() string {
+	if t.entity != nil {
+		return "entity '" + t.entity.Code + "'"
+	}
+	return "module '" + t.module.Code + "'"
+}
+
+// savePermissionEntry writes one matrix entry and returns the change it made,
+// or nil when the stored permission already matched
+func savePermissionEntry(tx *gorm.DB, tenantID uuid.UUID, role *models.Role, target permissionTarget, entry PermissionEntry) (*permissionChange, error) {
+	query := tx.Where("role_id = ? AND effect = ?", role.ID, entry.Effect)
+	if target.entity != nil {
+		query = query.Where("entity_id = ?", target.entity.ID)
+	} else {
+		query = query.Where("module_id = ?", target.module.ID)
+	}
+
+	var existing *models.Permission
+	var permission models.Permission
+	err := query.First(&permission).Error
+	switch {
+	case err == nil:
+		existing = &permission
+	case err != gorm.ErrRecordNotFound:
+		return nil, err
+	}
+
+	change := &permissionChange{target: target}
+	if existing != nil {
+		old := permissionEntry(role, target, existing)
+		change.old = &old
+	}
+
+	if entry.Remove {
+		if existing == nil {
+			return nil, nil
+		}
+		return change, tx.Delete(existing).Error
+	}
+
+	if existing == nil {
+		permission = models.Permission{
+			ID:       uuid.New(),
+			TenantID: tenantID,
+			RoleID:   role.ID,
+			Effect:   entry.Effect,
+		}
+		if target.entity != nil {
+			permission.EntityID = &target.entity.ID
+		} else {
+			permission.ModuleID = &target.module.ID
+		}
+	}
+	applyPermissionEntry(&permission, entry)
+	updated := permissionEntry(role, target, &permission)
+	change.new = &updated
+	if change.old != nil && samePermissionEntry(change.old, change.new) {
+		return nil, nil
+	}
+	return change, tx.Save(&permission).Error
+}
+
+// validatePermissionEntry checks an entry's actions, field permissions and
+// row filter against its target. Module entries cover entities with
+// different fields, so they may only filter rows on system columns; deny
+// entries only take actions and fields away.
+func validatePermissionEntry(errs *errors.ValidationErrors, path string, target permissionTarget, entry PermissionEntry) {
+	for i, action := range entry.Actions {
+		if !isAction(action) {
+			errs.Add(fmt.Sprintf("%s.actions[%d]", path, i), "INVALID_ACTION", fmt.Sprintf("unknown action '%s'", action))
+		}
+	}
+
+	fields := make(map[string]*models.Field)
+	if target.entity != nil {
+		for i := range target.entity.Fields {
+			fields[target.entity.Fields[i].Code] = &target.entity.Fields[i]
+		}
+	} else if len(entry.FieldPermissions) > 0 {
+		errs.Add(path+".field_permissions", "NOT_SUPPORTED", "module permissions cannot set field permissions")
+	}
+	if entry.Effect == auth.EffectDeny && len(entry.RowFilter) > 0 {
+		errs.Add(path+".row_filter", "NOT_SUPPORTED", "deny permissions cannot have a row filter")
+	}
+	if entry.Effect == auth.EffectDeny && len(entry.Scopes) > 0 {
+		errs.Add(path+".scopes", "NOT_SUPPORTED", "deny permissions cannot have scopes")
+	}
+
+	for _, action := range sortedKeys(entry.Scopes) {
+		scope := entry.Scopes[action]
+		scopePath := path + ".scopes." + string(action)
+		if entry.Effect == auth.EffectDeny {
+			break
+		}
+		if !isScopedAction(action) {
+			errs.Add(scopePath, "INVALID_ACTION", fmt.Sprintf("only view, edit and delete can be scoped, not '%s'", action))
+			continue
+		}
+		if scope != auth.ScopeAll && scope != auth.ScopeOwn && scope != auth.ScopeTeam {
+			errs.Add(scopePath, "INVALID_SCOPE", "scope must be all, own or team")
+		}
+	}
+
+	for _, code := range sortedKeys(entry.FieldPermissions) {
+		permission := entry.FieldPermissions[code]
+		fieldPath := path + ".field_permissions." + code
+		if target.entity == nil {
+			break
+		}
+		if fields[code] == nil {
+			errs.Add(fieldPath, "UNKNOWN_FIELD", fmt.Sprintf("entity '%s' has no field '%s'", target.entity.Code, code))
+			continue
+		}
+		if entry.Effect == auth.EffectAllow && permission.CanEdit && !permission.CanView {
+			errs.Add(fieldPath, "INVALID_FIELD_PERMISSION", "a field cannot be editable without being visible")
+		}
+	}
+
+	for _, column := range sortedKeys(entry.RowFilter) {
+		value := entry.RowFilter[column]
+		filterPath := path + ".row_filter." + column
+		if entry.Effect == auth.EffectDeny {
+			break
+		}
+		field := fields[column]
+		if (field == nil || !engine.IsColumnField(field)) && !engine.IsSystemField(column) {
+			errs.Add(filterPath, "UNKNOWN_FIELD", fmt.Sprintf("%s has no column '%s'", target.key(), column))
+			continue
+		}
+		if !validRowFilterValue(value) {
+			errs.Add(filterPath, "INVALID_ROW_FILTER", "row filter values must be a string, number, boolean, null, \"$current_user\" or a non-empty list of those")
+		}
+	}
+}
+
+// validRowFilterValue reports whether DataEngine can match a column against
+// a row filter value
+func validRowFilterValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil, string, float64, bool:
+		return true
+	case []interface{}:
+		if len(v) == 0 {
+			return false
+		}
+		for _, item := range v {
+			switch item.(type) {
+			case string, float64, bool:
+			default:
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// sortedKeys returns a map's keys in order, so errors are reported
+// consistently
+func sortedKeys[K ~string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+func isAction(action auth.Action) bool {
+	for _, known := range auth.Actions {
+		if action == known {
+			return true
+		}
+	}
+	return false
+}
+
+// isScopedAction reports whether an ownership scope can limit an action
+func isScopedAction(action auth.Action) bool {
+	for _, scoped := range auth.ScopedActions {
+		if action == scoped {
+			return true
+		}
+	}
+	return false
+}
+
+// sortPermissionEntries orders entries by role, then module entries before
+// entity entries, then allow before deny
+func sortPermissionEntries(entries []PermissionEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Role != b.Role {
+			return a.Role < b.Role
+		}
+		if a.Module != b.Module {
+			return a.Module > b.Module
+		}
+		if a.Entity != b.Entity {
+			return a.Entity < b.Entity
+		}
+		return a.Effect < b.Effect
+	})
+}
+
+// permissionEntry returns a stored permission as a matrix entry, listing
+// actions in matrix order
+func permissionEntry(role *models.Role, target permissionTarget, permission *models.Permission) PermissionEntry {
+	entry := PermissionEntry{Role: role.Code, Effect: permission.Effect, Actions: []auth.Action{}}
+	if entry.Effect == "" {
+		entry.Effect = auth.EffectAllow
+	}
+	if target.entity != nil {
+		entry.Entity = target.entity.Code
+	} else {
+		entry.Module = target.module.Code
+	}
+
+	granted := map[auth.Action]bool{
+		auth.ActionView:   permission.CanView,
+		auth.ActionCreate: permission.CanCreate,
+		auth.ActionEdit:   permission.CanEdit,
+		auth.ActionDelete: permission.CanDelete,
+		auth.ActionExport: permission.CanExport,
+		auth.ActionImport: permission.CanImport,
+	}
+	for _, action := range auth.Actions {
+		if granted[action] {
+			entry.Actions = append(entry.Actions, action)
+		}
+	}
+
+	scopes := map[auth.Action]string{
+		auth.ActionView:   permission.ViewScope,
+		auth.ActionEdit:   permission.EditScope,
+		auth.ActionDelete: permission.DeleteScope,
+	}
+	for _, action := range auth.ScopedActions {
+		if scope := scopes[action]; granted[action] && scope != "" && scope != auth.ScopeAll {
+			if entry.Scopes == nil {
+				entry.Scopes = make(map[auth.Action]string)
+			}
+			entry.Scopes[action] = scope
+		}
+	}
+
+	if fields := auth.ParseFieldPermissions(permission.FieldPermissions); len(fields) > 0 {
+		entry.FieldPermissions = fields
+	}
+	if len(permission.RowFilter) > 0 {
+		entry.RowFilter = permission.RowFilter
+	}
+	return entry
+}
+
+// applyPermissionEntry sets a stored permission from a matrix entry
+func applyPermissionEntry(permission *models.Permission, entry PermissionEntry) {
+	granted := make(map[auth.Action]bool, len(entry.Actions))
+	for _, action := range entry.Actions {
+		granted[action] = true
+	}
+	permission.CanView = granted[auth.ActionView]
+	permission.CanCreate = granted[auth.ActionCreate]
+	permission.CanEdit = granted[auth.ActionEdit]
+	permission.CanDelete = granted[auth.ActionDelete]
+	permission.CanExport = granted[auth.ActionExport]
+	permission.CanImport = granted[auth.ActionImport]
+
+	scope := func(action auth.Action) string {
+		if value := entry.Scopes[action]; value != "" && granted[action] {
+			return value
+		}
+		return auth.ScopeAll
+	}
+	permission.ViewScope = scope(auth.ActionView)
+	permission.EditScope = scope(auth.ActionEdit)
+	permission.DeleteScope = scope(auth.ActionDelete)
+
+	permission.FieldPermissions = models.JSONB{}
+	for code, fp := range entry.FieldPermissions {
+		permission.FieldPermissions[code] = map[string]interface{}{"view": fp.CanView, "edit": fp.CanEdit}
+	}
+	permission.RowFilter = nil
+	if len(entry.RowFilter) > 0 {
+		permission.RowFilter = models.JSONB(entry.RowFilter)
+	}
+}
+
+// samePermissionEntry compares entries by their JSON form, which orders map
+// keys
+func samePermissionEntry(a, b *PermissionEntry) bool {
+	aJSON, errA := json.Marshal(a)
+	bJSON, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(aJSON, bJSON)
+}
+
+// permissionEntryValues returns the audited form of an entry
+func permissionEntryValues(entry *PermissionEntry) map[string]interface{} {
+	if entry == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"role":              entry.Role,
+		"entity":            entry.Entity,
+		"module":            entry.Module,
+		"effect":            entry.Effect,
+		"actions":           entry.Actions,
+		"scopes":            entry.Scopes,
+		"field_permissions": entry.FieldPermissions,
+		"row_filter":        entry.RowFilter,
+	}
+}
@@ -14,7 +14,7 @@ import (
 )
 
 // SetupRouter creates and configures the Gin router
-func SetupRouter(handler *Handler, adminHandler *AdminHandler, authHandler *AuthHandler, setupHandler *SetupHandler, adminPanelHandler *AdminPanelHandler, uiHandler *UIHandler, generatorHandler *GeneratorHandler, contentHandler *ContentHandler) *gin.Engine {
+func SetupRouter(handler *Handler, adminHandler *AdminHandler, authHandler *AuthHandler, setupHandler *SetupHandler, adminPanelHandler *AdminPanelHandler, uiHandler *UIHandler, generatorHandler *GeneratorHandler, contentHandler *ContentHandler, graphqlHandler *GraphQLHandler) *gin.Engine {
 	r := gin.Default()
 
 	// Setup wizard (only shows if no tenants exist)
@@ -63,7 +63,7 @@ func SetupRouter(handler *Handler, adminHandler *AdminHandler, authHandler *Auth
 	// When credentials are used, specific origins must be provided (not *)
 	corsConfig := cors.Config{
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-Tenant-ID", "X-User-ID", "Accept"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-Tenant-ID", "X-User-ID", "X-API-Key", "Accept"},
 		ExposeHeaders:    []string{"Content-Length", "Content-Type"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
@@ -88,8 +88,12 @@ func SetupRouter(handler *Handler, adminHandler *AdminHandler, authHandler *Auth
 	// Health check (no auth required)
 	r.GET("/api/health", handler.Health)
 
-	// Public tenants list (for login page - no auth required)
-	r.GET("/api/tenants", adminHandler.ListTenantsPublic)
+	// Public keys that verify Genesis tokens, for downstream services
+	r.GET("/.well-known/jwks.json", authHandler.JWKS)
+
+	// Public tenants list for the login page; once authenticated, the tenants
+	// the user belongs to, for the tenant picker
+	r.GET("/api/tenants", handler.UserMiddleware(), adminHandler.ListTenantsPublic)
 
 	// ==========================================================================
 	// AUTH API - Authentication endpoints (no auth required)
@@ -99,6 +103,24 @@ func SetupRouter(handler *Handler, adminHandler *AdminHandler, authHandler *Auth
 		authRoutes.POST("/login", authHandler.Login)
 		authRoutes.POST("/register", authHandler.Register)
 		authRoutes.POST("/refresh", authHandler.RefreshToken)
+
+		// Second login step for users with two-factor authentication
+		authRoutes.POST("/login/verify", authHandler.VerifyTwoFactorLogin)
+		authRoutes.POST("/login/enroll", authHandler.EnrollTwoFactorLogin)
+
+		// Login step for users whose password expired
+		authRoutes.POST("/login/change-password", authHandler.ChangeExpiredPassword)
+
+		// Password reset and email verification links
+		authRoutes.POST("/forgot-password", authHandler.ForgotPassword)
+		authRoutes.POST("/reset-password", authHandler.ResetPassword)
+		authRoutes.POST("/verify-email", authHandler.VerifyEmail)
+		authRoutes.POST("/resend-verification", authHandler.ResendVerification)
+
+		// Single sign-on with the tenant's OpenID Connect provider
+		authRoutes.GET("/oidc/:tenant_id", authHandler.GetSSOStatus)
+		authRoutes.GET("/oidc/:tenant_id/authorize", authHandler.AuthorizeSSO)
+		authRoutes.POST("/oidc/callback", authHandler.CompleteSSO)
 	}
 
 	// Authenticated auth endpoints
@@ -106,9 +128,25 @@ func SetupRouter(handler *Handler, adminHandler *AdminHandler, authHandler *Auth
 	authProtected.Use(handler.UserMiddleware())
 	authProtected.Use(handler.RequireAuthMiddleware())
 	{
+		// Logging out of an impersonation session ends it; an impersonating
+		// admin may not touch the user's credentials or other sessions
+		noImpersonation := handler.RejectImpersonationMiddleware()
 		authProtected.GET("/me", authHandler.GetMe)
-		authProtected.POST("/change-password", authHandler.ChangePassword)
+		authProtected.POST("/change-password", noImpersonation, authHandler.ChangePassword)
 		authProtected.POST("/logout", authHandler.Logout)
+		authProtected.POST("/logout-all", noImpersonation, authHandler.LogoutAll)
+		authProtected.POST("/switch-tenant", noImpersonation, authHandler.SwitchTenant)
+
+		// Sessions and devices
+		authProtected.GET("/sessions", noImpersonation, authHandler.ListSessions)
+		authProtected.DELETE("/sessions/:id", noImpersonation, authHandler.RevokeSession)
+
+		// Two-factor authentication
+		authProtected.GET("/2fa", noImpersonation, authHandler.GetTwoFactorStatus)
+		authProtected.POST("/2fa/enroll", noImpersonation, authHandler.EnrollTwoFactor)
+		authProtected.POST("/2fa/confirm", noImpersonation, authHandler.ConfirmTwoFactor)
+		authProtected.POST("/2fa/recovery-codes", noImpersonation, authHandler.RegenerateRecoveryCodes)
+		authProtected.POST("/2fa/disable", noImpersonation, authHandler.DisableTwoFactor)
 	}
 
 	// ==========================================================================
@@ -122,15 +160,72 @@ func SetupRouter(handler *Handler, adminHandler *AdminHandler, authHandler *Auth
 	admin.Use(handler.RequireAdminMiddleware())
 	{
 		// Tenant management
-		admin.GET("/tenants", adminHandler.ListTenants)
-		admin.POST("/tenants", adminHandler.CreateTenant)
+		// Tenant admins may only read their own tenant; the rest is super_admin only
+		superAdmin := handler.RequireSuperAdminMiddleware()
+		// Credentials, roles and permissions cannot be changed while
+		// impersonating: an impersonation session acts as its target, not
+		// with the impersonator's own authority
+		noImpersonation := handler.RejectImpersonationMiddleware()
+		admin.GET("/tenants", superAdmin, adminHandler.ListTenants)
+		admin.POST("/tenants", superAdmin, adminHandler.CreateTenant)
 		admin.GET("/tenants/:id", adminHandler.GetTenant)
-		admin.PUT("/tenants/:id", adminHandler.UpdateTenant)
-		admin.DELETE("/tenants/:id", adminHandler.DeleteTenant)
+		admin.PUT("/tenants/:id", superAdmin, adminHandler.UpdateTenant)
+		admin.DELETE("/tenants/:id", superAdmin, adminHandler.DeleteTenant)
+
+		// Token signing keys
+		admin.GET("/signing-keys", superAdmin, adminHandler.ListSigningKeys)
+		admin.POST("/signing-keys/rotate", superAdmin, noImpersonation, adminHandler.RotateSigningKey)
+
+		// Single sign-on configuration
+		admin.GET("/tenants/:id/oidc", adminHandler.GetOIDCProvider)
+		admin.PUT("/tenants/:id/oidc", noImpersonation, adminHandler.SaveOIDCProvider)
+		admin.DELETE("/tenants/:id/oidc", noImpersonation, adminHandler.DeleteOIDCProvider)
+
+		// Tenant members, including users whose account is in another tenant.
+		// Adding one reads a user of another tenant, so it is super_admin only.
+		admin.GET("/tenants/:id/members", adminHandler.ListTenantMembers)
+		admin.POST("/tenants/:id/members", superAdmin, noImpersonation, adminHandler.AddTenantMember)
+		admin.PUT("/tenants/:id/members/:user_id/roles", noImpersonation, adminHandler.SetTenantMemberRoles)
+		admin.DELETE("/tenants/:id/members/:user_id", noImpersonation, adminHandler.RemoveTenantMember)
 
 		// User management
 		admin.GET("/users", adminHandler.ListUsers)
-		admin.POST("/users", adminHandler.CreateUser)
+		admin.POST("/users", noImpersonation, adminHandler.CreateUser)
+		admin.DELETE("/users/:id/2fa", noImpersonation, adminHandler.ResetUserTwoFactor)
+		admin.GET("/users/:id/sessions", adminHandler.ListUserSessions)
+		admin.DELETE("/users/:id/sessions", adminHandler.ForceLogout)
+		admin.DELETE("/users/:id/sessions/:session_id", adminHandler.RevokeUserSession)
+		admin.POST("/users/:id/unlock", noImpersonation, adminHandler.UnlockUser)
+		admin.GET("/users/:id/roles", adminHandler.ListUserRoles)
+		admin.PUT("/users/:id/roles", noImpersonation, adminHandler.SetUserRoles)
+		admin.POST("/users/:id/roles/:role_id", noImpersonation, adminHandler.AddUserRole)
+		admin.DELETE("/users/:id/roles/:role_id", noImpersonation, adminHandler.RemoveUserRole)
+
+		// Impersonation, which cannot be started while impersonating
+		admin.POST("/impersonate/:user_id", noImpersonation, adminHandler.Impersonate)
+		admin.GET("/impersonations", adminHandler.ListImpersonations)
+		admin.DELETE("/impersonations/:id", adminHandler.RevokeImpersonation)
+
+		// Roles and permissions
+		admin.GET("/roles", adminHandler.ListRoles)
+		admin.POST("/roles", noImpersonation, adminHandler.CreateRole)
+		admin.GET("/roles/:id", adminHandler.GetRole)
+		admin.PUT("/roles/:id", noImpersonation, adminHandler.UpdateRole)
+		admin.DELETE("/roles/:id", noImpersonation, adminHandler.DeleteRole)
+		admin.GET("/permissions", adminHandler.GetPermissionMatrix)
+		admin.GET("/permissions/explain", adminHandler.ExplainPermission)
+		admin.PUT("/permissions", noImpersonation, adminHandler.UpdatePermissionMatrix)
+
+		// Login lockouts
+		admin.GET("/lockouts", adminHandler.ListLockouts)
+		admin.DELETE("/lockouts/:id", adminHandler.DeleteLockout)
+
+		// Service accounts and API keys
+		admin.GET("/service-accounts", adminHandler.ListServiceAccounts)
+		admin.POST("/service-accounts", noImpersonation, adminHandler.CreateServiceAccount)
+		admin.GET("/api-keys", adminHandler.ListAPIKeys)
+		admin.POST("/api-keys", noImpersonation, adminHandler.CreateAPIKey)
+		admin.DELETE("/api-keys/:id", adminHandler.RevokeAPIKey)
 
 		// Module management
 		admin.GET("/modules", adminHandler.ListModules)
@@ -145,6 +240,9 @@ func SetupRouter(handler *Handler, adminHandler *AdminHandler, authHandler *Auth
 		admin.GET("/entities/:id", adminHandler.GetEntity)
 		admin.PUT("/entities/:id", adminHandler.UpdateEntity)
 		admin.DELETE("/entities/:id", adminHandler.DeleteEntity)
+		admin.GET("/entities/:id/deletion-impact", adminHandler.GetEntityDeletionImpact)
+		admin.GET("/entity-archives", adminHandler.ListEntityArchives)
+		admin.POST("/entity-archives/:id/restore", adminHandler.RestoreEntityArchive)
 
 		// Field management
 		admin.GET("/fields", adminHandler.ListFields)
@@ -159,6 +257,7 @@ func SetupRouter(handler *Handler, adminHandler *AdminHandler, authHandler *Auth
 		// Code generation
 		admin.POST("/generate", generatorHandler.GenerateAll)
 		admin.DELETE("/cache", generatorHandler.InvalidateCache)
+		admin.GET("/codegen", adminHandler.GenerateClient)
 	}
 
 	// Component bundle (public, cached)
@@ -221,6 +320,11 @@ func SetupRouter(handler *Handler, adminHandler *AdminHandler, authHandler *Auth
 		// Schema endpoints
 		api.GET("/schema", handler.GetSchema)
 		api.GET("/schema/:entity", handler.GetEntitySchema)
+		api.GET("/schema/:entity/jsonschema", handler.GetEntityJSONSchema)
+		api.GET("/openapi.json", handler.GetOpenAPI)
+
+		// GraphQL over the same entities, permissions checked per resolver
+		api.POST("/graphql", handler.RequireAuthMiddleware(), graphqlHandler.Query)
 
 		// Dynamic data endpoints with permission checking
 		// Read operations
@@ -232,9 +336,16 @@ func SetupRouter(handler *Handler, adminHandler *AdminHandler, authHandler *Auth
 
 			// Create permission required
 			data.POST("/:entity", handler.PermissionMiddleware(auth.ActionCreate), handler.Create)
+			data.POST("/:entity/bulk-create", handler.PermissionMiddleware(auth.ActionCreate), handler.BulkCreate)
 
 			// Edit permission required
 			data.PUT("/:entity/:id", handler.PermissionMiddleware(auth.ActionEdit), handler.Update)
+			data.POST("/:entity/:id/transfer", handler.PermissionMiddleware(auth.ActionEdit), handler.TransferOwnership)
+
+			// Sharing a record needs edit permission, seeing its shares view
+			data.GET("/:entity/:id/shares", handler.PermissionMiddleware(auth.ActionView), handler.ListShares)
+			data.POST("/:entity/:id/shares", handler.PermissionMiddleware(auth.ActionEdit), handler.ShareRecord)
+			data.DELETE("/:entity/:id/shares", handler.PermissionMiddleware(auth.ActionEdit), handler.RevokeShare)
 
 			// Delete permission required
 			data.DELETE("/:entity/:id", handler.PermissionMiddleware(auth.ActionDelete), handler.Delete)
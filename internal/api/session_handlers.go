@@ -0,0 +1,172 @@
+// Package api - Session and device management handlers
+package api
+
+import (
+	"net/http"
+
+	"github.com/aethra/genesis/internal/auth"
+	"github.com/aethra/genesis/internal/errors"
+	"github.com/aethra/genesis/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SessionResponse is a session as shown to its user or an admin
+type SessionResponse struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// =============================================================================
+// OWN SESSIONS
+// =============================================================================
+
+// ListSessions returns the current user's active sessions
+// GET /auth/sessions
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	sessions, err := h.tokenService.ListSessions(userID)
+	if err != nil {
+		status, response := errors.ToHTTPError(errors.NewInternalError(err))
+		c.JSON(status, response)
+		return
+	}
+	c.JSON(http.StatusOK, sessionResponses(sessions, currentSessionID(c)))
+}
+
+// RevokeSession ends one of the current user's sessions, such as a lost device
+// DELETE /auth/sessions/:id
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	err = h.tokenService.RevokeSession(userID, sessionID, auth.RevokeReasonSessionRevoked)
+	if err == auth.ErrSessionNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		status, response := errors.ToHTTPError(errors.NewInternalError(err))
+		c.JSON(status, response)
+		return
+	}
+
+	h.audit(c, c.MustGet("user_tenant_id").(uuid.UUID), userID, "session_revoke", map[string]interface{}{
+		"session_id": sessionID,
+		"current":    sessionID == currentSessionID(c),
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "session revoked"})
+}
+
+// =============================================================================
+// ADMINISTRATION
+// =============================================================================
+
+// ListUserSessions returns a user's active sessions
+// GET /admin/users/:id/sessions
+func (h *AdminHandler) ListUserSessions(c *gin.Context) {
+	user, ok := h.sessionUser(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.tokens.ListSessions(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sessionResponses(sessions, uuid.Nil))
+}
+
+// ForceLogout ends every session of a user
+// DELETE /admin/users/:id/sessions
+func (h *AdminHandler) ForceLogout(c *gin.Context) {
+	user, ok := h.sessionUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.tokens.RevokeUser(user.ID, auth.RevokeReasonAdmin); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, user.TenantID, nil, "force_logout", map[string]interface{}{
+		"user_id": user.ID,
+		"email":   user.Email,
+	}, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "user logged out of all sessions"})
+}
+
+// RevokeUserSession ends one session of a user
+// DELETE /admin/users/:id/sessions/:session_id
+func (h *AdminHandler) RevokeUserSession(c *gin.Context) {
+	user, ok := h.sessionUser(c)
+	if !ok {
+		return
+	}
+	sessionID, err := uuid.Parse(c.Param("session_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid session_id"})
+		return
+	}
+
+	err = h.tokens.RevokeSession(user.ID, sessionID, auth.RevokeReasonAdmin)
+	if err == auth.ErrSessionNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, user.TenantID, nil, "session_revoke", map[string]interface{}{
+		"user_id":    user.ID,
+		"email":      user.Email,
+		"session_id": sessionID,
+	}, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "session revoked"})
+}
+
+// sessionUser loads the user in the path and checks the caller may manage it
+func (h *AdminHandler) sessionUser(c *gin.Context) (*models.User, bool) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return nil, false
+	}
+
+	var user models.User
+	if err := h.db.Select("id, tenant_id, email").Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return nil, false
+	}
+	if !h.authorizeTenant(c, user.TenantID) {
+		return nil, false
+	}
+	return &user, true
+}
+
+// currentSessionID returns the session of the request's access token, or
+// uuid.Nil for API keys
+func currentSessionID(c *gin.Context) uuid.UUID {
+	value, _ := c.Get("token_claims")
+	if claims, ok := value.(*auth.Claims); ok {
+		return claims.FamilyID
+	}
+	return uuid.Nil
+}
+
+func sessionResponses(sessions []models.Session, current uuid.UUID) []SessionResponse {
+	responses := make([]SessionResponse, len(sessions))
+	for i, session := range sessions {
+		responses[i] = SessionResponse{Session: session, Current: current != uuid.Nil && session.ID == current}
+	}
+	return responses
+}
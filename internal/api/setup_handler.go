@@ -5,10 +5,10 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/aethra/genesis/internal/auth"
 	"github.com/aethra/genesis/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -407,6 +407,12 @@ func (h *SetupHandler) DoSetup(c *gin.Context) {
 		return
 	}
 
+	// New tenants start with the default password policy
+	if err := auth.DefaultPasswordPolicy().Check("password", input.Password).Err(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Create tenant
 	tenant := models.Tenant{
 		ID:       uuid.New(),
@@ -421,7 +427,7 @@ func (h *SetupHandler) DoSetup(c *gin.Context) {
 	}
 
 	// Create admin user
-	hash, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	hash, err := auth.HashPassword(input.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
 		return
@@ -431,7 +437,7 @@ func (h *SetupHandler) DoSetup(c *gin.Context) {
 		ID:           uuid.New(),
 		TenantID:     tenant.ID,
 		Email:        input.Email,
-		PasswordHash: string(hash),
+		PasswordHash: hash,
 		FirstName:    input.FirstName,
 		LastName:     input.LastName,
 		IsActive:     true,
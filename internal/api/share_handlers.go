@@ -0,0 +1,120 @@
+// Package api - Record sharing handlers
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/aethra/genesis/internal/engine"
+	"github.com/aethra/genesis/internal/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ListShares returns who a record is shared with
+// GET /api/data/:entity/:id/shares
+func (h *Handler) ListShares(c *gin.Context) {
+	tenantID := c.MustGet("tenant_id").(uuid.UUID)
+	recordID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.handleError(c, errors.NewBadRequestError("invalid id"))
+		return
+	}
+
+	shares, err := h.data(c).ListShares(tenantID, c.Param("entity"), recordID, optionalUserID(c))
+	if err != nil {
+		h.handleShareError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": shares})
+}
+
+// ShareRecord shares a record with a user or role, with view or edit access
+// and an optional expiry. Sharing again with the same user or role replaces
+// their share.
+// POST /api/data/:entity/:id/shares
+func (h *Handler) ShareRecord(c *gin.Context) {
+	tenantID := c.MustGet("tenant_id").(uuid.UUID)
+	recordID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.handleError(c, errors.NewBadRequestError("invalid id"))
+		return
+	}
+
+	var grant engine.ShareGrant
+	if err := c.ShouldBindJSON(&grant); err != nil {
+		h.handleError(c, errors.NewBadRequestError("invalid request body"))
+		return
+	}
+
+	share, err := h.data(c).ShareRecord(tenantID, c.Param("entity"), recordID, grant, optionalUserID(c))
+	if err != nil {
+		h.handleShareError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, share)
+}
+
+// RevokeShare stops sharing a record with a user or role
+// DELETE /api/data/:entity/:id/shares?user_id=xxx or ?role_id=xxx
+func (h *Handler) RevokeShare(c *gin.Context) {
+	tenantID := c.MustGet("tenant_id").(uuid.UUID)
+	recordID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.handleError(c, errors.NewBadRequestError("invalid id"))
+		return
+	}
+
+	var grant engine.ShareGrant
+	var ok bool
+	if grant.UserID, ok = h.optionalUUIDQuery(c, "user_id"); !ok {
+		return
+	}
+	if grant.RoleID, ok = h.optionalUUIDQuery(c, "role_id"); !ok {
+		return
+	}
+
+	if err := h.data(c).RevokeShare(tenantID, c.Param("entity"), recordID, grant, optionalUserID(c)); err != nil {
+		h.handleShareError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "share revoked"})
+}
+
+// handleShareError maps sharing errors to responses
+func (h *Handler) handleShareError(c *gin.Context, err error) {
+	switch {
+	case strings.Contains(err.Error(), "validation"):
+		h.handleError(c, errors.NewValidationError("", err.Error()))
+	case strings.Contains(err.Error(), "share not found"):
+		h.handleError(c, errors.NewNotFoundError("share"))
+	case strings.Contains(err.Error(), "not found"):
+		h.handleError(c, errors.NewNotFoundError("record"))
+	default:
+		h.handleError(c, err)
+	}
+}
+
+// optionalUUIDQuery parses an optional uuid query parameter, responding with
+// a bad request when it is malformed
+func (h *Handler) optionalUUIDQuery(c *gin.Context, name string) (*uuid.UUID, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		h.handleError(c, errors.NewBadRequestError("invalid "+name))
+		return nil, false
+	}
+	return &id, true
+}
+
+// optionalUserID returns the authenticated user's id, if any
+func optionalUserID(c *gin.Context) *uuid.UUID {
+	if uid, exists := c.Get("user_id"); exists {
+		id := uid.(uuid.UUID)
+		return &id
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+// Package api - Token signing key handlers
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS publishes the public keys that verify Genesis tokens. Verifiers should
+// refetch it when they meet an unknown kid, as keys rotate.
+// GET /.well-known/jwks.json
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.tokenService.SigningKeys().JWKS())
+}
+
+// ListSigningKeys returns the stored signing keys without their private keys
+// GET /admin/signing-keys
+func (h *AdminHandler) ListSigningKeys(c *gin.Context) {
+	keys, err := h.tokens.SigningKeys().List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// RotateSigningKey replaces the signing key ahead of schedule, as after a
+// suspected leak. Tokens signed by the old key stay valid for the grace
+// period; revoke sessions as well to end them sooner.
+// POST /admin/signing-keys/rotate
+func (h *AdminHandler) RotateSigningKey(c *gin.Context) {
+	key, err := h.tokens.SigningKeys().Rotate()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, h.callerTenantID(c), nil, "signing_key_rotate", nil, map[string]interface{}{
+		"kid":       key.KID,
+		"algorithm": key.Algorithm,
+	})
+	c.JSON(http.StatusOK, key)
+}
@@ -0,0 +1,84 @@
+// Package api - Login lockout administration handlers
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aethra/genesis/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ListLockouts returns the accounts and addresses currently locked out
+// GET /admin/lockouts
+func (h *AdminHandler) ListLockouts(c *gin.Context) {
+	query, ok := h.scopeTenant(c, h.db.Model(&models.LoginThrottle{}))
+	if !ok {
+		return
+	}
+
+	var lockouts []models.LoginThrottle
+	err := query.Where("locked_until > ?", time.Now()).
+		Order("locked_until DESC").
+		Find(&lockouts).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, lockouts)
+}
+
+// DeleteLockout lifts a lockout, including those of client addresses
+// DELETE /admin/lockouts/:id
+func (h *AdminHandler) DeleteLockout(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var lockout models.LoginThrottle
+	if err := h.db.Where("id = ?", id).First(&lockout).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "lockout not found"})
+		return
+	}
+	if !h.authorizeTenant(c, lockout.TenantID) {
+		return
+	}
+
+	if _, err := h.throttle.Unlock(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, lockout.TenantID, nil, "account_unlock", map[string]interface{}{
+		"scope":        lockout.Scope,
+		"key":          lockout.Key,
+		"failures":     lockout.Failures,
+		"locked_until": lockout.LockedUntil,
+	}, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "lockout removed"})
+}
+
+// UnlockUser clears a user's failed login and two-factor attempts
+// POST /admin/users/:id/unlock
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	user, ok := h.sessionUser(c)
+	if !ok {
+		return
+	}
+
+	cleared, err := h.throttle.UnlockUser(user.TenantID, user.ID, user.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, user.TenantID, nil, "account_unlock", nil, map[string]interface{}{
+		"user_id": user.ID,
+		"email":   user.Email,
+		"cleared": cleared,
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "user unlocked"})
+}
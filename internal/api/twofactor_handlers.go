@@ -0,0 +1,360 @@
+// Package api - Two-factor authentication handlers
+package api
+
+import (
+	"net/http"
+
+	"github.com/aethra/genesis/internal/auth"
+	"github.com/aethra/genesis/internal/errors"
+	"github.com/aethra/genesis/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// TwoFactorLoginRequest continues a login that returned mfa_required
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code"`
+}
+
+// =============================================================================
+// LOGIN SECOND STEP
+// =============================================================================
+
+// VerifyTwoFactorLogin completes a login with a TOTP or recovery code. Users
+// enrolling during login confirm their first code here and receive their
+// recovery codes with the tokens.
+// POST /auth/login/verify
+func (h *AuthHandler) VerifyTwoFactorLogin(c *gin.Context) {
+	var req TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "challenge_token and code are required"})
+		return
+	}
+
+	claims, err := h.tokenService.ValidateChallenge(req.ChallengeToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired challenge"})
+		return
+	}
+
+	// Codes are short, so guesses are limited per user rather than per IP
+	throttleKey := auth.ThrottleKey{Scope: auth.ThrottleTwoFactor, Value: claims.UserID.String()}
+	decision, err := h.throttle.Check(claims.TenantID, throttleKey)
+	if err != nil {
+		status, response := errors.ToHTTPError(errors.NewInternalError(err))
+		c.JSON(status, response)
+		return
+	}
+	if !decision.Allowed {
+		respondThrottled(c, decision, "too many verification attempts")
+		return
+	}
+
+	user, err := h.findLoginUser(claims.TenantID, "users.id = ?", claims.UserID)
+	if err != nil || !user.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired challenge"})
+		return
+	}
+
+	enabled, err := h.twoFactor.IsEnabled(user.ID)
+	if err != nil {
+		status, response := errors.ToHTTPError(errors.NewInternalError(err))
+		c.JSON(status, response)
+		return
+	}
+
+	extra := gin.H{}
+	if enabled {
+		usedRecovery, err := h.twoFactor.Verify(user.ID, req.Code)
+		if err != nil {
+			h.twoFactorFailed(c, claims.TenantID, throttleKey, err)
+			return
+		}
+		if usedRecovery {
+			left, _ := h.twoFactor.RemainingRecoveryCodes(user.ID)
+			extra["recovery_codes_remaining"] = left
+			h.audit(c, user.TenantID, user.ID, "2fa_recovery_code_used", map[string]interface{}{
+				"recovery_codes_remaining": left,
+			})
+		}
+	} else {
+		// Enrolment required by policy: the first code confirms the secret
+		// from /auth/login/enroll
+		codes, err := h.twoFactor.ConfirmEnrollment(user.ID, req.Code)
+		if err == auth.ErrTwoFactorNotEnabled {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "two-factor enrollment required, start it with /auth/login/enroll"})
+			return
+		}
+		if err != nil {
+			h.twoFactorFailed(c, claims.TenantID, throttleKey, err)
+			return
+		}
+		extra["recovery_codes"] = codes
+		h.audit(c, user.TenantID, user.ID, "2fa_enabled", nil)
+	}
+
+	h.throttle.Reset(claims.TenantID, throttleKey)
+	h.completeLogin(c, user, h.memberships.RoleCodes(user.ID, user.TenantID), extra)
+}
+
+// EnrollTwoFactorLogin starts TOTP enrolment for a user whose tenant requires
+// 2FA but who has not enrolled yet
+// POST /auth/login/enroll
+func (h *AuthHandler) EnrollTwoFactorLogin(c *gin.Context) {
+	var req struct {
+		ChallengeToken string `json:"challenge_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "challenge_token is required"})
+		return
+	}
+
+	claims, err := h.tokenService.ValidateChallenge(req.ChallengeToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired challenge"})
+		return
+	}
+
+	h.beginEnrollment(c, claims.UserID, claims.Email)
+}
+
+// =============================================================================
+// ACCOUNT SETTINGS
+// =============================================================================
+
+// GetTwoFactorStatus reports the current user's 2FA state
+// GET /auth/2fa
+func (h *AuthHandler) GetTwoFactorStatus(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	tenantID := c.MustGet("user_tenant_id").(uuid.UUID)
+
+	enabled, err := h.twoFactor.IsEnabled(userID)
+	if err != nil {
+		status, response := errors.ToHTTPError(errors.NewInternalError(err))
+		c.JSON(status, response)
+		return
+	}
+	pending, _ := h.twoFactor.HasPendingEnrollment(userID)
+	remaining, _ := h.twoFactor.RemainingRecoveryCodes(userID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                  enabled,
+		"pending":                  pending,
+		"required":                 h.requiresTwoFactor(tenantID, h.memberships.RoleCodes(userID, tenantID)),
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// EnrollTwoFactor starts TOTP enrolment for the current user
+// POST /auth/2fa/enroll
+func (h *AuthHandler) EnrollTwoFactor(c *gin.Context) {
+	h.beginEnrollment(c, c.MustGet("user_id").(uuid.UUID), c.GetString("user_email"))
+}
+
+// ConfirmTwoFactor enables 2FA with a first code from the authenticator app
+// and returns the recovery codes
+// POST /auth/2fa/confirm
+func (h *AuthHandler) ConfirmTwoFactor(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+
+	codes, err := h.twoFactor.ConfirmEnrollment(userID, req.Code)
+	if err == auth.ErrTwoFactorNotEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no enrollment in progress, start one with /auth/2fa/enroll"})
+		return
+	}
+	if err != nil {
+		h.respondTwoFactorError(c, err, -1)
+		return
+	}
+
+	h.audit(c, c.MustGet("user_tenant_id").(uuid.UUID), userID, "2fa_enabled", nil)
+	c.JSON(http.StatusOK, gin.H{"enabled": true, "recovery_codes": codes})
+}
+
+// RegenerateRecoveryCodes replaces the current user's recovery codes after
+// checking a TOTP code
+// POST /auth/2fa/recovery-codes
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+
+	if _, err := h.twoFactor.Verify(userID, req.Code); err != nil {
+		h.respondTwoFactorError(c, err, -1)
+		return
+	}
+	codes, err := h.twoFactor.RegenerateRecoveryCodes(userID)
+	if err != nil {
+		h.respondTwoFactorError(c, err, -1)
+		return
+	}
+
+	h.audit(c, c.MustGet("user_tenant_id").(uuid.UUID), userID, "2fa_recovery_codes_regenerated", nil)
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DisableTwoFactor turns 2FA off for the current user. Both the password and
+// a current code are required, and users the tenant requires 2FA for cannot
+// opt out.
+// POST /auth/2fa/disable
+func (h *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	tenantID := c.MustGet("user_tenant_id").(uuid.UUID)
+
+	var req struct {
+		Password string `json:"password" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password and code are required"})
+		return
+	}
+
+	if h.requiresTwoFactor(tenantID, h.memberships.RoleCodes(userID, tenantID)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "two-factor authentication is required for your role"})
+		return
+	}
+
+	var passwordHash string
+	err := h.db.Table("users").Select("password_hash").Where("id = ?", userID).First(&passwordHash).Error
+	if err != nil || !auth.CheckPassword(req.Password, passwordHash) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "password is incorrect"})
+		return
+	}
+	if _, err := h.twoFactor.Verify(userID, req.Code); err != nil {
+		h.respondTwoFactorError(c, err, -1)
+		return
+	}
+
+	if err := h.twoFactor.Disable(userID); err != nil {
+		status, response := errors.ToHTTPError(errors.NewInternalError(err))
+		c.JSON(status, response)
+		return
+	}
+
+	h.audit(c, tenantID, userID, "2fa_disabled", nil)
+	c.JSON(http.StatusOK, gin.H{"enabled": false})
+}
+
+// beginEnrollment writes a new TOTP secret and its provisioning URI, which
+// the client renders as a QR code
+func (h *AuthHandler) beginEnrollment(c *gin.Context, userID uuid.UUID, email string) {
+	enrollment, err := h.twoFactor.BeginEnrollment(userID, email)
+	if err == auth.ErrTwoFactorAlreadyEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		status, response := errors.ToHTTPError(errors.NewInternalError(err))
+		c.JSON(status, response)
+		return
+	}
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// twoFactorFailed counts a wrong code during login before responding
+func (h *AuthHandler) twoFactorFailed(c *gin.Context, tenantID uuid.UUID, key auth.ThrottleKey, err error) {
+	if err != auth.ErrInvalidTwoFactorCode {
+		h.respondTwoFactorError(c, err, -1)
+		return
+	}
+	decision, recordErr := h.throttle.RecordFailure(tenantID, clientInfo(c), key)
+	if recordErr != nil {
+		status, response := errors.ToHTTPError(errors.NewInternalError(recordErr))
+		c.JSON(status, response)
+		return
+	}
+	if decision.Locked {
+		respondThrottled(c, decision, "too many verification attempts")
+		return
+	}
+	h.respondTwoFactorError(c, err, decision.Remaining)
+}
+
+// respondTwoFactorError maps two-factor errors to responses. remaining is the
+// number of attempts left, or negative when not rate limited.
+func (h *AuthHandler) respondTwoFactorError(c *gin.Context, err error, remaining int) {
+	switch err {
+	case auth.ErrInvalidTwoFactorCode:
+		response := gin.H{"error": err.Error()}
+		if remaining >= 0 {
+			response["attempts_remaining"] = remaining
+		}
+		c.JSON(http.StatusUnauthorized, response)
+	case auth.ErrTwoFactorNotEnabled:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case auth.ErrTwoFactorAlreadyEnabled:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		status, response := errors.ToHTTPError(errors.NewInternalError(err))
+		c.JSON(status, response)
+	}
+}
+
+// =============================================================================
+// ADMINISTRATION
+// =============================================================================
+
+// ResetUserTwoFactor removes a user's 2FA enrolment, for users who lost both
+// their authenticator and recovery codes. Users the tenant requires 2FA for
+// enrol again at their next login. Only super admins reset the second factor
+// of a super admin.
+// DELETE /admin/users/:id/2fa
+func (h *AdminHandler) ResetUserTwoFactor(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.Select("id, tenant_id, email").Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if !h.authorizeTenant(c, user.TenantID) {
+		return
+	}
+
+	// Resetting the second factor hands the account to whoever knows the
+	// password, so it takes the same power as granting the user's roles
+	var roles []models.Role
+	err = h.db.Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", user.ID).
+		Find(&roles).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range roles {
+		if !h.canManageRole(c, &roles[i]) {
+			return
+		}
+	}
+
+	if err := h.twoFactor.Disable(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, user.TenantID, nil, "2fa_reset", map[string]interface{}{
+		"user_id": user.ID,
+		"email":   user.Email,
+	}, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication reset"})
+}
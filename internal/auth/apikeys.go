@@ -0,0 +1,306 @@
+// Package auth - API keys for integrations
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aethra/genesis/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// APIKeyPrefix starts every API key so it can be told apart from a JWT
+const APIKeyPrefix = "gk_"
+
+// lastUsedInterval limits how often last-used details are written for a key
+const lastUsedInterval = time.Minute
+
+var (
+	// ErrInvalidAPIKey is returned for unknown, malformed or revoked keys
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	// ErrAPIKeyExpired is returned for keys past their expiry
+	ErrAPIKeyExpired = errors.New("API key has expired")
+)
+
+// APIKeyScopes limits a key to actions on entities. Keys are entity codes or
+// "*" for every entity. A nil scope set allows everything the key's user may do.
+type APIKeyScopes map[string][]Action
+
+var scopeActions = map[Action]bool{
+	ActionView: true, ActionCreate: true, ActionEdit: true,
+	ActionDelete: true, ActionExport: true, ActionImport: true,
+}
+
+// ParseAPIKeyScopes reads scopes as stored on models.APIKey
+func ParseAPIKeyScopes(raw models.JSONB) (APIKeyScopes, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	scopes := make(APIKeyScopes, len(raw))
+	for entity, value := range raw {
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("scopes for '%s' must be a list of actions", entity)
+		}
+		actions := make([]Action, 0, len(list))
+		for _, item := range list {
+			name, _ := item.(string)
+			action := Action(name)
+			if !scopeActions[action] {
+				return nil, fmt.Errorf("unknown action '%v' in scopes for '%s'", item, entity)
+			}
+			actions = append(actions, action)
+		}
+		scopes[entity] = actions
+	}
+	return scopes, nil
+}
+
+// JSONB converts scopes to their stored form
+func (s APIKeyScopes) JSONB() models.JSONB {
+	if s == nil {
+		return nil
+	}
+	raw := make(models.JSONB, len(s))
+	for entity, actions := range s {
+		list := make([]interface{}, len(actions))
+		for i, action := range actions {
+			list[i] = string(action)
+		}
+		raw[entity] = list
+	}
+	return raw
+}
+
+// Allows reports whether the scopes permit an action on an entity
+func (s APIKeyScopes) Allows(entityCode string, action Action) bool {
+	if s == nil {
+		return true
+	}
+	for _, key := range []string{entityCode, "*"} {
+		for _, allowed := range s[key] {
+			if allowed == action {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Restrict narrows a resolved permission to what the scopes allow
+func (s APIKeyScopes) Restrict(entityCode string, perm *UserPermission) *UserPermission {
+	if s == nil || perm == nil {
+		return perm
+	}
+	restricted := *perm
+	restricted.CanView = perm.CanView && s.Allows(entityCode, ActionView)
+	restricted.CanCreate = perm.CanCreate && s.Allows(entityCode, ActionCreate)
+	restricted.CanEdit = perm.CanEdit && s.Allows(entityCode, ActionEdit)
+	restricted.CanDelete = perm.CanDelete && s.Allows(entityCode, ActionDelete)
+	restricted.CanExport = perm.CanExport && s.Allows(entityCode, ActionExport)
+	restricted.CanImport = perm.CanImport && s.Allows(entityCode, ActionImport)
+	return &restricted
+}
+
+type scopesKey struct{}
+
+// ContextWithScopes attaches API key scopes to a request context
+func ContextWithScopes(ctx context.Context, scopes APIKeyScopes) context.Context {
+	if scopes == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, scopesKey{}, scopes)
+}
+
+// ScopesFromContext returns the API key scopes attached to a context, if any
+func ScopesFromContext(ctx context.Context) APIKeyScopes {
+	scopes, _ := ctx.Value(scopesKey{}).(APIKeyScopes)
+	return scopes
+}
+
+// IsAPIKey reports whether a bearer credential is an API key rather than a JWT
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+// APIKeyIdentity is the caller behind an authenticated API key
+type APIKeyIdentity struct {
+	KeyID    uuid.UUID
+	UserID   uuid.UUID
+	TenantID uuid.UUID
+	Email    string
+	Roles    []string
+	Scopes   APIKeyScopes
+}
+
+// CreateAPIKeyInput describes a new key
+type CreateAPIKeyInput struct {
+	UserID    uuid.UUID
+	Name      string
+	Scopes    APIKeyScopes
+	ExpiresAt *time.Time
+	CreatedBy *uuid.UUID
+}
+
+// APIKeyService creates, authenticates and revokes API keys
+type APIKeyService struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService(db *gorm.DB) *APIKeyService {
+	return &APIKeyService{db: db}
+}
+
+// Create stores a new key for a user and returns it with the plaintext
+// secret, which is not recoverable afterwards
+func (s *APIKeyService) Create(input CreateAPIKeyInput) (*models.APIKey, string, error) {
+	var user models.User
+	if err := s.db.Select("id, tenant_id").Where("id = ?", input.UserID).First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, "", fmt.Errorf("user not found")
+		}
+		return nil, "", err
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return nil, "", fmt.Errorf("validation: expires_at must be in the future")
+	}
+
+	prefix, err := randomHex(6)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return nil, "", err
+	}
+	plaintext := APIKeyPrefix + prefix + "_" + secret
+
+	key := models.APIKey{
+		ID:        uuid.New(),
+		TenantID:  user.TenantID,
+		UserID:    user.ID,
+		Name:      input.Name,
+		Prefix:    APIKeyPrefix + prefix,
+		KeyHash:   hashAPIKey(plaintext),
+		Scopes:    input.Scopes.JSONB(),
+		ExpiresAt: input.ExpiresAt,
+		CreatedBy: input.CreatedBy,
+		CreatedAt: time.Now(),
+	}
+	if err := s.db.Create(&key).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to create API key: %w", err)
+	}
+	return &key, plaintext, nil
+}
+
+// Authenticate resolves a presented key to its user and records where and
+// when it was used
+func (s *APIKeyService) Authenticate(credential, ipAddress string) (*APIKeyIdentity, error) {
+	prefix, ok := splitAPIKey(credential)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	var key models.APIKey
+	if err := s.db.Where("prefix = ?", prefix).First(&key).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashAPIKey(credential)), []byte(key.KeyHash)) != 1 || key.RevokedAt != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	now := time.Now()
+	if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+		return nil, ErrAPIKeyExpired
+	}
+
+	var user struct {
+		Email    string
+		IsActive bool
+	}
+	err := s.db.Table("users").Select("email, is_active").Where("id = ?", key.UserID).First(&user).Error
+	if err != nil || !user.IsActive {
+		return nil, ErrInvalidAPIKey
+	}
+
+	scopes, err := ParseAPIKeyScopes(key.Scopes)
+	if err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	roles := roleCodes(s.db, key.UserID, key.TenantID)
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > lastUsedInterval || key.LastUsedIP != ipAddress {
+		s.db.Model(&models.APIKey{}).Where("id = ?", key.ID).Updates(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": ipAddress,
+		})
+	}
+
+	return &APIKeyIdentity{
+		KeyID:    key.ID,
+		UserID:   key.UserID,
+		TenantID: key.TenantID,
+		Email:    user.Email,
+		Roles:    roles,
+		Scopes:   scopes,
+	}, nil
+}
+
+// Revoke disables a key; revoked keys stay listed for auditing
+func (s *APIKeyService) Revoke(keyID uuid.UUID) error {
+	result := s.db.Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", keyID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("API key not found or already revoked")
+	}
+	return nil
+}
+
+// splitAPIKey returns the lookup prefix of a well-formed key
+func splitAPIKey(credential string) (string, bool) {
+	if !IsAPIKey(credential) {
+		return "", false
+	}
+	rest := strings.TrimPrefix(credential, APIKeyPrefix)
+	prefix, secret, ok := strings.Cut(rest, "_")
+	if !ok || prefix == "" || secret == "" {
+		return "", false
+	}
+	return APIKeyPrefix + prefix, true
+}
+
+// hashAPIKey hashes a key for storage. Keys carry 192 bits of randomness, so a
+// fast hash is sufficient, unlike passwords.
+func hashAPIKey(key string) string {
+	return sha256Hex(key)
+}
+
+// sha256Hex hashes high-entropy secrets such as keys and single-use tokens
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/aethra/genesis/internal/models"
+)
+
+func TestParseAPIKeyScopes(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  models.JSONB
+		want APIKeyScopes
+		ok   bool
+	}{
+		{"unscoped", nil, nil, true},
+		{"entity and wildcard", models.JSONB{"customer": []interface{}{"view", "edit"}, "*": []interface{}{"export"}},
+			APIKeyScopes{"customer": {ActionView, ActionEdit}, "*": {ActionExport}}, true},
+		{"not a list", models.JSONB{"customer": "view"}, nil, false},
+		{"unknown action", models.JSONB{"customer": []interface{}{"approve"}}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAPIKeyScopes(tt.raw)
+			if (err == nil) != tt.ok {
+				t.Fatalf("ParseAPIKeyScopes() error = %v, want ok = %v", err, tt.ok)
+			}
+			if len(got) != len(tt.want) || (got == nil) != (tt.want == nil) {
+				t.Fatalf("ParseAPIKeyScopes() = %v, want %v", got, tt.want)
+			}
+			for entity, actions := range tt.want {
+				if len(got[entity]) != len(actions) {
+					t.Fatalf("scopes for %s = %v, want %v", entity, got[entity], actions)
+				}
+				for i, action := range actions {
+					if got[entity][i] != action {
+						t.Errorf("scopes for %s = %v, want %v", entity, got[entity], actions)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestAPIKeyScopesAllows(t *testing.T) {
+	scopes := APIKeyScopes{"customer": {ActionView, ActionEdit}, "*": {ActionExport}}
+	tests := []struct {
+		scopes APIKeyScopes
+		entity string
+		action Action
+		want   bool
+	}{
+		{nil, "invoice", ActionDelete, true},
+		{scopes, "customer", ActionView, true},
+		{scopes, "customer", ActionDelete, false},
+		{scopes, "invoice", ActionView, false},
+		{scopes, "invoice", ActionExport, true},
+		{APIKeyScopes{}, "customer", ActionView, false},
+	}
+	for _, tt := range tests {
+		if got := tt.scopes.Allows(tt.entity, tt.action); got != tt.want {
+			t.Errorf("%v.Allows(%s, %s) = %v, want %v", tt.scopes, tt.entity, tt.action, got, tt.want)
+		}
+	}
+}
+
+func TestAPIKeyScopesRestrict(t *testing.T) {
+	perm := &UserPermission{CanView: true, CanCreate: true, CanDelete: true, RowFilter: map[string]interface{}{"status": "open"}}
+	scopes := APIKeyScopes{"customer": {ActionView, ActionEdit}}
+
+	got := scopes.Restrict("customer", perm)
+	if !got.CanView || got.CanCreate || got.CanDelete {
+		t.Errorf("Restrict() = %+v, want only view", got)
+	}
+	// Scopes never add to what the user may do
+	if got.CanEdit {
+		t.Error("Restrict() granted edit, which the user does not have")
+	}
+	if got.RowFilter["status"] != "open" {
+		t.Error("Restrict() dropped the row filter")
+	}
+	if !perm.CanCreate || !perm.CanDelete {
+		t.Error("Restrict() modified the resolved permission")
+	}
+	if APIKeyScopes(nil).Restrict("customer", perm) != perm {
+		t.Error("unscoped keys should keep the resolved permission")
+	}
+}
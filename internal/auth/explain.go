@@ -0,0 +1,158 @@
+// Package auth - Explaining how permissions resolve
+package auth
+
+import (
+	"github.com/aethra/genesis/internal/models"
+	"github.com/google/uuid"
+)
+
+// Outcomes of a permission entry in an explanation
+const (
+	// OutcomeGranted marks an allow entry merged into the result
+	OutcomeGranted = "granted"
+	// OutcomeOverridden marks a module entry ignored because the same role
+	// has an entry for the entity
+	OutcomeOverridden = "overridden"
+	// OutcomeDenied marks a deny entry taken away from the result
+	OutcomeDenied = "denied"
+)
+
+// PermissionExplanation records how PermissionService resolved a user's
+// permission on an entity, step by step as described on GetUserPermission
+type PermissionExplanation struct {
+	// Roles are the roles that were considered: the user's roles and their
+	// ancestors, including an inactive role that ended a chain
+	Roles []ExplainedRole `json:"roles"`
+	// Entries are the permission entries of the active roles on the entity
+	// or its module, in the order they were merged
+	Entries []ExplainedEntry `json:"entries"`
+	// Fields shows how each field with a field permission was merged.
+	// Other fields follow the entity's view and edit actions.
+	Fields map[string]ExplainedField `json:"fields"`
+	// RowFilterRole is the role whose grant decided the row filter: the
+	// first granting view, or the first granting view without a filter
+	RowFilterRole string `json:"row_filter_role,omitempty"`
+	// Result is the resolved permission, as enforced
+	Result *UserPermission `json:"result"`
+}
+
+// ExplainedRole is a role considered while resolving a permission
+type ExplainedRole struct {
+	ID       uuid.UUID `json:"id"`
+	Code     string    `json:"code"`
+	IsActive bool      `json:"is_active"`
+	// InheritedBy is the role this one was reached from, empty for roles
+	// assigned to the user
+	InheritedBy string `json:"inherited_by,omitempty"`
+}
+
+// ExplainedEntry is a permission entry that matched the entity or its module
+type ExplainedEntry struct {
+	PermissionID uuid.UUID `json:"permission_id"`
+	Role         string    `json:"role"`
+	Target       string    `json:"target"`
+	Effect       string    `json:"effect"`
+	Actions      []Action  `json:"actions"`
+	// Scopes lists the actions limited to owned records
+	Scopes           map[Action]string `json:"scopes,omitempty"`
+	FieldPermissions models.JSONB      `json:"field_permissions,omitempty"`
+	RowFilter        models.JSONB      `json:"row_filter,omitempty"`
+	Outcome          string            `json:"outcome"`
+}
+
+// ExplainedField shows which roles opened or closed a field
+type ExplainedField struct {
+	View          bool     `json:"view"`
+	Edit          bool     `json:"edit"`
+	ViewGrantedBy []string `json:"view_granted_by"`
+	EditGrantedBy []string `json:"edit_granted_by"`
+	DeniedBy      []string `json:"denied_by"`
+}
+
+// The recording methods below do nothing on a nil explanation, so resolution
+// only pays for them when explaining.
+
+func (e *PermissionExplanation) entry(role string, perm *models.Permission, outcome string) {
+	if e == nil {
+		return
+	}
+	target := "module"
+	if perm.EntityID != nil {
+		target = "entity"
+	}
+	actions := []Action{}
+	flags := []bool{perm.CanView, perm.CanCreate, perm.CanEdit, perm.CanDelete, perm.CanExport, perm.CanImport}
+	for i, action := range Actions {
+		if flags[i] {
+			actions = append(actions, action)
+		}
+	}
+	var scopes map[Action]string
+	for _, action := range ScopedActions {
+		if allowed, scope := permissionScope(perm, action); allowed && scope != ScopeAll && perm.Effect != EffectDeny {
+			if scopes == nil {
+				scopes = make(map[Action]string)
+			}
+			scopes[action] = scope
+		}
+	}
+	e.Entries = append(e.Entries, ExplainedEntry{
+		PermissionID:     perm.ID,
+		Role:             role,
+		Target:           target,
+		Effect:           perm.Effect,
+		Actions:          actions,
+		Scopes:           scopes,
+		FieldPermissions: perm.FieldPermissions,
+		RowFilter:        perm.RowFilter,
+		Outcome:          outcome,
+	})
+}
+
+func (e *PermissionExplanation) field(code string) ExplainedField {
+	field, ok := e.Fields[code]
+	if !ok {
+		field = ExplainedField{ViewGrantedBy: []string{}, EditGrantedBy: []string{}, DeniedBy: []string{}}
+	}
+	return field
+}
+
+func (e *PermissionExplanation) fieldGrant(code, role string, fp FieldPermission) {
+	if e == nil {
+		return
+	}
+	field := e.field(code)
+	if fp.CanView {
+		field.ViewGrantedBy = append(field.ViewGrantedBy, role)
+	}
+	if fp.CanEdit {
+		field.EditGrantedBy = append(field.EditGrantedBy, role)
+	}
+	e.Fields[code] = field
+}
+
+func (e *PermissionExplanation) fieldDeny(code, role string, denied FieldPermission) {
+	if e == nil || (!denied.CanView && !denied.CanEdit) {
+		return
+	}
+	field := e.field(code)
+	field.DeniedBy = append(field.DeniedBy, role)
+	e.Fields[code] = field
+}
+
+func (e *PermissionExplanation) fieldResult(code string, fp FieldPermission) {
+	if e == nil {
+		return
+	}
+	field := e.field(code)
+	field.View = fp.CanView
+	field.Edit = fp.CanEdit
+	e.Fields[code] = field
+}
+
+func (e *PermissionExplanation) rowFilterFrom(role string) {
+	if e == nil {
+		return
+	}
+	e.RowFilterRole = role
+}
@@ -2,8 +2,6 @@
 package auth
 
 import (
-	"crypto/rand"
-	"encoding/base64"
 	"fmt"
 	"os"
 	"time"
@@ -13,40 +11,84 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Token types carried in the typ claim
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	// TokenTypeChallenge proves the password step of a two-factor login
+	TokenTypeChallenge = "mfa_challenge"
+	// TokenTypePasswordChange lets a user whose password expired choose a
+	// new one to finish logging in
+	TokenTypePasswordChange = "password_change"
+)
+
+// challengeTokenExpiry is how long a user has to complete a login step, such
+// as entering their second factor
+const challengeTokenExpiry = 5 * time.Minute
+
+// impersonationTokenExpiry is how long an admin may act as another user
+// before impersonating them again
+const impersonationTokenExpiry = 30 * time.Minute
+
 // Claims represents JWT claims for Genesis
 type Claims struct {
-	UserID   uuid.UUID `json:"user_id"`
-	TenantID uuid.UUID `json:"tenant_id"`
-	Email    string    `json:"email"`
-	Roles    []string  `json:"roles,omitempty"`
+	UserID    uuid.UUID `json:"user_id"`
+	TenantID  uuid.UUID `json:"tenant_id"`
+	Email     string    `json:"email"`
+	Roles     []string  `json:"roles,omitempty"`
+	TokenType string    `json:"typ"`
+	FamilyID  uuid.UUID `json:"fid"` // token family, one per login
+	// ImpersonatorID and ImpersonatorEmail name the admin acting as the user
+	// on impersonation tokens
+	ImpersonatorID    *uuid.UUID `json:"impersonator_id,omitempty"`
+	ImpersonatorEmail string     `json:"impersonator_email,omitempty"`
 	jwt.RegisteredClaims
 }
 
 // TokenPair represents access and refresh tokens
 type TokenPair struct {
-	AccessToken  string    `json:"access_token"`
-	RefreshToken string    `json:"refresh_token"`
-	ExpiresAt    time.Time `json:"expires_at"`
-	TokenType    string    `json:"token_type"`
+	AccessToken      string    `json:"access_token"`
+	RefreshToken     string    `json:"refresh_token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	TokenType        string    `json:"token_type"`
+
+	AccessTokenID  uuid.UUID `json:"-"`
+	RefreshTokenID uuid.UUID `json:"-"`
 }
 
 // JWTService handles JWT operations
 type JWTService struct {
-	secretKey          []byte
+	keys               *SigningKeyService
+	legacySecret       []byte
 	accessTokenExpiry  time.Duration
 	refreshTokenExpiry time.Duration
 	issuer             string
 }
 
-// NewJWTService creates a new JWT service
-func NewJWTService() *JWTService {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		// Generate a random secret for development
-		secret = generateRandomSecret()
-		fmt.Println("⚠️  Warning: JWT_SECRET not set, using random secret (not suitable for production)")
+// NewJWTService creates a new JWT service signing with the current key of
+// keys. When JWT_SECRET is set, HS256 tokens issued with it before signing
+// keys were introduced are still accepted until they expire, for at most the
+// refresh token lifetime after the first signing key was created.
+func NewJWTService(keys *SigningKeyService) *JWTService {
+	accessExpiry, refreshExpiry := tokenLifetimesFromEnv()
+
+	var legacySecret []byte
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		legacySecret = []byte(secret)
+	}
+
+	return &JWTService{
+		keys:               keys,
+		legacySecret:       legacySecret,
+		accessTokenExpiry:  accessExpiry,
+		refreshTokenExpiry: refreshExpiry,
+		issuer:             "genesis",
 	}
+}
 
+// tokenLifetimesFromEnv reads the access and refresh token lifetimes
+func tokenLifetimesFromEnv() (time.Duration, time.Duration) {
 	accessExpiry := 24 * time.Hour      // 24 hours default
 	refreshExpiry := 7 * 24 * time.Hour // 7 days default
 
@@ -62,79 +104,177 @@ func NewJWTService() *JWTService {
 		}
 	}
 
-	return &JWTService{
-		secretKey:          []byte(secret),
-		accessTokenExpiry:  accessExpiry,
-		refreshTokenExpiry: refreshExpiry,
-		issuer:             "genesis",
+	return accessExpiry, refreshExpiry
+}
+
+// sign signs claims with the current signing key, naming it in the kid header
+func (s *JWTService) sign(claims *Claims) (string, error) {
+	kid, method, key, err := s.keys.Signer()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	return token.SignedString(key)
+}
+
+// GenerateChallengeToken issues a short-lived token for a user who passed the
+// password step of login and still has to present a second factor
+func (s *JWTService) GenerateChallengeToken(userID, tenantID uuid.UUID, email string) (string, time.Time, error) {
+	return s.generateLoginStepToken(userID, tenantID, email, TokenTypeChallenge)
+}
+
+// GeneratePasswordChangeToken issues a short-lived token for a user who
+// passed the password step of login with an expired password
+func (s *JWTService) GeneratePasswordChangeToken(userID, tenantID uuid.UUID, email string) (string, time.Time, error) {
+	return s.generateLoginStepToken(userID, tenantID, email, TokenTypePasswordChange)
+}
+
+func (s *JWTService) generateLoginStepToken(userID, tenantID uuid.UUID, email, tokenType string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(challengeTokenExpiry)
+
+	claims := &Claims{
+		UserID:    userID,
+		TenantID:  tenantID,
+		Email:     email,
+		TokenType: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    s.issuer,
+			Subject:   userID.String(),
+			ID:        uuid.New().String(),
+		},
 	}
+
+	token, err := s.sign(claims)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign %s token: %w", tokenType, err)
+	}
+	return token, expiresAt, nil
 }
 
-// GenerateTokenPair generates access and refresh tokens
-func (s *JWTService) GenerateTokenPair(userID, tenantID uuid.UUID, email string, roles []string) (*TokenPair, error) {
+// GenerateTokenPair generates access and refresh tokens belonging to a
+// token family. Use TokenService to issue pairs that can be refreshed and
+// revoked.
+func (s *JWTService) GenerateTokenPair(userID, tenantID, familyID uuid.UUID, email string, roles []string) (*TokenPair, error) {
 	now := time.Now()
 	accessExpiresAt := now.Add(s.accessTokenExpiry)
 	refreshExpiresAt := now.Add(s.refreshTokenExpiry)
+	accessID, refreshID := uuid.New(), uuid.New()
 
 	// Create access token
 	accessClaims := &Claims{
-		UserID:   userID,
-		TenantID: tenantID,
-		Email:    email,
-		Roles:    roles,
+		UserID:    userID,
+		TenantID:  tenantID,
+		Email:     email,
+		Roles:     roles,
+		TokenType: TokenTypeAccess,
+		FamilyID:  familyID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(accessExpiresAt),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
 			Issuer:    s.issuer,
 			Subject:   userID.String(),
-			ID:        uuid.New().String(),
+			ID:        accessID.String(),
 		},
 	}
 
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessTokenString, err := accessToken.SignedString(s.secretKey)
+	accessTokenString, err := s.sign(accessClaims)
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign access token: %w", err)
 	}
 
 	// Create refresh token (minimal claims)
 	refreshClaims := &Claims{
-		UserID:   userID,
-		TenantID: tenantID,
+		UserID:    userID,
+		TenantID:  tenantID,
+		TokenType: TokenTypeRefresh,
+		FamilyID:  familyID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(refreshExpiresAt),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
 			Issuer:    s.issuer,
 			Subject:   userID.String(),
-			ID:        uuid.New().String(),
+			ID:        refreshID.String(),
 		},
 	}
 
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshTokenString, err := refreshToken.SignedString(s.secretKey)
+	refreshTokenString, err := s.sign(refreshClaims)
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign refresh token: %w", err)
 	}
 
 	return &TokenPair{
-		AccessToken:  accessTokenString,
-		RefreshToken: refreshTokenString,
-		ExpiresAt:    accessExpiresAt,
-		TokenType:    "Bearer",
+		AccessToken:      accessTokenString,
+		RefreshToken:     refreshTokenString,
+		ExpiresAt:        accessExpiresAt,
+		RefreshExpiresAt: refreshExpiresAt,
+		TokenType:        "Bearer",
+		AccessTokenID:    accessID,
+		RefreshTokenID:   refreshID,
 	}, nil
 }
 
+// GenerateImpersonationToken issues a short-lived access token that lets an
+// admin act as a user. It belongs to the impersonation session familyID and
+// has no refresh token.
+func (s *JWTService) GenerateImpersonationToken(userID, tenantID, familyID uuid.UUID, email string, roles []string, impersonatorID uuid.UUID, impersonatorEmail string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(impersonationTokenExpiry)
+
+	claims := &Claims{
+		UserID:            userID,
+		TenantID:          tenantID,
+		Email:             email,
+		Roles:             roles,
+		TokenType:         TokenTypeAccess,
+		FamilyID:          familyID,
+		ImpersonatorID:    &impersonatorID,
+		ImpersonatorEmail: impersonatorEmail,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    s.issuer,
+			Subject:   userID.String(),
+			ID:        uuid.New().String(),
+		},
+	}
+
+	token, err := s.sign(claims)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign impersonation token: %w", err)
+	}
+	return token, expiresAt, nil
+}
+
 // ValidateToken validates a JWT token and returns the claims
 func (s *JWTService) ValidateToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// Validate signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			// Tokens from before signing keys carry no kid
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok && s.acceptsLegacy(token.Claims.(*Claims)) {
+				return s.legacySecret, nil
+			}
+			return nil, fmt.Errorf("missing key id")
+		}
+
+		// The algorithm is the key's, never the one the token claims
+		method, key, err := s.keys.VerificationKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return s.secretKey, nil
-	})
+		return key, nil
+	}, jwt.WithValidMethods([]string{SigningAlgRS256, SigningAlgEdDSA, jwt.SigningMethodHS256.Alg()}))
 
 	if err != nil {
 		return nil, fmt.Errorf("invalid token: %w", err)
@@ -148,29 +288,53 @@ func (s *JWTService) ValidateToken(tokenString string) (*Claims, error) {
 	return claims, nil
 }
 
-// RefreshAccessToken generates a new access token using a refresh token
-func (s *JWTService) RefreshAccessToken(refreshTokenString string, email string, roles []string) (*TokenPair, error) {
-	claims, err := s.ValidateToken(refreshTokenString)
-	if err != nil {
-		return nil, fmt.Errorf("invalid refresh token: %w", err)
+// acceptsLegacy reports whether a token without a key id may be verified with
+// JWT_SECRET: it was issued before signing keys were introduced, and no token
+// issued then can still be unexpired
+func (s *JWTService) acceptsLegacy(claims *Claims) bool {
+	if s.legacySecret == nil || claims.IssuedAt == nil {
+		return false
 	}
+	introducedAt := s.keys.IntroducedAt()
+	return claims.IssuedAt.Before(introducedAt) && time.Now().Before(introducedAt.Add(s.refreshTokenExpiry))
+}
 
-	// Generate new token pair
-	return s.GenerateTokenPair(claims.UserID, claims.TenantID, email, roles)
+// ValidateAccessToken validates a token and checks that it is an access token
+func (s *JWTService) ValidateAccessToken(tokenString string) (*Claims, error) {
+	return s.validateType(tokenString, TokenTypeAccess)
 }
 
-// generateRandomSecret generates a random 32-byte secret
-func generateRandomSecret() string {
-	bytes := make([]byte, 32)
-	if _, err := rand.Read(bytes); err != nil {
-		return "genesis-default-secret-change-me"
+// ValidateRefreshToken validates a token and checks that it is a refresh token
+func (s *JWTService) ValidateRefreshToken(tokenString string) (*Claims, error) {
+	return s.validateType(tokenString, TokenTypeRefresh)
+}
+
+// ValidateChallengeToken validates a token and checks that it is a two-factor
+// login challenge
+func (s *JWTService) ValidateChallengeToken(tokenString string) (*Claims, error) {
+	return s.validateType(tokenString, TokenTypeChallenge)
+}
+
+// ValidatePasswordChangeToken validates a token and checks that it is a
+// forced password change
+func (s *JWTService) ValidatePasswordChangeToken(tokenString string) (*Claims, error) {
+	return s.validateType(tokenString, TokenTypePasswordChange)
+}
+
+func (s *JWTService) validateType(tokenString, tokenType string) (*Claims, error) {
+	claims, err := s.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
 	}
-	return base64.StdEncoding.EncodeToString(bytes)
+	if claims.TokenType != tokenType {
+		return nil, fmt.Errorf("invalid token: expected %s token", tokenType)
+	}
+	return claims, nil
 }
 
-// HashPassword hashes a password using bcrypt
+// HashPassword hashes a password using bcrypt at the deployment's cost
 func HashPassword(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
 	if err != nil {
 		return "", fmt.Errorf("failed to hash password: %w", err)
 	}
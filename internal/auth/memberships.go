@@ -0,0 +1,187 @@
+// Package auth - Tenant memberships
+package auth
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aethra/genesis/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RevokeReasonMembershipRemoved ends a user's sessions in a tenant they were
+// removed from
+const RevokeReasonMembershipRemoved = "membership_removed"
+
+var (
+	// ErrNotMember is returned for tenants a user does not belong to
+	ErrNotMember = errors.New("user is not a member of the tenant")
+	// ErrAlreadyMember is returned when adding an existing member
+	ErrAlreadyMember = errors.New("user is already a member of the tenant")
+	// ErrHomeTenant is returned when removing a user from the tenant their
+	// account was created in
+	ErrHomeTenant = errors.New("users cannot be removed from their own tenant")
+)
+
+// MemberTenant is a tenant a user belongs to, with their roles there
+type MemberTenant struct {
+	ID    uuid.UUID `json:"id"`
+	Code  string    `json:"code"`
+	Name  string    `json:"name"`
+	Roles []string  `json:"roles" gorm:"-"`
+	// Home marks the tenant the user's account was created in
+	Home bool `json:"home"`
+}
+
+// MembershipService manages which tenants users belong to
+type MembershipService struct {
+	db *gorm.DB
+}
+
+// NewMembershipService creates a new membership service
+func NewMembershipService(db *gorm.DB) *MembershipService {
+	return &MembershipService{db: db}
+}
+
+// RoleCodes returns the codes of a user's roles in a tenant
+func (s *MembershipService) RoleCodes(userID, tenantID uuid.UUID) []string {
+	return roleCodes(s.db, userID, tenantID)
+}
+
+// Tenants returns the active tenants a user belongs to, ordered by name
+func (s *MembershipService) Tenants(userID uuid.UUID) ([]MemberTenant, error) {
+	tenants := []MemberTenant{}
+	err := s.db.Table("tenant_memberships").
+		Select("tenants.id, tenants.code, tenants.name, tenants.id = users.tenant_id AS home").
+		Joins("JOIN tenants ON tenants.id = tenant_memberships.tenant_id").
+		Joins("JOIN users ON users.id = tenant_memberships.user_id").
+		Where("tenant_memberships.user_id = ? AND tenants.is_active = true", userID).
+		Order("tenants.name").
+		Scan(&tenants).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tenants: %w", err)
+	}
+
+	var roles []struct {
+		TenantID uuid.UUID
+		Code     string
+	}
+	err = s.db.Table("user_roles").
+		Select("roles.tenant_id, roles.code").
+		Joins("JOIN roles ON roles.id = user_roles.role_id").
+		Where("user_roles.user_id = ?", userID).
+		Order("roles.code").
+		Scan(&roles).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	byTenant := make(map[uuid.UUID][]string)
+	for _, role := range roles {
+		byTenant[role.TenantID] = append(byTenant[role.TenantID], role.Code)
+	}
+	for i := range tenants {
+		tenants[i].Roles = byTenant[tenants[i].ID]
+		if tenants[i].Roles == nil {
+			tenants[i].Roles = []string{}
+		}
+	}
+	return tenants, nil
+}
+
+// IsMember reports whether a user belongs to a tenant that is active
+func (s *MembershipService) IsMember(userID, tenantID uuid.UUID) (bool, error) {
+	var count int64
+	err := s.db.Table("tenant_memberships").
+		Joins("JOIN tenants ON tenants.id = tenant_memberships.tenant_id").
+		Where("tenant_memberships.user_id = ? AND tenant_memberships.tenant_id = ? AND tenants.is_active = true", userID, tenantID).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check membership: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Members returns the memberships of a tenant with their users, oldest first
+func (s *MembershipService) Members(tenantID uuid.UUID) ([]models.TenantMembership, error) {
+	members := []models.TenantMembership{}
+	err := s.db.Preload("User").
+		Where("tenant_id = ?", tenantID).
+		Order("created_at").
+		Find(&members).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list members: %w", err)
+	}
+	return members, nil
+}
+
+// Add makes a user a member of a tenant, without roles there
+func (s *MembershipService) Add(tenantID, userID uuid.UUID, createdBy *uuid.UUID) (*models.TenantMembership, error) {
+	membership := models.TenantMembership{
+		ID:        uuid.New(),
+		TenantID:  tenantID,
+		UserID:    userID,
+		CreatedBy: createdBy,
+	}
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&membership)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to add member: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrAlreadyMember
+	}
+	return &membership, nil
+}
+
+// Remove takes a user out of a tenant: their roles there are unassigned and
+// their sessions in it revoked
+func (s *MembershipService) Remove(tenantID, userID uuid.UUID) error {
+	var homeTenantID uuid.UUID
+	if err := s.db.Table("users").Where("id = ?", userID).Pluck("tenant_id", &homeTenantID).Error; err != nil {
+		return fmt.Errorf("failed to find user: %w", err)
+	}
+	if homeTenantID == tenantID {
+		return ErrHomeTenant
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("tenant_id = ? AND user_id = ?", tenantID, userID).Delete(&models.TenantMembership{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to remove member: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotMember
+		}
+
+		err := tx.Exec("DELETE FROM user_roles WHERE user_id = ? AND role_id IN (SELECT id FROM roles WHERE tenant_id = ?)", userID, tenantID).Error
+		if err != nil {
+			return fmt.Errorf("failed to remove member roles: %w", err)
+		}
+
+		var sessionIDs []uuid.UUID
+		err = tx.Model(&models.Session{}).
+			Where("user_id = ? AND tenant_id = ? AND revoked_at IS NULL", userID, tenantID).
+			Pluck("id", &sessionIDs).Error
+		if err != nil {
+			return fmt.Errorf("failed to find sessions: %w", err)
+		}
+		if len(sessionIDs) == 0 {
+			return nil
+		}
+		if err := revokeWhere(tx, "family_id IN ?", sessionIDs, RevokeReasonMembershipRemoved); err != nil {
+			return err
+		}
+		return revokeSessions(tx, "id IN ?", sessionIDs, RevokeReasonMembershipRemoved)
+	})
+}
+
+// roleCodes returns the codes of a user's roles in a tenant
+func roleCodes(db *gorm.DB, userID, tenantID uuid.UUID) []string {
+	var roles []string
+	db.Table("user_roles").
+		Joins("JOIN roles ON roles.id = user_roles.role_id").
+		Where("user_roles.user_id = ? AND roles.tenant_id = ?", userID, tenantID).
+		Pluck("roles.code", &roles)
+	return roles
+}
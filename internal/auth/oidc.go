@@ -0,0 +1,990 @@
+// Package auth - OpenID Connect single sign-on
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aethra/genesis/internal/crypto"
+	"github.com/aethra/genesis/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// oidcStateTTL is how long a user has to sign in at the identity provider
+	oidcStateTTL = 10 * time.Minute
+	// oidcDiscoveryTTL is how long provider metadata is cached
+	oidcDiscoveryTTL = time.Hour
+	// oidcKeyRefreshInterval limits JWKS refetches for unknown key IDs
+	oidcKeyRefreshInterval = time.Minute
+	// oidcClockSkew is the leeway allowed on ID token timestamps
+	oidcClockSkew = time.Minute
+	// oidcDefaultScopes are requested when a provider configures none
+	oidcDefaultScopes = "openid email profile"
+	// oidcMaxResponseSize bounds responses read from identity providers
+	oidcMaxResponseSize = 1 << 20
+)
+
+// idTokenMethods are the ID token signature algorithms accepted
+var idTokenMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+var (
+	// ErrOIDCNotConfigured is returned when a tenant has no enabled provider
+	ErrOIDCNotConfigured = errors.New("single sign-on is not configured for this tenant")
+	// ErrInvalidOIDCState is returned for unknown, expired or reused state
+	ErrInvalidOIDCState = errors.New("invalid or expired sign-on request")
+	// ErrOIDCLoginFailed is returned when the provider rejects the login or
+	// returns an ID token that does not verify
+	ErrOIDCLoginFailed = errors.New("single sign-on failed")
+	// ErrOIDCProviderUnavailable is returned when the provider cannot be reached
+	ErrOIDCProviderUnavailable = errors.New("identity provider is unavailable")
+	// ErrOIDCSignupDisabled is returned for unknown identities when the
+	// provider does not create accounts
+	ErrOIDCSignupDisabled = errors.New("no account exists for this identity")
+	// ErrOIDCEmailNotVerified is returned when an unverified email would link
+	// the identity to an existing account
+	ErrOIDCEmailNotVerified = errors.New("the identity provider has not verified this email address")
+)
+
+// OIDCDiscovery is the subset of provider metadata Genesis uses
+type OIDCDiscovery struct {
+	Issuer                        string   `json:"issuer"`
+	AuthorizationEndpoint         string   `json:"authorization_endpoint"`
+	TokenEndpoint                 string   `json:"token_endpoint"`
+	UserinfoEndpoint              string   `json:"userinfo_endpoint"`
+	JWKSURI                       string   `json:"jwks_uri"`
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported"`
+}
+
+// OIDCProviderInput configures a tenant's identity provider
+type OIDCProviderInput struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret *string // nil keeps the stored secret, "" clears it
+	Scopes       string
+	RedirectURL  string
+	RoleClaim    string
+	RoleMapping  map[string][]string
+	DefaultRole  string
+	AllowSignup  bool
+	IsEnabled    bool
+}
+
+// OIDCIdentity is the user asserted by a verified ID token
+type OIDCIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+	Groups        []string // values of the provider's role claim
+	Claims        map[string]interface{}
+}
+
+// OIDCLogin is the result of a completed sign-on
+type OIDCLogin struct {
+	UserID     uuid.UUID
+	TenantID   uuid.UUID
+	ProviderID uuid.UUID
+	Subject    string
+	Created    bool
+	ReturnTo   string
+}
+
+type oidcDiscoveryEntry struct {
+	doc       *OIDCDiscovery
+	fetchedAt time.Time
+}
+
+type oidcKeySet struct {
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+// OIDCService runs the authorization code flow with PKCE against tenant
+// identity providers and provisions the users they sign in
+type OIDCService struct {
+	db          *gorm.DB
+	crypto      *crypto.Service
+	callbackURL string
+	client      *http.Client
+
+	mu        sync.Mutex
+	discovery map[string]*oidcDiscoveryEntry
+	keySets   map[string]*oidcKeySet
+}
+
+// NewOIDCService creates a new OIDC service. Client secrets are encrypted with
+// cryptoService; callbackURL is the redirect URI used when a provider does not
+// configure its own.
+func NewOIDCService(db *gorm.DB, cryptoService *crypto.Service, callbackURL string) *OIDCService {
+	return &OIDCService{
+		db:          db,
+		crypto:      cryptoService,
+		callbackURL: callbackURL,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		discovery:   make(map[string]*oidcDiscoveryEntry),
+		keySets:     make(map[string]*oidcKeySet),
+	}
+}
+
+// =============================================================================
+// CONFIGURATION
+// =============================================================================
+
+// Provider returns a tenant's identity provider configuration
+func (s *OIDCService) Provider(tenantID uuid.UUID) (*models.OIDCProvider, error) {
+	var provider models.OIDCProvider
+	err := s.db.Where("tenant_id = ?", tenantID).First(&provider).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrOIDCNotConfigured
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &provider, nil
+}
+
+// SaveProvider creates or updates a tenant's identity provider. The issuer's
+// discovery document is fetched to check that it is reachable and consistent.
+func (s *OIDCService) SaveProvider(ctx context.Context, tenantID uuid.UUID, input OIDCProviderInput) (*models.OIDCProvider, error) {
+	if err := validateIssuerURL(input.Issuer); err != nil {
+		return nil, err
+	}
+	if input.RedirectURL != "" {
+		if u, err := url.Parse(input.RedirectURL); err != nil || u.Host == "" {
+			return nil, fmt.Errorf("validation: redirect_url must be an absolute URL")
+		}
+	}
+
+	roleCodes := make([]string, 0)
+	for _, roles := range input.RoleMapping {
+		roleCodes = append(roleCodes, roles...)
+	}
+	if input.DefaultRole != "" {
+		roleCodes = append(roleCodes, input.DefaultRole)
+	}
+	if err := s.checkRoles(tenantID, roleCodes); err != nil {
+		return nil, err
+	}
+
+	doc, err := s.fetchDiscovery(ctx, input.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("validation: could not load the provider's OpenID configuration: %v", err)
+	}
+	if len(doc.CodeChallengeMethodsSupported) > 0 && !containsString(doc.CodeChallengeMethodsSupported, "S256") {
+		return nil, fmt.Errorf("validation: the provider does not support PKCE with S256")
+	}
+
+	provider, err := s.Provider(tenantID)
+	isNew := err == ErrOIDCNotConfigured
+	if isNew {
+		provider = &models.OIDCProvider{ID: uuid.New(), TenantID: tenantID, CreatedAt: time.Now()}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if input.ClientSecret != nil {
+		encrypted, err := s.crypto.Encrypt(*input.ClientSecret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt client secret: %w", err)
+		}
+		provider.ClientSecret = encrypted
+	}
+
+	mapping := make(models.JSONB, len(input.RoleMapping))
+	for value, roles := range input.RoleMapping {
+		list := make([]interface{}, len(roles))
+		for i, role := range roles {
+			list[i] = role
+		}
+		mapping[value] = list
+	}
+
+	provider.Issuer = doc.Issuer
+	provider.ClientID = input.ClientID
+	provider.Scopes = normalizeScopes(input.Scopes)
+	provider.RedirectURL = input.RedirectURL
+	provider.RoleClaim = input.RoleClaim
+	provider.RoleMapping = mapping
+	provider.DefaultRole = input.DefaultRole
+	provider.AllowSignup = input.AllowSignup
+	provider.IsEnabled = input.IsEnabled
+	provider.UpdatedAt = time.Now()
+
+	save := s.db.Save
+	if isNew {
+		save = s.db.Create
+	}
+	if err := save(provider).Error; err != nil {
+		return nil, fmt.Errorf("failed to save identity provider: %w", err)
+	}
+	return provider, nil
+}
+
+// DeleteProvider removes a tenant's identity provider and the identities
+// linked through it. Users keep their accounts.
+func (s *OIDCService) DeleteProvider(tenantID uuid.UUID) error {
+	result := s.db.Where("tenant_id = ?", tenantID).Delete(&models.OIDCProvider{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOIDCNotConfigured
+	}
+	return nil
+}
+
+// OIDCRoleMapping reads a provider's role mapping. Values may be a role code
+// or a list of role codes.
+func OIDCRoleMapping(raw models.JSONB) map[string][]string {
+	mapping := make(map[string][]string, len(raw))
+	for value, roles := range raw {
+		mapping[value] = claimStrings(roles)
+	}
+	return mapping
+}
+
+// checkRoles verifies that role codes exist in a tenant
+func (s *OIDCService) checkRoles(tenantID uuid.UUID, codes []string) error {
+	if len(codes) == 0 {
+		return nil
+	}
+	var found []string
+	if err := s.db.Model(&models.Role{}).Where("tenant_id = ? AND code IN ?", tenantID, codes).Pluck("code", &found).Error; err != nil {
+		return err
+	}
+	for _, code := range codes {
+		if !containsString(found, code) {
+			return fmt.Errorf("validation: unknown role '%s'", code)
+		}
+	}
+	return nil
+}
+
+// =============================================================================
+// AUTHORIZATION CODE FLOW
+// =============================================================================
+
+// Begin starts a sign-on for a tenant and returns the provider URL to send the
+// browser to. returnTo is handed back by Complete.
+func (s *OIDCService) Begin(ctx context.Context, tenantID uuid.UUID, returnTo string) (string, error) {
+	provider, err := s.Provider(tenantID)
+	if err != nil {
+		return "", err
+	}
+	if !provider.IsEnabled {
+		return "", ErrOIDCNotConfigured
+	}
+	doc, err := s.discover(ctx, provider.Issuer)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	redirectURI := provider.RedirectURL
+	if redirectURI == "" {
+		redirectURI = s.callbackURL
+	}
+
+	// Abandoned sign-ons are cleaned up as new ones start
+	s.db.Where("expires_at < ?", time.Now()).Delete(&models.OIDCLoginState{})
+
+	record := models.OIDCLoginState{
+		ID:           uuid.New(),
+		TenantID:     tenantID,
+		ProviderID:   provider.ID,
+		StateHash:    sha256Hex(state),
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		RedirectURI:  redirectURI,
+		ReturnTo:     returnTo,
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+		CreatedAt:    time.Now(),
+	}
+	if err := s.db.Create(&record).Error; err != nil {
+		return "", fmt.Errorf("failed to store sign-on state: %w", err)
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {provider.ClientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {provider.Scopes},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {pkceChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return doc.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Complete finishes a sign-on: it consumes the state, exchanges the code,
+// verifies the ID token and provisions the user
+func (s *OIDCService) Complete(ctx context.Context, state, code string) (*OIDCLogin, error) {
+	var records []models.OIDCLoginState
+	err := s.db.Raw(`DELETE FROM oidc_login_states WHERE state_hash = ? RETURNING *`, sha256Hex(state)).
+		Scan(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 || time.Now().After(records[0].ExpiresAt) {
+		return nil, ErrInvalidOIDCState
+	}
+	record := records[0]
+
+	var provider models.OIDCProvider
+	if err := s.db.Where("id = ?", record.ProviderID).First(&provider).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrOIDCNotConfigured
+		}
+		return nil, err
+	}
+	if !provider.IsEnabled {
+		return nil, ErrOIDCNotConfigured
+	}
+
+	identity, err := s.exchange(ctx, &provider, &record, code)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, created, err := s.Provision(&provider, identity)
+	if err != nil {
+		return nil, err
+	}
+	return &OIDCLogin{
+		UserID:     userID,
+		TenantID:   provider.TenantID,
+		ProviderID: provider.ID,
+		Subject:    identity.Subject,
+		Created:    created,
+		ReturnTo:   record.ReturnTo,
+	}, nil
+}
+
+// exchange redeems an authorization code and verifies the returned ID token
+func (s *OIDCService) exchange(ctx context.Context, provider *models.OIDCProvider, record *models.OIDCLoginState, code string) (*OIDCIdentity, error) {
+	doc, err := s.discover(ctx, provider.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := s.crypto.Decrypt(provider.ClientSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt client secret: %w", err)
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {record.RedirectURI},
+		"code_verifier": {record.CodeVerifier},
+		"client_id":     {provider.ClientID},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if secret != "" {
+		// client_secret_basic: credentials are form-encoded first (RFC 6749 2.3.1)
+		req.SetBasicAuth(url.QueryEscape(provider.ClientID), url.QueryEscape(secret))
+	}
+
+	var tokens struct {
+		IDToken          string `json:"id_token"`
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := s.doJSON(req, &tokens)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK || tokens.Error != "" {
+		return nil, fmt.Errorf("%w: token request rejected: %s %s", ErrOIDCLoginFailed, tokens.Error, tokens.ErrorDescription)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("%w: no ID token returned", ErrOIDCLoginFailed)
+	}
+
+	claims, err := s.verifyIDToken(ctx, provider, doc, tokens.IDToken)
+	if err != nil {
+		return nil, err
+	}
+	if nonce, _ := claims["nonce"].(string); nonce != record.Nonce {
+		return nil, fmt.Errorf("%w: ID token nonce does not match", ErrOIDCLoginFailed)
+	}
+
+	// Some providers only put profile claims in the userinfo response
+	if _, ok := claims["email"]; !ok && doc.UserinfoEndpoint != "" && tokens.AccessToken != "" {
+		if err := s.mergeUserinfo(ctx, doc.UserinfoEndpoint, tokens.AccessToken, claims); err != nil {
+			return nil, err
+		}
+	}
+
+	return identityFromClaims(claims, provider.RoleClaim)
+}
+
+// verifyIDToken checks an ID token's signature, issuer, audience and expiry
+func (s *OIDCService) verifyIDToken(ctx context.Context, provider *models.OIDCProvider, doc *OIDCDiscovery, raw string) (map[string]interface{}, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(idTokenMethods),
+		jwt.WithIssuer(provider.Issuer),
+		jwt.WithAudience(provider.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(oidcClockSkew),
+	)
+	claims := jwt.MapClaims{}
+	_, err := parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return s.signingKey(ctx, doc.JWKSURI, kid)
+	})
+	if err != nil {
+		if errors.Is(err, ErrOIDCProviderUnavailable) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: invalid ID token: %v", ErrOIDCLoginFailed, err)
+	}
+
+	// With several audiences the token must be issued to this client
+	if aud, _ := claims.GetAudience(); len(aud) > 1 {
+		if azp, _ := claims["azp"].(string); azp != provider.ClientID {
+			return nil, fmt.Errorf("%w: ID token is not authorized for this client", ErrOIDCLoginFailed)
+		}
+	}
+	return claims, nil
+}
+
+// mergeUserinfo adds userinfo claims that the ID token does not carry
+func (s *OIDCService) mergeUserinfo(ctx context.Context, endpoint, accessToken string, claims map[string]interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	info := map[string]interface{}{}
+	status, err := s.doJSON(req, &info)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("%w: userinfo request returned %d", ErrOIDCLoginFailed, status)
+	}
+	if sub, _ := info["sub"].(string); sub != claims["sub"] {
+		return fmt.Errorf("%w: userinfo subject does not match the ID token", ErrOIDCLoginFailed)
+	}
+	for key, value := range info {
+		if _, ok := claims[key]; !ok {
+			claims[key] = value
+		}
+	}
+	return nil
+}
+
+// =============================================================================
+// PROVISIONING
+// =============================================================================
+
+// Provision returns the user for a verified identity. Known identities map to
+// their linked user; otherwise the identity is linked to the tenant account
+// with the same (verified) email, or a new account is created when the
+// provider allows sign-up. Roles named in the provider's role mapping are then
+// synced with the identity's groups; other roles are left alone.
+func (s *OIDCService) Provision(provider *models.OIDCProvider, identity *OIDCIdentity) (uuid.UUID, bool, error) {
+	var userID uuid.UUID
+	created := false
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var link models.UserIdentity
+		err := tx.Where("provider_id = ? AND subject = ?", provider.ID, identity.Subject).First(&link).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		if err == gorm.ErrRecordNotFound {
+			if identity.Email == "" {
+				return fmt.Errorf("%w: the identity provider did not return an email address", ErrOIDCLoginFailed)
+			}
+
+			var existing models.User
+			err := tx.Select("id").
+				Where("tenant_id = ? AND LOWER(email) = LOWER(?)", provider.TenantID, identity.Email).
+				First(&existing).Error
+			switch {
+			case err == nil:
+				if !identity.EmailVerified {
+					return ErrOIDCEmailNotVerified
+				}
+				userID = existing.ID
+				// The provider has verified the address for us
+				err := tx.Model(&models.User{}).
+					Where("id = ? AND email_verified_at IS NULL", userID).
+					Update("email_verified_at", time.Now()).Error
+				if err != nil {
+					return err
+				}
+			case err != gorm.ErrRecordNotFound:
+				return err
+			case !provider.AllowSignup:
+				return ErrOIDCSignupDisabled
+			default:
+				now := time.Now()
+				user := models.User{
+					ID:              uuid.New(),
+					TenantID:        provider.TenantID,
+					Email:           identity.Email,
+					FirstName:       identity.FirstName,
+					LastName:        identity.LastName,
+					Settings:        models.JSONB{},
+					IsActive:        true,
+					EmailVerifiedAt: &now,
+				}
+				if err := tx.Omit("Roles").Create(&user).Error; err != nil {
+					return fmt.Errorf("failed to create user: %w", err)
+				}
+				userID = user.ID
+				created = true
+			}
+
+			link = models.UserIdentity{
+				ID:         uuid.New(),
+				UserID:     userID,
+				ProviderID: provider.ID,
+				Subject:    identity.Subject,
+				CreatedAt:  time.Now(),
+			}
+			if err := tx.Create(&link).Error; err != nil {
+				return fmt.Errorf("failed to link identity: %w", err)
+			}
+		} else {
+			userID = link.UserID
+		}
+
+		err = tx.Model(&models.UserIdentity{}).Where("id = ?", link.ID).Updates(map[string]interface{}{
+			"email":         identity.Email,
+			"last_login_at": time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+		return syncOIDCRoles(tx, provider, userID, identity.Groups, created)
+	})
+	return userID, created, err
+}
+
+// syncOIDCRoles grants the roles mapped from a user's groups and removes
+// mapped roles the groups no longer grant. New users without a mapped role
+// get the provider's default role, or "user".
+func syncOIDCRoles(tx *gorm.DB, provider *models.OIDCProvider, userID uuid.UUID, groups []string, created bool) error {
+	mapping := OIDCRoleMapping(provider.RoleMapping)
+	managed := make(map[string]bool)
+	for _, roles := range mapping {
+		for _, role := range roles {
+			managed[role] = true
+		}
+	}
+	granted := make(map[string]bool)
+	for _, group := range groups {
+		for _, role := range mapping[group] {
+			granted[role] = true
+		}
+	}
+	if created && len(granted) == 0 {
+		role := provider.DefaultRole
+		if role == "" {
+			role = "user"
+		}
+		granted[role] = true
+	}
+
+	codes := make([]string, 0, len(managed)+len(granted))
+	for code := range managed {
+		codes = append(codes, code)
+	}
+	for code := range granted {
+		if !managed[code] {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+
+	var roles []models.Role
+	if err := tx.Where("tenant_id = ? AND code IN ?", provider.TenantID, codes).Find(&roles).Error; err != nil {
+		return err
+	}
+	for _, role := range roles {
+		var err error
+		if granted[role.Code] {
+			err = tx.Exec(`INSERT INTO user_roles (id, user_id, role_id) VALUES (?, ?, ?)
+				ON CONFLICT (user_id, role_id) DO NOTHING`, uuid.New(), userID, role.ID).Error
+		} else {
+			err = tx.Exec("DELETE FROM user_roles WHERE user_id = ? AND role_id = ?", userID, role.ID).Error
+		}
+		if err != nil {
+			return fmt.Errorf("failed to sync roles: %w", err)
+		}
+	}
+	return nil
+}
+
+// identityFromClaims reads the standard claims of a verified ID token
+func identityFromClaims(claims map[string]interface{}, roleClaim string) (*OIDCIdentity, error) {
+	identity := &OIDCIdentity{Claims: claims}
+	identity.Subject, _ = claims["sub"].(string)
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%w: ID token has no subject", ErrOIDCLoginFailed)
+	}
+	identity.Email, _ = claims["email"].(string)
+	identity.Email = strings.TrimSpace(identity.Email)
+
+	// Some providers send email_verified as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+
+	identity.FirstName, _ = claims["given_name"].(string)
+	identity.LastName, _ = claims["family_name"].(string)
+	if identity.FirstName == "" && identity.LastName == "" {
+		name, _ := claims["name"].(string)
+		first, last, _ := strings.Cut(strings.TrimSpace(name), " ")
+		identity.FirstName, identity.LastName = first, last
+	}
+
+	if roleClaim != "" {
+		identity.Groups = claimStrings(claimValue(claims, roleClaim))
+	}
+	return identity, nil
+}
+
+// claimValue looks up a claim, following dots into nested objects when no
+// claim has the full name (e.g. "realm_access.roles")
+func claimValue(claims map[string]interface{}, path string) interface{} {
+	if value, ok := claims[path]; ok {
+		return value
+	}
+	var current interface{} = claims
+	for _, part := range strings.Split(path, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = object[part]
+	}
+	return current
+}
+
+// claimStrings reads a claim holding a string or a list of strings
+func claimStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// =============================================================================
+// PROVIDER METADATA AND KEYS
+// =============================================================================
+
+// discover returns cached provider metadata, fetching it when stale
+func (s *OIDCService) discover(ctx context.Context, issuer string) (*OIDCDiscovery, error) {
+	s.mu.Lock()
+	entry, ok := s.discovery[issuer]
+	s.mu.Unlock()
+	if ok && time.Since(entry.fetchedAt) < oidcDiscoveryTTL {
+		return entry.doc, nil
+	}
+
+	return s.fetchDiscovery(ctx, issuer)
+}
+
+// fetchDiscovery loads and caches an issuer's discovery document
+func (s *OIDCService) fetchDiscovery(ctx context.Context, issuer string) (*OIDCDiscovery, error) {
+	endpoint := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	var doc OIDCDiscovery
+	status, err := s.doJSON(req, &doc)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%w: discovery returned %d", ErrOIDCProviderUnavailable, status)
+	}
+	if doc.Issuer != issuer {
+		return nil, fmt.Errorf("%w: discovery issuer '%s' does not match '%s'", ErrOIDCProviderUnavailable, doc.Issuer, issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: discovery document is missing endpoints", ErrOIDCProviderUnavailable)
+	}
+
+	s.mu.Lock()
+	s.discovery[issuer] = &oidcDiscoveryEntry{doc: &doc, fetchedAt: time.Now()}
+	s.mu.Unlock()
+	return &doc, nil
+}
+
+// signingKey returns the provider key for a key ID, refetching the key set
+// once when the ID is unknown so rotated keys are picked up
+func (s *OIDCService) signingKey(ctx context.Context, jwksURI, kid string) (interface{}, error) {
+	s.mu.Lock()
+	set, ok := s.keySets[jwksURI]
+	s.mu.Unlock()
+
+	if ok {
+		if key := set.lookup(kid); key != nil {
+			return key, nil
+		}
+		if time.Since(set.fetchedAt) < oidcKeyRefreshInterval {
+			return nil, fmt.Errorf("unknown signing key '%s'", kid)
+		}
+	}
+
+	set, err := s.fetchKeySet(ctx, jwksURI)
+	if err != nil {
+		return nil, err
+	}
+	if key := set.lookup(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key '%s'", kid)
+}
+
+// lookup finds a key by ID. Tokens without a key ID are accepted when the
+// set holds a single key.
+func (set *oidcKeySet) lookup(kid string) interface{} {
+	if key, ok := set.keys[kid]; ok {
+		return key
+	}
+	if kid == "" && len(set.keys) == 1 {
+		for _, key := range set.keys {
+			return key
+		}
+	}
+	return nil
+}
+
+// fetchKeySet loads and caches a provider's JSON Web Key Set
+func (s *OIDCService) fetchKeySet(ctx context.Context, jwksURI string) (*oidcKeySet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	var doc JWKSet
+	status, err := s.doJSON(req, &doc)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%w: key set returned %d", ErrOIDCProviderUnavailable, status)
+	}
+
+	set := &oidcKeySet{keys: make(map[string]interface{}), fetchedAt: time.Now()}
+	for _, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// Keys of unsupported types are skipped rather than failing the set
+		if key, err := jwk.publicKey(); err == nil {
+			set.keys[jwk.KID] = key
+		}
+	}
+
+	s.mu.Lock()
+	s.keySets[jwksURI] = set
+	s.mu.Unlock()
+	return set, nil
+}
+
+// doJSON sends a request and decodes a JSON response of any status
+func (s *OIDCService) doJSON(req *http.Request, target interface{}) (int, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrOIDCProviderUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, oidcMaxResponseSize))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrOIDCProviderUnavailable, err)
+	}
+	if err := json.Unmarshal(body, target); err != nil && resp.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("%w: invalid response from %s", ErrOIDCProviderUnavailable, req.URL.Host)
+	}
+	return resp.StatusCode, nil
+}
+
+// JWKSet is a JSON Web Key Set document (RFC 7517)
+type JWKSet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// jsonWebKey is a public key from a JWKS document (RFC 7517)
+type jsonWebKey struct {
+	KID string `json:"kid"`
+	KTY string `json:"kty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	CRV string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// publicKey converts an RSA, EC or Ed25519 key to its crypto type
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch k.KTY {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.CRV {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve '%s'", k.CRV)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if k.CRV != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve '%s'", k.CRV)
+		}
+		x, err := decode(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type '%s'", k.KTY)
+}
+
+// =============================================================================
+// HELPERS
+// =============================================================================
+
+// pkceChallenge derives the S256 code challenge for a verifier (RFC 7636)
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// validateIssuerURL requires https, allowing plain http only for local
+// development providers
+func validateIssuerURL(issuer string) error {
+	u, err := url.Parse(issuer)
+	if err != nil || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("validation: issuer must be an absolute URL")
+	}
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		host := u.Hostname()
+		if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+			return nil
+		}
+	}
+	return fmt.Errorf("validation: issuer must use https")
+}
+
+// normalizeScopes makes sure the openid scope is requested
+func normalizeScopes(scopes string) string {
+	fields := strings.Fields(scopes)
+	if len(fields) == 0 {
+		return oidcDefaultScopes
+	}
+	if !containsString(fields, "openid") {
+		fields = append([]string{"openid"}, fields...)
+	}
+	return strings.Join(fields, " ")
+}
+
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,342 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aethra/genesis/internal/auth/oidctest"
+	"github.com/aethra/genesis/internal/crypto"
+	"github.com/aethra/genesis/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	testClientID    = "genesis"
+	testCallbackURL = "http://genesis.test/api/auth/oidc/callback"
+)
+
+// testOIDC starts a mock identity provider signing in user and returns it
+// with a provider configuration for it and a service using db, which may be
+// nil for tests that do not store state
+func testOIDC(t *testing.T, db *gorm.DB, user oidctest.User) (*oidctest.Provider, *models.OIDCProvider, *OIDCService) {
+	t.Helper()
+	idp, server, err := oidctest.NewServer(testClientID, "client-secret", user)
+	if err != nil {
+		t.Fatalf("oidctest.NewServer: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	cryptoService := crypto.NewService("genesis-test-encryption-key-0001")
+	secret, err := cryptoService.Encrypt("client-secret")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	provider := &models.OIDCProvider{
+		ID:           uuid.New(),
+		TenantID:     uuid.New(),
+		Issuer:       idp.Issuer,
+		ClientID:     testClientID,
+		ClientSecret: secret,
+		Scopes:       oidcDefaultScopes,
+		RoleClaim:    "groups",
+		RoleMapping: models.JSONB{
+			"sales-team": []interface{}{"sales"},
+			"admins":     []interface{}{"manager", "sales"},
+		},
+		AllowSignup: true,
+		IsEnabled:   true,
+	}
+	return idp, provider, NewOIDCService(db, cryptoService, testCallbackURL)
+}
+
+// signIn follows an authorization URL to the mock provider and returns the
+// parameters it redirects back to the callback with
+func signIn(t *testing.T, authURL string) url.Values {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorization request: %v", err)
+	}
+	resp.Body.Close()
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if resp.StatusCode != http.StatusFound || err != nil {
+		t.Fatalf("authorization request: status %d, location %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	return location.Query()
+}
+
+// authorizationURL builds the authorization request Begin sends for state
+func authorizationURL(idp *oidctest.Provider, state *models.OIDCLoginState, challenge string) string {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {testClientID},
+		"redirect_uri":          {state.RedirectURI},
+		"scope":                 {oidcDefaultScopes},
+		"state":                 {"state"},
+		"nonce":                 {state.Nonce},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+	return idp.Issuer + "/authorize?" + query.Encode()
+}
+
+func TestOIDCExchange(t *testing.T) {
+	user := oidctest.User{Subject: "u-1", Email: "ada@example.com", EmailVerified: true, GivenName: "Ada", FamilyName: "Lovelace", Groups: []string{"sales-team"}}
+	idp, provider, service := testOIDC(t, nil, user)
+	ctx := context.Background()
+
+	newState := func() *models.OIDCLoginState {
+		return &models.OIDCLoginState{CodeVerifier: uuid.NewString() + uuid.NewString(), Nonce: uuid.NewString(), RedirectURI: testCallbackURL}
+	}
+
+	t.Run("verified identity", func(t *testing.T) {
+		state := newState()
+		code := signIn(t, authorizationURL(idp, state, pkceChallenge(state.CodeVerifier))).Get("code")
+		identity, err := service.exchange(ctx, provider, state, code)
+		if err != nil {
+			t.Fatalf("exchange: %v", err)
+		}
+		if identity.Subject != "u-1" || identity.Email != "ada@example.com" || !identity.EmailVerified {
+			t.Errorf("identity = %+v", identity)
+		}
+		if identity.FirstName != "Ada" || identity.LastName != "Lovelace" {
+			t.Errorf("name = %q %q", identity.FirstName, identity.LastName)
+		}
+		if len(identity.Groups) != 1 || identity.Groups[0] != "sales-team" {
+			t.Errorf("groups = %v, want the role claim", identity.Groups)
+		}
+
+		// Codes are single use
+		if _, err := service.exchange(ctx, provider, state, code); !errors.Is(err, ErrOIDCLoginFailed) {
+			t.Errorf("redeemed code again: error = %v, want %v", err, ErrOIDCLoginFailed)
+		}
+	})
+
+	t.Run("PKCE verifier mismatch", func(t *testing.T) {
+		state := newState()
+		code := signIn(t, authorizationURL(idp, state, pkceChallenge(state.CodeVerifier))).Get("code")
+		state.CodeVerifier = "another-verifier-" + uuid.NewString()
+		if _, err := service.exchange(ctx, provider, state, code); !errors.Is(err, ErrOIDCLoginFailed) {
+			t.Errorf("error = %v, want %v", err, ErrOIDCLoginFailed)
+		}
+	})
+
+	t.Run("nonce mismatch", func(t *testing.T) {
+		state := newState()
+		code := signIn(t, authorizationURL(idp, state, pkceChallenge(state.CodeVerifier))).Get("code")
+		state.Nonce = uuid.NewString()
+		if _, err := service.exchange(ctx, provider, state, code); !errors.Is(err, ErrOIDCLoginFailed) {
+			t.Errorf("error = %v, want %v", err, ErrOIDCLoginFailed)
+		}
+	})
+
+	t.Run("redirect URI mismatch", func(t *testing.T) {
+		state := newState()
+		code := signIn(t, authorizationURL(idp, state, pkceChallenge(state.CodeVerifier))).Get("code")
+		state.RedirectURI = "http://attacker.test/callback"
+		if _, err := service.exchange(ctx, provider, state, code); !errors.Is(err, ErrOIDCLoginFailed) {
+			t.Errorf("error = %v, want %v", err, ErrOIDCLoginFailed)
+		}
+	})
+}
+
+func TestOIDCVerifyIDToken(t *testing.T) {
+	user := oidctest.User{Subject: "u-1", Email: "ada@example.com"}
+	idp, provider, service := testOIDC(t, nil, user)
+	ctx := context.Background()
+	doc, err := service.discover(ctx, provider.Issuer)
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+
+	valid, err := idp.IDToken(user, "n")
+	if err != nil {
+		t.Fatalf("IDToken: %v", err)
+	}
+	if _, err := service.verifyIDToken(ctx, provider, doc, valid); err != nil {
+		t.Errorf("valid token rejected: %v", err)
+	}
+
+	// A token from another key, client or issuer must not verify
+	impostor, err := oidctest.New(idp.Issuer, testClientID, "", user)
+	if err != nil {
+		t.Fatalf("oidctest.New: %v", err)
+	}
+	otherClient, _ := oidctest.New(idp.Issuer, "other-client", "", user)
+	otherIssuer, _ := oidctest.New("https://issuer.example.com", testClientID, "", user)
+	for name, signer := range map[string]*oidctest.Provider{"other key": impostor, "other client": otherClient, "other issuer": otherIssuer} {
+		raw, err := signer.IDToken(user, "n")
+		if err != nil {
+			t.Fatalf("IDToken: %v", err)
+		}
+		if _, err := service.verifyIDToken(ctx, provider, doc, raw); !errors.Is(err, ErrOIDCLoginFailed) {
+			t.Errorf("%s: error = %v, want %v", name, err, ErrOIDCLoginFailed)
+		}
+	}
+}
+
+func TestIdentityFromClaims(t *testing.T) {
+	identity, err := identityFromClaims(map[string]interface{}{
+		"sub":            "u-1",
+		"email":          " ada@example.com ",
+		"email_verified": "true",
+		"name":           "Ada King Lovelace",
+		"realm_access":   map[string]interface{}{"roles": []interface{}{"admins", 7, "sales-team"}},
+	}, "realm_access.roles")
+	if err != nil {
+		t.Fatalf("identityFromClaims: %v", err)
+	}
+	if identity.Email != "ada@example.com" || !identity.EmailVerified {
+		t.Errorf("email = %q verified %v", identity.Email, identity.EmailVerified)
+	}
+	if identity.FirstName != "Ada" || identity.LastName != "King Lovelace" {
+		t.Errorf("name = %q %q", identity.FirstName, identity.LastName)
+	}
+	if len(identity.Groups) != 2 || identity.Groups[0] != "admins" || identity.Groups[1] != "sales-team" {
+		t.Errorf("groups = %v", identity.Groups)
+	}
+
+	if _, err := identityFromClaims(map[string]interface{}{"email": "ada@example.com"}, ""); !errors.Is(err, ErrOIDCLoginFailed) {
+		t.Errorf("no subject: error = %v, want %v", err, ErrOIDCLoginFailed)
+	}
+}
+
+// storeOIDCProvider saves a provider configuration in a new tenant holding
+// the roles its mapping names
+func storeOIDCProvider(t *testing.T, db *gorm.DB, provider *models.OIDCProvider) {
+	t.Helper()
+	provider.TenantID, _ = testTenant(t, db, "user", "sales", "manager", "support")
+	provider.DefaultRole = "support"
+	if err := db.Create(provider).Error; err != nil {
+		t.Fatalf("failed to store provider: %v", err)
+	}
+}
+
+func TestOIDCSignOn(t *testing.T) {
+	db := testDB(t)
+	user := oidctest.User{Subject: "u-1", Email: "jit@example.com", EmailVerified: true, GivenName: "Grace", Groups: []string{"sales-team"}}
+	_, provider, service := testOIDC(t, db, user)
+	storeOIDCProvider(t, db, provider)
+	ctx := context.Background()
+
+	authURL, err := service.Begin(ctx, provider.TenantID, "/app")
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	request, _ := url.Parse(authURL)
+	if request.Query().Get("code_challenge_method") != "S256" || request.Query().Get("nonce") == "" {
+		t.Errorf("authorization request lacks PKCE or a nonce: %s", authURL)
+	}
+	callback := signIn(t, authURL)
+
+	login, err := service.Complete(ctx, callback.Get("state"), callback.Get("code"))
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if !login.Created || login.TenantID != provider.TenantID || login.ReturnTo != "/app" {
+		t.Errorf("login = %+v, want a new user in the provider's tenant", login)
+	}
+	var created models.User
+	if err := db.Where("id = ?", login.UserID).First(&created).Error; err != nil {
+		t.Fatalf("provisioned user not found: %v", err)
+	}
+	if created.Email != "jit@example.com" || created.FirstName != "Grace" || created.EmailVerifiedAt == nil {
+		t.Errorf("provisioned user = %+v", created)
+	}
+	if roles := userRoleCodes(t, db, login.UserID); len(roles) != 1 || roles[0] != "sales" {
+		t.Errorf("roles = %v, want the mapped role only", roles)
+	}
+
+	// State is single use, and unknown state is refused
+	if _, err := service.Complete(ctx, callback.Get("state"), callback.Get("code")); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Errorf("reused state: error = %v, want %v", err, ErrInvalidOIDCState)
+	}
+	if _, err := service.Complete(ctx, "forged", callback.Get("code")); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Errorf("unknown state: error = %v, want %v", err, ErrInvalidOIDCState)
+	}
+
+	// Expired state is refused even when the code is fresh
+	callback = signIn(t, mustBegin(t, service, provider.TenantID))
+	db.Model(&models.OIDCLoginState{}).Where("state_hash = ?", sha256Hex(callback.Get("state"))).
+		Update("expires_at", time.Now().Add(-time.Second))
+	if _, err := service.Complete(ctx, callback.Get("state"), callback.Get("code")); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Errorf("expired state: error = %v, want %v", err, ErrInvalidOIDCState)
+	}
+
+	// The next login syncs mapped roles with the groups and keeps the user
+	userID, createdAgain, err := service.Provision(provider, &OIDCIdentity{Subject: user.Subject, Email: user.Email, EmailVerified: true, Groups: []string{"admins"}})
+	if err != nil {
+		t.Fatalf("Provision: %v", err)
+	}
+	if createdAgain || userID != created.ID {
+		t.Errorf("second login: user %s created %v, want the linked user", userID, createdAgain)
+	}
+	if roles := userRoleCodes(t, db, userID); len(roles) != 2 || roles[0] != "manager" || roles[1] != "sales" {
+		t.Errorf("roles = %v, want [manager sales]", roles)
+	}
+}
+
+// mustBegin starts a sign-on and returns the authorization URL
+func mustBegin(t *testing.T, service *OIDCService, tenantID uuid.UUID) string {
+	t.Helper()
+	authURL, err := service.Begin(context.Background(), tenantID, "")
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	return authURL
+}
+
+func TestOIDCProvision(t *testing.T) {
+	db := testDB(t)
+	_, provider, service := testOIDC(t, db, oidctest.User{})
+	storeOIDCProvider(t, db, provider)
+
+	t.Run("default role for unmapped groups", func(t *testing.T) {
+		userID, created, err := service.Provision(provider, &OIDCIdentity{Subject: "u-default", Email: "default@example.com", EmailVerified: true, Groups: []string{"unknown"}})
+		if err != nil || !created {
+			t.Fatalf("Provision: created = %v, error = %v", created, err)
+		}
+		if roles := userRoleCodes(t, db, userID); len(roles) != 1 || roles[0] != "support" {
+			t.Errorf("roles = %v, want the default role", roles)
+		}
+	})
+
+	t.Run("unmanaged roles are kept", func(t *testing.T) {
+		userID, _, err := service.Provision(provider, &OIDCIdentity{Subject: "u-kept", Email: "kept@example.com", EmailVerified: true, Groups: []string{"sales-team"}})
+		if err != nil {
+			t.Fatalf("Provision: %v", err)
+		}
+		db.Exec("INSERT INTO user_roles (id, user_id, role_id) SELECT ?, ?, id FROM roles WHERE tenant_id = ? AND code = 'user'", uuid.New(), userID, provider.TenantID)
+		if _, _, err := service.Provision(provider, &OIDCIdentity{Subject: "u-kept", Email: "kept@example.com", EmailVerified: true}); err != nil {
+			t.Fatalf("Provision: %v", err)
+		}
+		if roles := userRoleCodes(t, db, userID); len(roles) != 1 || roles[0] != "user" {
+			t.Errorf("roles = %v, want only the unmanaged role", roles)
+		}
+	})
+
+	t.Run("existing account needs a verified email", func(t *testing.T) {
+		testUser(t, db, provider.TenantID, "existing@example.com")
+		_, _, err := service.Provision(provider, &OIDCIdentity{Subject: "u-existing", Email: "Existing@example.com"})
+		if !errors.Is(err, ErrOIDCEmailNotVerified) {
+			t.Errorf("error = %v, want %v", err, ErrOIDCEmailNotVerified)
+		}
+	})
+
+	t.Run("sign-up disabled", func(t *testing.T) {
+		closed := *provider
+		closed.AllowSignup = false
+		_, _, err := service.Provision(&closed, &OIDCIdentity{Subject: "u-new", Email: "new@example.com", EmailVerified: true})
+		if !errors.Is(err, ErrOIDCSignupDisabled) {
+			t.Errorf("error = %v, want %v", err, ErrOIDCSignupDisabled)
+		}
+	})
+}
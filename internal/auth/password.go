@@ -0,0 +1,238 @@
+// Package auth - Password policy and history
+package auth
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aethra/genesis/internal/errors"
+	"github.com/aethra/genesis/internal/models"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxPasswordBytes is where bcrypt stops reading a password
+	maxPasswordBytes = 72
+	// maxPasswordHistory is how many previous passwords are kept per user,
+	// so raising a tenant's history_count takes effect immediately
+	maxPasswordHistory = 24
+)
+
+//go:embed breached_passwords.txt
+var breachedPasswordList string
+
+var (
+	breachedPasswords = parsePasswordList(breachedPasswordList)
+	passwordCost      = bcryptCostFromEnv()
+)
+
+// bcryptCostFromEnv reads the deployment's hashing cost from BCRYPT_COST
+func bcryptCostFromEnv() int {
+	value := os.Getenv("BCRYPT_COST")
+	if value == "" {
+		return bcrypt.DefaultCost
+	}
+	cost, err := strconv.Atoi(value)
+	if err != nil || cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		fmt.Printf("⚠️  Warning: BCRYPT_COST must be between %d and %d, using %d\n", bcrypt.MinCost, bcrypt.MaxCost, bcrypt.DefaultCost)
+		return bcrypt.DefaultCost
+	}
+	return cost
+}
+
+func parsePasswordList(list string) map[string]bool {
+	passwords := make(map[string]bool)
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			passwords[strings.ToLower(line)] = true
+		}
+	}
+	return passwords
+}
+
+// IsBreachedPassword reports whether a password is on the bundled list of
+// breached and common passwords
+func IsBreachedPassword(password string) bool {
+	return breachedPasswords[strings.ToLower(password)]
+}
+
+// PasswordNeedsRehash reports whether a hash was made at another cost than
+// the deployment's, so it can be upgraded at the next login
+func PasswordNeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err == nil && cost != passwordCost
+}
+
+// DefaultPasswordPolicy is the policy of tenants that have not set one
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{}.WithDefaults()
+}
+
+// Expired reports whether a password changed at changedAt is past the
+// policy's maximum age
+func (p PasswordPolicy) Expired(changedAt *time.Time) bool {
+	if p.MaxAgeDays <= 0 || changedAt == nil {
+		return false
+	}
+	return time.Since(*changedAt) > time.Duration(p.MaxAgeDays)*24*time.Hour
+}
+
+// Check returns every rule of the policy a password breaks, reported against
+// field. Reuse is checked separately as it needs the user's history.
+func (p PasswordPolicy) Check(field, password string) errors.ValidationErrors {
+	var errs errors.ValidationErrors
+	if utf8.RuneCountInString(password) < p.MinLength {
+		errs.Add(field, "PASSWORD_TOO_SHORT", fmt.Sprintf("password must be at least %d characters", p.MinLength))
+	}
+	if len(password) > maxPasswordBytes {
+		errs.Add(field, "PASSWORD_TOO_LONG", fmt.Sprintf("password must be at most %d bytes", maxPasswordBytes))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		errs.Add(field, "PASSWORD_MISSING_UPPER", "password must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		errs.Add(field, "PASSWORD_MISSING_LOWER", "password must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		errs.Add(field, "PASSWORD_MISSING_DIGIT", "password must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		errs.Add(field, "PASSWORD_MISSING_SYMBOL", "password must contain a symbol")
+	}
+
+	if !p.AllowBreached && IsBreachedPassword(password) {
+		errs.Add(field, "PASSWORD_BREACHED", "password is too common or has appeared in a data breach")
+	}
+	return errs
+}
+
+// PasswordService applies tenant password policies when passwords are set
+type PasswordService struct {
+	db *gorm.DB
+}
+
+// NewPasswordService creates a new password service
+func NewPasswordService(db *gorm.DB) *PasswordService {
+	return &PasswordService{db: db}
+}
+
+// Policy returns a tenant's password policy
+func (s *PasswordService) Policy(tenantID uuid.UUID) PasswordPolicy {
+	var tenant models.Tenant
+	if err := s.db.Select("settings").Where("id = ?", tenantID).First(&tenant).Error; err != nil {
+		return DefaultPasswordPolicy()
+	}
+	return TenantSecuritySettings(tenant.Settings).PasswordPolicy.WithDefaults()
+}
+
+// HashNew checks the password of a new account against the tenant's policy
+// and hashes it. Policy violations are returned as errors.ValidationErrors.
+func (s *PasswordService) HashNew(tenantID uuid.UUID, field, password string) (string, error) {
+	if err := s.Policy(tenantID).Check(field, password).Err(); err != nil {
+		return "", err
+	}
+	return HashPassword(password)
+}
+
+// SetPassword checks a user's new password against the tenant's policy,
+// including reuse of the current and previous passwords, then stores it.
+// The replaced hash moves to the history. Policy violations are returned as
+// errors.ValidationErrors.
+func (s *PasswordService) SetPassword(tx *gorm.DB, userID uuid.UUID, field, password string) error {
+	var user models.User
+	if err := tx.Select("id, tenant_id, password_hash").Where("id = ?", userID).First(&user).Error; err != nil {
+		return err
+	}
+
+	policy := s.Policy(user.TenantID)
+	if err := policy.Check(field, password).Err(); err != nil {
+		return err
+	}
+
+	// The current password is always rejected, so a forced change cannot
+	// keep it
+	previous := []string{user.PasswordHash}
+	if policy.HistoryCount > 1 {
+		var history []string
+		err := tx.Model(&models.PasswordHistory{}).
+			Where("user_id = ?", userID).
+			Order("created_at DESC").
+			Limit(policy.HistoryCount-1).
+			Pluck("password_hash", &history).Error
+		if err != nil {
+			return err
+		}
+		previous = append(previous, history...)
+	}
+	for _, hash := range previous {
+		if hash != "" && CheckPassword(password, hash) {
+			var errs errors.ValidationErrors
+			if policy.HistoryCount > 1 {
+				errs.Add(field, "PASSWORD_REUSED", fmt.Sprintf("password must differ from your last %d passwords", policy.HistoryCount))
+			} else {
+				errs.Add(field, "PASSWORD_REUSED", "password must differ from your current password")
+			}
+			return errs
+		}
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"password_hash":       hash,
+		"password_changed_at": now,
+	}).Error
+	if err != nil {
+		return err
+	}
+	return s.recordHistory(tx, user, now)
+}
+
+// recordHistory keeps the replaced hash and drops entries beyond the limit
+func (s *PasswordService) recordHistory(tx *gorm.DB, user models.User, now time.Time) error {
+	if user.PasswordHash == "" {
+		return nil
+	}
+	err := tx.Create(&models.PasswordHistory{
+		ID:           uuid.New(),
+		TenantID:     user.TenantID,
+		UserID:       user.ID,
+		PasswordHash: user.PasswordHash,
+		CreatedAt:    now,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to record password history: %w", err)
+	}
+	return tx.Exec(`
+		DELETE FROM password_history WHERE user_id = ? AND id NOT IN (
+			SELECT id FROM password_history WHERE user_id = ? ORDER BY created_at DESC LIMIT ?
+		)
+	`, user.ID, user.ID, maxPasswordHistory).Error
+}
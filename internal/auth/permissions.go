@@ -2,7 +2,12 @@
 package auth
 
 import (
-	"log"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/aethra/genesis/internal/models"
 	"github.com/google/uuid"
@@ -21,6 +26,31 @@ const (
 	ActionImport Action = "import"
 )
 
+// Permission effects
+const (
+	// EffectAllow grants an entry's actions
+	EffectAllow = "allow"
+	// EffectDeny takes an entry's actions away, whatever other entries grant
+	EffectDeny = "deny"
+)
+
+// Actions lists every action, in the order permission matrices show them
+var Actions = []Action{ActionView, ActionCreate, ActionEdit, ActionDelete, ActionExport, ActionImport}
+
+// Ownership scopes, which limit an action to some records by their owner_id
+const (
+	// ScopeAll applies an action to every record
+	ScopeAll = "all"
+	// ScopeOwn applies an action to the records the user owns
+	ScopeOwn = "own"
+	// ScopeTeam applies an action to the records owned by the user or by
+	// anyone holding the role the permission belongs to
+	ScopeTeam = "team"
+)
+
+// ScopedActions are the actions an ownership scope can limit
+var ScopedActions = []Action{ActionView, ActionEdit, ActionDelete}
+
 // Permission represents a permission entry
 type Permission struct {
 	ID               uuid.UUID
@@ -37,26 +67,87 @@ type Permission struct {
 	RowFilter        map[string]interface{} `gorm:"type:jsonb"`
 }
 
-// PermissionService handles permission checks
+const (
+	// defaultPermissionCacheTTL bounds how long a resolved permission is
+	// reused. Invalidation only reaches the instance that made the change, so
+	// this is also how stale other instances can be.
+	defaultPermissionCacheTTL = time.Minute
+	// maxCachedPermissions bounds the cache; expired entries are swept, and
+	// the cache emptied, when it grows past this
+	maxCachedPermissions = 10000
+)
+
+// PermissionService handles permission checks. Resolved permissions are
+// cached per user and entity; call InvalidateUser or InvalidateTenant after
+// changing roles, permissions or role assignments.
 type PermissionService struct {
-	db *gorm.DB
+	db  *gorm.DB
+	ttl time.Duration
+
+	mu sync.RWMutex
+	// generation counts invalidations, so a resolution that raced one is
+	// not cached
+	generation uint64
+	cache      map[permissionCacheKey]cachedPermission
 }
 
-// NewPermissionService creates a new permission service
+type permissionCacheKey struct {
+	tenantID, userID uuid.UUID
+	entityCode       string
+}
+
+type cachedPermission struct {
+	perm    *UserPermission
+	expires time.Time
+}
+
+// NewPermissionService creates a new permission service. PERMISSION_CACHE_TTL
+// sets how many seconds resolved permissions are cached; 0 disables caching.
 func NewPermissionService(db *gorm.DB) *PermissionService {
-	return &PermissionService{db: db}
+	return &PermissionService{
+		db:    db,
+		ttl:   permissionCacheTTLFromEnv(),
+		cache: make(map[permissionCacheKey]cachedPermission),
+	}
 }
 
-// UserPermission represents computed permissions for a user on an entity
+// permissionCacheTTLFromEnv reads the permission cache lifetime from
+// PERMISSION_CACHE_TTL
+func permissionCacheTTLFromEnv() time.Duration {
+	value := os.Getenv("PERMISSION_CACHE_TTL")
+	if value == "" {
+		return defaultPermissionCacheTTL
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		fmt.Printf("⚠️  Warning: PERMISSION_CACHE_TTL must be a number of seconds, using %s\n", defaultPermissionCacheTTL)
+		return defaultPermissionCacheTTL
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// UserPermission represents computed permissions for a user on an entity.
+// Values returned by PermissionService are shared through its cache and
+// must not be modified.
 type UserPermission struct {
-	CanView          bool
-	CanCreate        bool
-	CanEdit          bool
-	CanDelete        bool
-	CanExport        bool
-	CanImport        bool
-	FieldPermissions map[string]FieldPermission
-	RowFilter        map[string]interface{}
+	CanView          bool                       `json:"can_view"`
+	CanCreate        bool                       `json:"can_create"`
+	CanEdit          bool                       `json:"can_edit"`
+	CanDelete        bool                       `json:"can_delete"`
+	CanExport        bool                       `json:"can_export"`
+	CanImport        bool                       `json:"can_import"`
+	FieldPermissions map[string]FieldPermission `json:"field_permissions"`
+	RowFilter        map[string]interface{}     `json:"row_filter"`
+	// Ownership limits scoped actions to owned records. Allowed actions
+	// without an entry apply to every record.
+	Ownership map[Action]OwnershipScope `json:"ownership,omitempty"`
+}
+
+// OwnershipScope limits an action to the records the user owns or, for the
+// team scope, that holders of TeamRoles own
+type OwnershipScope struct {
+	Scope     string      `json:"scope"`
+	TeamRoles []uuid.UUID `json:"team_roles,omitempty"`
 }
 
 // FieldPermission represents permissions for a specific field
@@ -65,122 +156,470 @@ type FieldPermission struct {
 	CanEdit bool `json:"edit"`
 }
 
-// CheckPermission checks if a user has permission for an action on an entity
-func (s *PermissionService) CheckPermission(tenantID, userID uuid.UUID, entityCode string, action Action) (bool, error) {
-	perm, err := s.GetUserPermission(tenantID, userID, entityCode)
-	if err != nil {
-		return false, err
-	}
-
+// Allows reports whether the permission grants an action
+func (p *UserPermission) Allows(action Action) bool {
 	switch action {
 	case ActionView:
-		return perm.CanView, nil
+		return p.CanView
 	case ActionCreate:
-		return perm.CanCreate, nil
+		return p.CanCreate
 	case ActionEdit:
-		return perm.CanEdit, nil
+		return p.CanEdit
 	case ActionDelete:
-		return perm.CanDelete, nil
+		return p.CanDelete
 	case ActionExport:
-		return perm.CanExport, nil
+		return p.CanExport
 	case ActionImport:
-		return perm.CanImport, nil
+		return p.CanImport
 	default:
-		return false, nil
+		return false
+	}
+}
+
+// CanViewField reports whether a field is visible. Fields without a field
+// permission follow the entity's view action.
+func (p *UserPermission) CanViewField(fieldCode string) bool {
+	if fp, ok := p.FieldPermissions[fieldCode]; ok {
+		return fp.CanView
 	}
+	return p.CanView
 }
 
-// GetUserPermission returns the computed permissions for a user on an entity
+// CanEditField reports whether a field is editable. Fields without a field
+// permission follow the entity's edit action.
+func (p *UserPermission) CanEditField(fieldCode string) bool {
+	if fp, ok := p.FieldPermissions[fieldCode]; ok {
+		return fp.CanEdit
+	}
+	return p.CanEdit
+}
+
+// CheckPermission checks if a user has permission for an action on an entity
+func (s *PermissionService) CheckPermission(tenantID, userID uuid.UUID, entityCode string, action Action) (bool, error) {
+	perm, err := s.GetUserPermission(tenantID, userID, entityCode)
+	if err != nil {
+		return false, err
+	}
+	return perm.Allows(action), nil
+}
+
+// InvalidateUser drops the cached permissions of a user, such as after their
+// roles change
+func (s *PermissionService) InvalidateUser(userID uuid.UUID) {
+	s.invalidate(func(key permissionCacheKey) bool { return key.userID == userID })
+}
+
+// InvalidateTenant drops the cached permissions of every user of a tenant,
+// such as after a role, permission or entity changes
+func (s *PermissionService) InvalidateTenant(tenantID uuid.UUID) {
+	s.invalidate(func(key permissionCacheKey) bool { return key.tenantID == tenantID })
+}
+
+func (s *PermissionService) invalidate(match func(permissionCacheKey) bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.generation++
+	for key := range s.cache {
+		if match(key) {
+			delete(s.cache, key)
+		}
+	}
+}
+
+// cached returns a resolved permission that has not expired, along with the
+// generation to store a fresh resolution under
+func (s *PermissionService) cached(key permissionCacheKey) (*UserPermission, uint64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if entry, ok := s.cache[key]; ok && time.Now().Before(entry.expires) {
+		return entry.perm, s.generation
+	}
+	return nil, s.generation
+}
+
+// store caches a resolved permission unless an invalidation happened while
+// it was being resolved
+func (s *PermissionService) store(key permissionCacheKey, perm *UserPermission, generation uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if generation != s.generation {
+		return
+	}
+
+	now := time.Now()
+	if len(s.cache) >= maxCachedPermissions {
+		for k, entry := range s.cache {
+			if !now.Before(entry.expires) {
+				delete(s.cache, k)
+			}
+		}
+		if len(s.cache) >= maxCachedPermissions {
+			s.cache = make(map[permissionCacheKey]cachedPermission)
+		}
+	}
+	s.cache[key] = cachedPermission{perm: perm, expires: now.Add(s.ttl)}
+}
+
+// GetUserPermission returns the computed permissions for a user on an entity.
+//
+// Permissions are resolved in a fixed order, so the outcome never depends on
+// how roles or entries happen to be stored:
+//
+//  1. Roles: the user's active roles and, following parent_id, their active
+//     ancestors. An inactive role grants nothing and ends its chain. Roles
+//     are ordered by code.
+//  2. Grants: each role contributes its allow entry for the entity or, when
+//     it has none, its allow entry for the entity's module. An entity entry
+//     thus overrides the module default of the same role. Inherited roles
+//     contribute their own grants, so a role has at least its parent's
+//     permissions.
+//  3. Union: an action is allowed when any grant allows it. A field is
+//     visible (editable) when any grant makes it so; a grant that does not
+//     list the field follows its own view (edit) action. Rows are not
+//     restricted when any grant allowing view has no row filter; otherwise
+//     the filter of the first such grant, in role order, applies. View, edit
+//     and delete are limited to owned records only when every grant allowing
+//     them is; team scopes add the holders of their grant's role as owners.
+//  4. Deny: deny entries of any of the roles, on the entity or its module,
+//     take their actions and listed fields away. Nothing overrides a deny.
+//
+// Results are cached for the service's TTL.
 func (s *PermissionService) GetUserPermission(tenantID, userID uuid.UUID, entityCode string) (*UserPermission, error) {
-	log.Printf("GetUserPermission: tenantID=%s, userID=%s, entityCode=%s", tenantID, userID, entityCode)
+	if s.ttl <= 0 {
+		return s.resolve(tenantID, userID, entityCode, nil)
+	}
 
-	// Get all user's roles
-	var roleIDs []uuid.UUID
-	err := s.db.Table("user_roles").
-		Where("user_id = ?", userID).
-		Pluck("role_id", &roleIDs).Error
+	key := permissionCacheKey{tenantID: tenantID, userID: userID, entityCode: entityCode}
+	perm, generation := s.cached(key)
+	if perm != nil {
+		return perm, nil
+	}
+	perm, err := s.resolve(tenantID, userID, entityCode, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.store(key, perm, generation)
+	return perm, nil
+}
 
+// Explain resolves a user's permission on an entity exactly as
+// GetUserPermission does, recording each step. It always reads the
+// database, so it shows what the cache will hold once it expires.
+func (s *PermissionService) Explain(tenantID, userID uuid.UUID, entityCode string) (*PermissionExplanation, error) {
+	trace := &PermissionExplanation{
+		Roles:   []ExplainedRole{},
+		Entries: []ExplainedEntry{},
+		Fields:  make(map[string]ExplainedField),
+	}
+	perm, err := s.resolve(tenantID, userID, entityCode, trace)
 	if err != nil {
-		log.Printf("Error getting roles: %v", err)
 		return nil, err
 	}
+	trace.Result = perm
+	return trace, nil
+}
 
-	log.Printf("Found %d roles: %v", len(roleIDs), roleIDs)
+// resolve computes a user's permission on an entity from the database,
+// recording the steps in trace when it is not nil
+func (s *PermissionService) resolve(tenantID, userID uuid.UUID, entityCode string, trace *PermissionExplanation) (*UserPermission, error) {
+	// Get the user's roles, then their ancestors
+	var directIDs []uuid.UUID
+	err := s.db.Table("user_roles").
+		Where("user_id = ?", userID).
+		Pluck("role_id", &directIDs).Error
+	if err != nil {
+		return nil, err
+	}
 
 	// If user has no roles, return no permissions
-	if len(roleIDs) == 0 {
-		log.Printf("No roles, returning empty permissions")
+	if len(directIDs) == 0 {
 		return &UserPermission{}, nil
 	}
 
-	// Get entity ID from code
-	var entity struct{ ID uuid.UUID }
-	err = s.db.Table("entities").
-		Select("id").
+	var tenantRoles []models.Role
+	err = s.db.Select("id, code, parent_id, is_active").
+		Where("tenant_id = ?", tenantID).
+		Find(&tenantRoles).Error
+	if err != nil {
+		return nil, err
+	}
+	roles := effectiveRoles(directIDs, tenantRoles, trace)
+	if len(roles) == 0 {
+		return &UserPermission{}, nil
+	}
+
+	// Get entity and module IDs from code
+	var entity models.Entity
+	err = s.db.Select("id, module_id").
 		Where("tenant_id = ? AND code = ?", tenantID, entityCode).
 		First(&entity).Error
-
 	if err != nil {
-		log.Printf("Error getting entity: %v", err)
 		// If entity not found, deny all
 		return &UserPermission{}, nil
 	}
 
-	entityID := entity.ID
-	log.Printf("Found entity ID: %s", entityID)
-
-	// Get all permissions for user's roles on this entity
+	roleIDs := make([]uuid.UUID, len(roles))
+	for i, role := range roles {
+		roleIDs[i] = role.ID
+	}
+	query := s.db.Where("tenant_id = ? AND role_id IN ?", tenantID, roleIDs)
+	if entity.ModuleID != nil {
+		query = query.Where("(entity_id = ? OR module_id = ?)", entity.ID, *entity.ModuleID)
+	} else {
+		query = query.Where("entity_id = ?", entity.ID)
+	}
 	var permissions []models.Permission
-	err = s.db.Where("tenant_id = ? AND entity_id = ? AND role_id IN ?", tenantID, entityID, roleIDs).
-		Find(&permissions).Error
-
-	if err != nil {
-		log.Printf("Error getting permissions: %v", err)
+	if err := query.Find(&permissions).Error; err != nil {
 		return nil, err
 	}
 
-	log.Printf("Found %d permissions", len(permissions))
-	for _, p := range permissions {
-		log.Printf("  Permission: can_view=%v, can_create=%v", p.CanView, p.CanCreate)
+	return resolvePermission(roles, permissions, trace), nil
+}
+
+// effectiveRoles returns the active roles among direct and their active
+// ancestors, ordered by code. trace, when not nil, also receives the
+// inactive roles that ended a chain.
+func effectiveRoles(direct []uuid.UUID, tenantRoles []models.Role, trace *PermissionExplanation) []models.Role {
+	byID := make(map[uuid.UUID]*models.Role, len(tenantRoles))
+	for i := range tenantRoles {
+		byID[tenantRoles[i].ID] = &tenantRoles[i]
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	var roles []models.Role
+	for _, id := range direct {
+		inheritedBy := ""
+		// seen also ends chains that loop back on themselves
+		for role := byID[id]; role != nil && !seen[role.ID]; {
+			seen[role.ID] = true
+			if trace != nil {
+				trace.Roles = append(trace.Roles, ExplainedRole{
+					ID:          role.ID,
+					Code:        role.Code,
+					IsActive:    role.IsActive,
+					InheritedBy: inheritedBy,
+				})
+			}
+			if !role.IsActive {
+				break
+			}
+			roles = append(roles, *role)
+			if role.ParentID == nil {
+				break
+			}
+			inheritedBy = role.Code
+			role = byID[*role.ParentID]
+		}
+	}
+
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Code < roles[j].Code })
+	if trace != nil {
+		sort.Slice(trace.Roles, func(i, j int) bool { return trace.Roles[i].Code < trace.Roles[j].Code })
+	}
+	return roles
+}
+
+// resolvePermission merges the entries of roles on one entity and its
+// module, as described on GetUserPermission. Entries with an entity ID are
+// for the entity, all others for its module. trace, when not nil, records
+// what each entry contributed.
+func resolvePermission(roles []models.Role, permissions []models.Permission, trace *PermissionExplanation) *UserPermission {
+	type roleEntries struct {
+		entity, module *models.Permission
+		denies         []*models.Permission
+	}
+	byRole := make(map[uuid.UUID]*roleEntries, len(roles))
+	for _, role := range roles {
+		byRole[role.ID] = &roleEntries{}
+	}
+	for i := range permissions {
+		perm := &permissions[i]
+		entries := byRole[perm.RoleID]
+		switch {
+		case entries == nil:
+		case perm.Effect == EffectDeny:
+			entries.denies = append(entries.denies, perm)
+		case perm.EntityID != nil:
+			entries.entity = perm
+		default:
+			entries.module = perm
+		}
+	}
+
+	// Grants in role order; denies apply whatever their order
+	var grants, denies []*models.Permission
+	var grantRoles, denyRoles []string
+	for _, role := range roles {
+		entries := byRole[role.ID]
+		if entries.entity != nil {
+			grants = append(grants, entries.entity)
+			grantRoles = append(grantRoles, role.Code)
+			trace.entry(role.Code, entries.entity, OutcomeGranted)
+			if entries.module != nil {
+				trace.entry(role.Code, entries.module, OutcomeOverridden)
+			}
+		} else if entries.module != nil {
+			grants = append(grants, entries.module)
+			grantRoles = append(grantRoles, role.Code)
+			trace.entry(role.Code, entries.module, OutcomeGranted)
+		}
+		for _, deny := range entries.denies {
+			denies = append(denies, deny)
+			denyRoles = append(denyRoles, role.Code)
+			trace.entry(role.Code, deny, OutcomeDenied)
+		}
 	}
 
-	// Merge permissions (OR logic - if any role grants permission, user has it)
 	result := &UserPermission{
 		FieldPermissions: make(map[string]FieldPermission),
 	}
 
-	for _, perm := range permissions {
-		result.CanView = result.CanView || perm.CanView
-		result.CanCreate = result.CanCreate || perm.CanCreate
-		result.CanEdit = result.CanEdit || perm.CanEdit
-		result.CanDelete = result.CanDelete || perm.CanDelete
-		result.CanExport = result.CanExport || perm.CanExport
-		result.CanImport = result.CanImport || perm.CanImport
-
-		// Merge field permissions
-		if perm.FieldPermissions != nil {
-			for field, perms := range perm.FieldPermissions {
-				if fp, ok := perms.(map[string]interface{}); ok {
-					existing := result.FieldPermissions[field]
-					if viewPerm, ok := fp["view"].(bool); ok {
-						existing.CanView = existing.CanView || viewPerm
-					}
-					if editPerm, ok := fp["edit"].(bool); ok {
-						existing.CanEdit = existing.CanEdit || editPerm
-					}
-					result.FieldPermissions[field] = existing
-				}
+	// An action is limited by ownership only when every grant allowing it is
+	for _, action := range ScopedActions {
+		var scope *OwnershipScope
+		for _, grant := range grants {
+			allowed, grantScope := permissionScope(grant, action)
+			if !allowed {
+				continue
+			}
+			if grantScope == ScopeAll {
+				scope = nil
+				break
+			}
+			if scope == nil {
+				scope = &OwnershipScope{Scope: ScopeOwn}
+			}
+			if grantScope == ScopeTeam {
+				scope.Scope = ScopeTeam
+				scope.TeamRoles = append(scope.TeamRoles, grant.RoleID)
+			}
+		}
+		if scope != nil {
+			if result.Ownership == nil {
+				result.Ownership = make(map[Action]OwnershipScope)
+			}
+			result.Ownership[action] = *scope
+		}
+	}
+
+	// Fields listed by any grant are resolved across all grants
+	grantFields := make([]map[string]FieldPermission, len(grants))
+	for i, grant := range grants {
+		grantFields[i] = ParseFieldPermissions(grant.FieldPermissions)
+		for field := range grantFields[i] {
+			result.FieldPermissions[field] = FieldPermission{}
+		}
+	}
+
+	unfiltered := false
+	for i, grant := range grants {
+		result.CanView = result.CanView || grant.CanView
+		result.CanCreate = result.CanCreate || grant.CanCreate
+		result.CanEdit = result.CanEdit || grant.CanEdit
+		result.CanDelete = result.CanDelete || grant.CanDelete
+		result.CanExport = result.CanExport || grant.CanExport
+		result.CanImport = result.CanImport || grant.CanImport
+
+		for field, merged := range result.FieldPermissions {
+			fp, listed := grantFields[i][field]
+			if !listed {
+				fp = FieldPermission{CanView: grant.CanView, CanEdit: grant.CanEdit}
+			}
+			merged.CanView = merged.CanView || fp.CanView
+			merged.CanEdit = merged.CanEdit || fp.CanEdit
+			result.FieldPermissions[field] = merged
+			trace.fieldGrant(field, grantRoles[i], fp)
+		}
+
+		if grant.CanView && !unfiltered {
+			if len(grant.RowFilter) == 0 {
+				unfiltered = true
+				result.RowFilter = nil
+				trace.rowFilterFrom(grantRoles[i])
+			} else if result.RowFilter == nil {
+				result.RowFilter = grant.RowFilter
+				trace.rowFilterFrom(grantRoles[i])
+			}
+		}
+	}
+
+	for i, deny := range denies {
+		result.CanView = result.CanView && !deny.CanView
+		result.CanCreate = result.CanCreate && !deny.CanCreate
+		result.CanEdit = result.CanEdit && !deny.CanEdit
+		result.CanDelete = result.CanDelete && !deny.CanDelete
+		result.CanExport = result.CanExport && !deny.CanExport
+		result.CanImport = result.CanImport && !deny.CanImport
+
+		// A denied field is listed, so it no longer follows the entity's actions
+		for field, denied := range ParseFieldPermissions(deny.FieldPermissions) {
+			fp, listed := result.FieldPermissions[field]
+			if !listed {
+				fp = FieldPermission{CanView: result.CanView, CanEdit: result.CanEdit}
 			}
+			fp.CanView = fp.CanView && !denied.CanView
+			fp.CanEdit = fp.CanEdit && !denied.CanEdit
+			result.FieldPermissions[field] = fp
+			trace.fieldDeny(field, denyRoles[i], denied)
 		}
+	}
 
-		// Row filters are merged (user sees records matching ANY of their role filters)
-		// For simplicity, we use the first non-nil filter
-		if result.RowFilter == nil && perm.RowFilter != nil {
-			result.RowFilter = perm.RowFilter
+	// Fields cannot be more open than their entity, nor editable while hidden
+	for field, fp := range result.FieldPermissions {
+		fp.CanView = fp.CanView && result.CanView
+		fp.CanEdit = fp.CanEdit && fp.CanView && result.CanEdit
+		result.FieldPermissions[field] = fp
+		trace.fieldResult(field, fp)
+	}
+	if !result.CanView {
+		result.RowFilter = nil
+		trace.rowFilterFrom("")
+	}
+	for action := range result.Ownership {
+		if !result.Allows(action) {
+			delete(result.Ownership, action)
 		}
 	}
 
-	return result, nil
+	return result
+}
+
+// permissionScope reports whether an entry allows a scoped action and the
+// ownership scope it allows it in
+func permissionScope(perm *models.Permission, action Action) (bool, string) {
+	var allowed bool
+	var scope string
+	switch action {
+	case ActionView:
+		allowed, scope = perm.CanView, perm.ViewScope
+	case ActionEdit:
+		allowed, scope = perm.CanEdit, perm.EditScope
+	case ActionDelete:
+		allowed, scope = perm.CanDelete, perm.DeleteScope
+	default:
+		return false, ScopeAll
+	}
+	if scope == "" {
+		scope = ScopeAll
+	}
+	return allowed, scope
+}
+
+// ParseFieldPermissions reads an entry's field_permissions, such as
+// {"salary": {"view": false}}. Flags that are not set are false.
+func ParseFieldPermissions(value models.JSONB) map[string]FieldPermission {
+	fields := make(map[string]FieldPermission, len(value))
+	for field, perms := range value {
+		if fp, ok := perms.(map[string]interface{}); ok {
+			view, _ := fp["view"].(bool)
+			edit, _ := fp["edit"].(bool)
+			fields[field] = FieldPermission{CanView: view, CanEdit: edit}
+		}
+	}
+	return fields
 }
 
 // GetRowFilter returns the row filter for a user on an entity
@@ -193,25 +632,44 @@ func (s *PermissionService) GetRowFilter(tenantID, userID uuid.UUID, entityCode
 	return perm.RowFilter, nil
 }
 
-// CanAccessField checks if a user can view a specific field
-func (s *PermissionService) CanAccessField(tenantID, userID uuid.UUID, entityCode, fieldCode string) (bool, error) {
+// GetOwnershipScope reports whether a user may only perform an action on the
+// records they own, or that holders of teamRoles own, rather than on all
+func (s *PermissionService) GetOwnershipScope(tenantID, userID uuid.UUID, entityCode, action string) (bool, []uuid.UUID, error) {
 	perm, err := s.GetUserPermission(tenantID, userID, entityCode)
 	if err != nil {
-		return false, err
+		return false, nil, err
 	}
+	scope, limited := perm.Ownership[Action(action)]
+	return limited, scope.TeamRoles, nil
+}
 
-	// If no field-level permissions defined, allow all
-	if len(perm.FieldPermissions) == 0 {
-		return perm.CanView, nil
+// GetViewPermission reports whether a user may view an entity's records and
+// which of its fields are hidden from them when they can
+func (s *PermissionService) GetViewPermission(tenantID, userID uuid.UUID, entityCode string) (bool, []string, error) {
+	perm, err := s.GetUserPermission(tenantID, userID, entityCode)
+	if err != nil {
+		return false, nil, err
 	}
-
-	// Check specific field permission
-	if fp, ok := perm.FieldPermissions[fieldCode]; ok {
-		return fp.CanView, nil
+	if !perm.CanView {
+		return false, nil, nil
+	}
+	var hidden []string
+	for field, fp := range perm.FieldPermissions {
+		if !fp.CanView {
+			hidden = append(hidden, field)
+		}
 	}
+	sort.Strings(hidden)
+	return true, hidden, nil
+}
 
-	// Default to entity-level view permission
-	return perm.CanView, nil
+// CanAccessField checks if a user can view a specific field
+func (s *PermissionService) CanAccessField(tenantID, userID uuid.UUID, entityCode, fieldCode string) (bool, error) {
+	perm, err := s.GetUserPermission(tenantID, userID, entityCode)
+	if err != nil {
+		return false, err
+	}
+	return perm.CanViewField(fieldCode), nil
 }
 
 // CanEditField checks if a user can edit a specific field
@@ -220,17 +678,5 @@ func (s *PermissionService) CanEditField(tenantID, userID uuid.UUID, entityCode,
 	if err != nil {
 		return false, err
 	}
-
-	// If no field-level permissions defined, use entity-level
-	if len(perm.FieldPermissions) == 0 {
-		return perm.CanEdit, nil
-	}
-
-	// Check specific field permission
-	if fp, ok := perm.FieldPermissions[fieldCode]; ok {
-		return fp.CanEdit, nil
-	}
-
-	// Default to entity-level edit permission
-	return perm.CanEdit, nil
+	return perm.CanEditField(fieldCode), nil
 }
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/aethra/genesis/internal/models"
+	"github.com/google/uuid"
+)
+
+// testRole returns an active role, optionally inheriting from parent
+func testRole(code string, parent *models.Role) models.Role {
+	role := models.Role{ID: uuid.New(), Code: code, IsActive: true}
+	if parent != nil {
+		role.ParentID = &parent.ID
+	}
+	return role
+}
+
+func TestResolvePermissionDenyPrecedence(t *testing.T) {
+	entityID := uuid.New()
+	editor, auditor := testRole("editor", nil), testRole("auditor", nil)
+
+	tests := []struct {
+		name        string
+		permissions []models.Permission
+		want        UserPermission
+	}{
+		{
+			name: "grants combine across roles",
+			permissions: []models.Permission{
+				{RoleID: editor.ID, EntityID: &entityID, CanView: true, CanEdit: true},
+				{RoleID: auditor.ID, EntityID: &entityID, CanView: true, CanExport: true},
+			},
+			want: UserPermission{CanView: true, CanEdit: true, CanExport: true},
+		},
+		{
+			name: "deny on another role wins",
+			permissions: []models.Permission{
+				{RoleID: editor.ID, EntityID: &entityID, CanView: true, CanEdit: true, CanDelete: true},
+				{RoleID: auditor.ID, EntityID: &entityID, Effect: EffectDeny, CanDelete: true},
+			},
+			want: UserPermission{CanView: true, CanEdit: true},
+		},
+		{
+			name: "module deny overrides an entity grant",
+			permissions: []models.Permission{
+				{RoleID: editor.ID, EntityID: &entityID, CanView: true, CanEdit: true},
+				{RoleID: auditor.ID, Effect: EffectDeny, CanEdit: true},
+			},
+			want: UserPermission{CanView: true},
+		},
+		{
+			name: "entity entry overrides the role's module entry",
+			permissions: []models.Permission{
+				{RoleID: editor.ID, CanView: true, CanEdit: true, CanDelete: true},
+				{RoleID: editor.ID, EntityID: &entityID, CanView: true},
+			},
+			want: UserPermission{CanView: true},
+		},
+		{
+			name: "entries of other roles are ignored",
+			permissions: []models.Permission{
+				{RoleID: uuid.New(), EntityID: &entityID, CanView: true},
+			},
+			want: UserPermission{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolvePermission([]models.Role{auditor, editor}, tt.permissions, nil)
+			if got.CanView != tt.want.CanView || got.CanCreate != tt.want.CanCreate || got.CanEdit != tt.want.CanEdit ||
+				got.CanDelete != tt.want.CanDelete || got.CanExport != tt.want.CanExport || got.CanImport != tt.want.CanImport {
+				t.Errorf("resolvePermission() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolvePermissionFields(t *testing.T) {
+	entityID := uuid.New()
+	editor, auditor := testRole("editor", nil), testRole("auditor", nil)
+
+	got := resolvePermission([]models.Role{auditor, editor}, []models.Permission{
+		{RoleID: editor.ID, EntityID: &entityID, CanView: true, CanEdit: true,
+			FieldPermissions: models.JSONB{"notes": map[string]interface{}{"view": true}}},
+		{RoleID: auditor.ID, EntityID: &entityID, Effect: EffectDeny,
+			FieldPermissions: models.JSONB{"salary": map[string]interface{}{"view": true}}},
+	}, nil)
+
+	if got.CanViewField("salary") {
+		t.Error("denied field is visible")
+	}
+	if !got.CanViewField("notes") || got.CanEditField("notes") {
+		t.Errorf("notes = %+v, want view only", got.FieldPermissions["notes"])
+	}
+	if !got.CanViewField("name") || !got.CanEditField("name") {
+		t.Error("unlisted field should follow the entity's actions")
+	}
+}
+
+func TestResolvePermissionOwnership(t *testing.T) {
+	entityID := uuid.New()
+	owner, lead, manager := testRole("owner", nil), testRole("lead", nil), testRole("manager", nil)
+
+	tests := []struct {
+		name  string
+		roles []models.Role
+		want  *OwnershipScope
+	}{
+		{"own scope", []models.Role{owner}, &OwnershipScope{Scope: ScopeOwn}},
+		{"team scope takes the team roles", []models.Role{lead, owner}, &OwnershipScope{Scope: ScopeTeam, TeamRoles: []uuid.UUID{lead.ID}}},
+		{"an unscoped grant lifts the limit", []models.Role{manager, owner}, nil},
+	}
+	permissions := []models.Permission{
+		{RoleID: owner.ID, EntityID: &entityID, CanView: true, ViewScope: ScopeOwn},
+		{RoleID: lead.ID, EntityID: &entityID, CanView: true, ViewScope: ScopeTeam},
+		{RoleID: manager.ID, EntityID: &entityID, CanView: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, limited := resolvePermission(tt.roles, permissions, nil).Ownership[ActionView]
+			if limited != (tt.want != nil) {
+				t.Fatalf("view limited = %v, want %v", limited, tt.want != nil)
+			}
+			if tt.want == nil {
+				return
+			}
+			if got.Scope != tt.want.Scope || len(got.TeamRoles) != len(tt.want.TeamRoles) {
+				t.Errorf("view scope = %+v, want %+v", got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestEffectiveRoles(t *testing.T) {
+	base := testRole("base", nil)
+	staff := testRole("staff", &base)
+	manager := testRole("manager", &staff)
+	retired := testRole("retired", nil)
+	retired.IsActive = false
+	contractor := testRole("contractor", &retired)
+	tenantRoles := []models.Role{base, staff, manager, retired, contractor}
+
+	tests := []struct {
+		name   string
+		direct []uuid.UUID
+		want   []string
+	}{
+		{"ancestors are inherited", []uuid.UUID{manager.ID}, []string{"base", "manager", "staff"}},
+		{"inactive parent ends the chain", []uuid.UUID{contractor.ID}, []string{"contractor"}},
+		{"inactive role grants nothing", []uuid.UUID{retired.ID}, nil},
+		{"shared ancestors count once", []uuid.UUID{manager.ID, staff.ID}, []string{"base", "manager", "staff"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := effectiveRoles(tt.direct, tenantRoles, nil)
+			if len(got) != len(tt.want) {
+				t.Fatalf("effectiveRoles() = %d roles, want %v", len(got), tt.want)
+			}
+			for i, role := range got {
+				if role.Code != tt.want[i] {
+					t.Errorf("effectiveRoles()[%d] = %s, want %s", i, role.Code, tt.want[i])
+				}
+			}
+		})
+	}
+
+	// A parent loop must not hang resolution
+	loopA, loopB := testRole("loop_a", nil), testRole("loop_b", nil)
+	loopA.ParentID, loopB.ParentID = &loopB.ID, &loopA.ID
+	if got := effectiveRoles([]uuid.UUID{loopA.ID}, []models.Role{loopA, loopB}, nil); len(got) != 2 {
+		t.Errorf("effectiveRoles() with a loop = %d roles, want 2", len(got))
+	}
+}
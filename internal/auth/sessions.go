@@ -0,0 +1,135 @@
+// Package auth - Sessions and devices
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aethra/genesis/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrSessionNotFound is returned for unknown sessions or sessions of another user
+var ErrSessionNotFound = errors.New("session not found")
+
+// ListSessions returns a user's active sessions, most recently used first.
+// Sessions of admins impersonating the user are not among them.
+func (s *TokenService) ListSessions(userID uuid.UUID) ([]models.Session, error) {
+	var sessions []models.Session
+	err := s.db.Where("user_id = ? AND impersonator_id IS NULL AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	for i := range sessions {
+		if sessions[i].Device == "" {
+			sessions[i].Device = DeviceName(sessions[i].UserAgent)
+		}
+	}
+	return sessions, err
+}
+
+// RevokeSession ends one of a user's sessions, other than impersonations
+func (s *TokenService) RevokeSession(userID, sessionID uuid.UUID, reason string) error {
+	var count int64
+	err := s.db.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND impersonator_id IS NULL AND revoked_at IS NULL", sessionID, userID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrSessionNotFound
+	}
+	return s.RevokeFamily(sessionID, reason)
+}
+
+// saveSession records the login a refresh token belongs to: the first token
+// of a family creates the session, later ones update where and when it was
+// last seen
+func saveSession(tx *gorm.DB, record *models.RefreshToken, client ClientInfo) error {
+	session := models.Session{
+		ID:         record.FamilyID,
+		TenantID:   record.TenantID,
+		UserID:     record.UserID,
+		Device:     DeviceName(client.UserAgent),
+		IPAddress:  client.IPAddress,
+		UserAgent:  client.UserAgent,
+		CreatedAt:  record.CreatedAt,
+		LastSeenAt: record.CreatedAt,
+		ExpiresAt:  record.ExpiresAt,
+	}
+	err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"device", "ip_address", "user_agent", "last_seen_at", "expires_at"}),
+	}).Create(&session).Error
+	if err != nil {
+		return fmt.Errorf("failed to store session: %w", err)
+	}
+	return nil
+}
+
+// revokeSessions marks the sessions matching the condition revoked
+func revokeSessions(tx *gorm.DB, condition string, value interface{}, reason string) error {
+	err := tx.Model(&models.Session{}).
+		Where(condition+" AND revoked_at IS NULL", value).
+		Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"revoked_reason": reason,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
+
+// DeviceName describes a user agent as "Browser on OS" for session lists
+func DeviceName(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+	ua := strings.ToLower(userAgent)
+
+	// Order matters: many user agents also name the engines they imitate
+	browser := "Unknown browser"
+	for _, candidate := range []struct{ token, name string }{
+		{"edg/", "Edge"},
+		{"opr/", "Opera"},
+		{"firefox/", "Firefox"},
+		{"chrome/", "Chrome"},
+		{"crios/", "Chrome"},
+		{"safari/", "Safari"},
+		{"curl/", "curl"},
+		{"postman", "Postman"},
+		{"go-http-client", "Go client"},
+		{"python", "Python client"},
+	} {
+		if strings.Contains(ua, candidate.token) {
+			browser = candidate.name
+			break
+		}
+	}
+
+	os := ""
+	for _, candidate := range []struct{ token, name string }{
+		{"iphone", "iOS"},
+		{"ipad", "iPadOS"},
+		{"android", "Android"},
+		{"windows", "Windows"},
+		{"mac os x", "macOS"},
+		{"cros", "ChromeOS"},
+		{"linux", "Linux"},
+	} {
+		if strings.Contains(ua, candidate.token) {
+			os = candidate.name
+			break
+		}
+	}
+
+	if os == "" {
+		return browser
+	}
+	return browser + " on " + os
+}
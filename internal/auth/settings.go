@@ -0,0 +1,129 @@
+// Package auth - Per-tenant security settings
+package auth
+
+import (
+	"encoding/json"
+
+	"github.com/aethra/genesis/internal/models"
+)
+
+// SecuritySettings are read from the "security" object of Tenant.Settings:
+//
+//	{"security": {"require_2fa_for_admins": true, "login_throttle": {"max_account_failures": 3},
+//	  "password_policy": {"min_length": 12, "history_count": 5}}}
+type SecuritySettings struct {
+	// Require2FAForAdmins makes users with the admin or super_admin role
+	// enrol in and pass two-factor authentication at login
+	Require2FAForAdmins bool `json:"require_2fa_for_admins"`
+
+	// LoginThrottle limits failed logins; unset values use the defaults
+	LoginThrottle ThrottleSettings `json:"login_throttle"`
+
+	// PasswordPolicy applies whenever a password is set
+	PasswordPolicy PasswordPolicy `json:"password_policy"`
+}
+
+// PasswordPolicy is checked when a password is set by registration, change,
+// reset or an admin. MaxAgeDays makes users choose a new password at their
+// next login once the current one is that old; 0 never expires passwords.
+type PasswordPolicy struct {
+	MinLength     int  `json:"min_length"`
+	RequireUpper  bool `json:"require_upper"`
+	RequireLower  bool `json:"require_lower"`
+	RequireDigit  bool `json:"require_digit"`
+	RequireSymbol bool `json:"require_symbol"`
+	// AllowBreached skips the check against the bundled list of breached
+	// and common passwords
+	AllowBreached bool `json:"allow_breached"`
+	// HistoryCount rejects the current and previous passwords, up to this
+	// many in total
+	HistoryCount int `json:"history_count"`
+	MaxAgeDays   int `json:"max_age_days"`
+}
+
+// WithDefaults fills unset or invalid values with the defaults
+func (p PasswordPolicy) WithDefaults() PasswordPolicy {
+	if p.MinLength < 8 {
+		p.MinLength = 8
+	}
+	if p.MinLength > maxPasswordBytes {
+		p.MinLength = maxPasswordBytes
+	}
+	if p.HistoryCount < 0 {
+		p.HistoryCount = 0
+	}
+	if p.HistoryCount > maxPasswordHistory {
+		p.HistoryCount = maxPasswordHistory
+	}
+	if p.MaxAgeDays < 0 {
+		p.MaxAgeDays = 0
+	}
+	return p
+}
+
+// ThrottleSettings control login throttling. After each failure the next
+// attempt waits DelaySeconds, doubling per failure up to MaxDelaySeconds;
+// reaching the failure limit locks the key for LockoutMinutes. Failures are
+// forgotten once WindowMinutes pass without another.
+type ThrottleSettings struct {
+	MaxAccountFailures int `json:"max_account_failures"`
+	MaxIPFailures      int `json:"max_ip_failures"`
+	WindowMinutes      int `json:"window_minutes"`
+	LockoutMinutes     int `json:"lockout_minutes"`
+	DelaySeconds       int `json:"delay_seconds"`
+	MaxDelaySeconds    int `json:"max_delay_seconds"`
+}
+
+// WithDefaults fills unset or invalid values with the defaults
+func (t ThrottleSettings) WithDefaults() ThrottleSettings {
+	if t.MaxAccountFailures <= 0 {
+		t.MaxAccountFailures = 5
+	}
+	if t.MaxIPFailures <= 0 {
+		t.MaxIPFailures = 20
+	}
+	if t.WindowMinutes <= 0 {
+		t.WindowMinutes = 15
+	}
+	if t.LockoutMinutes <= 0 {
+		t.LockoutMinutes = 15
+	}
+	if t.DelaySeconds <= 0 {
+		t.DelaySeconds = 1
+	}
+	if t.MaxDelaySeconds <= 0 {
+		t.MaxDelaySeconds = 30
+	}
+	if t.MaxDelaySeconds < t.DelaySeconds {
+		t.MaxDelaySeconds = t.DelaySeconds
+	}
+	return t
+}
+
+// TenantSecuritySettings extracts the security settings from tenant
+// settings. Missing or malformed values fall back to the defaults.
+func TenantSecuritySettings(settings models.JSONB) SecuritySettings {
+	var result SecuritySettings
+	raw, ok := settings["security"]
+	if !ok {
+		return result
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return result
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return SecuritySettings{}
+	}
+	return result
+}
+
+// HasAdminRole reports whether roles include admin or super_admin
+func HasAdminRole(roles []string) bool {
+	for _, role := range roles {
+		if role == "admin" || role == "super_admin" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,453 @@
+// Package auth - Token signing keys and rotation
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aethra/genesis/internal/crypto"
+	"github.com/aethra/genesis/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// Signing algorithms
+const (
+	SigningAlgRS256 = "RS256"
+	SigningAlgEdDSA = "EdDSA"
+)
+
+const (
+	// signingKeyCheckInterval is how often keys are reloaded and rotation is
+	// checked, which also picks up keys rotated by other instances
+	signingKeyCheckInterval = 10 * time.Minute
+	// signingKeyReloadInterval limits reloads for unknown key IDs
+	signingKeyReloadInterval = 10 * time.Second
+	rsaKeyBits               = 2048
+)
+
+// ErrUnknownSigningKey is returned for tokens signed by a key that is not, or
+// no longer, trusted
+var ErrUnknownSigningKey = errors.New("unknown signing key")
+
+// signingKey is a decrypted key ready to sign or verify
+type signingKey struct {
+	record  models.SigningKey
+	method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+}
+
+// SigningKeyService keeps the keys that sign tokens. The newest key signs;
+// keys it replaced keep verifying for a grace period so tokens they signed
+// stay valid until they expire. Keys are stored encrypted in the database and
+// shared by every instance.
+type SigningKeyService struct {
+	db        *gorm.DB
+	crypto    *crypto.Service
+	algorithm string
+	rotation  time.Duration
+	grace     time.Duration
+
+	// introducedAt is when the oldest stored key was created, before which
+	// tokens were signed with JWT_SECRET
+	introducedAt time.Time
+
+	mu       sync.RWMutex
+	current  *signingKey
+	keys     map[string]*signingKey
+	loadedAt time.Time
+}
+
+// NewSigningKeyService loads the signing keys, creating the first one when
+// needed, and starts scheduled rotation. It reads JWT_SIGNING_ALG (RS256 or
+// EdDSA), JWT_KEY_ROTATION_DAYS (default 30) and JWT_KEY_GRACE_DAYS, which
+// is never shorter than the refresh token lifetime. Private keys are only
+// stored encrypted, so cryptoService must have an encryption key.
+func NewSigningKeyService(db *gorm.DB, cryptoService *crypto.Service) (*SigningKeyService, error) {
+	if !cryptoService.Configured() {
+		return nil, errors.New("ENCRYPTION_KEY must be set to store token signing keys")
+	}
+
+	algorithm := os.Getenv("JWT_SIGNING_ALG")
+	switch algorithm {
+	case "":
+		algorithm = SigningAlgRS256
+	case SigningAlgRS256, SigningAlgEdDSA:
+	default:
+		return nil, fmt.Errorf("JWT_SIGNING_ALG must be %s or %s", SigningAlgRS256, SigningAlgEdDSA)
+	}
+
+	_, refreshExpiry := tokenLifetimesFromEnv()
+	s := &SigningKeyService{
+		db:        db,
+		crypto:    cryptoService,
+		algorithm: algorithm,
+		rotation:  envDays("JWT_KEY_ROTATION_DAYS", 30),
+		grace:     envDays("JWT_KEY_GRACE_DAYS", 0),
+	}
+	if s.grace < refreshExpiry {
+		s.grace = refreshExpiry
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if s.rotationDue() {
+		if _, err := s.rotate(false); err != nil {
+			return nil, err
+		}
+	}
+
+	var first models.SigningKey
+	if err := db.Order("created_at").Take(&first).Error; err != nil {
+		return nil, fmt.Errorf("failed to load signing keys: %w", err)
+	}
+	s.introducedAt = first.CreatedAt
+
+	go s.run()
+	return s, nil
+}
+
+// IntroducedAt returns when tokens started being signed with these keys
+func (s *SigningKeyService) IntroducedAt() time.Time {
+	return s.introducedAt
+}
+
+// envDays reads a whole number of days from the environment
+func envDays(key string, fallback int) time.Duration {
+	days := fallback
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			days = n
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Signer returns the current key with its ID and signing method
+func (s *SigningKeyService) Signer() (string, jwt.SigningMethod, interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.current == nil {
+		return "", nil, nil, errors.New("no signing key available")
+	}
+	return s.current.record.KID, s.current.method, s.current.private, nil
+}
+
+// VerificationKey returns the method and public key of a trusted key ID.
+// Unknown IDs trigger a reload, as another instance may have rotated.
+func (s *SigningKeyService) VerificationKey(kid string) (jwt.SigningMethod, interface{}, error) {
+	if key, ok := s.lookup(kid); ok {
+		return key.method, key.public, nil
+	}
+
+	s.mu.RLock()
+	recent := time.Since(s.loadedAt) < signingKeyReloadInterval
+	s.mu.RUnlock()
+	if !recent {
+		if err := s.load(); err != nil {
+			return nil, nil, err
+		}
+		if key, ok := s.lookup(kid); ok {
+			return key.method, key.public, nil
+		}
+	}
+	return nil, nil, ErrUnknownSigningKey
+}
+
+func (s *SigningKeyService) lookup(kid string) (*signingKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[kid]
+	if !ok || (key.record.ExpiresAt != nil && time.Now().After(*key.record.ExpiresAt)) {
+		return nil, false
+	}
+	return key, true
+}
+
+// JWKS returns the public keys that verify tokens, current key first
+func (s *SigningKeyService) JWKS() JWKSet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var retired []*signingKey
+	for _, key := range s.keys {
+		if key == s.current || (key.record.ExpiresAt != nil && time.Now().After(*key.record.ExpiresAt)) {
+			continue
+		}
+		retired = append(retired, key)
+	}
+	sort.Slice(retired, func(i, j int) bool {
+		return retired[i].record.CreatedAt.After(retired[j].record.CreatedAt)
+	})
+
+	set := JWKSet{Keys: []jsonWebKey{}}
+	if s.current != nil {
+		set.Keys = append(set.Keys, publicJWK(s.current))
+	}
+	for _, key := range retired {
+		set.Keys = append(set.Keys, publicJWK(key))
+	}
+	return set
+}
+
+// List returns the stored keys, newest first
+func (s *SigningKeyService) List() ([]models.SigningKey, error) {
+	var keys []models.SigningKey
+	err := s.db.Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// Rotate replaces the signing key immediately, as after a suspected leak.
+// The replaced key keeps verifying for the grace period.
+func (s *SigningKeyService) Rotate() (*models.SigningKey, error) {
+	return s.rotate(true)
+}
+
+// rotate creates a new signing key and retires the current one. Unless
+// forced, it does nothing when another instance rotated in the meantime.
+func (s *SigningKeyService) rotate(force bool) (*models.SigningKey, error) {
+	record, private, err := generateSigningKey(s.algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if record.PrivateKey, err = s.crypto.Encrypt(private); err != nil {
+		return nil, fmt.Errorf("failed to encrypt signing key: %w", err)
+	}
+
+	created := true
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Serializes rotation across instances, including the first key
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('signing_keys'))").Error; err != nil {
+			return err
+		}
+		var active []models.SigningKey
+		err := tx.Where("retired_at IS NULL").Order("created_at DESC").Find(&active).Error
+		if err != nil {
+			return err
+		}
+		if !force && len(active) > 0 && !s.due(active[0]) {
+			// Another instance rotated in the meantime; its key is used
+			// unless it cannot be decrypted here
+			if _, err := s.decode(active[0]); err == nil {
+				created = false
+				return nil
+			}
+		}
+
+		now := time.Now()
+		expiresAt := now.Add(s.grace)
+		if len(active) > 0 {
+			err := tx.Model(&models.SigningKey{}).
+				Where("retired_at IS NULL").
+				Updates(map[string]interface{}{"retired_at": now, "expires_at": expiresAt}).Error
+			if err != nil {
+				return err
+			}
+		}
+		record.CreatedAt = now
+		return tx.Create(record).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate signing key: %w", err)
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, nil
+	}
+	log.Printf("Token signing key rotated, new key %s (%s)", record.KID, record.Algorithm)
+	return record, nil
+}
+
+// load reads the keys that still verify tokens from the database
+func (s *SigningKeyService) load() error {
+	var records []models.SigningKey
+	err := s.db.Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Order("created_at DESC").
+		Find(&records).Error
+	if err != nil {
+		return fmt.Errorf("failed to load signing keys: %w", err)
+	}
+
+	keys := make(map[string]*signingKey, len(records))
+	var current *signingKey
+	for _, record := range records {
+		key, err := s.decode(record)
+		if err != nil {
+			// A key that cannot be decrypted, e.g. after ENCRYPTION_KEY
+			// changed, is skipped; rotation replaces it as signer
+			log.Printf("Warning: signing key %s unusable: %v", record.KID, err)
+			continue
+		}
+		keys[record.KID] = key
+		if current == nil && record.RetiredAt == nil {
+			current = key
+		}
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.current = current
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// decode decrypts and parses a stored key
+func (s *SigningKeyService) decode(record models.SigningKey) (*signingKey, error) {
+	plaintext, err := s.crypto.Decrypt(record.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	der, err := base64.StdEncoding.DecodeString(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	private, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{record: record, private: private}
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.public = jwt.SigningMethodRS256, &k.PublicKey
+	case ed25519.PrivateKey:
+		key.method, key.public = jwt.SigningMethodEdDSA, k.Public()
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", private)
+	}
+	if key.method.Alg() != record.Algorithm {
+		return nil, fmt.Errorf("key type does not match algorithm %s", record.Algorithm)
+	}
+	return key, nil
+}
+
+// rotationDue reports whether the current key should be replaced: it is
+// missing, too old, or of another algorithm than configured
+func (s *SigningKeyService) rotationDue() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current == nil || s.due(s.current.record)
+}
+
+func (s *SigningKeyService) due(record models.SigningKey) bool {
+	return record.Algorithm != s.algorithm || time.Since(record.CreatedAt) >= s.rotation
+}
+
+// run reloads keys, rotates on schedule and deletes expired keys
+func (s *SigningKeyService) run() {
+	ticker := time.NewTicker(signingKeyCheckInterval)
+	for range ticker.C {
+		if err := s.load(); err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		if s.rotationDue() {
+			if _, err := s.rotate(false); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+		s.db.Where("expires_at < ?", time.Now()).Delete(&models.SigningKey{})
+	}
+}
+
+// generateSigningKey creates a key pair, returning the record without its
+// encrypted private key and the base64 PKCS#8 private key
+func generateSigningKey(algorithm string) (*models.SigningKey, string, error) {
+	var private, public interface{}
+	switch algorithm {
+	case SigningAlgRS256:
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to generate signing key: %w", err)
+		}
+		private, public = key, &key.PublicKey
+	case SigningAlgEdDSA:
+		pub, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to generate signing key: %w", err)
+		}
+		private, public = key, pub
+	default:
+		return nil, "", fmt.Errorf("unsupported signing algorithm %s", algorithm)
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, "", err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, "", err
+	}
+
+	jwk := publicKeyJWK(public)
+	record := &models.SigningKey{
+		KID:       jwkThumbprint(jwk),
+		Algorithm: algorithm,
+		PublicKey: base64.StdEncoding.EncodeToString(publicDER),
+	}
+	return record, base64.StdEncoding.EncodeToString(privateDER), nil
+}
+
+// publicJWK describes a key for the JWKS document
+func publicJWK(key *signingKey) jsonWebKey {
+	jwk := publicKeyJWK(key.public)
+	jwk.KID = key.record.KID
+	jwk.Alg = key.record.Algorithm
+	jwk.Use = "sig"
+	return jwk
+}
+
+// publicKeyJWK converts an RSA or Ed25519 public key to a JWK
+func publicKeyJWK(public interface{}) jsonWebKey {
+	encode := base64.RawURLEncoding.EncodeToString
+	switch k := public.(type) {
+	case *rsa.PublicKey:
+		return jsonWebKey{KTY: "RSA", N: encode(k.N.Bytes()), E: encode(big.NewInt(int64(k.E)).Bytes())}
+	case ed25519.PublicKey:
+		return jsonWebKey{KTY: "OKP", CRV: "Ed25519", X: encode(k)}
+	}
+	return jsonWebKey{}
+}
+
+// jwkThumbprint computes the RFC 7638 thumbprint used as key ID
+func jwkThumbprint(jwk jsonWebKey) string {
+	// Required members only, in lexicographic order
+	var members interface{}
+	switch jwk.KTY {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			KTY string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.KTY, jwk.N}
+	default:
+		members = struct {
+			CRV string `json:"crv"`
+			KTY string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.CRV, jwk.KTY, jwk.X}
+	}
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,259 @@
+// Package auth - Login throttling and account lockout
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aethra/genesis/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Throttle scopes
+const (
+	ThrottleAccount   = "account" // lower-cased email
+	ThrottleIP        = "ip"
+	ThrottleTwoFactor = "2fa"  // user id
+	ThrottleMail      = "mail" // lower-cased email, for emailed links
+)
+
+// ThrottleKey identifies what failures are counted against
+type ThrottleKey struct {
+	Scope string
+	Value string
+}
+
+// AccountKey returns the throttle key of an account by email
+func AccountKey(email string) ThrottleKey {
+	return ThrottleKey{Scope: ThrottleAccount, Value: strings.ToLower(strings.TrimSpace(email))}
+}
+
+// IPKey returns the throttle key of a client address
+func IPKey(ip string) ThrottleKey {
+	return ThrottleKey{Scope: ThrottleIP, Value: ip}
+}
+
+// ThrottleDecision is the outcome of checking or recording an attempt
+type ThrottleDecision struct {
+	Allowed    bool
+	Locked     bool          // a lockout, rather than a progressive delay
+	RetryAfter time.Duration // when not allowed
+	Remaining  int           // failures left before lockout
+}
+
+// LoginThrottle tracks failed attempts in the database so limits hold across
+// restarts and server instances
+type LoginThrottle struct {
+	db *gorm.DB
+}
+
+// NewLoginThrottle creates a new login throttle
+func NewLoginThrottle(db *gorm.DB) *LoginThrottle {
+	return &LoginThrottle{db: db}
+}
+
+// Check reports whether an attempt may be made now. It is denied while any
+// key is locked out or still waiting out its progressive delay.
+func (t *LoginThrottle) Check(tenantID uuid.UUID, keys ...ThrottleKey) (*ThrottleDecision, error) {
+	settings := t.settings(tenantID)
+	now := time.Now()
+	window := time.Duration(settings.WindowMinutes) * time.Minute
+
+	decision := &ThrottleDecision{Allowed: true, Remaining: -1}
+	for _, key := range keys {
+		var record models.LoginThrottle
+		err := t.db.Where("tenant_id = ? AND scope = ? AND key = ?", tenantID, key.Scope, key.Value).
+			First(&record).Error
+		if err == gorm.ErrRecordNotFound {
+			decision.remaining(settings.maxFailures(key.Scope))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if record.LockedUntil != nil && now.Before(*record.LockedUntil) {
+			decision.deny(record.LockedUntil.Sub(now), true)
+			continue
+		}
+		// Failures are forgotten after a quiet window or a served lockout
+		if record.LastFailureAt == nil || now.Sub(*record.LastFailureAt) > window || record.LockedUntil != nil {
+			decision.remaining(settings.maxFailures(key.Scope))
+			continue
+		}
+
+		if wait := record.LastFailureAt.Add(settings.delay(record.Failures)).Sub(now); wait > 0 {
+			decision.deny(wait, false)
+		}
+		decision.remaining(settings.maxFailures(key.Scope) - record.Failures)
+	}
+	return decision, nil
+}
+
+// RecordFailure counts a failed attempt against each key, locking keys that
+// reach their limit. New lockouts are written to the audit log.
+func (t *LoginThrottle) RecordFailure(tenantID uuid.UUID, client ClientInfo, keys ...ThrottleKey) (*ThrottleDecision, error) {
+	settings := t.settings(tenantID)
+	now := time.Now()
+	windowStart := now.Add(-time.Duration(settings.WindowMinutes) * time.Minute)
+	lockout := time.Duration(settings.LockoutMinutes) * time.Minute
+
+	decision := &ThrottleDecision{Allowed: true, Remaining: -1}
+	for _, key := range keys {
+		var record models.LoginThrottle
+		err := t.db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Raw(`
+				INSERT INTO login_throttles (id, tenant_id, scope, key, failures, first_failure_at, last_failure_at, updated_at)
+				VALUES (?, ?, ?, ?, 1, ?, ?, ?)
+				ON CONFLICT (tenant_id, scope, key) DO UPDATE SET
+					failures = CASE
+						WHEN login_throttles.last_failure_at < ? OR login_throttles.locked_until <= ? THEN 1
+						ELSE login_throttles.failures + 1 END,
+					first_failure_at = CASE
+						WHEN login_throttles.last_failure_at < ? OR login_throttles.locked_until <= ? THEN EXCLUDED.first_failure_at
+						ELSE login_throttles.first_failure_at END,
+					locked_until = CASE
+						WHEN login_throttles.locked_until <= ? THEN NULL
+						ELSE login_throttles.locked_until END,
+					last_failure_at = EXCLUDED.last_failure_at,
+					updated_at = EXCLUDED.updated_at
+				RETURNING *
+			`, uuid.New(), tenantID, key.Scope, key.Value, now, now, now,
+				windowStart, now, windowStart, now, now).Scan(&record).Error
+			if err != nil {
+				return err
+			}
+
+			max := settings.maxFailures(key.Scope)
+			if record.Failures < max || record.LockedUntil != nil {
+				decision.remaining(max - record.Failures)
+				return nil
+			}
+
+			until := now.Add(lockout)
+			err = tx.Model(&models.LoginThrottle{}).Where("id = ?", record.ID).Updates(map[string]interface{}{
+				"locked_until": until,
+				"lockouts":     gorm.Expr("lockouts + 1"),
+			}).Error
+			if err != nil {
+				return err
+			}
+			record.LockedUntil = &until
+			decision.deny(lockout, true)
+			decision.remaining(0)
+			return t.auditLockout(tx, tenantID, key, record.Failures, until, client)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to record failed attempt: %w", err)
+		}
+
+		// Locked keys are reported by the lockout above; others may now
+		// have to wait out their delay before the next attempt
+		if record.LockedUntil == nil {
+			decision.deny(settings.delay(record.Failures), false)
+		} else if now.Before(*record.LockedUntil) {
+			decision.deny(record.LockedUntil.Sub(now), true)
+		}
+	}
+	return decision, nil
+}
+
+// Reset clears the failures of a key, as after a successful login
+func (t *LoginThrottle) Reset(tenantID uuid.UUID, key ThrottleKey) error {
+	return t.db.Where("tenant_id = ? AND scope = ? AND key = ?", tenantID, key.Scope, key.Value).
+		Delete(&models.LoginThrottle{}).Error
+}
+
+// Unlock lifts a lockout and clears the failures of a throttle entry
+func (t *LoginThrottle) Unlock(id uuid.UUID) (*models.LoginThrottle, error) {
+	var record models.LoginThrottle
+	if err := t.db.Where("id = ?", id).First(&record).Error; err != nil {
+		return nil, err
+	}
+	if err := t.db.Delete(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// UnlockUser lifts the account and two-factor lockouts of a user
+func (t *LoginThrottle) UnlockUser(tenantID, userID uuid.UUID, email string) (int64, error) {
+	account := AccountKey(email)
+	result := t.db.Where(
+		"tenant_id = ? AND ((scope = ? AND key = ?) OR (scope = ? AND key = ?))",
+		tenantID, account.Scope, account.Value, ThrottleTwoFactor, userID.String(),
+	).Delete(&models.LoginThrottle{})
+	return result.RowsAffected, result.Error
+}
+
+// auditLockout records a new lockout in the audit log
+func (t *LoginThrottle) auditLockout(tx *gorm.DB, tenantID uuid.UUID, key ThrottleKey, failures int, until time.Time, client ClientInfo) error {
+	return tx.Create(&models.AuditLog{
+		ID:       uuid.New(),
+		TenantID: tenantID,
+		Action:   "login_lockout",
+		NewValues: models.JSONB{
+			"scope":        key.Scope,
+			"key":          key.Value,
+			"failures":     failures,
+			"locked_until": until,
+		},
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		CreatedAt: time.Now(),
+	}).Error
+}
+
+// settings loads the tenant's throttle settings
+func (t *LoginThrottle) settings(tenantID uuid.UUID) ThrottleSettings {
+	var tenant models.Tenant
+	if err := t.db.Select("settings").Where("id = ?", tenantID).First(&tenant).Error; err != nil {
+		return ThrottleSettings{}.WithDefaults()
+	}
+	return TenantSecuritySettings(tenant.Settings).LoginThrottle.WithDefaults()
+}
+
+// maxFailures returns the failures allowed for a scope before lockout
+func (t ThrottleSettings) maxFailures(scope string) int {
+	if scope == ThrottleIP {
+		return t.MaxIPFailures
+	}
+	return t.MaxAccountFailures
+}
+
+// delay returns the wait after the given number of consecutive failures
+func (t ThrottleSettings) delay(failures int) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	delay := time.Duration(t.DelaySeconds) * time.Second
+	max := time.Duration(t.MaxDelaySeconds) * time.Second
+	for i := 1; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// deny records that an attempt must wait, keeping the longest wait
+func (d *ThrottleDecision) deny(wait time.Duration, locked bool) {
+	d.Allowed = false
+	d.Locked = d.Locked || locked
+	if wait > d.RetryAfter {
+		d.RetryAfter = wait
+	}
+}
+
+// remaining keeps the lowest number of failures left across keys
+func (d *ThrottleDecision) remaining(n int) {
+	if n < 0 {
+		n = 0
+	}
+	if d.Remaining < 0 || n < d.Remaining {
+		d.Remaining = n
+	}
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestThrottleDelay(t *testing.T) {
+	settings := ThrottleSettings{}.WithDefaults()
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{10, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := settings.delay(tt.failures); got != tt.want {
+			t.Errorf("delay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestThrottleDecisionAcrossKeys(t *testing.T) {
+	decision := &ThrottleDecision{Allowed: true, Remaining: -1}
+	decision.remaining(18)
+	decision.deny(2*time.Second, false)
+	decision.remaining(3)
+	decision.deny(time.Second, true)
+
+	if decision.Allowed || !decision.Locked {
+		t.Errorf("decision = %+v, want denied and locked", decision)
+	}
+	if decision.RetryAfter != 2*time.Second {
+		t.Errorf("RetryAfter = %v, want the longest wait", decision.RetryAfter)
+	}
+	if decision.Remaining != 3 {
+		t.Errorf("Remaining = %d, want the lowest across keys", decision.Remaining)
+	}
+}
+
+func TestLoginThrottleLockout(t *testing.T) {
+	db := testDB(t)
+	throttle := NewLoginThrottle(db)
+	tenantID, _ := testTenant(t, db)
+	account, ip := AccountKey(" Locked@Example.com "), IPKey("192.0.2.1")
+	max := ThrottleSettings{}.WithDefaults().MaxAccountFailures
+
+	for i := 1; i < max; i++ {
+		decision, err := throttle.RecordFailure(tenantID, ClientInfo{}, account, ip)
+		if err != nil {
+			t.Fatalf("RecordFailure: %v", err)
+		}
+		if decision.Locked || decision.Remaining != max-i {
+			t.Fatalf("after %d failures: decision = %+v, want %d remaining", i, decision, max-i)
+		}
+	}
+
+	decision, err := throttle.RecordFailure(tenantID, ClientInfo{}, account, ip)
+	if err != nil {
+		t.Fatalf("RecordFailure: %v", err)
+	}
+	if !decision.Locked || decision.Remaining != 0 {
+		t.Fatalf("at the limit: decision = %+v, want locked", decision)
+	}
+
+	// The account stays locked whatever address the next attempt comes from
+	decision, err = throttle.Check(tenantID, AccountKey("locked@example.com"), IPKey("198.51.100.7"))
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if decision.Allowed || !decision.Locked || decision.RetryAfter <= 0 {
+		t.Errorf("Check while locked = %+v, want denied", decision)
+	}
+
+	var audited int64
+	db.Table("audit_log").Where("tenant_id = ? AND action = ?", tenantID, "login_lockout").Count(&audited)
+	if audited != 1 {
+		t.Errorf("lockout audit entries = %d, want 1", audited)
+	}
+
+	// Lockouts are per tenant
+	otherTenant, _ := testTenant(t, db)
+	decision, err = throttle.Check(otherTenant, account)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if !decision.Allowed {
+		t.Error("lockout applied to another tenant")
+	}
+
+	if _, err := throttle.UnlockUser(tenantID, uuid.New(), "locked@example.com"); err != nil {
+		t.Fatalf("UnlockUser: %v", err)
+	}
+	decision, err = throttle.Check(tenantID, account)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if !decision.Allowed || decision.Remaining != max {
+		t.Errorf("Check after unlock = %+v, want allowed", decision)
+	}
+}
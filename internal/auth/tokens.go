@@ -0,0 +1,341 @@
+// Package auth - Refresh token rotation and revocation
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aethra/genesis/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Revocation reasons recorded on refresh tokens and the revocation list
+const (
+	RevokeReasonLogout    = "logout"
+	RevokeReasonLogoutAll = "logout_all"
+	RevokeReasonReuse     = "reuse"
+	// RevokeReasonPasswordReset ends every session when a password is reset
+	RevokeReasonPasswordReset = "password_reset"
+	// RevokeReasonSessionRevoked is a user ending one of their sessions
+	RevokeReasonSessionRevoked = "session_revoked"
+	// RevokeReasonAdmin is an admin forcing a user to log out
+	RevokeReasonAdmin = "admin_logout"
+	// RevokeReasonImpersonationEnded is an admin ending an impersonation
+	RevokeReasonImpersonationEnded = "impersonation_ended"
+	// RevokeReasonTenantSwitch ends a session moved to another tenant
+	RevokeReasonTenantSwitch = "tenant_switch"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for refresh tokens that are malformed,
+	// expired or unknown to the server
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is
+	// presented again; the whole token family has been revoked
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+	// ErrTokenRevoked is returned for tokens revoked by logout
+	ErrTokenRevoked = errors.New("token has been revoked")
+)
+
+// ClientInfo identifies the client a token pair was issued to
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+// TokenService issues token pairs backed by server-side refresh token records,
+// rotates refresh tokens and maintains the access token revocation list
+type TokenService struct {
+	db  *gorm.DB
+	jwt *JWTService
+}
+
+// NewTokenService creates a new token service
+func NewTokenService(db *gorm.DB, jwtService *JWTService) *TokenService {
+	return &TokenService{db: db, jwt: jwtService}
+}
+
+// SigningKeys returns the keys that sign and verify tokens
+func (s *TokenService) SigningKeys() *SigningKeyService {
+	return s.jwt.keys
+}
+
+// Issue creates a token pair starting a new token family, as on login
+func (s *TokenService) Issue(userID, tenantID uuid.UUID, email string, roles []string, client ClientInfo) (*TokenPair, error) {
+	return s.issue(s.db, userID, tenantID, uuid.New(), nil, email, roles, client)
+}
+
+// IssueChallenge creates a two-factor login challenge for a user who passed
+// the password step
+func (s *TokenService) IssueChallenge(userID, tenantID uuid.UUID, email string) (string, time.Time, error) {
+	return s.jwt.GenerateChallengeToken(userID, tenantID, email)
+}
+
+// ValidateChallenge validates a two-factor login challenge
+func (s *TokenService) ValidateChallenge(token string) (*Claims, error) {
+	return s.jwt.ValidateChallengeToken(token)
+}
+
+// IssuePasswordChange creates a forced password change for a user who passed
+// the password step with an expired password
+func (s *TokenService) IssuePasswordChange(userID, tenantID uuid.UUID, email string) (string, time.Time, error) {
+	return s.jwt.GeneratePasswordChangeToken(userID, tenantID, email)
+}
+
+// ValidatePasswordChange validates a forced password change token
+func (s *TokenService) ValidatePasswordChange(token string) (*Claims, error) {
+	return s.jwt.ValidatePasswordChangeToken(token)
+}
+
+func (s *TokenService) issue(tx *gorm.DB, userID, tenantID, familyID uuid.UUID, parentID *uuid.UUID, email string, roles []string, client ClientInfo) (*TokenPair, error) {
+	pair, err := s.jwt.GenerateTokenPair(userID, tenantID, familyID, email, roles)
+	if err != nil {
+		return nil, err
+	}
+
+	record := models.RefreshToken{
+		ID:              pair.RefreshTokenID,
+		TenantID:        tenantID,
+		UserID:          userID,
+		FamilyID:        familyID,
+		ParentID:        parentID,
+		AccessJTI:       pair.AccessTokenID,
+		AccessExpiresAt: pair.ExpiresAt,
+		ExpiresAt:       pair.RefreshExpiresAt,
+		IPAddress:       client.IPAddress,
+		UserAgent:       client.UserAgent,
+		CreatedAt:       time.Now(),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	if err := saveSession(tx, &record, client); err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// Refresh consumes a refresh token and issues its successor in the same
+// family. Presenting a token that was already consumed revokes the family and
+// returns ErrRefreshTokenReused.
+func (s *TokenService) Refresh(refreshToken string, client ClientInfo) (*TokenPair, error) {
+	claims, err := s.jwt.ValidateRefreshToken(refreshToken)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	var pair *TokenPair
+	var reused *models.RefreshToken
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var record models.RefreshToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", tokenID, claims.UserID).
+			First(&record).Error
+		if err == gorm.ErrRecordNotFound {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+
+		if record.RevokedAt != nil {
+			return ErrTokenRevoked
+		}
+		if record.UsedAt != nil {
+			// Someone is replaying a rotated token: either the legitimate client
+			// or an attacker holds a stale copy, so end the whole login
+			if err := revokeWhere(tx, "family_id = ?", record.FamilyID, RevokeReasonReuse); err != nil {
+				return err
+			}
+			if err := revokeSessions(tx, "id = ?", record.FamilyID, RevokeReasonReuse); err != nil {
+				return err
+			}
+			reused = &record
+			return nil
+		}
+		if time.Now().After(record.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		// Reload the user so role and status changes apply on refresh
+		var user struct {
+			Email    string
+			IsActive bool
+		}
+		err = tx.Table("users").
+			Select("email, is_active").
+			Where("id = ?", record.UserID).
+			First(&user).Error
+		if err != nil || !user.IsActive {
+			return ErrInvalidRefreshToken
+		}
+
+		roles := roleCodes(tx, record.UserID, record.TenantID)
+
+		if err := tx.Model(&record).Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		pair, err = s.issue(tx, record.UserID, record.TenantID, record.FamilyID, &record.ID, user.Email, roles, client)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if reused != nil {
+		s.auditReuse(reused, client)
+		return nil, ErrRefreshTokenReused
+	}
+	return pair, nil
+}
+
+// auditReuse records a detected refresh token replay in the audit log
+func (s *TokenService) auditReuse(record *models.RefreshToken, client ClientInfo) {
+	userID := record.UserID
+	s.db.Create(&models.AuditLog{
+		ID:       uuid.New(),
+		TenantID: record.TenantID,
+		UserID:   &userID,
+		Action:   "refresh_token_reuse",
+		NewValues: models.JSONB{
+			"family_id":   record.FamilyID,
+			"token_id":    record.ID,
+			"issued_ip":   record.IPAddress,
+			"replayed_at": time.Now(),
+		},
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		CreatedAt: time.Now(),
+	})
+}
+
+// RevokeFamily revokes every token issued for one login and ends its session
+func (s *TokenService) RevokeFamily(familyID uuid.UUID, reason string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := revokeWhere(tx, "family_id = ?", familyID, reason); err != nil {
+			return err
+		}
+		return revokeSessions(tx, "id = ?", familyID, reason)
+	})
+}
+
+// RevokeUser revokes every token issued to a user, across all logins, and
+// ends all of their sessions
+func (s *TokenService) RevokeUser(userID uuid.UUID, reason string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := revokeWhere(tx, "user_id = ?", userID, reason); err != nil {
+			return err
+		}
+		return revokeSessions(tx, "user_id = ?", userID, reason)
+	})
+}
+
+// revokeWhere revokes the refresh tokens matching the condition and puts
+// their still-valid access tokens on the revocation list
+func revokeWhere(tx *gorm.DB, condition string, value interface{}, reason string) error {
+	err := tx.Exec(`
+		INSERT INTO revoked_tokens (jti, user_id, reason, expires_at, revoked_at)
+		SELECT access_jti, user_id, ?, access_expires_at, CURRENT_TIMESTAMP
+		FROM refresh_tokens
+		WHERE `+condition+` AND revoked_at IS NULL AND access_expires_at > CURRENT_TIMESTAMP
+		ON CONFLICT (jti) DO NOTHING
+	`, reason, value).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+
+	err = tx.Model(&models.RefreshToken{}).
+		Where(condition+" AND revoked_at IS NULL", value).
+		Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"revoked_reason": reason,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
+
+// RevokeAccessToken puts a single access token on the revocation list
+func (s *TokenService) RevokeAccessToken(claims *Claims, reason string) error {
+	jti, err := uuid.Parse(claims.ID)
+	if err != nil || claims.ExpiresAt == nil {
+		return nil
+	}
+	userID := claims.UserID
+	return s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RevokedToken{
+		JTI:       jti,
+		UserID:    &userID,
+		Reason:    reason,
+		ExpiresAt: claims.ExpiresAt.Time,
+		RevokedAt: time.Now(),
+	}).Error
+}
+
+// IsRevoked reports whether an access token is on the revocation list or
+// belongs to a revoked session
+func (s *TokenService) IsRevoked(claims *Claims) (bool, error) {
+	id, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return true, nil
+	}
+	var revoked bool
+	err = s.db.Raw(`
+		SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = ?)
+			OR EXISTS (SELECT 1 FROM sessions WHERE id = ? AND revoked_at IS NOT NULL)
+	`, id, claims.FamilyID).Scan(&revoked).Error
+	return revoked, err
+}
+
+// ValidateAccessToken validates an access token and checks it against the
+// revocation list and its session
+func (s *TokenService) ValidateAccessToken(tokenString string) (*Claims, error) {
+	claims, err := s.jwt.ValidateAccessToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	revoked, err := s.IsRevoked(claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
+}
+
+// PurgeExpired deletes revocation entries, refresh token records and sessions
+// that can no longer be presented. Impersonation sessions are kept as the
+// record of who impersonated whom.
+func (s *TokenService) PurgeExpired() error {
+	if err := s.db.Where("expires_at < CURRENT_TIMESTAMP").Delete(&models.RevokedToken{}).Error; err != nil {
+		return err
+	}
+	if err := s.db.Where("expires_at < CURRENT_TIMESTAMP").Delete(&models.RefreshToken{}).Error; err != nil {
+		return err
+	}
+	return s.db.Where("expires_at < CURRENT_TIMESTAMP AND impersonator_id IS NULL").Delete(&models.Session{}).Error
+}
+
+// RunPurge calls PurgeExpired now and then at every interval, for the life
+// of the process
+func (s *TokenService) RunPurge(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for {
+		if err := s.PurgeExpired(); err != nil {
+			log.Printf("Warning: failed to purge expired tokens: %v", err)
+		}
+		<-ticker.C
+	}
+}
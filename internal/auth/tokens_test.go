@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aethra/genesis/internal/crypto"
+)
+
+// testTokenService returns a token service signing with keys stored in db
+func testTokenService(t *testing.T) *TokenService {
+	t.Helper()
+	db := testDB(t)
+	keys, err := NewSigningKeyService(db, crypto.NewService("genesis-test-encryption-key-0001"))
+	if err != nil {
+		t.Fatalf("NewSigningKeyService: %v", err)
+	}
+	return NewTokenService(db, NewJWTService(keys))
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	tokens := testTokenService(t)
+	tenantID, _ := testTenant(t, tokens.db)
+	userID := testUser(t, tokens.db, tenantID, "reuse@example.com")
+	client := ClientInfo{IPAddress: "127.0.0.1", UserAgent: "test"}
+
+	first, err := tokens.Issue(userID, tenantID, "reuse@example.com", nil, client)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	second, err := tokens.Refresh(first.RefreshToken, client)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if _, err := tokens.ValidateAccessToken(second.AccessToken); err != nil {
+		t.Fatalf("rotated access token rejected: %v", err)
+	}
+
+	// Replaying the consumed token ends the login for everyone holding it
+	if _, err := tokens.Refresh(first.RefreshToken, client); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("replayed refresh token: error = %v, want %v", err, ErrRefreshTokenReused)
+	}
+	if _, err := tokens.Refresh(second.RefreshToken, client); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("refresh after reuse: error = %v, want %v", err, ErrTokenRevoked)
+	}
+	if _, err := tokens.ValidateAccessToken(second.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("access token after reuse: error = %v, want %v", err, ErrTokenRevoked)
+	}
+
+	var audited int64
+	tokens.db.Table("audit_log").Where("tenant_id = ? AND action = ?", tenantID, "refresh_token_reuse").Count(&audited)
+	if audited != 1 {
+		t.Errorf("reuse audit entries = %d, want 1", audited)
+	}
+}
+
+func TestRefreshTokenInactiveUser(t *testing.T) {
+	tokens := testTokenService(t)
+	tenantID, _ := testTenant(t, tokens.db)
+	userID := testUser(t, tokens.db, tenantID, "inactive@example.com")
+
+	pair, err := tokens.Issue(userID, tenantID, "inactive@example.com", nil, ClientInfo{})
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	tokens.db.Table("users").Where("id = ?", userID).Update("is_active", false)
+	if _, err := tokens.Refresh(pair.RefreshToken, ClientInfo{}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh for a deactivated user: error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+}
@@ -0,0 +1,275 @@
+// Package auth - Two-factor authentication
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aethra/genesis/internal/crypto"
+	"github.com/aethra/genesis/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// recoveryCodeCount is how many recovery codes a user holds at a time
+const recoveryCodeCount = 10
+
+var (
+	// ErrTwoFactorNotEnabled is returned when a user has not enrolled
+	ErrTwoFactorNotEnabled = errors.New("two-factor authentication is not enabled")
+	// ErrTwoFactorAlreadyEnabled is returned when enrolling twice
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrInvalidTwoFactorCode is returned for wrong, expired or replayed codes
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+)
+
+// TwoFactorEnrollment is returned when a user starts enrolling
+type TwoFactorEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"otpauth_uri"`
+}
+
+// TwoFactorService manages TOTP enrolment, verification and recovery codes
+type TwoFactorService struct {
+	db     *gorm.DB
+	crypto *crypto.Service
+	issuer string
+}
+
+// NewTwoFactorService creates a new two-factor service. Secrets are
+// encrypted with cryptoService; issuer is the name shown in authenticator apps.
+func NewTwoFactorService(db *gorm.DB, cryptoService *crypto.Service, issuer string) *TwoFactorService {
+	return &TwoFactorService{db: db, crypto: cryptoService, issuer: issuer}
+}
+
+// IsEnabled reports whether a user has confirmed TOTP enrolment
+func (s *TwoFactorService) IsEnabled(userID uuid.UUID) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.UserTwoFactor{}).
+		Where("user_id = ? AND enabled_at IS NOT NULL", userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// RemainingRecoveryCodes returns how many unused recovery codes a user has
+func (s *TwoFactorService) RemainingRecoveryCodes(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := s.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// BeginEnrollment generates a new secret for a user. Enrolment takes effect
+// once ConfirmEnrollment accepts a code generated from it.
+func (s *TwoFactorService) BeginEnrollment(userID uuid.UUID, account string) (*TwoFactorEnrollment, error) {
+	enabled, err := s.IsEnabled(userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := s.crypto.Encrypt(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt TOTP secret: %w", err)
+	}
+
+	// Starting over replaces any unconfirmed secret
+	record := models.UserTwoFactor{UserID: userID, Secret: encrypted, CreatedAt: time.Now()}
+	err = s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"secret", "last_used_step", "created_at"}),
+	}).Create(&record).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to store TOTP secret: %w", err)
+	}
+
+	return &TwoFactorEnrollment{
+		Secret:          secret,
+		ProvisioningURI: TOTPProvisioningURI(s.issuer, account, secret),
+	}, nil
+}
+
+// ConfirmEnrollment enables 2FA if the code matches the pending secret and
+// returns a fresh set of recovery codes
+func (s *TwoFactorService) ConfirmEnrollment(userID uuid.UUID, code string) ([]string, error) {
+	var codes []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		record, err := s.lockRecord(tx, userID)
+		if err != nil {
+			return err
+		}
+		if record.EnabledAt != nil {
+			return ErrTwoFactorAlreadyEnabled
+		}
+		step, err := s.checkTOTP(record, code)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(record).Updates(map[string]interface{}{
+			"enabled_at":     time.Now(),
+			"last_used_step": step,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// HasPendingEnrollment reports whether a user started enrolling but has not
+// confirmed a code yet
+func (s *TwoFactorService) HasPendingEnrollment(userID uuid.UUID) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.UserTwoFactor{}).
+		Where("user_id = ? AND enabled_at IS NULL", userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Verify checks a TOTP code or, failing that, a recovery code, which is then
+// used up. It reports whether a recovery code was used.
+func (s *TwoFactorService) Verify(userID uuid.UUID, code string) (bool, error) {
+	code = normalizeTwoFactorCode(code)
+	usedRecovery := false
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		record, err := s.lockRecord(tx, userID)
+		if err != nil {
+			return err
+		}
+		if record.EnabledAt == nil {
+			return ErrTwoFactorNotEnabled
+		}
+
+		if len(code) == totpDigits {
+			step, err := s.checkTOTP(record, code)
+			if err != nil {
+				return err
+			}
+			return tx.Model(record).Update("last_used_step", step).Error
+		}
+
+		result := tx.Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashRecoveryCode(code)).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidTwoFactorCode
+		}
+		usedRecovery = true
+		return nil
+	})
+	return usedRecovery, err
+}
+
+// RegenerateRecoveryCodes replaces a user's recovery codes
+func (s *TwoFactorService) RegenerateRecoveryCodes(userID uuid.UUID) ([]string, error) {
+	enabled, err := s.IsEnabled(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+
+	var codes []string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// Disable removes a user's TOTP secret and recovery codes
+func (s *TwoFactorService) Disable(userID uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.UserTwoFactor{}).Error
+	})
+}
+
+func (s *TwoFactorService) lockRecord(tx *gorm.DB, userID uuid.UUID) (*models.UserTwoFactor, error) {
+	var record models.UserTwoFactor
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ?", userID).
+		First(&record).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// checkTOTP validates a code against the stored secret, rejecting codes from
+// a time step that was already used
+func (s *TwoFactorService) checkTOTP(record *models.UserTwoFactor, code string) (int64, error) {
+	secret, err := s.crypto.Decrypt(record.Secret)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decrypt TOTP secret: %w", err)
+	}
+	step, ok := ValidateTOTP(secret, normalizeTwoFactorCode(code), time.Now())
+	if !ok || step <= record.LastUsedStep {
+		return 0, ErrInvalidTwoFactorCode
+	}
+	return step, nil
+}
+
+// replaceRecoveryCodes deletes a user's recovery codes and stores a new set,
+// returning the plaintext codes
+func replaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	records := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		// 16 hex characters: 64 bits, shown as four groups of four
+		raw, err := randomHex(8)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+		records[i] = models.RecoveryCode{
+			ID:        uuid.New(),
+			UserID:    userID,
+			CodeHash:  hashRecoveryCode(raw),
+			CreatedAt: time.Now(),
+		}
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to store recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+// normalizeTwoFactorCode strips the spaces and dashes users type
+func normalizeTwoFactorCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
+
+func hashRecoveryCode(code string) string {
+	return sha256Hex(normalizeTwoFactorCode(code))
+}
@@ -0,0 +1,86 @@
+// Package auth - Single-use tokens sent by email
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aethra/genesis/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// User token purposes
+const (
+	PurposePasswordReset     = "password_reset"
+	PurposeEmailVerification = "email_verification"
+)
+
+// ErrInvalidUserToken is returned for unknown, expired or already used tokens
+var ErrInvalidUserToken = errors.New("invalid or expired token")
+
+// UserTokenService issues and consumes single-use tokens for links sent by
+// email
+type UserTokenService struct {
+	db *gorm.DB
+}
+
+// NewUserTokenService creates a new user token service
+func NewUserTokenService(db *gorm.DB) *UserTokenService {
+	return &UserTokenService{db: db}
+}
+
+// Create issues a token for a purpose, invalidating the user's earlier
+// unused tokens for the same purpose, and returns the plaintext
+func (s *UserTokenService) Create(userID uuid.UUID, purpose string, ttl time.Duration, ipAddress string) (string, error) {
+	token, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Update("used_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&models.UserToken{
+			ID:        uuid.New(),
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: sha256Hex(token),
+			ExpiresAt: time.Now().Add(ttl),
+			IPAddress: ipAddress,
+			CreatedAt: time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create token: %w", err)
+	}
+	return token, nil
+}
+
+// Consume marks a token used and returns its user. Each token succeeds once.
+func (s *UserTokenService) Consume(purpose, token string) (uuid.UUID, error) {
+	return s.ConsumeTx(s.db, purpose, token)
+}
+
+// ConsumeTx consumes a token within a transaction, so the token stays usable
+// if the change it authorizes is rolled back
+func (s *UserTokenService) ConsumeTx(tx *gorm.DB, purpose, token string) (uuid.UUID, error) {
+	var userIDs []uuid.UUID
+	err := tx.Raw(`
+		UPDATE user_tokens SET used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING user_id
+	`, sha256Hex(token), purpose).Scan(&userIDs).Error
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if len(userIDs) == 0 {
+		return uuid.Nil, ErrInvalidUserToken
+	}
+	return userIDs[0], nil
+}
@@ -12,14 +12,21 @@ import (
 
 // Service handles encryption and decryption operations
 type Service struct {
-	key []byte
+	key        []byte
+	configured bool
 }
 
 // NewService creates a new crypto service with the given encryption key
 func NewService(encryptionKey string) *Service {
 	key := make([]byte, 32)
 	copy(key, []byte(encryptionKey))
-	return &Service{key: key}
+	return &Service{key: key, configured: encryptionKey != ""}
+}
+
+// Configured reports whether the service was given an encryption key. Without
+// one it encrypts with a key of zeros, which protects nothing.
+func (s *Service) Configured() bool {
+	return s != nil && s.configured
 }
 
 // Encrypt encrypts a plaintext string using AES-GCM
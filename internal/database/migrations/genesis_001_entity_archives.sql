@@ -0,0 +1,24 @@
+-- ============================================================================
+-- ENTITY ARCHIVES
+-- Deleted entities whose data table was renamed aside instead of dropped
+-- ============================================================================
+
+CREATE TABLE entity_archives (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+
+    entity_code VARCHAR(50) NOT NULL,
+    entity_name VARCHAR(100),
+
+    original_table VARCHAR(100) NOT NULL,       -- 'data_customers'
+    archived_table VARCHAR(100) NOT NULL,       -- 'data_customers_archived_20250101120000'
+
+    snapshot JSONB NOT NULL,                    -- Entity and field definitions at archive time
+    index_names JSONB DEFAULT '{}',             -- Archived index name -> original index name
+
+    archived_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    archived_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    restored_at TIMESTAMP
+);
+
+CREATE INDEX idx_entity_archives_tenant ON entity_archives(tenant_id, entity_code);
@@ -0,0 +1,43 @@
+-- ============================================================================
+-- REFRESH TOKENS & REVOCATION
+-- Each login starts a token family; every refresh consumes one refresh token
+-- and issues its successor in the same family. Presenting a consumed token
+-- again revokes the whole family.
+-- ============================================================================
+
+CREATE TABLE refresh_tokens (
+    id UUID PRIMARY KEY,                        -- jti of the refresh token
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id UUID NOT NULL,                    -- shared by every rotation of one login
+    parent_id UUID,                             -- refresh token this one replaced
+
+    access_jti UUID NOT NULL,                   -- access token issued alongside
+    access_expires_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+
+    used_at TIMESTAMP,                          -- set when rotated
+    revoked_at TIMESTAMP,
+    revoked_reason VARCHAR(30),                 -- 'logout', 'logout_all', 'reuse'
+
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_refresh_tokens_user ON refresh_tokens(user_id);
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens(family_id);
+
+-- ----------------------------------------------------------------------------
+-- Revocation list: access tokens rejected before they expire.
+-- Rows can be purged once expires_at has passed.
+-- ----------------------------------------------------------------------------
+CREATE TABLE revoked_tokens (
+    jti UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    reason VARCHAR(30),
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_revoked_tokens_expires ON revoked_tokens(expires_at);
@@ -0,0 +1,32 @@
+-- ============================================================================
+-- API KEYS
+-- Personal access tokens and service-account keys for integrations. Only a
+-- SHA-256 hash of the secret is stored; the prefix identifies the key.
+-- ============================================================================
+
+-- Service accounts are users without a password that authenticate with keys
+ALTER TABLE users ADD COLUMN is_service_account BOOLEAN DEFAULT FALSE;
+
+CREATE TABLE api_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(20) NOT NULL UNIQUE,         -- shown in listings, used for lookup
+    key_hash VARCHAR(64) NOT NULL,              -- hex SHA-256 of the full key
+
+    -- {"customers": ["view", "export"], "*": ["view"]}; NULL = all of the user's permissions
+    scopes JSONB,
+
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    last_used_ip VARCHAR(45),
+
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX idx_api_keys_tenant ON api_keys(tenant_id);
+CREATE INDEX idx_api_keys_user ON api_keys(user_id);
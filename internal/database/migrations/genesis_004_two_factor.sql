@@ -0,0 +1,22 @@
+-- ============================================================================
+-- TWO-FACTOR AUTHENTICATION
+-- TOTP (RFC 6238) enrolment and one-time recovery codes
+-- ============================================================================
+
+CREATE TABLE user_two_factor (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret TEXT NOT NULL,                       -- base32 secret, encrypted with ENCRYPTION_KEY
+    enabled_at TIMESTAMP,                       -- NULL until the first code is confirmed
+    last_used_step BIGINT DEFAULT 0,            -- last accepted time step, blocks replays
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE user_recovery_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,             -- hex SHA-256 of the normalized code
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_user_recovery_codes_user ON user_recovery_codes(user_id);
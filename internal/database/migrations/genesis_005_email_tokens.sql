@@ -0,0 +1,25 @@
+-- ============================================================================
+-- EMAIL VERIFICATION & PASSWORD RESET
+-- ============================================================================
+
+-- Users created by admins or setup count as verified; self-registration
+-- inserts NULL until the emailed link is opened
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+UPDATE users SET email_verified_at = created_at;
+
+-- ----------------------------------------------------------------------------
+-- Single-use tokens sent by email. Only a SHA-256 hash is stored; issuing a
+-- new token for a purpose invalidates the user's earlier ones.
+-- ----------------------------------------------------------------------------
+CREATE TABLE user_tokens (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(30) NOT NULL,               -- 'password_reset', 'email_verification'
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    ip_address VARCHAR(45),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_user_tokens_user ON user_tokens(user_id, purpose);
@@ -0,0 +1,59 @@
+-- ============================================================================
+-- OPENID CONNECT SINGLE SIGN-ON
+-- ============================================================================
+
+-- ----------------------------------------------------------------------------
+-- One identity provider per tenant. The client secret is encrypted with
+-- ENCRYPTION_KEY; role_mapping maps values of role_claim to role codes.
+-- ----------------------------------------------------------------------------
+CREATE TABLE oidc_providers (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL UNIQUE REFERENCES tenants(id) ON DELETE CASCADE,
+    issuer VARCHAR(500) NOT NULL,
+    client_id VARCHAR(255) NOT NULL,
+    client_secret TEXT,
+    scopes VARCHAR(255) NOT NULL DEFAULT 'openid email profile',
+    redirect_url VARCHAR(500),                  -- defaults to <APP_BASE_URL>/app/sso/callback
+    role_claim VARCHAR(100),                    -- e.g. 'groups' or 'realm_access.roles'
+    role_mapping JSONB DEFAULT '{}',            -- {"Admins": ["admin"], "Staff": ["user"]}
+    default_role VARCHAR(50),
+    allow_signup BOOLEAN DEFAULT TRUE,          -- create users on first sign-on
+    is_enabled BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- ----------------------------------------------------------------------------
+-- Pending authorization requests: the state parameter (hashed), the PKCE
+-- verifier and the nonce expected in the ID token. Each is consumed once.
+-- ----------------------------------------------------------------------------
+CREATE TABLE oidc_login_states (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    provider_id UUID NOT NULL REFERENCES oidc_providers(id) ON DELETE CASCADE,
+    state_hash VARCHAR(64) NOT NULL UNIQUE,
+    code_verifier VARCHAR(128) NOT NULL,
+    nonce VARCHAR(64) NOT NULL,
+    redirect_uri VARCHAR(500) NOT NULL,
+    return_to VARCHAR(500),
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_oidc_login_states_expires ON oidc_login_states(expires_at);
+
+-- ----------------------------------------------------------------------------
+-- Links between users and their identity at a provider (the 'sub' claim)
+-- ----------------------------------------------------------------------------
+CREATE TABLE user_identities (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider_id UUID NOT NULL REFERENCES oidc_providers(id) ON DELETE CASCADE,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    last_login_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(provider_id, subject)
+);
+
+CREATE INDEX idx_user_identities_user ON user_identities(user_id);
@@ -0,0 +1,30 @@
+-- ============================================================================
+-- SESSIONS
+-- One row per login (refresh token family; the session id is the family id).
+-- Written on login and refresh; revoking a session rejects its access tokens
+-- immediately and its refresh tokens on next use.
+-- ============================================================================
+
+CREATE TABLE sessions (
+    id UUID PRIMARY KEY,                        -- family_id of the session's refresh tokens
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device VARCHAR(100),                        -- e.g. 'Firefox on Windows'
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,              -- expiry of the latest refresh token
+    revoked_at TIMESTAMP,
+    revoked_reason VARCHAR(30)
+);
+
+CREATE INDEX idx_sessions_user ON sessions(user_id);
+
+-- Logins made before sessions were tracked
+INSERT INTO sessions (id, tenant_id, user_id, ip_address, user_agent, created_at, last_seen_at, expires_at, revoked_at, revoked_reason)
+SELECT DISTINCT ON (family_id)
+    family_id, tenant_id, user_id, ip_address, user_agent,
+    MIN(created_at) OVER (PARTITION BY family_id), created_at, expires_at, revoked_at, revoked_reason
+FROM refresh_tokens
+ORDER BY family_id, created_at DESC;
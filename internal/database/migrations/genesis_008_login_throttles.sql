@@ -0,0 +1,23 @@
+-- ============================================================================
+-- LOGIN THROTTLING
+-- Failed attempts per tenant and key, shared by every server instance.
+-- Scopes: 'account' (lower-cased email), 'ip', '2fa' (user id) and 'mail'
+-- (lower-cased email, for reset and verification emails). Counters reset
+-- after the tenant's window passes without failures or once a lockout ends.
+-- ============================================================================
+
+CREATE TABLE login_throttles (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    scope VARCHAR(20) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    first_failure_at TIMESTAMP,
+    last_failure_at TIMESTAMP,
+    locked_until TIMESTAMP,
+    lockouts INTEGER NOT NULL DEFAULT 0,       -- lockouts so far, for review
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(tenant_id, scope, key)
+);
+
+CREATE INDEX idx_login_throttles_locked ON login_throttles(tenant_id, locked_until);
@@ -0,0 +1,18 @@
+-- ============================================================================
+-- PASSWORD POLICY
+-- password_changed_at drives the tenant's maximum password age; existing
+-- users start counting from this migration. password_history keeps hashes of
+-- recent passwords so the policy can reject reuse.
+-- ============================================================================
+
+ALTER TABLE users ADD COLUMN password_changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+
+CREATE TABLE password_history (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_password_history_user ON password_history(user_id, created_at DESC);
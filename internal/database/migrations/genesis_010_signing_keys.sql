@@ -0,0 +1,20 @@
+-- ============================================================================
+-- TOKEN SIGNING KEYS
+-- Asymmetric keys that sign access, refresh and login step tokens. The newest
+-- unretired key signs; retired keys still verify until expires_at, which is
+-- at least the longest token lifetime after retirement. Public keys are
+-- published at /.well-known/jwks.json.
+-- ============================================================================
+
+CREATE TABLE signing_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    kid VARCHAR(64) NOT NULL UNIQUE,            -- RFC 7638 thumbprint of the public key
+    algorithm VARCHAR(10) NOT NULL,             -- 'RS256' or 'EdDSA'
+    private_key TEXT NOT NULL,                  -- PKCS#8, encrypted with ENCRYPTION_KEY
+    public_key TEXT NOT NULL,                   -- PKIX, base64
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    retired_at TIMESTAMP,                       -- replaced as the signing key
+    expires_at TIMESTAMP                        -- no longer verifies tokens
+);
+
+CREATE INDEX idx_signing_keys_expires ON signing_keys(expires_at);
@@ -0,0 +1,25 @@
+-- ============================================================================
+-- ROLE INHERITANCE AND MODULE PERMISSIONS
+-- A role inherits every permission of its parent chain. Permissions target
+-- either one entity or every entity of a module, and either allow or deny
+-- their actions; see auth.PermissionService for how they are resolved.
+-- ============================================================================
+
+ALTER TABLE roles ADD COLUMN parent_id UUID REFERENCES roles(id) ON DELETE SET NULL;
+CREATE INDEX idx_roles_parent ON roles(parent_id);
+
+ALTER TABLE permissions ADD COLUMN module_id UUID REFERENCES modules(id) ON DELETE CASCADE;
+ALTER TABLE permissions ADD COLUMN effect VARCHAR(10) NOT NULL DEFAULT 'allow';
+
+ALTER TABLE permissions ADD CONSTRAINT permissions_effect_check
+    CHECK (effect IN ('allow', 'deny'));
+ALTER TABLE permissions ADD CONSTRAINT permissions_target_check
+    CHECK ((entity_id IS NULL) <> (module_id IS NULL));
+
+-- A role may both allow and deny on the same target
+ALTER TABLE permissions DROP CONSTRAINT IF EXISTS permissions_role_id_entity_id_key;
+CREATE UNIQUE INDEX idx_permissions_role_entity ON permissions(role_id, entity_id, effect)
+    WHERE entity_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_permissions_role_module ON permissions(role_id, module_id, effect)
+    WHERE module_id IS NOT NULL;
+CREATE INDEX idx_permissions_module ON permissions(module_id);
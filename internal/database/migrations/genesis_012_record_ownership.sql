@@ -0,0 +1,41 @@
+-- ============================================================================
+-- RECORD OWNERSHIP
+-- Every entity table records who created and last updated a record and who
+-- owns it. Existing records take their creator, and owner, from the audit
+-- log where it has one. Permissions can limit view, edit and delete to the
+-- records a user, or their team, owns.
+-- ============================================================================
+
+DO $$
+DECLARE
+    entity RECORD;
+    entity_table TEXT;
+BEGIN
+    FOR entity IN SELECT id, code, table_name FROM entities LOOP
+        entity_table := COALESCE(NULLIF(entity.table_name, ''), 'data_' || entity.code);
+        IF to_regclass(quote_ident(entity_table)) IS NULL THEN
+            CONTINUE;
+        END IF;
+
+        EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS created_by UUID REFERENCES users(id) ON DELETE SET NULL', entity_table);
+        EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS updated_by UUID REFERENCES users(id) ON DELETE SET NULL', entity_table);
+        EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS owner_id UUID REFERENCES users(id) ON DELETE SET NULL', entity_table);
+        EXECUTE format('CREATE INDEX IF NOT EXISTS %I ON %I(owner_id)', 'idx_' || entity_table || '_owner_id', entity_table);
+
+        EXECUTE format(
+            'UPDATE %I t SET created_by = a.user_id, updated_by = a.user_id, owner_id = a.user_id
+             FROM audit_log a
+             WHERE a.entity_id = $1 AND a.action = ''create'' AND a.record_id = t.id AND t.owner_id IS NULL',
+            entity_table) USING entity.id;
+    END LOOP;
+END $$;
+
+ALTER TABLE permissions ADD COLUMN view_scope VARCHAR(10) NOT NULL DEFAULT 'all';
+ALTER TABLE permissions ADD COLUMN edit_scope VARCHAR(10) NOT NULL DEFAULT 'all';
+ALTER TABLE permissions ADD COLUMN delete_scope VARCHAR(10) NOT NULL DEFAULT 'all';
+
+ALTER TABLE permissions ADD CONSTRAINT permissions_scope_check CHECK (
+    view_scope IN ('all', 'own', 'team') AND
+    edit_scope IN ('all', 'own', 'team') AND
+    delete_scope IN ('all', 'own', 'team')
+);
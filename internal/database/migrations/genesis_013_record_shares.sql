@@ -0,0 +1,32 @@
+-- ============================================================================
+-- RECORD SHARES
+-- Shares one record with one user, or with every holder of a role, on top of
+-- what role permissions allow. A share gives view or edit access and may
+-- expire; the entity-level view or edit permission is still required.
+-- ============================================================================
+
+CREATE TABLE record_shares (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    entity_id UUID NOT NULL REFERENCES entities(id) ON DELETE CASCADE,
+    record_id UUID NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    role_id UUID REFERENCES roles(id) ON DELETE CASCADE,
+    access VARCHAR(10) NOT NULL DEFAULT 'view',
+    expires_at TIMESTAMP,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT record_shares_access_check CHECK (access IN ('view', 'edit')),
+    CONSTRAINT record_shares_target_check CHECK ((user_id IS NULL) <> (role_id IS NULL))
+);
+
+CREATE UNIQUE INDEX idx_record_shares_user ON record_shares(entity_id, record_id, user_id)
+    WHERE user_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_record_shares_role ON record_shares(entity_id, record_id, role_id)
+    WHERE role_id IS NOT NULL;
+-- Access checks look shares up by grantee
+CREATE INDEX idx_record_shares_grantee_user ON record_shares(user_id, entity_id);
+CREATE INDEX idx_record_shares_grantee_role ON record_shares(role_id, entity_id);
+
+CREATE TRIGGER update_record_shares_updated_at BEFORE UPDATE ON record_shares FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
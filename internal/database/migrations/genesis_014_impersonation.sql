@@ -0,0 +1,16 @@
+-- ============================================================================
+-- IMPERSONATION
+-- An admin impersonating a user gets a short-lived session of that user with
+-- no refresh token. The session records who is impersonating and why, and is
+-- listed and revoked like any other session. Audit entries written during
+-- impersonation name the impersonator next to the impersonated user.
+-- ============================================================================
+
+ALTER TABLE sessions ADD COLUMN impersonator_id UUID REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE sessions ADD COLUMN impersonation_reason TEXT;
+
+CREATE INDEX idx_sessions_impersonator ON sessions(impersonator_id) WHERE impersonator_id IS NOT NULL;
+
+ALTER TABLE audit_log ADD COLUMN impersonator_id UUID REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_audit_log_impersonator ON audit_log(impersonator_id) WHERE impersonator_id IS NOT NULL;
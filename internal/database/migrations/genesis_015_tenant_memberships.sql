@@ -0,0 +1,37 @@
+-- ============================================================================
+-- TENANT MEMBERSHIPS
+-- One account, and one password, can belong to several tenants. Every user is
+-- a member of the tenant their account was created in, and admins add them to
+-- others. A member's roles in a tenant are their user_roles whose role
+-- belongs to that tenant. Tokens are issued for one tenant at a time; the
+-- user picks another with POST /auth/switch-tenant.
+-- ============================================================================
+
+CREATE TABLE tenant_memberships (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE(tenant_id, user_id)
+);
+
+CREATE INDEX idx_tenant_memberships_user ON tenant_memberships(user_id);
+
+INSERT INTO tenant_memberships (tenant_id, user_id, created_at)
+SELECT tenant_id, id, created_at FROM users WHERE tenant_id IS NOT NULL;
+
+-- New accounts join their own tenant, however they are created
+CREATE OR REPLACE FUNCTION add_home_tenant_membership()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.tenant_id IS NOT NULL THEN
+        INSERT INTO tenant_memberships (tenant_id, user_id) VALUES (NEW.tenant_id, NEW.id)
+        ON CONFLICT (tenant_id, user_id) DO NOTHING;
+    END IF;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER add_users_home_tenant_membership AFTER INSERT ON users FOR EACH ROW EXECUTE FUNCTION add_home_tenant_membership();
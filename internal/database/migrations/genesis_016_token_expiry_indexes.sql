@@ -0,0 +1,8 @@
+-- ============================================================================
+-- TOKEN EXPIRY INDEXES
+-- Expired refresh tokens and sessions are purged on a schedule, like the
+-- revocation list; index the column the purge selects on.
+-- ============================================================================
+
+CREATE INDEX idx_refresh_tokens_expires ON refresh_tokens(expires_at);
+CREATE INDEX idx_sessions_expires ON sessions(expires_at);
@@ -17,8 +17,31 @@ import (
 type DataEngine struct {
 	db           *gorm.DB
 	schemaEngine *SchemaEngine
+	rowFilters   RowFilterProvider
+
+	// impersonatorID is recorded on audit entries; see Impersonated
+	impersonatorID *uuid.UUID
+}
+
+// RowFilterProvider resolves which rows of an entity a user may act on: the
+// row filter they are subject to and, for view, edit and delete, whether they
+// are limited to records they or their team own. It also reports whether they
+// may view an entity at all and which of its fields are hidden from them,
+// for records loaded on their behalf from other entities.
+// auth.PermissionService satisfies it.
+type RowFilterProvider interface {
+	GetRowFilter(tenantID, userID uuid.UUID, entityCode string) (map[string]interface{}, error)
+	GetOwnershipScope(tenantID, userID uuid.UUID, entityCode, action string) (limited bool, teamRoles []uuid.UUID, err error)
+	GetViewPermission(tenantID, userID uuid.UUID, entityCode string) (canView bool, hiddenFields []string, err error)
 }
 
+// Actions an ownership scope can limit
+const (
+	accessView   = "view"
+	accessEdit   = "edit"
+	accessDelete = "delete"
+)
+
 // NewDataEngine creates a new data engine
 func NewDataEngine(db *gorm.DB, schemaEngine *SchemaEngine) *DataEngine {
 	return &DataEngine{
@@ -27,6 +50,27 @@ func NewDataEngine(db *gorm.DB, schemaEngine *SchemaEngine) *DataEngine {
 	}
 }
 
+// NewDataEngineWithPermissions creates a data engine that scopes reads,
+// writes and reference checks to the records the acting user may act on
+func NewDataEngineWithPermissions(db *gorm.DB, schemaEngine *SchemaEngine, rowFilters RowFilterProvider) *DataEngine {
+	return &DataEngine{
+		db:           db,
+		schemaEngine: schemaEngine,
+		rowFilters:   rowFilters,
+	}
+}
+
+// Impersonated returns an engine that records impersonatorID, the admin
+// acting as the user of each call, on the audit entries it writes
+func (e *DataEngine) Impersonated(impersonatorID *uuid.UUID) *DataEngine {
+	if impersonatorID == nil {
+		return e
+	}
+	impersonated := *e
+	impersonated.impersonatorID = impersonatorID
+	return &impersonated
+}
+
 // =============================================================================
 // QUERY TYPES
 // =============================================================================
@@ -40,6 +84,12 @@ type QueryParams struct {
 	Search   string                 `json:"search"`
 	Filters  map[string]interface{} `json:"filters"`
 	Include  []string               `json:"include"` // Relations to include
+
+	// UserID, when set, restricts results to the rows the user's row filter allows
+	UserID *uuid.UUID `json:"-"`
+	// IncludeAllowed, when set, reports whether records of a related entity
+	// may be included; API key scopes use it to hide entities beyond the key
+	IncludeAllowed func(entityCode string) bool `json:"-"`
 }
 
 // QueryResult represents the result of a list query
@@ -87,6 +137,12 @@ func (e *DataEngine) List(tenantID uuid.UUID, entityCode string, params QueryPar
 		query = query.Where("deleted_at IS NULL")
 	}
 
+	// Apply the user's row filter and ownership scope
+	query, err = e.accessScope(query, tenantID, schema, params.UserID, accessView)
+	if err != nil {
+		return nil, err
+	}
+
 	// Apply search with parameterized query
 	if params.Search != "" {
 		searchCols := e.getSearchableColumns(schema.Entity.Fields)
@@ -182,6 +238,13 @@ func (e *DataEngine) List(tenantID uuid.UUID, entityCode string, params QueryPar
 		totalPages++
 	}
 
+	// Attach related records requested with include
+	if len(params.Include) > 0 && len(results) > 0 {
+		if err := e.attachIncludes(tenantID, schema, results, params); err != nil {
+			return nil, err
+		}
+	}
+
 	return &QueryResult{
 		Data:       results,
 		Total:      total,
@@ -191,8 +254,9 @@ func (e *DataEngine) List(tenantID uuid.UUID, entityCode string, params QueryPar
 	}, nil
 }
 
-// Get returns a single record by ID
-func (e *DataEngine) Get(tenantID uuid.UUID, entityCode string, recordID uuid.UUID) (map[string]interface{}, error) {
+// Get returns a single record by ID. When userID is set, records outside the
+// user's row filter or view scope are not found.
+func (e *DataEngine) Get(tenantID uuid.UUID, entityCode string, recordID uuid.UUID, userID *uuid.UUID) (map[string]interface{}, error) {
 	schema, err := e.schemaEngine.GetEntitySchema(tenantID, entityCode)
 	if err != nil {
 		return nil, err
@@ -209,6 +273,11 @@ func (e *DataEngine) Get(tenantID uuid.UUID, entityCode string, recordID uuid.UU
 		query = query.Where("deleted_at IS NULL")
 	}
 
+	query, err = e.accessScope(query, tenantID, schema, userID, accessView)
+	if err != nil {
+		return nil, err
+	}
+
 	var result map[string]interface{}
 	rows, err := query.Rows()
 	if err != nil {
@@ -244,6 +313,53 @@ func (e *DataEngine) Get(tenantID uuid.UUID, entityCode string, recordID uuid.UU
 	return result, nil
 }
 
+// FindByField returns the records whose field matches any of the given values,
+// honouring soft deletes and, when userID is set, the user's row filter and
+// view scope. It is
+// the batch lookup behind relation loading: one call resolves a whole level of
+// belongs_to (field "id") or has_many (the foreign key field) references.
+func (e *DataEngine) FindByField(tenantID uuid.UUID, entityCode, fieldCode string, values []interface{}, userID *uuid.UUID) ([]map[string]interface{}, error) {
+	if len(values) == 0 {
+		return []map[string]interface{}{}, nil
+	}
+
+	schema, err := e.schemaEngine.GetEntitySchema(tenantID, entityCode)
+	if err != nil {
+		return nil, err
+	}
+
+	tableName, err := e.safeTableName(schema.Entity)
+	if err != nil {
+		return nil, err
+	}
+
+	if fieldCode != "id" && !e.isValidField(schema.Entity.Fields, fieldCode) {
+		return nil, fmt.Errorf("unknown field '%s'", fieldCode)
+	}
+	quotedField, err := security.SafeIdentifier(fieldCode)
+	if err != nil {
+		return nil, err
+	}
+
+	query := e.db.Table(tableName).
+		Where("tenant_id = ?", tenantID).
+		Where(fmt.Sprintf("%s IN ?", quotedField), values)
+	if schema.Entity.UseSoftDelete {
+		query = query.Where("deleted_at IS NULL")
+	}
+
+	query, err = e.accessScope(query, tenantID, schema, userID, accessView)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []map[string]interface{}
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to query records: %w", err)
+	}
+	return records, nil
+}
+
 // Create creates a new record
 func (e *DataEngine) Create(tenantID uuid.UUID, entityCode string, data map[string]interface{}, userID *uuid.UUID) (map[string]interface{}, error) {
 	schema, err := e.schemaEngine.GetEntitySchema(tenantID, entityCode)
@@ -262,12 +378,96 @@ func (e *DataEngine) Create(tenantID uuid.UUID, entityCode string, data map[stri
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
 
+	// Validate belongs_to references
+	if err := e.validateReferences(tenantID, userID, schema, []map[string]interface{}{filteredData}); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	// The record and its audit entry are committed together
+	var result map[string]interface{}
+	err = e.db.Transaction(func(tx *gorm.DB) error {
+		result, err = e.insertRecord(tx, tenantID, tableName, schema.Entity, filteredData, userID)
+		if err != nil {
+			return err
+		}
+		if schema.Entity.UseAuditLog && userID != nil {
+			return e.createAuditLogTx(tx, tenantID, userID, schema.Entity, filteredData["id"].(uuid.UUID), "create", nil, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// BulkCreate creates several records in a single transaction.
+// Every record is validated, and all belongs_to references are checked in one
+// batch, before anything is inserted.
+func (e *DataEngine) BulkCreate(tenantID uuid.UUID, entityCode string, records []map[string]interface{}, userID *uuid.UUID) ([]map[string]interface{}, error) {
+	schema, err := e.schemaEngine.GetEntitySchema(tenantID, entityCode)
+	if err != nil {
+		return nil, err
+	}
+
+	tableName, err := e.safeTableName(schema.Entity)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]map[string]interface{}, 0, len(records))
+	for i, data := range records {
+		filteredData, err := e.validateAndFilterData(schema.Entity.Fields, data, true)
+		if err != nil {
+			return nil, fmt.Errorf("validation failed: record %d: %w", i, err)
+		}
+		filtered = append(filtered, filteredData)
+	}
+
+	if err := e.validateReferences(tenantID, userID, schema, filtered); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	// Records and their audit entries are committed together
+	results := make([]map[string]interface{}, 0, len(filtered))
+	err = e.db.Transaction(func(tx *gorm.DB) error {
+		for _, filteredData := range filtered {
+			result, err := e.insertRecord(tx, tenantID, tableName, schema.Entity, filteredData, userID)
+			if err != nil {
+				return err
+			}
+			if schema.Entity.UseAuditLog && userID != nil {
+				err := e.createAuditLogTx(tx, tenantID, userID, schema.Entity, filteredData["id"].(uuid.UUID), "create", nil, result)
+				if err != nil {
+					return err
+				}
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// insertRecord adds system fields to already validated data and inserts it.
+// The creating user, if any, also owns the record.
+func (e *DataEngine) insertRecord(db *gorm.DB, tenantID uuid.UUID, tableName string, entity *models.Entity, filteredData map[string]interface{}, userID *uuid.UUID) (map[string]interface{}, error) {
 	// Add system fields
-	newID := uuid.New()
-	filteredData["id"] = newID
+	filteredData["id"] = uuid.New()
 	filteredData["tenant_id"] = tenantID
 
-	if schema.Entity.UseTimestamps {
+	if userID != nil {
+		filteredData["created_by"] = *userID
+		filteredData["updated_by"] = *userID
+		if _, set := filteredData["owner_id"]; !set {
+			filteredData["owner_id"] = *userID
+		}
+	}
+
+	if entity.UseTimestamps {
 		now := time.Now()
 		filteredData["created_at"] = now
 		filteredData["updated_at"] = now
@@ -281,7 +481,7 @@ func (e *DataEngine) Create(tenantID uuid.UUID, entityCode string, data map[stri
 	i := 1
 	for col, val := range filteredData {
 		// Validate column name (system fields are always valid)
-		if !isSystemField(col) {
+		if !IsSystemField(col) {
 			if err := security.ValidateIdentifier(col); err != nil {
 				continue
 			}
@@ -298,7 +498,7 @@ func (e *DataEngine) Create(tenantID uuid.UUID, entityCode string, data map[stri
 		strings.Join(placeholders, ", "))
 
 	// Execute and get the created record
-	rows, err := e.db.Raw(sql, values...).Rows()
+	rows, err := db.Raw(sql, values...).Rows()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create record: %w", err)
 	}
@@ -325,11 +525,6 @@ func (e *DataEngine) Create(tenantID uuid.UUID, entityCode string, data map[stri
 		}
 	}
 
-	// Create audit log
-	if schema.Entity.UseAuditLog && userID != nil {
-		e.createAuditLog(tenantID, userID, schema.Entity, newID, "create", nil, result)
-	}
-
 	return result, nil
 }
 
@@ -348,7 +543,7 @@ func (e *DataEngine) Update(tenantID uuid.UUID, entityCode string, recordID uuid
 	// Get old values for audit
 	var oldValues map[string]interface{}
 	if schema.Entity.UseAuditLog && userID != nil {
-		oldValues, _ = e.Get(tenantID, entityCode, recordID)
+		oldValues, _ = e.Get(tenantID, entityCode, recordID, nil)
 	}
 
 	// Validate and filter data
@@ -361,35 +556,101 @@ func (e *DataEngine) Update(tenantID uuid.UUID, entityCode string, recordID uuid
 		return nil, fmt.Errorf("no valid fields to update")
 	}
 
-	// Add updated_at
+	// Validate belongs_to references
+	if err := e.validateReferences(tenantID, userID, schema, []map[string]interface{}{filteredData}); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	result, err := e.updateRecord(tableName, schema, tenantID, recordID, filteredData, userID, accessEdit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create audit log
+	if schema.Entity.UseAuditLog && userID != nil {
+		e.createAuditLog(tenantID, userID, schema.Entity, recordID, "update", oldValues, result)
+	}
+
+	return result, nil
+}
+
+// TransferOwnership makes another active user of the tenant the owner of a
+// record. When userID is set, the record must be one they may edit.
+func (e *DataEngine) TransferOwnership(tenantID uuid.UUID, entityCode string, recordID, ownerID uuid.UUID, userID *uuid.UUID) (map[string]interface{}, error) {
+	schema, err := e.schemaEngine.GetEntitySchema(tenantID, entityCode)
+	if err != nil {
+		return nil, err
+	}
+
+	tableName, err := e.safeTableName(schema.Entity)
+	if err != nil {
+		return nil, err
+	}
+
+	var owners int64
+	if err := e.db.Table("users").Where("id = ? AND tenant_id = ? AND is_active = true", ownerID, tenantID).Count(&owners).Error; err != nil {
+		return nil, fmt.Errorf("failed to find owner: %w", err)
+	}
+	if owners == 0 {
+		return nil, fmt.Errorf("validation failed: owner must be an active user of the tenant")
+	}
+
+	old, err := e.Get(tenantID, entityCode, recordID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := e.updateRecord(tableName, schema, tenantID, recordID, map[string]interface{}{"owner_id": ownerID}, userID, accessEdit)
+	if err != nil {
+		return nil, err
+	}
+
+	if schema.Entity.UseAuditLog && userID != nil {
+		e.createAuditLog(tenantID, userID, schema.Entity, recordID, "transfer",
+			map[string]interface{}{"owner_id": old["owner_id"]},
+			map[string]interface{}{"owner_id": ownerID})
+	}
+
+	return result, nil
+}
+
+// updateRecord sets columns of a record, along with updated_at and
+// updated_by, and returns the updated record. When userID is set, a record
+// outside the user's scope for action is not found.
+func (e *DataEngine) updateRecord(tableName string, schema *EntitySchema, tenantID, recordID uuid.UUID, data map[string]interface{}, userID *uuid.UUID, action string) (map[string]interface{}, error) {
 	if schema.Entity.UseTimestamps {
-		filteredData["updated_at"] = time.Now()
+		data["updated_at"] = time.Now()
+	}
+	if userID != nil {
+		data["updated_by"] = *userID
 	}
 
 	// Build UPDATE statement with validated column names
-	setClauses := make([]string, 0, len(filteredData))
-	values := make([]interface{}, 0, len(filteredData)+2)
+	setClauses := make([]string, 0, len(data))
+	values := make([]interface{}, 0, len(data)+2)
 
-	i := 1
-	for col, val := range filteredData {
+	for col, val := range data {
 		// Validate column name (system fields are always valid)
-		if !isSystemField(col) {
+		if !IsSystemField(col) {
 			if err := security.ValidateIdentifier(col); err != nil {
 				continue
 			}
 		}
-		setClauses = append(setClauses, fmt.Sprintf("%s = $%d", security.QuoteIdentifier(col), i))
+		setClauses = append(setClauses, fmt.Sprintf("%s = ?", security.QuoteIdentifier(col)))
 		values = append(values, val)
-		i++
 	}
 
-	values = append(values, tenantID, recordID)
+	// The record must be within the user's scope, checked in the same statement
+	scoped, err := e.accessScope(e.db.Table(tableName).Select("id").Where("tenant_id = ? AND id = ?", tenantID, recordID),
+		tenantID, schema, userID, action)
+	if err != nil {
+		return nil, err
+	}
+	values = append(values, tenantID, scoped)
 
-	sql := fmt.Sprintf("UPDATE %s SET %s WHERE tenant_id = $%d AND id = $%d RETURNING *",
+	sql := fmt.Sprintf("UPDATE %s SET %s WHERE tenant_id = ? AND id IN (?) RETURNING *",
 		tableName,
-		strings.Join(setClauses, ", "),
-		i,
-		i+1)
+		strings.Join(setClauses, ", "))
 
 	// Execute and get the updated record
 	rows, err := e.db.Raw(sql, values...).Rows()
@@ -421,15 +682,11 @@ func (e *DataEngine) Update(tenantID uuid.UUID, entityCode string, recordID uuid
 		return nil, fmt.Errorf("record not found")
 	}
 
-	// Create audit log
-	if schema.Entity.UseAuditLog && userID != nil {
-		e.createAuditLog(tenantID, userID, schema.Entity, recordID, "update", oldValues, result)
-	}
-
 	return result, nil
 }
 
-// Delete deletes a record (soft or hard delete based on entity config)
+// Delete deletes a record (soft or hard delete based on entity config). When
+// userID is set, a record outside the user's delete scope is not found.
 func (e *DataEngine) Delete(tenantID uuid.UUID, entityCode string, recordID uuid.UUID, userID *uuid.UUID) error {
 	schema, err := e.schemaEngine.GetEntitySchema(tenantID, entityCode)
 	if err != nil {
@@ -444,20 +701,35 @@ func (e *DataEngine) Delete(tenantID uuid.UUID, entityCode string, recordID uuid
 	// Get old values for audit
 	var oldValues map[string]interface{}
 	if schema.Entity.UseAuditLog && userID != nil {
-		oldValues, _ = e.Get(tenantID, entityCode, recordID)
+		oldValues, _ = e.Get(tenantID, entityCode, recordID, nil)
+	}
+
+	scoped, err := e.accessScope(e.db.Table(tableName).Select("id").Where("tenant_id = ? AND id = ?", tenantID, recordID),
+		tenantID, schema, userID, accessDelete)
+	if err != nil {
+		return err
 	}
 
-	var sql string
+	var result *gorm.DB
 	if schema.Entity.UseSoftDelete {
-		sql = fmt.Sprintf("UPDATE %s SET deleted_at = $1 WHERE tenant_id = $2 AND id = $3",
-			tableName)
-		if err := e.db.Exec(sql, time.Now(), tenantID, recordID).Error; err != nil {
-			return fmt.Errorf("failed to delete record: %w", err)
-		}
+		sql := fmt.Sprintf("UPDATE %s SET deleted_at = ? WHERE tenant_id = ? AND id IN (?)", tableName)
+		result = e.db.Exec(sql, time.Now(), tenantID, scoped)
 	} else {
-		sql = fmt.Sprintf("DELETE FROM %s WHERE tenant_id = $1 AND id = $2", tableName)
-		if err := e.db.Exec(sql, tenantID, recordID).Error; err != nil {
-			return fmt.Errorf("failed to delete record: %w", err)
+		sql := fmt.Sprintf("DELETE FROM %s WHERE tenant_id = ? AND id IN (?)", tableName)
+		result = e.db.Exec(sql, tenantID, scoped)
+	}
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete record: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("record not found")
+	}
+
+	// Shares outlive a soft delete, so they apply again if it is undone
+	if !schema.Entity.UseSoftDelete {
+		if err := e.db.Where("tenant_id = ? AND entity_id = ? AND record_id = ?", tenantID, schema.Entity.ID, recordID).
+			Delete(&models.RecordShare{}).Error; err != nil {
+			return fmt.Errorf("failed to delete record shares: %w", err)
 		}
 	}
 
@@ -469,8 +741,40 @@ func (e *DataEngine) Delete(tenantID uuid.UUID, entityCode string, recordID uuid
 	return nil
 }
 
-// BulkDelete deletes multiple records
+// BulkDelete deletes multiple records. Nothing is deleted unless every record
+// exists and, when userID is set, is within the user's delete scope.
 func (e *DataEngine) BulkDelete(tenantID uuid.UUID, entityCode string, recordIDs []uuid.UUID, userID *uuid.UUID) error {
+	if len(recordIDs) == 0 {
+		return nil
+	}
+
+	schema, err := e.schemaEngine.GetEntitySchema(tenantID, entityCode)
+	if err != nil {
+		return err
+	}
+	tableName, err := e.safeTableName(schema.Entity)
+	if err != nil {
+		return err
+	}
+	query, err := e.accessScope(e.db.Table(tableName).Where("tenant_id = ? AND id IN ?", tenantID, recordIDs),
+		tenantID, schema, userID, accessDelete)
+	if err != nil {
+		return err
+	}
+	var found []uuid.UUID
+	if err := query.Pluck("id", &found).Error; err != nil {
+		return fmt.Errorf("failed to find records: %w", err)
+	}
+	deletable := make(map[uuid.UUID]bool, len(found))
+	for _, id := range found {
+		deletable[id] = true
+	}
+	for _, id := range recordIDs {
+		if !deletable[id] {
+			return fmt.Errorf("record not found: %s", id)
+		}
+	}
+
 	for _, id := range recordIDs {
 		if err := e.Delete(tenantID, entityCode, id, userID); err != nil {
 			return err
@@ -509,11 +813,16 @@ func (e *DataEngine) isValidField(fields []models.Field, fieldCode string) bool
 		}
 	}
 	// Also allow system fields
-	return isSystemField(fieldCode)
+	return IsSystemField(fieldCode)
 }
 
-func isSystemField(fieldCode string) bool {
-	systemFields := []string{"id", "tenant_id", "created_at", "updated_at", "deleted_at"}
+// OwnershipColumns record who created, last updated and owns a record. Every
+// entity table has them; DataEngine fills them in.
+var OwnershipColumns = []string{"created_by", "updated_by", "owner_id"}
+
+// IsSystemField reports whether a column is one every entity table has
+func IsSystemField(fieldCode string) bool {
+	systemFields := append([]string{"id", "tenant_id", "created_at", "updated_at", "deleted_at"}, OwnershipColumns...)
 	for _, sf := range systemFields {
 		if sf == fieldCode {
 			return true
@@ -545,8 +854,8 @@ func (e *DataEngine) validateAndFilterData(fields []models.Field, data map[strin
 	result := make(map[string]interface{})
 
 	for _, field := range fields {
-		// Skip system fields
-		if field.IsSystem || field.IsAuto || field.IsPrimary {
+		// Skip system and computed fields
+		if field.IsSystem || field.IsAuto || field.IsPrimary || !IsColumnField(&field) {
 			continue
 		}
 
@@ -556,6 +865,9 @@ func (e *DataEngine) validateAndFilterData(fields []models.Field, data map[strin
 		}
 
 		value, exists := data[field.Code]
+		if exists {
+			value = coerceFieldValue(&field, value)
+		}
 
 		// Check required
 		if field.IsRequired && isCreate && (!exists || value == nil || value == "") {
@@ -577,40 +889,444 @@ func (e *DataEngine) validateAndFilterData(fields []models.Field, data map[strin
 	return result, nil
 }
 
+// validateFieldValue checks a value against the field's JSON Schema, the same
+// schema clients get from /api/schema/:entity/jsonschema
 func (e *DataEngine) validateFieldValue(field *models.Field, value interface{}) error {
-	if value == nil {
-		return nil
+	return validateAgainstSchema(field, FieldSchema(field), value)
+}
+
+// validateReferences checks that every belongs_to value in records points at an
+// existing, non-deleted record of the related entity within the same tenant.
+// When a user is known, the referenced record must also pass that user's row
+// filter on the related entity. References are grouped per related entity so
+// a batch of records costs one query per entity, not one per record.
+func (e *DataEngine) validateReferences(tenantID uuid.UUID, userID *uuid.UUID, schema *EntitySchema, records []map[string]interface{}) error {
+	type reference struct {
+		field *models.Field
+		id    uuid.UUID
 	}
+	byTarget := make(map[string][]reference)
+	var targets []string
 
-	// String length validation
-	if strVal, ok := value.(string); ok {
-		if field.MinLength != nil && len(strVal) < *field.MinLength {
-			return fmt.Errorf("field '%s' must be at least %d characters", field.Name, *field.MinLength)
+	for i := range schema.Entity.Fields {
+		field := &schema.Entity.Fields[i]
+		if field.FieldType == nil || field.FieldType.Code != "belongs_to" {
+			continue
 		}
-		if field.MaxLength != nil && len(strVal) > *field.MaxLength {
-			return fmt.Errorf("field '%s' must be at most %d characters", field.Name, *field.MaxLength)
+		target := e.referenceTarget(schema, field)
+		if target == "" {
+			return fmt.Errorf("field '%s' has no related entity configured", field.Name)
 		}
-		// Regex pattern validation
-		if field.RegexPattern != "" {
-			// Note: In production, compile and cache regex patterns
-			// For now, we skip complex regex validation
+
+		for _, record := range records {
+			value, exists := record[field.Code]
+			if !exists || value == nil || value == "" {
+				continue
+			}
+			id, err := uuid.Parse(fmt.Sprintf("%v", value))
+			if err != nil {
+				return fmt.Errorf("field '%s' must be a valid id", field.Name)
+			}
+			if _, seen := byTarget[target]; !seen {
+				targets = append(targets, target)
+			}
+			byTarget[target] = append(byTarget[target], reference{field: field, id: id})
 		}
 	}
 
-	// Numeric validation
-	if numVal, ok := value.(float64); ok {
-		if field.MinValue != nil && numVal < *field.MinValue {
-			return fmt.Errorf("field '%s' must be at least %v", field.Name, *field.MinValue)
+	for _, target := range targets {
+		refs := byTarget[target]
+		ids := make([]uuid.UUID, 0, len(refs))
+		for _, ref := range refs {
+			ids = append(ids, ref.id)
+		}
+
+		found, err := e.visibleRecordIDs(tenantID, userID, target, ids)
+		if err != nil {
+			return err
 		}
-		if field.MaxValue != nil && numVal > *field.MaxValue {
-			return fmt.Errorf("field '%s' must be at most %v", field.Name, *field.MaxValue)
+		for _, ref := range refs {
+			if !found[ref.id] {
+				return fmt.Errorf("field '%s' references a record that does not exist", ref.field.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// referenceTarget returns the code of the entity a belongs_to field points at.
+// The relations table is authoritative; a "target_entity" setting on the field
+// is used for fields created before their relation row existed.
+func (e *DataEngine) referenceTarget(schema *EntitySchema, field *models.Field) string {
+	for _, rel := range schema.Relations {
+		if rel.SourceEntityID == schema.Entity.ID && rel.SourceFieldCode == field.Code &&
+			rel.RelationType == "belongs_to" && rel.TargetEntity != nil {
+			return rel.TargetEntity.Code
+		}
+	}
+	if target, ok := field.Settings["target_entity"].(string); ok {
+		return target
+	}
+	return ""
+}
+
+// visibleRecordIDs returns which of ids exist in the entity's table for the
+// tenant and, when userID is set, are within the user's row filter and view
+// scope
+func (e *DataEngine) visibleRecordIDs(tenantID uuid.UUID, userID *uuid.UUID, entityCode string, ids []uuid.UUID) (map[uuid.UUID]bool, error) {
+	schema, err := e.schemaEngine.GetEntitySchema(tenantID, entityCode)
+	if err != nil {
+		return nil, fmt.Errorf("related entity '%s' is not available", entityCode)
+	}
+
+	tableName, err := e.safeTableName(schema.Entity)
+	if err != nil {
+		return nil, err
+	}
+
+	query := e.db.Table(tableName).Where("tenant_id = ? AND id IN ?", tenantID, ids)
+	if schema.Entity.UseSoftDelete {
+		query = query.Where("deleted_at IS NULL")
+	}
+
+	query, err = e.accessScope(query, tenantID, schema, userID, accessView)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing []uuid.UUID
+	if err := query.Pluck("id", &existing).Error; err != nil {
+		return nil, fmt.Errorf("failed to check references: %w", err)
+	}
+
+	found := make(map[uuid.UUID]bool, len(existing))
+	for _, id := range existing {
+		found[id] = true
+	}
+	return found, nil
+}
+
+// RecordAccess explains whether a user may perform an action on one record
+type RecordAccess struct {
+	Exists          bool `json:"exists"`
+	PassesRowFilter bool `json:"passes_row_filter"`
+	// OwnershipLimited is set when the user's roles limit the action to
+	// owned records
+	OwnershipLimited bool `json:"ownership_limited"`
+	// Owned is set when the user, or under a team scope a holder of one of
+	// the team roles, owns the record
+	Owned bool `json:"owned"`
+	// Shared is set when the record is shared with the user, or one of their
+	// roles, for the action
+	Shared bool `json:"shared"`
+	// InScope is what enforcement decides: the record is among those the
+	// user may perform the action on
+	InScope bool `json:"in_scope"`
+}
+
+// ExplainRecordAccess reports how the row filter, ownership scope and shares
+// of userID apply to a record for an action, evaluated the way List, Get,
+// Update and Delete scope their queries
+func (e *DataEngine) ExplainRecordAccess(tenantID uuid.UUID, entityCode string, recordID, userID uuid.UUID, action string) (*RecordAccess, error) {
+	schema, err := e.schemaEngine.GetEntitySchema(tenantID, entityCode)
+	if err != nil {
+		return nil, err
+	}
+
+	tableName, err := e.safeTableName(schema.Entity)
+	if err != nil {
+		return nil, err
+	}
+
+	query := func() *gorm.DB {
+		q := e.db.Table(tableName).Where("tenant_id = ? AND id = ?", tenantID, recordID)
+		if schema.Entity.UseSoftDelete {
+			q = q.Where("deleted_at IS NULL")
+		}
+		return q
+	}
+	matches := func(q *gorm.DB) (bool, error) {
+		var count int64
+		if err := q.Count(&count).Error; err != nil {
+			return false, fmt.Errorf("failed to check record: %w", err)
+		}
+		return count > 0, nil
+	}
+
+	access := &RecordAccess{}
+	if access.Exists, err = matches(query()); err != nil || !access.Exists {
+		return access, err
+	}
+	if e.rowFilters == nil {
+		access.PassesRowFilter, access.InScope = true, true
+		return access, nil
+	}
+
+	filter, err := e.rowFilters.GetRowFilter(tenantID, userID, entityCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve row filter: %w", err)
+	}
+	if access.PassesRowFilter, err = matches(e.applyRowFilter(query(), schema.Entity.Fields, filter, userID)); err != nil {
+		return nil, err
+	}
+
+	limited, teamRoles, err := e.rowFilters.GetOwnershipScope(tenantID, userID, entityCode, action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve ownership scope: %w", err)
+	}
+	access.OwnershipLimited = limited
+	owned := query().Where("owner_id = ?", userID)
+	if len(teamRoles) > 0 {
+		owned = query().Where("(owner_id = ? OR owner_id IN (SELECT user_id FROM user_roles WHERE role_id IN ?))", userID, teamRoles)
+	}
+	if access.Owned, err = matches(owned); err != nil {
+		return nil, err
+	}
+
+	if action == accessView || action == accessEdit {
+		shared := query().Where("id IN (?)", e.sharedRecordIDs(tenantID, schema.Entity.ID, userID, action))
+		if access.Shared, err = matches(shared); err != nil {
+			return nil, err
 		}
 	}
 
+	scoped, err := e.accessScope(query(), tenantID, schema, &userID, action)
+	if err != nil {
+		return nil, err
+	}
+	if access.InScope, err = matches(scoped); err != nil {
+		return nil, err
+	}
+	return access, nil
+}
+
+// attachIncludes loads the records referenced by the requested belongs_to
+// fields with one query per field and nests them under "_included", keyed by
+// field code. Unknown or non-relation fields are ignored, like unknown filters.
+// Related records are loaded as params.UserID would see them through the
+// related entity: a record they may not view is included as nil, and fields
+// hidden from them are left out.
+func (e *DataEngine) attachIncludes(tenantID uuid.UUID, schema *EntitySchema, records []map[string]interface{}, params QueryParams) error {
+	for _, code := range params.Include {
+		var field *models.Field
+		for i := range schema.Entity.Fields {
+			f := &schema.Entity.Fields[i]
+			if f.Code == code && f.FieldType != nil && f.FieldType.Code == "belongs_to" {
+				field = f
+				break
+			}
+		}
+		if field == nil {
+			continue
+		}
+		target := e.referenceTarget(schema, field)
+		if target == "" {
+			continue
+		}
+
+		var ids []uuid.UUID
+		for _, record := range records {
+			if id, ok := recordUUID(record[field.Code]); ok {
+				ids = append(ids, id)
+			}
+		}
+
+		var related map[uuid.UUID]map[string]interface{}
+		if len(ids) > 0 && (params.IncludeAllowed == nil || params.IncludeAllowed(target)) {
+			var err error
+			related, err = e.recordsByID(tenantID, target, ids, params.UserID)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, record := range records {
+			included, _ := record["_included"].(map[string]interface{})
+			if included == nil {
+				included = make(map[string]interface{})
+				record["_included"] = included
+			}
+			included[field.Code] = nil
+			if id, ok := recordUUID(record[field.Code]); ok {
+				if row, found := related[id]; found {
+					included[field.Code] = row
+				}
+			}
+		}
+	}
 	return nil
 }
 
+// recordsByID fetches the non-deleted records of an entity with the given ids.
+// When userID is set, only the records within their view scope are fetched,
+// none if they may not view the entity, without the fields hidden from them.
+func (e *DataEngine) recordsByID(tenantID uuid.UUID, entityCode string, ids []uuid.UUID, userID *uuid.UUID) (map[uuid.UUID]map[string]interface{}, error) {
+	schema, err := e.schemaEngine.GetEntitySchema(tenantID, entityCode)
+	if err != nil {
+		return nil, fmt.Errorf("related entity '%s' is not available", entityCode)
+	}
+
+	tableName, err := e.safeTableName(schema.Entity)
+	if err != nil {
+		return nil, err
+	}
+
+	var hidden []string
+	if userID != nil && e.rowFilters != nil {
+		var canView bool
+		canView, hidden, err = e.rowFilters.GetViewPermission(tenantID, *userID, entityCode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve permissions: %w", err)
+		}
+		if !canView {
+			return nil, nil
+		}
+	}
+
+	query := e.db.Table(tableName).Where("tenant_id = ? AND id IN ?", tenantID, ids)
+	if schema.Entity.UseSoftDelete {
+		query = query.Where("deleted_at IS NULL")
+	}
+
+	query, err = e.accessScope(query, tenantID, schema, userID, accessView)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []map[string]interface{}
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load related records: %w", err)
+	}
+
+	byID := make(map[uuid.UUID]map[string]interface{}, len(rows))
+	for _, row := range rows {
+		for _, code := range hidden {
+			delete(row, code)
+		}
+		if id, ok := recordUUID(row["id"]); ok {
+			byID[id] = row
+		}
+	}
+	return byID, nil
+}
+
+// recordUUID reads a uuid column value as returned by the driver
+func recordUUID(value interface{}) (uuid.UUID, bool) {
+	switch v := value.(type) {
+	case nil:
+		return uuid.Nil, false
+	case uuid.UUID:
+		return v, true
+	case [16]byte:
+		return uuid.UUID(v), true
+	case []byte:
+		id, err := uuid.ParseBytes(v)
+		return id, err == nil
+	default:
+		id, err := uuid.Parse(fmt.Sprintf("%v", v))
+		return id, err == nil
+	}
+}
+
+// accessScope narrows a query on an entity's table to the records userID may
+// perform action on: those within the scope of the user's roles and, for view
+// and edit, those shared with the user. Without a user or permissions nothing
+// is narrowed.
+func (e *DataEngine) accessScope(query *gorm.DB, tenantID uuid.UUID, schema *EntitySchema, userID *uuid.UUID, action string) (*gorm.DB, error) {
+	scope, err := e.roleScope(tenantID, schema, userID, action)
+	if err != nil || scope == nil {
+		return query, err
+	}
+	if action == accessView || action == accessEdit {
+		shared := e.sharedRecordIDs(tenantID, schema.Entity.ID, *userID, action)
+		return query.Where(e.db.Where(scope).Or("id IN (?)", shared)), nil
+	}
+	return query.Where(scope), nil
+}
+
+// roleScope returns the condition the user's roles limit action to: their
+// row filter and, for scoped actions, records owned by the user or by holders
+// of their team roles. It is nil when the roles do not limit the action.
+func (e *DataEngine) roleScope(tenantID uuid.UUID, schema *EntitySchema, userID *uuid.UUID, action string) (*gorm.DB, error) {
+	if userID == nil || e.rowFilters == nil {
+		return nil, nil
+	}
+	entityCode := schema.Entity.Code
+
+	filter, err := e.rowFilters.GetRowFilter(tenantID, *userID, entityCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve row filter: %w", err)
+	}
+	limited, teamRoles, err := e.rowFilters.GetOwnershipScope(tenantID, *userID, entityCode, action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve ownership scope: %w", err)
+	}
+	if len(filter) == 0 && !limited {
+		return nil, nil
+	}
+
+	scope := e.applyRowFilter(e.db.Session(&gorm.Session{NewDB: true}), schema.Entity.Fields, filter, *userID)
+	switch {
+	case !limited:
+	case len(teamRoles) == 0:
+		scope = scope.Where("owner_id = ?", *userID)
+	default:
+		scope = scope.Where("(owner_id = ? OR owner_id IN (SELECT user_id FROM user_roles WHERE role_id IN ?))", *userID, teamRoles)
+	}
+	return scope, nil
+}
+
+// applyRowFilter narrows a query to the rows matched by a permission row filter.
+// Filter keys are field codes; "$current_user" is replaced with the acting user,
+// nil matches NULL and a list matches any of its values. A filter that names an
+// unknown field matches nothing rather than everything.
+func (e *DataEngine) applyRowFilter(query *gorm.DB, fields []models.Field, filter map[string]interface{}, userID uuid.UUID) *gorm.DB {
+	for column, value := range filter {
+		if !e.isValidField(fields, column) {
+			return query.Where("1 = 0")
+		}
+		quotedColumn, err := security.SafeIdentifier(column)
+		if err != nil {
+			return query.Where("1 = 0")
+		}
+
+		switch v := resolveRowFilterValue(value, userID).(type) {
+		case nil:
+			query = query.Where(fmt.Sprintf("%s IS NULL", quotedColumn))
+		case []interface{}:
+			query = query.Where(fmt.Sprintf("%s IN ?", quotedColumn), v)
+		default:
+			query = query.Where(fmt.Sprintf("%s = ?", quotedColumn), v)
+		}
+	}
+	return query
+}
+
+// resolveRowFilterValue substitutes row filter placeholders with request values
+func resolveRowFilterValue(value interface{}, userID uuid.UUID) interface{} {
+	switch v := value.(type) {
+	case string:
+		if v == "$current_user" {
+			return userID
+		}
+	case []interface{}:
+		resolved := make([]interface{}, len(v))
+		for i, item := range v {
+			resolved[i] = resolveRowFilterValue(item, userID)
+		}
+		return resolved
+	}
+	return value
+}
+
 func (e *DataEngine) createAuditLog(tenantID uuid.UUID, userID *uuid.UUID, entity *models.Entity, recordID uuid.UUID, action string, oldValues, newValues map[string]interface{}) {
+	e.createAuditLogTx(e.db, tenantID, userID, entity, recordID, action, oldValues, newValues)
+}
+
+// createAuditLogTx creates an audit log entry within a transaction, so a
+// change is not committed without its entry
+func (e *DataEngine) createAuditLogTx(tx *gorm.DB, tenantID uuid.UUID, userID *uuid.UUID, entity *models.Entity, recordID uuid.UUID, action string, oldValues, newValues map[string]interface{}) error {
 	// Find changed fields
 	var changedFields []string
 	if oldValues != nil && newValues != nil {
@@ -624,18 +1340,22 @@ func (e *DataEngine) createAuditLog(tenantID uuid.UUID, userID *uuid.UUID, entit
 	}
 
 	log := models.AuditLog{
-		ID:            uuid.New(),
-		TenantID:      tenantID,
-		UserID:        userID,
-		EntityID:      &entity.ID,
-		EntityCode:    entity.Code,
-		RecordID:      &recordID,
-		Action:        action,
-		OldValues:     oldValues,
-		NewValues:     newValues,
-		ChangedFields: changedFields,
-		CreatedAt:     time.Now(),
-	}
-
-	e.db.Create(&log)
+		ID:             uuid.New(),
+		TenantID:       tenantID,
+		UserID:         userID,
+		ImpersonatorID: e.impersonatorID,
+		EntityID:       &entity.ID,
+		EntityCode:     entity.Code,
+		RecordID:       &recordID,
+		Action:         action,
+		OldValues:      oldValues,
+		NewValues:      newValues,
+		ChangedFields:  changedFields,
+		CreatedAt:      time.Now(),
+	}
+
+	if err := tx.Create(&log).Error; err != nil {
+		return fmt.Errorf("failed to create audit log: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,545 @@
+// Package engine - OpenAPI generation
+// Builds an OpenAPI 3.1 document for a tenant's dynamic data API
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aethra/genesis/internal/models"
+	"github.com/google/uuid"
+)
+
+// OpenAPIGenerator builds OpenAPI documents from tenant schemas and caches
+// them until the tenant's schema version changes
+type OpenAPIGenerator struct {
+	schemaEngine *SchemaEngine
+
+	mu    sync.RWMutex
+	cache map[uuid.UUID]cachedDocument
+}
+
+type cachedDocument struct {
+	version string
+	body    []byte
+}
+
+// NewOpenAPIGenerator creates a new OpenAPI generator
+func NewOpenAPIGenerator(schemaEngine *SchemaEngine) *OpenAPIGenerator {
+	return &OpenAPIGenerator{
+		schemaEngine: schemaEngine,
+		cache:        make(map[uuid.UUID]cachedDocument),
+	}
+}
+
+// Document returns the JSON-encoded OpenAPI document for a tenant
+func (g *OpenAPIGenerator) Document(tenantID uuid.UUID) ([]byte, error) {
+	version, err := g.schemaEngine.SchemaVersion(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	g.mu.RLock()
+	cached, ok := g.cache[tenantID]
+	g.mu.RUnlock()
+	if ok && cached.version == version {
+		return cached.body, nil
+	}
+
+	schema, err := g.schemaEngine.GetFullSchema(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(BuildOpenAPIDocument(schema, version))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode OpenAPI document: %w", err)
+	}
+
+	g.mu.Lock()
+	g.cache[tenantID] = cachedDocument{version: version, body: body}
+	g.mu.Unlock()
+
+	return body, nil
+}
+
+// BuildOpenAPIDocument describes the /api/data endpoints of every active
+// entity in the schema
+func BuildOpenAPIDocument(schema *TenantSchema, version string) map[string]interface{} {
+	paths := map[string]interface{}{}
+	schemas := map[string]interface{}{
+		"Error": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"error":   map[string]interface{}{"type": "string"},
+				"message": map[string]interface{}{"type": "string"},
+			},
+		},
+	}
+
+	entityCodes := make(map[uuid.UUID]string, len(schema.Entities))
+	for _, entity := range schema.Entities {
+		entityCodes[entity.ID] = entity.Code
+	}
+
+	for i := range schema.Entities {
+		entity := &schema.Entities[i]
+		name := SchemaName(entity.Code)
+		targets := referenceTargets(entity, schema.Relations, entityCodes)
+
+		schemas[name] = RecordSchema(entity, targets)
+		schemas[name+"Create"] = InputSchema(entity, true)
+		schemas[name+"Update"] = InputSchema(entity, false)
+		schemas[name+"List"] = map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"data":        map[string]interface{}{"type": "array", "items": schemaRef(name)},
+				"total":       map[string]interface{}{"type": "integer"},
+				"page":        map[string]interface{}{"type": "integer"},
+				"page_size":   map[string]interface{}{"type": "integer"},
+				"total_pages": map[string]interface{}{"type": "integer"},
+			},
+		}
+
+		base := "/api/data/" + entity.Code
+		paths[base] = map[string]interface{}{
+			"get": operation(entity, "list", "List "+pluralName(entity), listParameters(entity),
+				nil, "200", schemaRef(name+"List")),
+			"post": operation(entity, "create", "Create a "+entity.Name, nil,
+				schemaRef(name+"Create"), "201", schemaRef(name)),
+		}
+		paths[base+"/{id}"] = map[string]interface{}{
+			"parameters": []interface{}{idParameter()},
+			"get": operation(entity, "get", "Get a "+entity.Name, nil,
+				nil, "200", schemaRef(name)),
+			"put": operation(entity, "update", "Update a "+entity.Name, nil,
+				schemaRef(name+"Update"), "200", schemaRef(name)),
+			"delete": operation(entity, "delete", "Delete a "+entity.Name, nil,
+				nil, "200", messageSchema()),
+		}
+		paths[base+"/bulk-create"] = map[string]interface{}{
+			"post": operation(entity, "bulkCreate", "Create several "+pluralName(entity)+" in one transaction", nil,
+				map[string]interface{}{
+					"type":     "object",
+					"required": []string{"records"},
+					"properties": map[string]interface{}{
+						"records": map[string]interface{}{"type": "array", "items": schemaRef(name + "Create")},
+					},
+				},
+				"201", map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"data":  map[string]interface{}{"type": "array", "items": schemaRef(name)},
+						"count": map[string]interface{}{"type": "integer"},
+					},
+				}),
+		}
+		paths[base+"/bulk-delete"] = map[string]interface{}{
+			"post": operation(entity, "bulkDelete", "Delete several "+pluralName(entity), nil,
+				map[string]interface{}{
+					"type":     "object",
+					"required": []string{"ids"},
+					"properties": map[string]interface{}{
+						"ids": map[string]interface{}{
+							"type":  "array",
+							"items": map[string]interface{}{"type": "string", "format": "uuid"},
+						},
+					},
+				},
+				"200", messageSchema()),
+		}
+	}
+
+	title := "Genesis API"
+	if schema.Tenant != nil {
+		title = schema.Tenant.Name + " API"
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":            title,
+			"version":          "1.0.0",
+			"x-schema-version": version,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":         "http",
+					"scheme":       "bearer",
+					"bearerFormat": "JWT",
+				},
+				"tenantHeader": map[string]interface{}{
+					"type": "apiKey",
+					"in":   "header",
+					"name": "X-Tenant-ID",
+				},
+			},
+		},
+		"security": []interface{}{
+			map[string]interface{}{"bearerAuth": []string{}, "tenantHeader": []string{}},
+		},
+	}
+}
+
+// RecordSchema describes a stored record of an entity as returned by the API.
+// targets maps belongs_to field codes to the entity they reference.
+func RecordSchema(entity *models.Entity, targets map[string]string) map[string]interface{} {
+	properties := map[string]interface{}{
+		"id":        map[string]interface{}{"type": "string", "format": "uuid", "readOnly": true},
+		"tenant_id": map[string]interface{}{"type": "string", "format": "uuid", "readOnly": true},
+	}
+	required := []string{"id"}
+
+	for i := range entity.Fields {
+		field := &entity.Fields[i]
+		if !IsColumnField(field) {
+			continue
+		}
+		prop := FieldSchema(field)
+		if field.IsSystem || field.IsAuto || field.IsPrimary {
+			prop["readOnly"] = true
+		}
+		properties[field.Code] = prop
+	}
+
+	if entity.UseTimestamps {
+		properties["created_at"] = map[string]interface{}{"type": "string", "format": "date-time", "readOnly": true}
+		properties["updated_at"] = map[string]interface{}{"type": "string", "format": "date-time", "readOnly": true}
+	}
+	if entity.UseSoftDelete {
+		properties["deleted_at"] = map[string]interface{}{"type": []string{"string", "null"}, "format": "date-time", "readOnly": true}
+	}
+	for _, column := range OwnershipColumns {
+		if _, ok := properties[column]; !ok {
+			properties[column] = map[string]interface{}{"type": []string{"string", "null"}, "format": "uuid", "readOnly": true}
+		}
+	}
+
+	if len(targets) > 0 {
+		included := map[string]interface{}{}
+		for code, target := range targets {
+			included[code] = map[string]interface{}{
+				"oneOf": []interface{}{schemaRef(SchemaName(target)), map[string]interface{}{"type": "null"}},
+			}
+		}
+		properties["_included"] = map[string]interface{}{
+			"type":        "object",
+			"description": "Related records requested with the include parameter",
+			"properties":  included,
+			"readOnly":    true,
+		}
+	}
+
+	return map[string]interface{}{
+		"type":       "object",
+		"title":      entity.Name,
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// InputSchema describes the request body accepted when creating (required
+// fields enforced) or updating (every field optional) a record
+func InputSchema(entity *models.Entity, isCreate bool) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+
+	for i := range entity.Fields {
+		field := &entity.Fields[i]
+		if !IsColumnField(field) || field.IsSystem || field.IsAuto || field.IsPrimary {
+			continue
+		}
+		properties[field.Code] = FieldSchema(field)
+		if isCreate && field.IsRequired {
+			required = append(required, field.Code)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"title":      entity.Name,
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	if !isCreate {
+		schema["minProperties"] = 1
+	}
+	return schema
+}
+
+// FieldSchema describes the values a field accepts, in JSON Schema terms
+func FieldSchema(field *models.Field) map[string]interface{} {
+	prop := map[string]interface{}{}
+	jsonType := "string"
+
+	switch fieldTypeCode(field) {
+	case "uuid", "belongs_to":
+		prop["format"] = "uuid"
+	case "integer":
+		jsonType = "integer"
+	case "decimal":
+		jsonType = "number"
+	case "boolean":
+		jsonType = "boolean"
+	case "date":
+		prop["format"] = "date"
+	case "datetime":
+		prop["format"] = "date-time"
+	case "time":
+		prop["format"] = "time"
+	case "email":
+		prop["format"] = "email"
+	case "url":
+		prop["format"] = "uri"
+	case "enum":
+		if options := FieldOptions(field); len(options) > 0 {
+			prop["enum"] = options
+		}
+	case "multi_enum", "tags":
+		jsonType = "array"
+		items := map[string]interface{}{"type": "string"}
+		if options := FieldOptions(field); len(options) > 0 {
+			items["enum"] = options
+		}
+		prop["items"] = items
+	case "json":
+		jsonType = ""
+	}
+
+	switch jsonType {
+	case "string":
+		if field.MinLength != nil {
+			prop["minLength"] = *field.MinLength
+		}
+		if field.MaxLength != nil {
+			prop["maxLength"] = *field.MaxLength
+		}
+		if field.RegexPattern != "" {
+			prop["pattern"] = field.RegexPattern
+		}
+	case "integer", "number":
+		if field.MinValue != nil {
+			prop["minimum"] = *field.MinValue
+		}
+		if field.MaxValue != nil {
+			prop["maximum"] = *field.MaxValue
+		}
+	}
+
+	if jsonType != "" {
+		if field.IsRequired {
+			prop["type"] = jsonType
+		} else {
+			prop["type"] = []string{jsonType, "null"}
+			if enum, ok := prop["enum"].([]interface{}); ok {
+				prop["enum"] = append(enum, nil)
+			}
+		}
+	}
+
+	prop["title"] = field.Name
+	if field.Description != "" {
+		prop["description"] = field.Description
+	} else if field.HelpText != "" {
+		prop["description"] = field.HelpText
+	}
+	return prop
+}
+
+// SchemaName converts an entity code to the PascalCase name used for its
+// component schemas, e.g. "sales_orders" -> "SalesOrders"
+func SchemaName(code string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(code, func(r rune) bool { return r == '_' || r == '-' }) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// IsColumnField reports whether a field is stored in the entity table
+func IsColumnField(field *models.Field) bool {
+	return field.FieldType == nil || field.FieldType.DBType != "VIRTUAL"
+}
+
+func fieldTypeCode(field *models.Field) string {
+	if field.FieldType != nil {
+		return field.FieldType.Code
+	}
+	return ""
+}
+
+// FieldOptions returns the allowed values of a choice field, configured as
+// settings.options (or component_options.options) holding either plain values
+// or {"value", "label"} objects
+func FieldOptions(field *models.Field) []interface{} {
+	raw, ok := field.Settings["options"].([]interface{})
+	if !ok {
+		raw, _ = field.ComponentOptions["options"].([]interface{})
+	}
+
+	var options []interface{}
+	for _, option := range raw {
+		if m, ok := option.(map[string]interface{}); ok {
+			if value, ok := m["value"]; ok {
+				options = append(options, value)
+			}
+			continue
+		}
+		options = append(options, option)
+	}
+	return options
+}
+
+// referenceTargets maps an entity's belongs_to field codes to the code of
+// the entity each one references
+func referenceTargets(entity *models.Entity, relations []models.Relation, entityCodes map[uuid.UUID]string) map[string]string {
+	targets := map[string]string{}
+	for i := range entity.Fields {
+		field := &entity.Fields[i]
+		if fieldTypeCode(field) != "belongs_to" {
+			continue
+		}
+		for _, rel := range relations {
+			if rel.SourceEntityID == entity.ID && rel.SourceFieldCode == field.Code && rel.RelationType == "belongs_to" {
+				if code, ok := entityCodes[rel.TargetEntityID]; ok {
+					targets[field.Code] = code
+				}
+			}
+		}
+		if _, ok := targets[field.Code]; !ok {
+			if target, ok := field.Settings["target_entity"].(string); ok {
+				for _, code := range entityCodes {
+					if code == target {
+						targets[field.Code] = target
+						break
+					}
+				}
+			}
+		}
+	}
+	return targets
+}
+
+func listParameters(entity *models.Entity) []interface{} {
+	var sortable, includable []interface{}
+	params := []interface{}{
+		queryParameter("page", "Page number, starting at 1", map[string]interface{}{"type": "integer", "minimum": 1, "default": 1}),
+		queryParameter("page_size", "Records per page", map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 100, "default": 25}),
+		queryParameter("search", "Case-insensitive text search across searchable fields", map[string]interface{}{"type": "string"}),
+		queryParameter("sort_dir", "Sort direction", map[string]interface{}{"type": "string", "enum": []string{"asc", "desc"}}),
+	}
+
+	for i := range entity.Fields {
+		field := &entity.Fields[i]
+		if !IsColumnField(field) {
+			continue
+		}
+		sortable = append(sortable, field.Code)
+		if fieldTypeCode(field) == "belongs_to" {
+			includable = append(includable, field.Code)
+		}
+
+		filter := FieldSchema(field)
+		delete(filter, "title")
+		params = append(params, queryParameter("filter["+field.Code+"]", "Only records whose "+field.Name+" equals this value", filter))
+	}
+
+	if len(sortable) > 0 {
+		params = append(params, queryParameter("sort", "Field to sort by (defaults to newest first)", map[string]interface{}{"type": "string", "enum": sortable}))
+	}
+	if len(includable) > 0 {
+		param := queryParameter("include", "Comma-separated belongs_to fields whose related records are embedded under _included", map[string]interface{}{
+			"type":  "array",
+			"items": map[string]interface{}{"type": "string", "enum": includable},
+		})
+		param["style"] = "form"
+		param["explode"] = false
+		params = append(params, param)
+	}
+
+	return params
+}
+
+func operation(entity *models.Entity, action, summary string, parameters []interface{}, requestBody interface{}, status string, response interface{}) map[string]interface{} {
+	op := map[string]interface{}{
+		"operationId": action + SchemaName(entity.Code),
+		"summary":     summary,
+		"tags":        []string{entity.Code},
+		"responses": map[string]interface{}{
+			status: map[string]interface{}{
+				"description": "Success",
+				"content":     jsonContent(response),
+			},
+			"400": errorResponse("Invalid request"),
+			"401": errorResponse("Authentication required"),
+			"403": errorResponse("Permission denied"),
+			"404": errorResponse("Not found"),
+		},
+	}
+	if len(parameters) > 0 {
+		op["parameters"] = parameters
+	}
+	if requestBody != nil {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  jsonContent(requestBody),
+		}
+	}
+	return op
+}
+
+func queryParameter(name, description string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"in":          "query",
+		"description": description,
+		"schema":      schema,
+	}
+}
+
+func idParameter() map[string]interface{} {
+	return map[string]interface{}{
+		"name":     "id",
+		"in":       "path",
+		"required": true,
+		"schema":   map[string]interface{}{"type": "string", "format": "uuid"},
+	}
+}
+
+func messageSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"message": map[string]interface{}{"type": "string"}},
+	}
+}
+
+func errorResponse(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content":     jsonContent(schemaRef("Error")),
+	}
+}
+
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+func pluralName(entity *models.Entity) string {
+	if entity.NamePlural != "" {
+		return entity.NamePlural
+	}
+	return entity.Name
+}
@@ -3,15 +3,29 @@
 package engine
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/aethra/genesis/internal/models"
 	"github.com/aethra/genesis/internal/security"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrSharedTable is returned when deleting an entity whose data table other
+// entities or other tenants' rows still use
+var ErrSharedTable = errors.New("data table is shared with other entities or tenants")
+
+// ErrTableNameTaken is returned for a new entity whose data table name is
+// used by another entity or by a table that is not an entity's, such as a
+// core table
+var ErrTableNameTaken = errors.New("table name is already in use")
+
 // SchemaEngine handles all schema-related operations
 type SchemaEngine struct {
 	db *gorm.DB
@@ -152,24 +166,104 @@ func (e *SchemaEngine) GetEntitySchema(tenantID uuid.UUID, entityCode string) (*
 	}, nil
 }
 
+// SchemaVersion returns a fingerprint of a tenant's entities, fields and
+// relations. It changes whenever any of them is created, updated or deleted,
+// so documents derived from the schema can be cached against it.
+func (e *SchemaEngine) SchemaVersion(tenantID uuid.UUID) (string, error) {
+	var version string
+	err := e.db.Raw(`SELECT concat_ws('|',
+		(SELECT count(*) || '/' || coalesce(max(updated_at)::text, '') FROM entities WHERE tenant_id = @tenant),
+		(SELECT count(*) || '/' || coalesce(max(updated_at)::text, '') FROM fields WHERE tenant_id = @tenant),
+		(SELECT count(*) || '/' || coalesce(max(updated_at)::text, '') FROM relations WHERE tenant_id = @tenant),
+		(SELECT count(*) FROM field_types)
+	)`, map[string]interface{}{"tenant": tenantID}).Scan(&version).Error
+	if err != nil {
+		return "", fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
 // =============================================================================
 // DYNAMIC TABLE MANAGEMENT
 // =============================================================================
 
+// CheckTableName checks the data table name of a new entity. It must carry
+// the data_ prefix and must not name an existing table no entity owns, such
+// as a core table. A custom name, one other than data_<code>, must also not
+// be used by any other entity: tenants only share the default tables.
+func (e *SchemaEngine) CheckTableName(entity *models.Entity) error {
+	tableName := e.getTableName(entity)
+	if !strings.HasPrefix(tableName, "data_") {
+		return fmt.Errorf("invalid table name '%s': must start with 'data_'", tableName)
+	}
+	if err := security.ValidateIdentifier(tableName); err != nil {
+		return fmt.Errorf("invalid table name '%s': %w", tableName, err)
+	}
+
+	var owners int64
+	if err := e.db.Model(&models.Entity{}).
+		Where("table_name = ? OR (COALESCE(table_name, '') = '' AND 'data_' || code = ?)", tableName, tableName).
+		Count(&owners).Error; err != nil {
+		return fmt.Errorf("failed to check table name: %w", err)
+	}
+	if owners > 0 && tableName != "data_"+entity.Code {
+		return fmt.Errorf("%w: %s belongs to another entity", ErrTableNameTaken, tableName)
+	}
+
+	exists, err := e.tableExists(e.db, tableName)
+	if err != nil {
+		return err
+	}
+	if exists && owners == 0 {
+		return fmt.Errorf("%w: %s is not an entity table", ErrTableNameTaken, tableName)
+	}
+	return nil
+}
+
 // CreateEntityTable creates the actual database table for an entity
 func (e *SchemaEngine) CreateEntityTable(entity *models.Entity, fields []models.Field) error {
-	tableName, err := e.safeTableName(entity)
+	statements, err := e.EntityTableDDL(entity, fields)
 	if err != nil {
 		return err
 	}
 
+	// Use transaction for atomicity
+	return e.db.Transaction(func(tx *gorm.DB) error {
+		for _, sql := range statements {
+			if err := tx.Exec(sql).Error; err != nil {
+				return fmt.Errorf("failed to create table %s: %w", e.getTableName(entity), err)
+			}
+		}
+		return nil
+	})
+}
+
+// EntityTableDDL returns the statements that create an entity's data table:
+// the table itself, its indexes and the updated_at trigger
+func (e *SchemaEngine) EntityTableDDL(entity *models.Entity, fields []models.Field) ([]string, error) {
+	return e.entityTableDDL(entity, fields, true)
+}
+
+// StandaloneTableDDL returns the statements of EntityTableDDL for a database
+// without Genesis' users table, such as an ejected module's. The ownership
+// columns are kept, without their foreign key.
+func (e *SchemaEngine) StandaloneTableDDL(entity *models.Entity, fields []models.Field) ([]string, error) {
+	return e.entityTableDDL(entity, fields, false)
+}
+
+func (e *SchemaEngine) entityTableDDL(entity *models.Entity, fields []models.Field, referenceUsers bool) ([]string, error) {
+	tableName, err := e.safeTableName(entity)
+	if err != nil {
+		return nil, err
+	}
+
 	// Build CREATE TABLE statement
 	var columns []string
 
 	for _, field := range fields {
 		colDef, err := e.buildColumnDefinition(&field)
 		if err != nil {
-			return fmt.Errorf("failed to build column definition for %s: %w", field.Code, err)
+			return nil, fmt.Errorf("failed to build column definition for %s: %w", field.Code, err)
 		}
 		if colDef != "" {
 			columns = append(columns, colDef)
@@ -189,28 +283,34 @@ func (e *SchemaEngine) CreateEntityTable(entity *models.Entity, fields []models.
 	// Always add tenant_id for multi-tenancy
 	columns = append(columns, "tenant_id UUID NOT NULL REFERENCES tenants(id)")
 
-	sql := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  %s\n)", tableName, strings.Join(columns, ",\n  "))
-
-	// Use transaction for atomicity
-	return e.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Exec(sql).Error; err != nil {
-			return fmt.Errorf("failed to create table %s: %w", tableName, err)
+	// Who created, last updated and owns each record, unless a field of the
+	// entity already stores it
+	for _, column := range OwnershipColumns {
+		if hasColumn(fields, column) {
+			continue
 		}
-
-		// Create indexes
-		if err := e.createTableIndexes(tx, tableName, fields); err != nil {
-			return fmt.Errorf("failed to create indexes: %w", err)
+		if referenceUsers {
+			columns = append(columns, column+" UUID REFERENCES users(id) ON DELETE SET NULL")
+		} else {
+			columns = append(columns, column+" UUID")
 		}
+	}
 
-		// Create updated_at trigger if timestamps enabled
-		if entity.UseTimestamps {
-			if err := e.createUpdatedAtTrigger(tx, tableName); err != nil {
-				return fmt.Errorf("failed to create trigger: %w", err)
-			}
+	statements := []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  %s\n)", tableName, strings.Join(columns, ",\n  ")),
+	}
+	statements = append(statements, e.tableIndexStatements(tableName, fields)...)
+
+	// Create updated_at trigger if timestamps enabled
+	if entity.UseTimestamps {
+		trigger, err := e.updatedAtTriggerStatement(tableName)
+		if err != nil {
+			return nil, err
 		}
+		statements = append(statements, trigger)
+	}
 
-		return nil
-	})
+	return statements, nil
 }
 
 // AddField adds a new column to an existing entity table
@@ -259,6 +359,538 @@ func (e *SchemaEngine) RemoveField(entity *models.Entity, field *models.Field) e
 	return nil
 }
 
+// =============================================================================
+// ENTITY DELETION
+// =============================================================================
+
+// DeletionMode controls what happens to an entity's data table on deletion
+type DeletionMode string
+
+const (
+	// DeletionArchive renames the table aside so it can be restored
+	DeletionArchive DeletionMode = "archive"
+	// DeletionDrop drops the table and its data permanently
+	DeletionDrop DeletionMode = "drop"
+)
+
+// EntityDeletionImpact describes everything deleting an entity will affect
+type EntityDeletionImpact struct {
+	EntityID     uuid.UUID `json:"entity_id"`
+	EntityCode   string    `json:"entity_code"`
+	TableName    string    `json:"table_name"`
+	TableExists  bool      `json:"table_exists"`
+	Records      int64     `json:"records"`
+	Fields       int64     `json:"fields"`
+	Views        int64     `json:"views"`
+	Permissions  int64     `json:"permissions"`
+	Relations    int64     `json:"relations"`
+	Actions      int64     `json:"actions"`
+	MenuItems    int64     `json:"menu_items"`
+	Pages        int64     `json:"pages"`
+	ReferencedBy []string  `json:"referenced_by"`
+	// SharedEntities and OtherTenantRecords count the other entities naming
+	// the same table and the rows other tenants keep in it. Deletion drops or
+	// renames the whole table, so it is refused while either is non-zero.
+	SharedEntities     int64 `json:"shared_entities"`
+	OtherTenantRecords int64 `json:"other_tenant_records"`
+}
+
+// GetDeletionImpact reports what deleting an entity would remove
+func (e *SchemaEngine) GetDeletionImpact(entity *models.Entity) (*EntityDeletionImpact, error) {
+	tableName := e.getTableName(entity)
+	impact := &EntityDeletionImpact{
+		EntityID:     entity.ID,
+		EntityCode:   entity.Code,
+		TableName:    tableName,
+		ReferencedBy: []string{},
+	}
+
+	exists, err := e.tableExists(e.db, tableName)
+	if err != nil {
+		return nil, err
+	}
+	impact.TableExists = exists
+
+	if exists {
+		quoted, err := e.safeTableName(entity)
+		if err != nil {
+			return nil, err
+		}
+		if err := e.db.Table(quoted).Where("tenant_id = ?", entity.TenantID).Count(&impact.Records).Error; err != nil {
+			return nil, fmt.Errorf("failed to count records: %w", err)
+		}
+	}
+
+	impact.SharedEntities, impact.OtherTenantRecords, err = e.tableSharing(e.db, entity, tableName, exists)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := []struct {
+		table string
+		where string
+		dest  *int64
+	}{
+		{"fields", "entity_id = ?", &impact.Fields},
+		{"views", "entity_id = ?", &impact.Views},
+		{"permissions", "entity_id = ?", &impact.Permissions},
+		{"relations", "source_entity_id = ? OR target_entity_id = ?", &impact.Relations},
+		{"actions", "entity_id = ?", &impact.Actions},
+		{"menu_items", "entity_id = ?", &impact.MenuItems},
+		{"ui_pages", "entity_id = ?", &impact.Pages},
+	}
+	for _, c := range counts {
+		args := []interface{}{entity.ID}
+		if strings.Count(c.where, "?") == 2 {
+			args = append(args, entity.ID)
+		}
+		if err := e.db.Table(c.table).Where(c.where, args...).Count(c.dest).Error; err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", c.table, err)
+		}
+	}
+
+	impact.ReferencedBy, err = e.referencingEntities(e.db, entity)
+	if err != nil {
+		return nil, err
+	}
+
+	return impact, nil
+}
+
+// referencingEntities returns the codes of the other entities whose
+// relations or belongs_to fields point at entity. Deleting entity would
+// leave their references dangling, so it is refused until they are removed.
+func (e *SchemaEngine) referencingEntities(db *gorm.DB, entity *models.Entity) ([]string, error) {
+	var byRelation, byField []string
+	if err := db.Table("relations").
+		Joins("JOIN entities ON entities.id = relations.source_entity_id").
+		Where("relations.target_entity_id = ? AND relations.source_entity_id <> ?", entity.ID, entity.ID).
+		Distinct().Pluck("entities.code", &byRelation).Error; err != nil {
+		return nil, fmt.Errorf("failed to find referencing entities: %w", err)
+	}
+	if err := db.Table("fields").
+		Joins("JOIN entities ON entities.id = fields.entity_id").
+		Where("entities.tenant_id = ? AND entities.id <> ? AND fields.settings->>'target_entity' = ?", entity.TenantID, entity.ID, entity.Code).
+		Distinct().Pluck("entities.code", &byField).Error; err != nil {
+		return nil, fmt.Errorf("failed to find referencing entities: %w", err)
+	}
+
+	codes := []string{}
+	seen := make(map[string]bool)
+	for _, code := range append(byRelation, byField...) {
+		if !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	return codes, nil
+}
+
+// tableSharing returns how many other entities name entity's data table and
+// how many of its rows belong to other tenants
+func (e *SchemaEngine) tableSharing(db *gorm.DB, entity *models.Entity, tableName string, exists bool) (int64, int64, error) {
+	var entities int64
+	if err := db.Model(&models.Entity{}).
+		Where("id <> ? AND (table_name = ? OR (COALESCE(table_name, '') = '' AND 'data_' || code = ?))", entity.ID, tableName, tableName).
+		Count(&entities).Error; err != nil {
+		return 0, 0, fmt.Errorf("failed to find entities sharing table %s: %w", tableName, err)
+	}
+
+	var records int64
+	if exists {
+		if err := db.Table(security.QuoteIdentifier(tableName)).Where("tenant_id <> ?", entity.TenantID).Count(&records).Error; err != nil {
+			return 0, 0, fmt.Errorf("failed to count records: %w", err)
+		}
+	}
+	return entities, records, nil
+}
+
+// DeleteEntity removes an entity and all metadata that depends on it in one
+// transaction. In archive mode the data table is renamed with a timestamp
+// suffix and an EntityArchive row is returned; in drop mode the table is
+// dropped and nil is returned. Entities other entities still reference, and
+// tables other entities or tenants' rows share, cannot be deleted. audit,
+// when set, records the deletion in the same transaction.
+func (e *SchemaEngine) DeleteEntity(entity *models.Entity, mode DeletionMode, actorID *uuid.UUID, audit func(tx *gorm.DB, archive *models.EntityArchive) error) (*models.EntityArchive, error) {
+	if mode != DeletionArchive && mode != DeletionDrop {
+		return nil, fmt.Errorf("invalid deletion mode '%s'", mode)
+	}
+
+	tableName := e.getTableName(entity)
+	quotedTable, err := e.safeTableName(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []models.Field
+	if err := e.db.Where("entity_id = ?", entity.ID).Order("display_order").Find(&fields).Error; err != nil {
+		return nil, fmt.Errorf("failed to load fields: %w", err)
+	}
+
+	var relations []models.Relation
+	if err := e.db.Where("source_entity_id = ?", entity.ID).Preload("TargetEntity").Find(&relations).Error; err != nil {
+		return nil, fmt.Errorf("failed to load relations: %w", err)
+	}
+
+	var archive *models.EntityArchive
+	err = e.db.Transaction(func(tx *gorm.DB) error {
+		referencedBy, err := e.referencingEntities(tx, entity)
+		if err != nil {
+			return err
+		}
+		if len(referencedBy) > 0 {
+			return fmt.Errorf("entity is referenced by %s; remove those references first", strings.Join(referencedBy, ", "))
+		}
+
+		exists, err := e.tableExists(tx, tableName)
+		if err != nil {
+			return err
+		}
+		if exists {
+			// Keep other tenants from writing rows between the check and the drop
+			if err := tx.Exec(fmt.Sprintf("LOCK TABLE %s IN ACCESS EXCLUSIVE MODE", quotedTable)).Error; err != nil {
+				return fmt.Errorf("failed to lock table %s: %w", tableName, err)
+			}
+		}
+		sharedEntities, otherRecords, err := e.tableSharing(tx, entity, tableName, exists)
+		if err != nil {
+			return err
+		}
+		if sharedEntities > 0 || otherRecords > 0 {
+			return fmt.Errorf("%w: %s cannot be dropped or archived", ErrSharedTable, tableName)
+		}
+
+		if exists && mode == DeletionArchive {
+			archive, err = e.archiveTable(tx, entity, fields, relations, tableName, actorID)
+			if err != nil {
+				return err
+			}
+		} else if exists {
+			if err := tx.Exec(fmt.Sprintf("DROP TABLE %s", quotedTable)).Error; err != nil {
+				return fmt.Errorf("failed to drop table %s: %w", tableName, err)
+			}
+		}
+
+		if err := e.deleteEntityMetadata(tx, entity); err != nil {
+			return err
+		}
+		if audit != nil {
+			return audit(tx, archive)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return archive, nil
+}
+
+// RestoreEntity renames an archived table back and recreates the entity,
+// field and relation definitions captured when it was archived. The entities
+// its relations point at must exist, by id or, if recreated since, by code.
+// Views, permissions and menu items are not part of the snapshot and must be
+// set up again. audit, when set, records the restore in the same transaction.
+func (e *SchemaEngine) RestoreEntity(archive *models.EntityArchive, audit func(tx *gorm.DB, entity *models.Entity) error) (*models.Entity, error) {
+	if err := security.ValidateIdentifier(archive.OriginalTable); err != nil {
+		return nil, fmt.Errorf("invalid table name '%s': %w", archive.OriginalTable, err)
+	}
+	if err := security.ValidateIdentifier(archive.ArchivedTable); err != nil {
+		return nil, fmt.Errorf("invalid table name '%s': %w", archive.ArchivedTable, err)
+	}
+
+	var snapshot struct {
+		Entity    models.Entity     `json:"entity"`
+		Fields    []models.Field    `json:"fields"`
+		Relations []models.Relation `json:"relations"`
+		// RelationTargets maps relation ids to the code of their target
+		RelationTargets map[string]string `json:"relation_targets"`
+	}
+	raw, err := json.Marshal(archive.Snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	if err := json.Unmarshal(raw, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	entity := snapshot.Entity
+	entity.Fields = nil
+	entity.Views = nil
+	entity.Tenant = nil
+	entity.Module = nil
+
+	err = e.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the archive so concurrent restores of it queue behind this one
+		var locked models.EntityArchive
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", archive.ID).First(&locked).Error; err != nil {
+			return fmt.Errorf("failed to lock archive: %w", err)
+		}
+		if locked.RestoredAt != nil {
+			return fmt.Errorf("archive already restored")
+		}
+
+		// Table names are physical, so another tenant's entity using it conflicts too
+		var conflicts int64
+		if err := tx.Model(&models.Entity{}).
+			Where("(tenant_id = ? AND code = ?) OR table_name = ?", archive.TenantID, archive.EntityCode, archive.OriginalTable).
+			Count(&conflicts).Error; err != nil {
+			return fmt.Errorf("failed to check for conflicting entities: %w", err)
+		}
+		if conflicts > 0 {
+			return fmt.Errorf("an entity with code '%s' or table '%s' already exists", archive.EntityCode, archive.OriginalTable)
+		}
+
+		if entity.ModuleID != nil {
+			var modules int64
+			if err := tx.Model(&models.Module{}).Where("id = ?", *entity.ModuleID).Count(&modules).Error; err != nil {
+				return fmt.Errorf("failed to find module: %w", err)
+			}
+			if modules == 0 {
+				entity.ModuleID = nil
+			}
+		}
+
+		relations, err := e.restorableRelations(tx, &entity, snapshot.Relations, snapshot.RelationTargets)
+		if err != nil {
+			return err
+		}
+
+		exists, err := e.tableExists(tx, archive.OriginalTable)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("table %s already exists", archive.OriginalTable)
+		}
+
+		if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s RENAME TO %s",
+			security.QuoteIdentifier(archive.ArchivedTable),
+			security.QuoteIdentifier(archive.OriginalTable))).Error; err != nil {
+			return fmt.Errorf("failed to restore table: %w", err)
+		}
+
+		for archived, original := range archive.IndexNames {
+			originalName, ok := original.(string)
+			if !ok || security.ValidateIdentifier(archived) != nil || security.ValidateIdentifier(originalName) != nil {
+				continue
+			}
+			if err := tx.Exec(fmt.Sprintf("ALTER INDEX %s RENAME TO %s",
+				security.QuoteIdentifier(archived), security.QuoteIdentifier(originalName))).Error; err != nil {
+				return fmt.Errorf("failed to restore index %s: %w", originalName, err)
+			}
+		}
+
+		if err := e.addOwnershipColumns(tx, archive.OriginalTable, &entity, snapshot.Fields); err != nil {
+			return err
+		}
+
+		if err := tx.Create(&entity).Error; err != nil {
+			return fmt.Errorf("failed to restore entity: %w", err)
+		}
+		for _, field := range snapshot.Fields {
+			field.Entity = nil
+			field.FieldType = nil
+			if err := tx.Create(&field).Error; err != nil {
+				return fmt.Errorf("failed to restore field %s: %w", field.Code, err)
+			}
+		}
+		for _, relation := range relations {
+			if err := tx.Create(&relation).Error; err != nil {
+				return fmt.Errorf("failed to restore relation %s: %w", relation.SourceFieldCode, err)
+			}
+		}
+
+		now := time.Now()
+		archive.RestoredAt = &now
+		if err := tx.Model(archive).Update("restored_at", now).Error; err != nil {
+			return err
+		}
+		if audit != nil {
+			return audit(tx, &entity)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity, nil
+}
+
+// addOwnershipColumns adds the ownership columns, and the owner index, to a
+// table that predates them, such as one archived before records had owners.
+// Like the migration that introduced them, existing records take their
+// creator and owner from the audit log.
+func (e *SchemaEngine) addOwnershipColumns(tx *gorm.DB, tableName string, entity *models.Entity, fields []models.Field) error {
+	quotedTable := security.QuoteIdentifier(tableName)
+	backfill := true
+	for _, column := range OwnershipColumns {
+		if hasColumn(fields, column) {
+			// A field of the entity stores it, with its own meaning
+			backfill = false
+			continue
+		}
+		if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s UUID REFERENCES users(id) ON DELETE SET NULL",
+			quotedTable, column)).Error; err != nil {
+			return fmt.Errorf("failed to add column %s: %w", column, err)
+		}
+	}
+
+	for _, statement := range e.tableIndexStatements(quotedTable, nil) {
+		if err := tx.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to restore indexes: %w", err)
+		}
+	}
+
+	if !backfill {
+		return nil
+	}
+	err := tx.Exec(fmt.Sprintf(`UPDATE %s t SET created_by = a.user_id, updated_by = a.user_id, owner_id = a.user_id
+		FROM audit_log a
+		WHERE a.entity_id = ? AND a.action = 'create' AND a.record_id = t.id AND t.owner_id IS NULL`, quotedTable), entity.ID).Error
+	if err != nil {
+		return fmt.Errorf("failed to set record owners: %w", err)
+	}
+	return nil
+}
+
+// restorableRelations returns an archived entity's relations pointed at the
+// current ids of their targets. Self relations follow the entity; other
+// targets are found by id or, failing that, by code.
+func (e *SchemaEngine) restorableRelations(db *gorm.DB, entity *models.Entity, relations []models.Relation, targets map[string]string) ([]models.Relation, error) {
+	restored := make([]models.Relation, 0, len(relations))
+	for _, relation := range relations {
+		relation.SourceEntity = nil
+		relation.TargetEntity = nil
+		relation.SourceEntityID = entity.ID
+
+		if relation.TargetEntityID != entity.ID {
+			var target models.Entity
+			err := db.Select("id").Where("id = ? AND tenant_id = ?", relation.TargetEntityID, entity.TenantID).Take(&target).Error
+			if err == gorm.ErrRecordNotFound && targets[relation.ID.String()] != "" {
+				err = db.Select("id").Where("code = ? AND tenant_id = ?", targets[relation.ID.String()], entity.TenantID).Take(&target).Error
+			}
+			if err == gorm.ErrRecordNotFound {
+				return nil, fmt.Errorf("entity '%s' related through field '%s' no longer exists; recreate it first",
+					targets[relation.ID.String()], relation.SourceFieldCode)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to find related entity: %w", err)
+			}
+			relation.TargetEntityID = target.ID
+		}
+		restored = append(restored, relation)
+	}
+	return restored, nil
+}
+
+// archiveTable renames an entity table (and its indexes, so a new entity with
+// the same code can create its own) and records the archive
+func (e *SchemaEngine) archiveTable(tx *gorm.DB, entity *models.Entity, fields []models.Field, relations []models.Relation, tableName string, actorID *uuid.UUID) (*models.EntityArchive, error) {
+	now := time.Now()
+	suffix := "_archived_" + now.Format("20060102150405")
+	base := tableName
+	if len(base)+len(suffix) > 63 {
+		base = base[:63-len(suffix)]
+	}
+	archivedTable := base + suffix
+	if err := security.ValidateIdentifier(archivedTable); err != nil {
+		return nil, fmt.Errorf("invalid archive table name '%s': %w", archivedTable, err)
+	}
+
+	var indexes []string
+	if err := tx.Raw(`SELECT indexname FROM pg_indexes WHERE schemaname = current_schema() AND tablename = ?`, tableName).
+		Scan(&indexes).Error; err != nil {
+		return nil, fmt.Errorf("failed to list indexes: %w", err)
+	}
+
+	if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s RENAME TO %s",
+		security.QuoteIdentifier(tableName), security.QuoteIdentifier(archivedTable))).Error; err != nil {
+		return nil, fmt.Errorf("failed to archive table %s: %w", tableName, err)
+	}
+
+	indexNames := models.JSONB{}
+	archiveID := uuid.New()
+	for i, index := range indexes {
+		archivedIndex := fmt.Sprintf("arch_%s_%d", strings.ReplaceAll(archiveID.String(), "-", ""), i)
+		if err := tx.Exec(fmt.Sprintf("ALTER INDEX %s RENAME TO %s",
+			security.QuoteIdentifier(index), security.QuoteIdentifier(archivedIndex))).Error; err != nil {
+			return nil, fmt.Errorf("failed to archive index %s: %w", index, err)
+		}
+		indexNames[archivedIndex] = index
+	}
+
+	relationTargets := make(map[string]string, len(relations))
+	for i := range relations {
+		if relations[i].TargetEntity != nil {
+			relationTargets[relations[i].ID.String()] = relations[i].TargetEntity.Code
+		}
+		relations[i].TargetEntity = nil
+	}
+
+	archive := &models.EntityArchive{
+		ID:            archiveID,
+		TenantID:      entity.TenantID,
+		EntityCode:    entity.Code,
+		EntityName:    entity.Name,
+		OriginalTable: tableName,
+		ArchivedTable: archivedTable,
+		Snapshot: models.JSONB{
+			"entity":           entity,
+			"fields":           fields,
+			"relations":        relations,
+			"relation_targets": relationTargets,
+		},
+		IndexNames: indexNames,
+		ArchivedBy: actorID,
+		ArchivedAt: now,
+	}
+	if err := tx.Create(archive).Error; err != nil {
+		return nil, fmt.Errorf("failed to record archive: %w", err)
+	}
+
+	return archive, nil
+}
+
+// deleteEntityMetadata removes an entity row and everything that hangs off it
+func (e *SchemaEngine) deleteEntityMetadata(tx *gorm.DB, entity *models.Entity) error {
+	steps := []struct {
+		what  string
+		query string
+		args  []interface{}
+	}{
+		{"menu items", "DELETE FROM menu_items WHERE entity_id = ?", []interface{}{entity.ID}},
+		{"pages", "DELETE FROM ui_pages WHERE entity_id = ?", []interface{}{entity.ID}},
+		{"components", "DELETE FROM ui_components WHERE tenant_id = ? AND category = 'entity' AND code IN ?",
+			[]interface{}{entity.TenantID, []string{entity.Code + "_list", entity.Code + "_form", entity.Code + "_detail"}}},
+		{"actions", "DELETE FROM actions WHERE entity_id = ?", []interface{}{entity.ID}},
+		{"permissions", "DELETE FROM permissions WHERE entity_id = ?", []interface{}{entity.ID}},
+		{"relations", "DELETE FROM relations WHERE source_entity_id = ? OR target_entity_id = ?", []interface{}{entity.ID, entity.ID}},
+		{"views", "DELETE FROM views WHERE entity_id = ?", []interface{}{entity.ID}},
+		{"fields", "DELETE FROM fields WHERE entity_id = ?", []interface{}{entity.ID}},
+		{"entity", "DELETE FROM entities WHERE id = ?", []interface{}{entity.ID}},
+	}
+	for _, step := range steps {
+		if err := tx.Exec(step.query, step.args...).Error; err != nil {
+			return fmt.Errorf("failed to delete %s: %w", step.what, err)
+		}
+	}
+	return nil
+}
+
+func (e *SchemaEngine) tableExists(db *gorm.DB, tableName string) (bool, error) {
+	var exists bool
+	if err := db.Raw(`SELECT EXISTS (
+		SELECT 1 FROM information_schema.tables
+		WHERE table_schema = current_schema() AND table_name = ?
+	)`, tableName).Scan(&exists).Error; err != nil {
+		return false, fmt.Errorf("failed to check table %s: %w", tableName, err)
+	}
+	return exists, nil
+}
+
 // =============================================================================
 // HELPER METHODS
 // =============================================================================
@@ -299,6 +931,20 @@ func (e *SchemaEngine) getColumnName(field *models.Field) string {
 	return field.Code
 }
 
+// hasColumn reports whether one of the fields is stored in the column
+func hasColumn(fields []models.Field, column string) bool {
+	for i := range fields {
+		name := fields[i].ColumnName
+		if name == "" {
+			name = fields[i].Code
+		}
+		if name == column && IsColumnField(&fields[i]) {
+			return true
+		}
+	}
+	return false
+}
+
 func (e *SchemaEngine) buildColumnDefinition(field *models.Field) (string, error) {
 	columnName := e.getColumnName(field)
 
@@ -425,7 +1071,12 @@ func (e *SchemaEngine) mapFieldTypeToSQL(ft *models.FieldType, field *models.Fie
 	}
 }
 
-func (e *SchemaEngine) createTableIndexes(tx *gorm.DB, tableName string, fields []models.Field) error {
+func (e *SchemaEngine) tableIndexStatements(tableName string, fields []models.Field) []string {
+	var statements []string
+
+	// Extract unquoted table name for index naming
+	unquotedTableName := strings.Trim(tableName, `"`)
+
 	for _, field := range fields {
 		columnName := e.getColumnName(&field)
 
@@ -436,64 +1087,55 @@ func (e *SchemaEngine) createTableIndexes(tx *gorm.DB, tableName string, fields
 
 		quotedColumnName := security.QuoteIdentifier(columnName)
 
-		// Extract unquoted table name for index naming
-		unquotedTableName := strings.Trim(tableName, `"`)
-
 		// Create index for searchable fields
 		if field.InSearch || field.InFilter || field.InSort {
 			indexName := fmt.Sprintf("idx_%s_%s", unquotedTableName, columnName)
-			if err := security.ValidateIdentifier(indexName); err != nil {
-				continue
-			}
-			quotedIndexName := security.QuoteIdentifier(indexName)
-			sql := fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s(%s)", quotedIndexName, tableName, quotedColumnName)
-			if err := tx.Exec(sql).Error; err != nil {
-				return err
+			if err := security.ValidateIdentifier(indexName); err == nil {
+				statements = append(statements, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s(%s)",
+					security.QuoteIdentifier(indexName), tableName, quotedColumnName))
 			}
 		}
 
 		// Create unique index if needed
 		if field.IsUnique && !field.IsPrimary {
 			indexName := fmt.Sprintf("idx_%s_%s_unique", unquotedTableName, columnName)
-			if err := security.ValidateIdentifier(indexName); err != nil {
-				continue
-			}
-			quotedIndexName := security.QuoteIdentifier(indexName)
-			sql := fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s(%s)", quotedIndexName, tableName, quotedColumnName)
-			if err := tx.Exec(sql).Error; err != nil {
-				return err
+			if err := security.ValidateIdentifier(indexName); err == nil {
+				statements = append(statements, fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s(%s)",
+					security.QuoteIdentifier(indexName), tableName, quotedColumnName))
 			}
 		}
 	}
 
 	// Always create tenant_id index
-	unquotedTableName := strings.Trim(tableName, `"`)
 	tenantIndexName := fmt.Sprintf("idx_%s_tenant_id", unquotedTableName)
 	if err := security.ValidateIdentifier(tenantIndexName); err == nil {
-		quotedTenantIndexName := security.QuoteIdentifier(tenantIndexName)
-		sql := fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s(tenant_id)", quotedTenantIndexName, tableName)
-		return tx.Exec(sql).Error
+		statements = append(statements, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s(tenant_id)",
+			security.QuoteIdentifier(tenantIndexName), tableName))
 	}
-	return nil
+
+	// Ownership scopes filter on owner_id
+	ownerIndexName := fmt.Sprintf("idx_%s_owner_id", unquotedTableName)
+	if err := security.ValidateIdentifier(ownerIndexName); err == nil {
+		statements = append(statements, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s(owner_id)",
+			security.QuoteIdentifier(ownerIndexName), tableName))
+	}
+	return statements
 }
 
-func (e *SchemaEngine) createUpdatedAtTrigger(tx *gorm.DB, tableName string) error {
+func (e *SchemaEngine) updatedAtTriggerStatement(tableName string) (string, error) {
 	unquotedTableName := strings.Trim(tableName, `"`)
 	triggerName := fmt.Sprintf("update_%s_updated_at", unquotedTableName)
 
 	// Validate trigger name
 	if err := security.ValidateIdentifier(triggerName); err != nil {
-		return fmt.Errorf("invalid trigger name: %w", err)
+		return "", fmt.Errorf("invalid trigger name: %w", err)
 	}
 
 	quotedTriggerName := security.QuoteIdentifier(triggerName)
-	sql := fmt.Sprintf(`
-		CREATE OR REPLACE TRIGGER %s
-		BEFORE UPDATE ON %s
-		FOR EACH ROW
-		EXECUTE FUNCTION update_updated_at_column()
-	`, quotedTriggerName, tableName)
-	return tx.Exec(sql).Error
+	return fmt.Sprintf(`CREATE OR REPLACE TRIGGER %s
+	BEFORE UPDATE ON %s
+	FOR EACH ROW
+	EXECUTE FUNCTION update_updated_at_column()`, quotedTriggerName, tableName), nil
 }
 
 // =============================================================================
@@ -0,0 +1,249 @@
+// Package engine - Record sharing
+// Shares give a user, or the holders of a role, access to single records
+// beyond the scope of their permissions
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aethra/genesis/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Share access levels
+const (
+	// ShareView lets the grantee see the record
+	ShareView = "view"
+	// ShareEdit lets the grantee see and edit the record
+	ShareEdit = "edit"
+)
+
+// ShareGrant names who a record is shared with and how. Exactly one of
+// UserID and RoleID is set.
+type ShareGrant struct {
+	UserID    *uuid.UUID `json:"user_id"`
+	RoleID    *uuid.UUID `json:"role_id"`
+	Access    string     `json:"access"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// ListShares returns the unexpired shares of a record. When userID is set,
+// the record must be one they may view.
+func (e *DataEngine) ListShares(tenantID uuid.UUID, entityCode string, recordID uuid.UUID, userID *uuid.UUID) ([]models.RecordShare, error) {
+	schema, query, err := e.recordQuery(tenantID, entityCode, recordID)
+	if err != nil {
+		return nil, err
+	}
+	query, err = e.accessScope(query, tenantID, schema, userID, accessView)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordFound(query); err != nil {
+		return nil, err
+	}
+
+	shares := []models.RecordShare{}
+	err = e.db.Where("tenant_id = ? AND entity_id = ? AND record_id = ?", tenantID, schema.Entity.ID, recordID).
+		Where("(expires_at IS NULL OR expires_at > ?)", time.Now()).
+		Order("created_at").
+		Find(&shares).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shares: %w", err)
+	}
+	return shares, nil
+}
+
+// ShareRecord shares a record with a user or a role, replacing any share
+// with them. When userID is set, they must be able to edit the record through
+// their own permissions; a share cannot be passed on.
+func (e *DataEngine) ShareRecord(tenantID uuid.UUID, entityCode string, recordID uuid.UUID, grant ShareGrant, userID *uuid.UUID) (*models.RecordShare, error) {
+	if grant.Access == "" {
+		grant.Access = ShareView
+	}
+	if grant.Access != ShareView && grant.Access != ShareEdit {
+		return nil, fmt.Errorf("validation failed: access must be view or edit")
+	}
+	if grant.ExpiresAt != nil && !grant.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("validation failed: expires_at must be in the future")
+	}
+	if err := e.validateGrantee(tenantID, grant); err != nil {
+		return nil, err
+	}
+
+	schema, err := e.shareableRecord(tenantID, entityCode, recordID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var share models.RecordShare
+	var oldValues map[string]interface{}
+	err = e.granteeShares(tenantID, schema.Entity.ID, recordID, grant).First(&share).Error
+	switch {
+	case err == nil:
+		oldValues = shareValues(&share)
+	case err == gorm.ErrRecordNotFound:
+		share = models.RecordShare{
+			ID:        uuid.New(),
+			TenantID:  tenantID,
+			EntityID:  schema.Entity.ID,
+			RecordID:  recordID,
+			UserID:    grant.UserID,
+			RoleID:    grant.RoleID,
+			CreatedBy: userID,
+		}
+	default:
+		return nil, fmt.Errorf("failed to find share: %w", err)
+	}
+
+	share.Access = grant.Access
+	share.ExpiresAt = grant.ExpiresAt
+	if oldValues == nil {
+		err = e.db.Create(&share).Error
+	} else {
+		err = e.db.Save(&share).Error
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to share record: %w", err)
+	}
+
+	// Shares change who can reach the record, so they are audited even when
+	// the entity's changes are not
+	e.createAuditLog(tenantID, userID, schema.Entity, recordID, "share", oldValues, shareValues(&share))
+	return &share, nil
+}
+
+// RevokeShare removes a record's share with the user or role of grant. When
+// userID is set, they must be able to edit the record through their own
+// permissions.
+func (e *DataEngine) RevokeShare(tenantID uuid.UUID, entityCode string, recordID uuid.UUID, grant ShareGrant, userID *uuid.UUID) error {
+	if (grant.UserID == nil) == (grant.RoleID == nil) {
+		return fmt.Errorf("validation failed: give either user_id or role_id")
+	}
+
+	schema, err := e.shareableRecord(tenantID, entityCode, recordID, userID)
+	if err != nil {
+		return err
+	}
+
+	var share models.RecordShare
+	if err := e.granteeShares(tenantID, schema.Entity.ID, recordID, grant).First(&share).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return fmt.Errorf("share not found")
+		}
+		return fmt.Errorf("failed to find share: %w", err)
+	}
+	if err := e.db.Delete(&share).Error; err != nil {
+		return fmt.Errorf("failed to revoke share: %w", err)
+	}
+
+	e.createAuditLog(tenantID, userID, schema.Entity, recordID, "unshare", shareValues(&share), nil)
+	return nil
+}
+
+// sharedRecordIDs selects the ids of an entity's records shared with a user,
+// directly or through one of their roles, for at least action
+func (e *DataEngine) sharedRecordIDs(tenantID, entityID, userID uuid.UUID, action string) *gorm.DB {
+	query := e.db.Table("record_shares").Select("record_id").
+		Where("tenant_id = ? AND entity_id = ?", tenantID, entityID).
+		Where("(user_id = ? OR role_id IN (SELECT role_id FROM user_roles WHERE user_id = ?))", userID, userID).
+		Where("(expires_at IS NULL OR expires_at > ?)", time.Now())
+	if action == accessEdit {
+		query = query.Where("access = ?", ShareEdit)
+	}
+	return query
+}
+
+// granteeShares selects a record's share with the user or role of grant
+func (e *DataEngine) granteeShares(tenantID, entityID, recordID uuid.UUID, grant ShareGrant) *gorm.DB {
+	query := e.db.Where("tenant_id = ? AND entity_id = ? AND record_id = ?", tenantID, entityID, recordID)
+	if grant.UserID != nil {
+		return query.Where("user_id = ?", *grant.UserID)
+	}
+	return query.Where("role_id = ?", *grant.RoleID)
+}
+
+// validateGrantee checks that a grant names exactly one active user or one
+// role of the tenant
+func (e *DataEngine) validateGrantee(tenantID uuid.UUID, grant ShareGrant) error {
+	var count int64
+	var err error
+	switch {
+	case (grant.UserID == nil) == (grant.RoleID == nil):
+		return fmt.Errorf("validation failed: share with either a user_id or a role_id")
+	case grant.UserID != nil:
+		// Members from other tenants can be shared with like home users
+		err = e.db.Table("users").
+			Where("id = ? AND is_active = true AND id IN (SELECT user_id FROM tenant_memberships WHERE tenant_id = ?)", *grant.UserID, tenantID).
+			Count(&count).Error
+	default:
+		err = e.db.Table("roles").Where("id = ? AND tenant_id = ?", *grant.RoleID, tenantID).Count(&count).Error
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find grantee: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("validation failed: grantee is not a user or role of the tenant")
+	}
+	return nil
+}
+
+// shareableRecord returns the schema of a record the user may share: one
+// they can edit through their roles, without counting shares
+func (e *DataEngine) shareableRecord(tenantID uuid.UUID, entityCode string, recordID uuid.UUID, userID *uuid.UUID) (*EntitySchema, error) {
+	schema, query, err := e.recordQuery(tenantID, entityCode, recordID)
+	if err != nil {
+		return nil, err
+	}
+	scope, err := e.roleScope(tenantID, schema, userID, accessEdit)
+	if err != nil {
+		return nil, err
+	}
+	if scope != nil {
+		query = query.Where(scope)
+	}
+	return schema, recordFound(query)
+}
+
+// recordQuery returns an entity's schema and a query selecting one of its
+// records that is not deleted
+func (e *DataEngine) recordQuery(tenantID uuid.UUID, entityCode string, recordID uuid.UUID) (*EntitySchema, *gorm.DB, error) {
+	schema, err := e.schemaEngine.GetEntitySchema(tenantID, entityCode)
+	if err != nil {
+		return nil, nil, err
+	}
+	tableName, err := e.safeTableName(schema.Entity)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query := e.db.Table(tableName).Where("tenant_id = ? AND id = ?", tenantID, recordID)
+	if schema.Entity.UseSoftDelete {
+		query = query.Where("deleted_at IS NULL")
+	}
+	return schema, query, nil
+}
+
+// recordFound reports a "record not found" error when query selects nothing
+func recordFound(query *gorm.DB) error {
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to find record: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("record not found")
+	}
+	return nil
+}
+
+// shareValues returns the audited form of a share
+func shareValues(share *models.RecordShare) map[string]interface{} {
+	return map[string]interface{}{
+		"share_id":   share.ID,
+		"user_id":    share.UserID,
+		"role_id":    share.RoleID,
+		"access":     share.Access,
+		"expires_at": share.ExpiresAt,
+	}
+}
@@ -0,0 +1,245 @@
+// Package engine - Payload validation
+// Validates record payloads against the JSON Schema derived from entity fields,
+// the same schema served to clients at /api/schema/:entity/jsonschema
+package engine
+
+import (
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aethra/genesis/internal/models"
+	"github.com/google/uuid"
+)
+
+// JSONSchemaDialect is the JSON Schema draft used for payload schemas
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// PayloadSchema returns the standalone JSON Schema for create or update
+// payloads of an entity
+func PayloadSchema(entity *models.Entity, isCreate bool) map[string]interface{} {
+	schema := InputSchema(entity, isCreate)
+	schema["$schema"] = JSONSchemaDialect
+	return schema
+}
+
+// textFieldTypes keep empty strings as values; every other type treats an
+// empty string (what HTML forms send for a blank input) as null
+var textFieldTypes = map[string]bool{
+	"": true, "string": true, "text": true, "richtext": true, "phone": true,
+	"slug": true, "color": true, "icon": true, "file": true, "image": true,
+}
+
+// coerceFieldValue normalizes form-style input before validation: blank
+// non-text values become null, numeric and boolean strings become numbers and
+// booleans, and datetime-local values become RFC 3339 timestamps
+func coerceFieldValue(field *models.Field, value interface{}) interface{} {
+	str, ok := value.(string)
+	if !ok {
+		return value
+	}
+
+	typeCode := fieldTypeCode(field)
+	if str == "" && !textFieldTypes[typeCode] {
+		return nil
+	}
+
+	switch typeCode {
+	case "integer", "decimal":
+		if n, err := strconv.ParseFloat(strings.TrimSpace(str), 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(str); err == nil {
+			return b
+		}
+	case "datetime":
+		for _, layout := range []string{"2006-01-02T15:04", "2006-01-02T15:04:05", "2006-01-02 15:04:05"} {
+			if t, err := time.Parse(layout, str); err == nil {
+				return t.Format(time.RFC3339)
+			}
+		}
+	}
+	return value
+}
+
+// validateAgainstSchema checks a value against the schema FieldSchema builds
+// for the field
+func validateAgainstSchema(field *models.Field, schema map[string]interface{}, value interface{}) error {
+	if !matchesType(schema["type"], value) {
+		return fmt.Errorf("field '%s' must be %s", field.Name, describeType(schema["type"]))
+	}
+	if value == nil {
+		return nil
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && !inEnum(enum, value) {
+		return fmt.Errorf("field '%s' must be one of %s", field.Name, formatEnum(enum))
+	}
+
+	switch v := value.(type) {
+	case string:
+		length := utf8.RuneCountInString(v)
+		if min, ok := schema["minLength"].(int); ok && length < min {
+			return fmt.Errorf("field '%s' must be at least %d characters", field.Name, min)
+		}
+		if max, ok := schema["maxLength"].(int); ok && length > max {
+			return fmt.Errorf("field '%s' must be at most %d characters", field.Name, max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re := compilePattern(pattern); re != nil && !re.MatchString(v) {
+				return fmt.Errorf("field '%s' does not match the required format", field.Name)
+			}
+		}
+		if format, ok := schema["format"].(string); ok && !matchesFormat(format, v) {
+			return fmt.Errorf("field '%s' must be a valid %s", field.Name, format)
+		}
+	case float64:
+		if min, ok := schema["minimum"].(float64); ok && v < min {
+			return fmt.Errorf("field '%s' must be at least %v", field.Name, min)
+		}
+		if max, ok := schema["maximum"].(float64); ok && v > max {
+			return fmt.Errorf("field '%s' must be at most %v", field.Name, max)
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for _, item := range v {
+				if err := validateAgainstSchema(field, items, item); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func matchesType(schemaType interface{}, value interface{}) bool {
+	switch t := schemaType.(type) {
+	case nil:
+		return true
+	case string:
+		return isJSONType(t, value)
+	case []string:
+		for _, candidate := range t {
+			if isJSONType(candidate, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isJSONType(jsonType string, value interface{}) bool {
+	switch jsonType {
+	case "null":
+		return value == nil
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	}
+	return false
+}
+
+func describeType(schemaType interface{}) string {
+	names := map[string]string{
+		"string": "text", "integer": "a whole number", "number": "a number",
+		"boolean": "true or false", "array": "a list", "object": "an object", "null": "empty",
+	}
+	switch t := schemaType.(type) {
+	case string:
+		return names[t]
+	case []string:
+		var parts []string
+		for _, candidate := range t {
+			parts = append(parts, names[candidate])
+		}
+		return strings.Join(parts, " or ")
+	}
+	return "valid"
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, option := range enum {
+		if fmt.Sprintf("%v", option) == fmt.Sprintf("%v", value) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatEnum(enum []interface{}) string {
+	var parts []string
+	for _, option := range enum {
+		if option != nil {
+			parts = append(parts, fmt.Sprintf("%v", option))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func matchesFormat(format, value string) bool {
+	switch format {
+	case "uuid":
+		_, err := uuid.Parse(value)
+		return err == nil
+	case "email":
+		addr, err := mail.ParseAddress(value)
+		return err == nil && addr.Address == value
+	case "uri":
+		u, err := url.Parse(value)
+		return err == nil && u.Scheme != ""
+	case "date":
+		_, err := time.Parse("2006-01-02", value)
+		return err == nil
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	case "time":
+		for _, layout := range []string{"15:04:05", "15:04", "15:04:05Z07:00"} {
+			if _, err := time.Parse(layout, value); err == nil {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// patternCache holds compiled RegexPattern values; patterns that don't compile
+// are cached as nil and not enforced
+var patternCache sync.Map
+
+func compilePattern(pattern string) *regexp.Regexp {
+	if cached, ok := patternCache.Load(pattern); ok {
+		return cached.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		re = nil
+	}
+	patternCache.Store(pattern, re)
+	return re
+}
@@ -4,6 +4,7 @@ package errors
 import (
 	"fmt"
 	"net/http"
+	"strings"
 )
 
 // GenesisError is the base interface for all Genesis errors
@@ -53,7 +54,7 @@ func NewNotFoundError(resource string) *NotFoundError {
 // ValidationError represents a validation error
 type ValidationError struct {
 	BaseError
-	Field string
+	Field string `json:"field,omitempty"`
 }
 
 func NewValidationError(field, message string) *ValidationError {
@@ -67,6 +68,44 @@ func NewValidationError(field, message string) *ValidationError {
 	}
 }
 
+// ValidationErrors collects every validation error of a request, such as
+// each rule a password breaks
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, ve := range e {
+		messages[i] = ve.Message
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+func (e ValidationErrors) HTTPStatus() int {
+	return http.StatusBadRequest
+}
+
+func (e ValidationErrors) Code() string {
+	return "VALIDATION_ERROR"
+}
+
+// Add records a validation error. code identifies the rule, e.g.
+// PASSWORD_TOO_SHORT; empty uses VALIDATION_ERROR.
+func (e *ValidationErrors) Add(field, code, message string) {
+	ve := NewValidationError(field, message)
+	if code != "" {
+		ve.ErrorCode = code
+	}
+	*e = append(*e, ve)
+}
+
+// Err returns nil when nothing was invalid
+func (e ValidationErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
 // PermissionDeniedError represents a permission denied error
 type PermissionDeniedError struct {
 	BaseError
@@ -159,6 +198,15 @@ func ToHTTPError(err error) (int, map[string]interface{}) {
 		return http.StatusOK, nil
 	}
 
+	// Validation errors list each problem
+	if ve, ok := err.(ValidationErrors); ok {
+		return ve.HTTPStatus(), map[string]interface{}{
+			"error":   ve.Code(),
+			"message": ve.Error(),
+			"errors":  []*ValidationError(ve),
+		}
+	}
+
 	// Check if it's a GenesisError
 	if ge, ok := err.(GenesisError); ok {
 		return ge.HTTPStatus(), map[string]interface{}{
@@ -0,0 +1,766 @@
+// Package generator - Typed API client generation
+// Emits TypeScript and Go clients for a tenant's /api/data endpoints
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/aethra/genesis/internal/engine"
+	"github.com/aethra/genesis/internal/models"
+)
+
+// SourceFile is one generated source file
+type SourceFile struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// ClientOptions controls client generation
+type ClientOptions struct {
+	Lang    string // "ts" or "go"
+	Package string // Go package name, defaults to "genesisclient"
+}
+
+// clientEntity is the language-neutral view of an entity used by the emitters.
+// Entities and fields are sorted so that output only changes when the schema
+// does.
+type clientEntity struct {
+	Code      string
+	Name      string
+	Type      string
+	Fields    []clientField
+	Timestamp bool
+}
+
+type clientField struct {
+	Code     string
+	Name     string
+	Kind     string // string, integer, number, boolean, datetime, json, list, enum, enum_list
+	Required bool
+	ReadOnly bool
+	Enum     string // enum type name when Kind is enum or enum_list
+	Options  []string
+}
+
+// GenerateClient produces typed client sources for every active entity in
+// the tenant schema
+func GenerateClient(schema *engine.TenantSchema, opts ClientOptions) ([]SourceFile, error) {
+	entities := clientEntities(schema)
+
+	switch opts.Lang {
+	case "ts":
+		return []SourceFile{
+			{Path: "types.ts", Content: tsTypes(entities)},
+			{Path: "client.ts", Content: tsClient(entities)},
+			{Path: "index.ts", Content: clientHeader("//") + "export * from './types';\nexport * from './client';\n"},
+		}, nil
+	case "go":
+		pkg := opts.Package
+		if pkg == "" {
+			pkg = "genesisclient"
+		}
+		types, err := format.Source([]byte(goTypes(pkg, entities)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to format generated types: %w", err)
+		}
+		client, err := format.Source([]byte(goClient(pkg, entities)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to format generated client: %w", err)
+		}
+		return []SourceFile{
+			{Path: "types.go", Content: string(types)},
+			{Path: "client.go", Content: string(client)},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported language '%s' (use ts or go)", opts.Lang)
+	}
+}
+
+func clientEntities(schema *engine.TenantSchema) []clientEntity {
+	var entities []clientEntity
+	for i := range schema.Entities {
+		entity := &schema.Entities[i]
+		ce := clientEntity{
+			Code:      entity.Code,
+			Name:      entity.Name,
+			Type:      engine.SchemaName(entity.Code),
+			Timestamp: entity.UseTimestamps,
+		}
+
+		fields := make([]models.Field, len(entity.Fields))
+		copy(fields, entity.Fields)
+		sort.SliceStable(fields, func(a, b int) bool {
+			if fields[a].DisplayOrder != fields[b].DisplayOrder {
+				return fields[a].DisplayOrder < fields[b].DisplayOrder
+			}
+			return fields[a].Code < fields[b].Code
+		})
+
+		for j := range fields {
+			field := &fields[j]
+			if !engine.IsColumnField(field) || clientSystemColumns[field.Code] {
+				continue
+			}
+			cf := clientField{
+				Code:     field.Code,
+				Name:     field.Name,
+				Kind:     clientKind(field),
+				Required: field.IsRequired,
+				ReadOnly: field.IsSystem || field.IsAuto || field.IsPrimary,
+			}
+			if cf.Kind == "enum" || cf.Kind == "enum_list" {
+				for _, option := range engine.FieldOptions(field) {
+					cf.Options = append(cf.Options, fmt.Sprintf("%v", option))
+				}
+				if len(cf.Options) == 0 {
+					cf.Kind = map[string]string{"enum": "string", "enum_list": "list"}[cf.Kind]
+				} else {
+					cf.Enum = ce.Type + engine.SchemaName(field.Code)
+				}
+			}
+			ce.Fields = append(ce.Fields, cf)
+		}
+		entities = append(entities, ce)
+	}
+
+	sort.Slice(entities, func(a, b int) bool { return entities[a].Code < entities[b].Code })
+	return entities
+}
+
+// clientSystemColumns are emitted by the generators themselves
+var clientSystemColumns = map[string]bool{
+	"id": true, "tenant_id": true, "created_at": true, "updated_at": true, "deleted_at": true,
+}
+
+func clientKind(field *models.Field) string {
+	code := ""
+	if field.FieldType != nil {
+		code = field.FieldType.Code
+	}
+	switch code {
+	case "integer":
+		return "integer"
+	case "decimal":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "datetime":
+		return "datetime"
+	case "json":
+		return "json"
+	case "enum":
+		return "enum"
+	case "multi_enum":
+		return "enum_list"
+	case "tags":
+		return "list"
+	default:
+		return "string"
+	}
+}
+
+func clientHeader(comment string) string {
+	return comment + " Code generated by Genesis. DO NOT EDIT.\n" +
+		comment + " Regenerate with: genesis codegen\n\n"
+}
+
+// =============================================================================
+// TYPESCRIPT
+// =============================================================================
+
+func tsType(field clientField) string {
+	switch field.Kind {
+	case "integer", "number":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "json":
+		return "unknown"
+	case "list":
+		return "string[]"
+	case "enum":
+		return field.Enum
+	case "enum_list":
+		return field.Enum + "[]"
+	default:
+		return "string"
+	}
+}
+
+func tsTypes(entities []clientEntity) string {
+	var b strings.Builder
+	b.WriteString(clientHeader("//"))
+
+	for _, entity := range entities {
+		for _, field := range entity.Fields {
+			if field.Enum == "" {
+				continue
+			}
+			var values []string
+			for _, option := range field.Options {
+				values = append(values, jsonString(option))
+			}
+			fmt.Fprintf(&b, "export type %s = %s;\n", field.Enum, strings.Join(values, " | "))
+			fmt.Fprintf(&b, "export const %sValues: readonly %s[] = [%s] as const;\n\n", field.Enum, field.Enum, strings.Join(values, ", "))
+		}
+	}
+
+	for _, entity := range entities {
+		fmt.Fprintf(&b, "/** %s record */\n", entity.Name)
+		fmt.Fprintf(&b, "export interface %s {\n", entity.Type)
+		b.WriteString("  id: string;\n")
+		b.WriteString("  tenant_id: string;\n")
+		for _, field := range entity.Fields {
+			if field.Required {
+				fmt.Fprintf(&b, "  %s: %s;\n", field.Code, tsType(field))
+			} else {
+				fmt.Fprintf(&b, "  %s?: %s | null;\n", field.Code, tsType(field))
+			}
+		}
+		if entity.Timestamp {
+			b.WriteString("  created_at: string;\n")
+			b.WriteString("  updated_at: string;\n")
+		}
+		b.WriteString("}\n\n")
+
+		fmt.Fprintf(&b, "/** Payload for creating a %s */\n", entity.Name)
+		fmt.Fprintf(&b, "export interface %sInput {\n", entity.Type)
+		for _, field := range entity.Fields {
+			if field.ReadOnly {
+				continue
+			}
+			if field.Required {
+				fmt.Fprintf(&b, "  %s: %s;\n", field.Code, tsType(field))
+			} else {
+				fmt.Fprintf(&b, "  %s?: %s | null;\n", field.Code, tsType(field))
+			}
+		}
+		b.WriteString("}\n\n")
+
+		fmt.Fprintf(&b, "/** Equality filters for listing %s records */\n", entity.Name)
+		fmt.Fprintf(&b, "export interface %sFilter {\n", entity.Type)
+		for _, field := range entity.Fields {
+			if field.Kind == "json" || field.Kind == "list" || field.Kind == "enum_list" {
+				continue
+			}
+			fmt.Fprintf(&b, "  %s?: %s;\n", field.Code, tsType(field))
+		}
+		b.WriteString("}\n\n")
+	}
+
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+func tsClient(entities []clientEntity) string {
+	var b strings.Builder
+	b.WriteString(clientHeader("//"))
+	b.WriteString("import type {\n")
+	for _, entity := range entities {
+		fmt.Fprintf(&b, "  %s,\n  %sInput,\n  %sFilter,\n", entity.Type, entity.Type, entity.Type)
+	}
+	b.WriteString("} from './types';\n\n")
+	b.WriteString(tsClientRuntime)
+
+	b.WriteString("\nexport class GenesisClient extends GenesisTransport {\n")
+	for _, entity := range entities {
+		fmt.Fprintf(&b, "  readonly %s = new EntityClient<%s, %sInput, %sFilter>(this, '%s');\n",
+			tsPropertyName(entity.Code), entity.Type, entity.Type, entity.Type, entity.Code)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func tsPropertyName(code string) string {
+	name := engine.SchemaName(code)
+	if name == "" {
+		return code
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+const tsClientRuntime = `export interface ClientOptions {
+  baseUrl: string;
+  tenantId: string;
+  token?: string;
+  fetch?: typeof fetch;
+}
+
+export interface ListParams<F> {
+  page?: number;
+  page_size?: number;
+  sort?: string;
+  sort_dir?: 'asc' | 'desc';
+  search?: string;
+  include?: string[];
+  filter?: F;
+}
+
+export interface Page<T> {
+  data: T[];
+  total: number;
+  page: number;
+  page_size: number;
+  total_pages: number;
+}
+
+export class GenesisError extends Error {
+  constructor(readonly status: number, readonly code: string, message: string) {
+    super(message);
+  }
+}
+
+export class GenesisTransport {
+  constructor(readonly options: ClientOptions) {}
+
+  async request<T>(method: string, path: string, body?: unknown): Promise<T> {
+    const doFetch = this.options.fetch ?? fetch;
+    const headers: Record<string, string> = {
+      'Content-Type': 'application/json',
+      'X-Tenant-ID': this.options.tenantId,
+    };
+    if (this.options.token) {
+      headers.Authorization = ` + "`Bearer ${this.options.token}`" + `;
+    }
+    const res = await doFetch(this.options.baseUrl.replace(/\/$/, '') + path, {
+      method,
+      headers,
+      body: body === undefined ? undefined : JSON.stringify(body),
+    });
+    const payload = await res.json().catch(() => ({}));
+    if (!res.ok) {
+      throw new GenesisError(res.status, payload.error ?? 'ERROR', payload.message ?? res.statusText);
+    }
+    return payload as T;
+  }
+}
+
+export class EntityClient<T, I, F> {
+  constructor(private readonly transport: GenesisTransport, readonly entity: string) {}
+
+  list(params: ListParams<F> = {}): Promise<Page<T>> {
+    const query = new URLSearchParams();
+    for (const key of ['page', 'page_size', 'sort', 'sort_dir', 'search'] as const) {
+      if (params[key] !== undefined) query.set(key, String(params[key]));
+    }
+    if (params.include?.length) query.set('include', params.include.join(','));
+    for (const [field, value] of Object.entries(params.filter ?? {})) {
+      if (value !== undefined && value !== null) query.set(` + "`filter[${field}]`" + `, String(value));
+    }
+    const qs = query.toString();
+    return this.transport.request('GET', ` + "`/api/data/${this.entity}${qs ? `?${qs}` : ''}`" + `);
+  }
+
+  get(id: string): Promise<T> {
+    return this.transport.request('GET', ` + "`/api/data/${this.entity}/${id}`" + `);
+  }
+
+  create(input: I): Promise<T> {
+    return this.transport.request('POST', ` + "`/api/data/${this.entity}`" + `, input);
+  }
+
+  bulkCreate(records: I[]): Promise<{ data: T[]; count: number }> {
+    return this.transport.request('POST', ` + "`/api/data/${this.entity}/bulk-create`" + `, { records });
+  }
+
+  update(id: string, input: Partial<I>): Promise<T> {
+    return this.transport.request('PUT', ` + "`/api/data/${this.entity}/${id}`" + `, input);
+  }
+
+  delete(id: string): Promise<{ message: string }> {
+    return this.transport.request('DELETE', ` + "`/api/data/${this.entity}/${id}`" + `);
+  }
+
+  bulkDelete(ids: string[]): Promise<{ message: string }> {
+    return this.transport.request('POST', ` + "`/api/data/${this.entity}/bulk-delete`" + `, { ids });
+  }
+}
+`
+
+// =============================================================================
+// GO
+// =============================================================================
+
+func goType(field clientField, pointer bool) string {
+	var t string
+	switch field.Kind {
+	case "integer":
+		t = "int64"
+	case "number":
+		t = "float64"
+	case "boolean":
+		t = "bool"
+	case "datetime":
+		t = "time.Time"
+	case "json":
+		return "json.RawMessage"
+	case "list":
+		return "[]string"
+	case "enum":
+		t = field.Enum
+	case "enum_list":
+		return "[]" + field.Enum
+	default:
+		t = "string"
+	}
+	if pointer {
+		return "*" + t
+	}
+	return t
+}
+
+func goTypes(pkg string, entities []clientEntity) string {
+	var b strings.Builder
+	b.WriteString(clientHeader("//"))
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+
+	needsTime, needsJSON := false, false
+	for _, entity := range entities {
+		needsTime = needsTime || entity.Timestamp
+		for _, field := range entity.Fields {
+			needsTime = needsTime || field.Kind == "datetime"
+			needsJSON = needsJSON || field.Kind == "json"
+		}
+	}
+	if needsTime || needsJSON {
+		b.WriteString("import (\n")
+		if needsJSON {
+			b.WriteString("\t\"encoding/json\"\n")
+		}
+		if needsTime {
+			b.WriteString("\t\"time\"\n")
+		}
+		b.WriteString(")\n\n")
+	}
+
+	for _, entity := range entities {
+		for _, field := range entity.Fields {
+			if field.Enum == "" {
+				continue
+			}
+			fmt.Fprintf(&b, "// %s is the set of values allowed for %s.%s\n", field.Enum, entity.Name, field.Name)
+			fmt.Fprintf(&b, "type %s string\n\n", field.Enum)
+			b.WriteString("const (\n")
+			seen := map[string]bool{}
+			for i, option := range field.Options {
+				name := field.Enum + goIdentifier(option)
+				if seen[name] || name == field.Enum {
+					name = fmt.Sprintf("%s%d", field.Enum, i+1)
+				}
+				seen[name] = true
+				fmt.Fprintf(&b, "\t%s %s = %s\n", name, field.Enum, jsonString(option))
+			}
+			b.WriteString(")\n\n")
+		}
+	}
+
+	for _, entity := range entities {
+		fmt.Fprintf(&b, "// %s is a %s record\n", entity.Type, entity.Name)
+		fmt.Fprintf(&b, "type %s struct {\n", entity.Type)
+		b.WriteString("\tID string `json:\"id\"`\n")
+		b.WriteString("\tTenantID string `json:\"tenant_id\"`\n")
+		for _, field := range entity.Fields {
+			fmt.Fprintf(&b, "\t%s %s `json:\"%s\"`\n", goFieldName(field.Code), goType(field, !field.Required), field.Code)
+		}
+		if entity.Timestamp {
+			b.WriteString("\tCreatedAt time.Time `json:\"created_at\"`\n")
+			b.WriteString("\tUpdatedAt time.Time `json:\"updated_at\"`\n")
+		}
+		b.WriteString("}\n\n")
+
+		fmt.Fprintf(&b, "// %sInput is the payload for creating or updating a %s.\n", entity.Type, entity.Name)
+		b.WriteString("// Nil fields are left out of the request.\n")
+		fmt.Fprintf(&b, "type %sInput struct {\n", entity.Type)
+		for _, field := range entity.Fields {
+			if field.ReadOnly {
+				continue
+			}
+			fmt.Fprintf(&b, "\t%s %s `json:\"%s,omitempty\"`\n", goFieldName(field.Code), goType(field, true), field.Code)
+		}
+		b.WriteString("}\n\n")
+
+		fmt.Fprintf(&b, "// %sFilter holds equality filters for listing %s records\n", entity.Type, entity.Name)
+		fmt.Fprintf(&b, "type %sFilter struct {\n", entity.Type)
+		for _, field := range entity.Fields {
+			if field.Kind == "json" || field.Kind == "list" || field.Kind == "enum_list" {
+				continue
+			}
+			fmt.Fprintf(&b, "\t%s %s `json:\"%s,omitempty\"`\n", goFieldName(field.Code), goType(field, true), field.Code)
+		}
+		b.WriteString("}\n\n")
+	}
+
+	return b.String()
+}
+
+func goClient(pkg string, entities []clientEntity) string {
+	var b strings.Builder
+	b.WriteString(clientHeader("//"))
+	fmt.Fprintf(&b, "package %s\n", pkg)
+	b.WriteString(goClientRuntime)
+
+	b.WriteString("\n// Client is a typed client for the tenant's data API\n")
+	b.WriteString("type Client struct {\n\t*Transport\n")
+	for _, entity := range entities {
+		fmt.Fprintf(&b, "\t%s *EntityClient[%s, %sInput, %sFilter]\n", entity.Type, entity.Type, entity.Type, entity.Type)
+	}
+	b.WriteString("}\n\n")
+
+	b.WriteString("// NewClient creates a client for baseURL acting within tenantID\n")
+	b.WriteString("func NewClient(baseURL, tenantID, token string) *Client {\n")
+	b.WriteString("\tt := &Transport{BaseURL: baseURL, TenantID: tenantID, Token: token, HTTPClient: http.DefaultClient}\n")
+	b.WriteString("\treturn &Client{\n\t\tTransport: t,\n")
+	for _, entity := range entities {
+		fmt.Fprintf(&b, "\t\t%s: &EntityClient[%s, %sInput, %sFilter]{transport: t, entity: %q},\n",
+			entity.Type, entity.Type, entity.Type, entity.Type, entity.Code)
+	}
+	b.WriteString("\t}\n}\n")
+	return b.String()
+}
+
+const goClientRuntime = `
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Transport sends authenticated requests to a Genesis server
+type Transport struct {
+	BaseURL    string
+	TenantID   string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// Error is returned for non-2xx responses
+type Error struct {
+	Status  int
+	Code    string ` + "`json:\"error\"`" + `
+	Message string ` + "`json:\"message\"`" + `
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("genesis: %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// Do sends a request and decodes the JSON response into out
+func (t *Transport) Do(ctx context.Context, method, path string, body, out any) error {
+	var reader *bytes.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(t.BaseURL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tenant-ID", t.TenantID)
+	if t.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.Token)
+	}
+
+	client := t.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		apiErr := &Error{Status: resp.StatusCode}
+		_ = json.NewDecoder(resp.Body).Decode(apiErr)
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// ListParams controls paging, sorting, search, includes and filters
+type ListParams[F any] struct {
+	Page     int
+	PageSize int
+	Sort     string
+	SortDir  string
+	Search   string
+	Include  []string
+	Filter   *F
+}
+
+// Page is one page of list results
+type Page[T any] struct {
+	Data       []T   ` + "`json:\"data\"`" + `
+	Total      int64 ` + "`json:\"total\"`" + `
+	Page       int   ` + "`json:\"page\"`" + `
+	PageSize   int   ` + "`json:\"page_size\"`" + `
+	TotalPages int   ` + "`json:\"total_pages\"`" + `
+}
+
+// EntityClient provides CRUD operations for one entity
+type EntityClient[T, I, F any] struct {
+	transport *Transport
+	entity    string
+}
+
+// List returns a page of records
+func (c *EntityClient[T, I, F]) List(ctx context.Context, params ListParams[F]) (*Page[T], error) {
+	query := url.Values{}
+	if params.Page > 0 {
+		query.Set("page", strconv.Itoa(params.Page))
+	}
+	if params.PageSize > 0 {
+		query.Set("page_size", strconv.Itoa(params.PageSize))
+	}
+	if params.Sort != "" {
+		query.Set("sort", params.Sort)
+	}
+	if params.SortDir != "" {
+		query.Set("sort_dir", params.SortDir)
+	}
+	if params.Search != "" {
+		query.Set("search", params.Search)
+	}
+	if len(params.Include) > 0 {
+		query.Set("include", strings.Join(params.Include, ","))
+	}
+	if params.Filter != nil {
+		raw, err := json.Marshal(params.Filter)
+		if err != nil {
+			return nil, err
+		}
+		var filters map[string]any
+		if err := json.Unmarshal(raw, &filters); err != nil {
+			return nil, err
+		}
+		for field, value := range filters {
+			query.Set("filter["+field+"]", fmt.Sprint(value))
+		}
+	}
+
+	path := "/api/data/" + c.entity
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var page Page[T]
+	if err := c.transport.Do(ctx, http.MethodGet, path, nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Get returns a single record
+func (c *EntityClient[T, I, F]) Get(ctx context.Context, id string) (*T, error) {
+	var record T
+	if err := c.transport.Do(ctx, http.MethodGet, "/api/data/"+c.entity+"/"+url.PathEscape(id), nil, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Create creates a record
+func (c *EntityClient[T, I, F]) Create(ctx context.Context, input I) (*T, error) {
+	var record T
+	if err := c.transport.Do(ctx, http.MethodPost, "/api/data/"+c.entity, input, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// BulkCreate creates several records in one transaction
+func (c *EntityClient[T, I, F]) BulkCreate(ctx context.Context, inputs []I) ([]T, error) {
+	var result struct {
+		Data []T ` + "`json:\"data\"`" + `
+	}
+	body := map[string]any{"records": inputs}
+	if err := c.transport.Do(ctx, http.MethodPost, "/api/data/"+c.entity+"/bulk-create", body, &result); err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
+
+// Update updates the fields set in input
+func (c *EntityClient[T, I, F]) Update(ctx context.Context, id string, input I) (*T, error) {
+	var record T
+	if err := c.transport.Do(ctx, http.MethodPut, "/api/data/"+c.entity+"/"+url.PathEscape(id), input, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Delete deletes a record
+func (c *EntityClient[T, I, F]) Delete(ctx context.Context, id string) error {
+	return c.transport.Do(ctx, http.MethodDelete, "/api/data/"+c.entity+"/"+url.PathEscape(id), nil, nil)
+}
+
+// BulkDelete deletes several records
+func (c *EntityClient[T, I, F]) BulkDelete(ctx context.Context, ids []string) error {
+	return c.transport.Do(ctx, http.MethodPost, "/api/data/"+c.entity+"/bulk-delete", map[string]any{"ids": ids}, nil)
+}
+`
+
+// goFieldName converts a field code to an exported Go identifier, keeping
+// common initialisms upper case ("customer_id" -> "CustomerID")
+func goFieldName(code string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(code, "_") {
+		if part == "" {
+			continue
+		}
+		switch part {
+		case "id", "url", "uuid", "api", "ip", "http":
+			b.WriteString(strings.ToUpper(part))
+		default:
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	name := b.String()
+	if name == "" || !unicode.IsLetter(rune(name[0])) {
+		name = "F" + name
+	}
+	return name
+}
+
+// goIdentifier turns an enum value into the suffix of a Go constant name
+func goIdentifier(value string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range value {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if upper {
+				b.WriteRune(unicode.ToUpper(r))
+			} else {
+				b.WriteRune(r)
+			}
+			upper = false
+		} else {
+			upper = true
+		}
+	}
+	return b.String()
+}
+
+func jsonString(s string) string {
+	encoded, _ := json.Marshal(s)
+	return string(encoded)
+}
@@ -0,0 +1,78 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aethra/genesis/internal/engine"
+	"github.com/aethra/genesis/internal/models"
+	"github.com/google/uuid"
+)
+
+// ejectTestSchema is a tenant schema with one module holding one entity
+func ejectTestSchema() *engine.TenantSchema {
+	moduleID := uuid.New()
+	uuidType := &models.FieldType{Code: "uuid"}
+	stringType := &models.FieldType{Code: "string"}
+
+	return &engine.TenantSchema{
+		Modules: []models.Module{{ID: moduleID, Code: "crm", Name: "CRM"}},
+		Entities: []models.Entity{{
+			ID:            uuid.New(),
+			ModuleID:      &moduleID,
+			Code:          "customer",
+			Name:          "Customer",
+			TableName:     "data_customer",
+			UseTimestamps: true,
+			Fields: []models.Field{
+				{Code: "id", Name: "ID", FieldType: uuidType, IsPrimary: true, IsAuto: true, IsSystem: true},
+				{Code: "name", Name: "Name", FieldType: stringType, IsRequired: true, DisplayOrder: 1},
+			},
+		}},
+	}
+}
+
+func TestEjectMigrationCreatesReferencedTables(t *testing.T) {
+	files, err := Eject(engine.NewSchemaEngine(nil), ejectTestSchema(), EjectOptions{ModuleCode: "crm"})
+	if err != nil {
+		t.Fatalf("Eject: %v", err)
+	}
+
+	var migration string
+	for _, f := range files {
+		if f.Path == "migrations/001_crm.sql" {
+			migration = f.Content
+		}
+	}
+	if migration == "" {
+		t.Fatal("no migration generated")
+	}
+	if err := checkMigrationReferences(migration); err != nil {
+		t.Errorf("migration does not apply to an empty database: %v", err)
+	}
+	for _, column := range engine.OwnershipColumns {
+		if !strings.Contains(migration, column+" UUID") {
+			t.Errorf("migration lacks ownership column %s", column)
+		}
+	}
+}
+
+func TestCheckMigrationReferences(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		ok   bool
+	}{
+		{"created table", `CREATE TABLE IF NOT EXISTS tenants (id UUID); CREATE TABLE t (tenant_id UUID REFERENCES tenants(id))`, true},
+		{"quoted names", `CREATE TABLE "tenants" (id UUID); CREATE TABLE "t" (tenant_id UUID REFERENCES "tenants" (id))`, true},
+		{"missing table", `CREATE TABLE t (owner_id UUID REFERENCES users(id) ON DELETE SET NULL)`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkMigrationReferences(tt.sql)
+			if (err == nil) != tt.ok {
+				t.Errorf("checkMigrationReferences() error = %v, want ok = %v", err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aethra/genesis/internal/auth"
+	"github.com/aethra/genesis/internal/engine"
+	"github.com/google/uuid"
+)
+
+type stateKey struct{}
+
+// requestState carries the caller and per-request caches through resolvers
+type requestState struct {
+	service    *Service
+	dataEngine *engine.DataEngine // audits the impersonator, if any
+	tenantID   uuid.UUID
+	userID     uuid.UUID
+	scopes     auth.APIKeyScopes
+
+	mu          sync.Mutex
+	permissions map[string]*auth.UserPermission
+	loaders     map[string]*loader
+}
+
+func stateFrom(ctx context.Context) *requestState {
+	state, _ := ctx.Value(stateKey{}).(*requestState)
+	return state
+}
+
+// permission resolves the caller's permissions on an entity once per request
+func (r *requestState) permission(entityCode string) (*auth.UserPermission, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if perm, ok := r.permissions[entityCode]; ok {
+		return perm, nil
+	}
+
+	perm := &auth.UserPermission{CanView: true, CanCreate: true, CanEdit: true, CanDelete: true}
+	if r.service.permissions != nil {
+		var err error
+		perm, err = r.service.permissions.GetUserPermission(r.tenantID, r.userID, entityCode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check permissions: %w", err)
+		}
+	}
+
+	perm = r.scopes.Restrict(entityCode, perm)
+
+	r.permissions[entityCode] = perm
+	return perm, nil
+}
+
+// require fails unless the caller may perform action on the entity
+func (r *requestState) require(entityCode string, action auth.Action) (*auth.UserPermission, error) {
+	perm, err := r.permission(entityCode)
+	if err != nil {
+		return nil, err
+	}
+
+	if !perm.Allows(action) {
+		return nil, fmt.Errorf("permission denied: cannot %s %s", action, entityCode)
+	}
+	return perm, nil
+}
+
+// loader returns the request's batch loader for records of entityCode keyed
+// by fieldCode
+func (r *requestState) loader(entityCode, fieldCode string) *loader {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := entityCode + "." + fieldCode
+	if l, ok := r.loaders[key]; ok {
+		return l
+	}
+
+	l := &loader{
+		field:   fieldCode,
+		results: make(map[string][]map[string]interface{}),
+		fetch: func(keys []interface{}) ([]map[string]interface{}, error) {
+			return r.dataEngine.FindByField(r.tenantID, entityCode, fieldCode, keys, &r.userID)
+		},
+	}
+	r.loaders[key] = l
+	return l
+}
+
+// loader batches relation lookups. Resolvers register keys and return thunks;
+// the executor resolves thunks breadth-first, so the first thunk of a level
+// fetches every key registered at that level in a single query.
+type loader struct {
+	field string
+	fetch func(keys []interface{}) ([]map[string]interface{}, error)
+
+	mu      sync.Mutex
+	pending []interface{}
+	results map[string][]map[string]interface{}
+	err     error
+}
+
+// load schedules key and returns a thunk yielding the records whose field
+// equals it
+func (l *loader) load(key string) func() ([]map[string]interface{}, error) {
+	l.mu.Lock()
+	if _, done := l.results[key]; !done {
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	return func() ([]map[string]interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if _, done := l.results[key]; !done && l.err == nil {
+			l.flush()
+		}
+		if l.err != nil {
+			return nil, l.err
+		}
+		return l.results[key], nil
+	}
+}
+
+// flush fetches all pending keys; callers hold l.mu
+func (l *loader) flush() {
+	keys := l.pending
+	l.pending = nil
+
+	records, err := l.fetch(keys)
+	if err != nil {
+		l.err = err
+		return
+	}
+
+	for _, key := range keys {
+		if _, ok := l.results[key.(string)]; !ok {
+			l.results[key.(string)] = nil
+		}
+	}
+	for _, record := range records {
+		key := keyString(record[l.field])
+		l.results[key] = append(l.results[key], record)
+	}
+}
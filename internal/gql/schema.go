@@ -0,0 +1,684 @@
+package gql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aethra/genesis/internal/auth"
+	"github.com/aethra/genesis/internal/engine"
+	"github.com/aethra/genesis/internal/models"
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// belongsTo is a many-to-one link exposed as a single related record
+type belongsTo struct {
+	name   string // GraphQL field name, e.g. "customer"
+	field  string // foreign key field code, e.g. "customer_id"
+	target *models.Entity
+}
+
+// hasMany is a one-to-many link exposed as a list of related records
+type hasMany struct {
+	name  string // GraphQL field name, e.g. "orders"
+	child *models.Entity
+	field string // foreign key field code on the child
+}
+
+// builder generates the GraphQL schema for one tenant
+type builder struct {
+	service *Service
+	schema  *engine.TenantSchema
+
+	entities   []*models.Entity
+	belongsTo  map[uuid.UUID][]belongsTo
+	hasMany    map[uuid.UUID][]hasMany
+	typeNames  map[uuid.UUID]string
+	objects    map[uuid.UUID]*graphql.Object
+	inputs     map[uuid.UUID]*graphql.InputObject
+	filters    map[uuid.UUID]*graphql.InputObject
+	pages      map[uuid.UUID]*graphql.Object
+	queryRoot  graphql.Fields
+	mutateRoot graphql.Fields
+}
+
+func newBuilder(service *Service, schema *engine.TenantSchema) *builder {
+	return &builder{
+		service:    service,
+		schema:     schema,
+		belongsTo:  make(map[uuid.UUID][]belongsTo),
+		hasMany:    make(map[uuid.UUID][]hasMany),
+		typeNames:  make(map[uuid.UUID]string),
+		objects:    make(map[uuid.UUID]*graphql.Object),
+		inputs:     make(map[uuid.UUID]*graphql.InputObject),
+		filters:    make(map[uuid.UUID]*graphql.InputObject),
+		pages:      make(map[uuid.UUID]*graphql.Object),
+		queryRoot:  graphql.Fields{},
+		mutateRoot: graphql.Fields{},
+	}
+}
+
+// reservedTypeNames cannot be used for entity types
+var reservedTypeNames = map[string]bool{
+	"Query": true, "Mutation": true, "Subscription": true, "JSON": true,
+	"String": true, "Int": true, "Float": true, "Boolean": true, "ID": true,
+}
+
+func (b *builder) build() (graphql.Schema, error) {
+	used := map[string]bool{}
+	for i := range b.schema.Entities {
+		entity := &b.schema.Entities[i]
+		if strings.HasPrefix(entity.Code, "__") {
+			continue
+		}
+		name := engine.SchemaName(entity.Code)
+		if name == "" || reservedTypeNames[name] || used[name] {
+			name += "Record"
+		}
+		used[name] = true
+		b.typeNames[entity.ID] = name
+		b.entities = append(b.entities, entity)
+	}
+
+	b.resolveRelations()
+
+	for _, entity := range b.entities {
+		b.objects[entity.ID] = b.objectType(entity)
+		b.inputs[entity.ID] = b.inputType(entity)
+		b.filters[entity.ID] = b.filterType(entity)
+		b.pages[entity.ID] = b.pageType(entity)
+	}
+
+	for _, entity := range b.entities {
+		b.addQueries(entity)
+		b.addMutations(entity)
+	}
+
+	if len(b.queryRoot) == 0 {
+		b.queryRoot["_empty"] = &graphql.Field{
+			Type:        graphql.Boolean,
+			Description: "Placeholder: the tenant has no entities yet",
+		}
+	}
+
+	config := graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: b.queryRoot}),
+	}
+	if len(b.mutateRoot) > 0 {
+		config.Mutation = graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: b.mutateRoot})
+	}
+
+	schema, err := graphql.NewSchema(config)
+	if err != nil {
+		return graphql.Schema{}, fmt.Errorf("failed to build GraphQL schema: %w", err)
+	}
+	return schema, nil
+}
+
+// resolveRelations derives belongs_to and has_many links from belongs_to
+// fields (targets come from the relations table or the field's
+// "target_entity" setting) and from explicit has_many relations
+func (b *builder) resolveRelations() {
+	byID := map[uuid.UUID]*models.Entity{}
+	byCode := map[string]*models.Entity{}
+	for _, entity := range b.entities {
+		byID[entity.ID] = entity
+		byCode[entity.Code] = entity
+	}
+
+	for _, entity := range b.entities {
+		for i := range entity.Fields {
+			field := &entity.Fields[i]
+			if field.FieldType == nil || field.FieldType.Code != "belongs_to" {
+				continue
+			}
+
+			var target *models.Entity
+			for _, rel := range b.schema.Relations {
+				if rel.SourceEntityID == entity.ID && rel.SourceFieldCode == field.Code && rel.RelationType == "belongs_to" {
+					target = byID[rel.TargetEntityID]
+				}
+			}
+			if target == nil {
+				if code, ok := field.Settings["target_entity"].(string); ok {
+					target = byCode[code]
+				}
+			}
+			if target == nil {
+				continue
+			}
+
+			name := strings.TrimSuffix(field.Code, "_id")
+			if name == field.Code || b.nameTaken(entity, name) {
+				name = field.Code + "_record"
+			}
+			b.belongsTo[entity.ID] = append(b.belongsTo[entity.ID], belongsTo{name: name, field: field.Code, target: target})
+
+			listName := entity.Code
+			if b.nameTaken(target, listName) {
+				listName = entity.Code + "_by_" + field.Code
+			}
+			b.hasMany[target.ID] = append(b.hasMany[target.ID], hasMany{name: listName, child: entity, field: field.Code})
+		}
+	}
+
+	for _, rel := range b.schema.Relations {
+		if rel.RelationType != "has_many" || rel.TargetFieldCode == "" || rel.TargetFieldCode == "id" {
+			continue
+		}
+		parent, child := byID[rel.SourceEntityID], byID[rel.TargetEntityID]
+		if parent == nil || child == nil || b.nameTaken(parent, rel.SourceFieldCode) {
+			continue
+		}
+		b.hasMany[parent.ID] = append(b.hasMany[parent.ID], hasMany{name: rel.SourceFieldCode, child: child, field: rel.TargetFieldCode})
+	}
+}
+
+// nameTaken reports whether name is already a field of the entity's type
+func (b *builder) nameTaken(entity *models.Entity, name string) bool {
+	switch name {
+	case "id", "tenant_id", "created_at", "updated_at", "deleted_at", "created_by", "updated_by", "owner_id":
+		return true
+	}
+	for i := range entity.Fields {
+		if entity.Fields[i].Code == name {
+			return true
+		}
+	}
+	for _, link := range b.belongsTo[entity.ID] {
+		if link.name == name {
+			return true
+		}
+	}
+	for _, link := range b.hasMany[entity.ID] {
+		if link.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *builder) objectType(entity *models.Entity) *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name:        b.typeNames[entity.ID],
+		Description: entity.Description,
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			fields := graphql.Fields{
+				"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: columnResolver(entity.Code, "id", graphql.ID)},
+				"tenant_id": &graphql.Field{Type: graphql.ID, Resolve: columnResolver(entity.Code, "tenant_id", graphql.ID)},
+			}
+			if entity.UseTimestamps {
+				fields["created_at"] = &graphql.Field{Type: graphql.String, Resolve: columnResolver(entity.Code, "created_at", graphql.String)}
+				fields["updated_at"] = &graphql.Field{Type: graphql.String, Resolve: columnResolver(entity.Code, "updated_at", graphql.String)}
+			}
+			for _, column := range engine.OwnershipColumns {
+				fields[column] = &graphql.Field{Type: graphql.ID, Resolve: columnResolver(entity.Code, column, graphql.ID)}
+			}
+
+			for i := range entity.Fields {
+				field := &entity.Fields[i]
+				if !engine.IsColumnField(field) {
+					continue
+				}
+				fieldType := scalarFor(field)
+				fields[field.Code] = &graphql.Field{
+					Type:        fieldType,
+					Description: field.Description,
+					Resolve:     columnResolver(entity.Code, field.Code, fieldType),
+				}
+			}
+
+			for _, link := range b.belongsTo[entity.ID] {
+				fields[link.name] = &graphql.Field{
+					Type:    b.objects[link.target.ID],
+					Resolve: b.belongsToResolver(entity, link),
+				}
+			}
+			for _, link := range b.hasMany[entity.ID] {
+				fields[link.name] = &graphql.Field{
+					Type:    graphql.NewList(graphql.NewNonNull(b.objects[link.child.ID])),
+					Resolve: b.hasManyResolver(link),
+				}
+			}
+
+			return fields
+		}),
+	})
+}
+
+func (b *builder) inputType(entity *models.Entity) *graphql.InputObject {
+	fields := graphql.InputObjectConfigFieldMap{}
+	for i := range entity.Fields {
+		field := &entity.Fields[i]
+		if !engine.IsColumnField(field) || field.IsSystem || field.IsAuto || field.IsPrimary {
+			continue
+		}
+		fields[field.Code] = &graphql.InputObjectFieldConfig{Type: scalarFor(field), Description: field.Description}
+	}
+	if len(fields) == 0 {
+		fields["_empty"] = &graphql.InputObjectFieldConfig{Type: graphql.Boolean}
+	}
+	return graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:   b.typeNames[entity.ID] + "Input",
+		Fields: fields,
+	})
+}
+
+func (b *builder) filterType(entity *models.Entity) *graphql.InputObject {
+	fields := graphql.InputObjectConfigFieldMap{
+		"id": &graphql.InputObjectFieldConfig{Type: graphql.ID},
+	}
+	for i := range entity.Fields {
+		field := &entity.Fields[i]
+		if !engine.IsColumnField(field) {
+			continue
+		}
+		fieldType := scalarFor(field)
+		if fieldType == jsonScalar {
+			continue
+		}
+		fields[field.Code] = &graphql.InputObjectFieldConfig{Type: fieldType}
+	}
+	return graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        b.typeNames[entity.ID] + "Filter",
+		Description: "Equality filters; records must match every field given",
+		Fields:      fields,
+	})
+}
+
+func (b *builder) pageType(entity *models.Entity) *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: b.typeNames[entity.ID] + "Page",
+		Fields: graphql.Fields{
+			"data":        &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(b.objects[entity.ID]))},
+			"total":       &graphql.Field{Type: graphql.Int},
+			"page":        &graphql.Field{Type: graphql.Int},
+			"page_size":   &graphql.Field{Type: graphql.Int},
+			"total_pages": &graphql.Field{Type: graphql.Int},
+		},
+	})
+}
+
+func (b *builder) addQueries(entity *models.Entity) {
+	code := entity.Code
+
+	b.queryRoot[code] = &graphql.Field{
+		Type:        b.objects[entity.ID],
+		Description: "Get a " + entity.Name + " by id",
+		Args: graphql.FieldConfigArgument{
+			"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			state := stateFrom(p.Context)
+			if _, err := state.require(code, auth.ActionView); err != nil {
+				return nil, err
+			}
+			return state.findRecord(code, p.Args["id"].(string))
+		},
+	}
+
+	b.queryRoot[code+"_list"] = &graphql.Field{
+		Type:        b.pages[entity.ID],
+		Description: "List " + pluralName(entity),
+		Args: graphql.FieldConfigArgument{
+			"page":      &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 1},
+			"page_size": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 25},
+			"sort":      &graphql.ArgumentConfig{Type: graphql.String},
+			"sort_dir":  &graphql.ArgumentConfig{Type: graphql.String},
+			"search":    &graphql.ArgumentConfig{Type: graphql.String},
+			"filter":    &graphql.ArgumentConfig{Type: b.filters[entity.ID]},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			state := stateFrom(p.Context)
+			if _, err := state.require(code, auth.ActionView); err != nil {
+				return nil, err
+			}
+
+			params := engine.QueryParams{
+				Page:     p.Args["page"].(int),
+				PageSize: p.Args["page_size"].(int),
+				Filters:  map[string]interface{}{},
+				UserID:   &state.userID,
+			}
+			params.Sort, _ = p.Args["sort"].(string)
+			params.SortDir, _ = p.Args["sort_dir"].(string)
+			params.Search, _ = p.Args["search"].(string)
+			if filter, ok := p.Args["filter"].(map[string]interface{}); ok {
+				for field, value := range filter {
+					params.Filters[field] = value
+				}
+			}
+
+			result, err := state.dataEngine.List(state.tenantID, code, params)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{
+				"data":        result.Data,
+				"total":       result.Total,
+				"page":        result.Page,
+				"page_size":   result.PageSize,
+				"total_pages": result.TotalPages,
+			}, nil
+		},
+	}
+}
+
+func (b *builder) addMutations(entity *models.Entity) {
+	code := entity.Code
+
+	b.mutateRoot["create_"+code] = &graphql.Field{
+		Type:        b.objects[entity.ID],
+		Description: "Create a " + entity.Name,
+		Args: graphql.FieldConfigArgument{
+			"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(b.inputs[entity.ID])},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			state := stateFrom(p.Context)
+			perm, err := state.require(code, auth.ActionCreate)
+			if err != nil {
+				return nil, err
+			}
+			data, err := writableInput(perm, code, p.Args["input"])
+			if err != nil {
+				return nil, err
+			}
+			return state.dataEngine.Create(state.tenantID, code, data, &state.userID)
+		},
+	}
+
+	b.mutateRoot["update_"+code] = &graphql.Field{
+		Type:        b.objects[entity.ID],
+		Description: "Update a " + entity.Name,
+		Args: graphql.FieldConfigArgument{
+			"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+			"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(b.inputs[entity.ID])},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			state := stateFrom(p.Context)
+			perm, err := state.require(code, auth.ActionEdit)
+			if err != nil {
+				return nil, err
+			}
+			recordID, err := state.visibleRecordID(code, p.Args["id"].(string))
+			if err != nil {
+				return nil, err
+			}
+			data, err := writableInput(perm, code, p.Args["input"])
+			if err != nil {
+				return nil, err
+			}
+			return state.dataEngine.Update(state.tenantID, code, recordID, data, &state.userID)
+		},
+	}
+
+	b.mutateRoot["delete_"+code] = &graphql.Field{
+		Type:        graphql.Boolean,
+		Description: "Delete a " + entity.Name,
+		Args: graphql.FieldConfigArgument{
+			"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			state := stateFrom(p.Context)
+			if _, err := state.require(code, auth.ActionDelete); err != nil {
+				return nil, err
+			}
+			recordID, err := state.visibleRecordID(code, p.Args["id"].(string))
+			if err != nil {
+				return nil, err
+			}
+			if err := state.dataEngine.Delete(state.tenantID, code, recordID, &state.userID); err != nil {
+				return nil, err
+			}
+			return true, nil
+		},
+	}
+}
+
+func (b *builder) belongsToResolver(entity *models.Entity, link belongsTo) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		state := stateFrom(p.Context)
+		record, _ := p.Source.(map[string]interface{})
+
+		perm, err := state.permission(entity.Code)
+		if err != nil {
+			return nil, err
+		}
+		targetPerm, err := state.permission(link.target.Code)
+		if err != nil {
+			return nil, err
+		}
+		if !perm.CanViewField(link.field) || !targetPerm.CanView {
+			return nil, nil
+		}
+
+		key := keyString(record[link.field])
+		if key == "" {
+			return nil, nil
+		}
+
+		thunk := state.loader(link.target.Code, "id").load(key)
+		return func() (interface{}, error) {
+			records, err := thunk()
+			if err != nil || len(records) == 0 {
+				return nil, err
+			}
+			return records[0], nil
+		}, nil
+	}
+}
+
+func (b *builder) hasManyResolver(link hasMany) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		state := stateFrom(p.Context)
+		record, _ := p.Source.(map[string]interface{})
+
+		childPerm, err := state.permission(link.child.Code)
+		if err != nil {
+			return nil, err
+		}
+		if !childPerm.CanView || !childPerm.CanViewField(link.field) {
+			return nil, nil
+		}
+
+		key := keyString(record["id"])
+		if key == "" {
+			return nil, nil
+		}
+
+		thunk := state.loader(link.child.Code, link.field).load(key)
+		return func() (interface{}, error) {
+			records, err := thunk()
+			if err != nil {
+				return nil, err
+			}
+			if records == nil {
+				return []map[string]interface{}{}, nil
+			}
+			return records, nil
+		}, nil
+	}
+}
+
+// findRecord returns a record the caller can see, or nil
+func (r *requestState) findRecord(entityCode, id string) (interface{}, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("invalid id")
+	}
+	records, err := r.dataEngine.FindByField(r.tenantID, entityCode, "id", []interface{}{id}, &r.userID)
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return records[0], nil
+}
+
+// visibleRecordID parses id and checks the record exists within the caller's
+// row filter, so mutations can't reach rows queries would hide
+func (r *requestState) visibleRecordID(entityCode, id string) (uuid.UUID, error) {
+	recordID, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid id")
+	}
+	record, err := r.findRecord(entityCode, id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if record == nil {
+		return uuid.Nil, fmt.Errorf("record not found")
+	}
+	return recordID, nil
+}
+
+// writableInput converts a mutation input to DataEngine data, rejecting
+// fields the caller may not edit
+func writableInput(perm *auth.UserPermission, entityCode string, input interface{}) (map[string]interface{}, error) {
+	values, _ := input.(map[string]interface{})
+	data := make(map[string]interface{}, len(values))
+	for field, value := range values {
+		if field == "_empty" {
+			continue
+		}
+		if !perm.CanEditField(field) {
+			return nil, fmt.Errorf("permission denied: cannot edit field '%s' of %s", field, entityCode)
+		}
+		if n, ok := value.(int); ok {
+			value = float64(n)
+		}
+		data[field] = value
+	}
+	return data, nil
+}
+
+// columnResolver reads a column from a record map, hiding it when the
+// caller's field permissions don't allow viewing it
+func columnResolver(entityCode, fieldCode string, fieldType graphql.Output) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		record, _ := p.Source.(map[string]interface{})
+		if fieldCode != "id" {
+			perm, err := stateFrom(p.Context).permission(entityCode)
+			if err != nil {
+				return nil, err
+			}
+			if !perm.CanViewField(fieldCode) {
+				return nil, nil
+			}
+		}
+		return normalize(record[fieldCode], fieldType), nil
+	}
+}
+
+// normalize converts driver values to what the GraphQL scalars serialize
+func normalize(value interface{}, fieldType graphql.Output) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case [16]byte:
+		return uuid.UUID(v).String()
+	case []byte:
+		value = string(v)
+	}
+
+	str, isString := value.(string)
+	switch fieldType {
+	case graphql.Int, graphql.Float:
+		if isString {
+			if n, err := strconv.ParseFloat(str, 64); err == nil {
+				return n
+			}
+		}
+	case jsonScalar:
+		if isString {
+			var decoded interface{}
+			if err := json.Unmarshal([]byte(str), &decoded); err == nil {
+				return decoded
+			}
+		}
+	}
+	return value
+}
+
+// keyString normalizes an id or foreign key value for loader lookups
+func keyString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case [16]byte:
+		return uuid.UUID(v).String()
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// scalarFor maps a field type to a GraphQL scalar
+func scalarFor(field *models.Field) graphql.Output {
+	code := ""
+	if field.FieldType != nil {
+		code = field.FieldType.Code
+	}
+	switch code {
+	case "uuid", "belongs_to":
+		return graphql.ID
+	case "integer":
+		return graphql.Int
+	case "decimal":
+		return graphql.Float
+	case "boolean":
+		return graphql.Boolean
+	case "json", "multi_enum", "tags":
+		return jsonScalar
+	default:
+		return graphql.String
+	}
+}
+
+func pluralName(entity *models.Entity) string {
+	if entity.NamePlural != "" {
+		return entity.NamePlural
+	}
+	return entity.Name
+}
+
+// jsonScalar carries arbitrary JSON values (json, multi-select and tag fields)
+var jsonScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:         "JSON",
+	Description:  "Any JSON value",
+	Serialize:    func(value interface{}) interface{} { return value },
+	ParseValue:   func(value interface{}) interface{} { return value },
+	ParseLiteral: parseJSONLiteral,
+})
+
+func parseJSONLiteral(value ast.Value) interface{} {
+	switch v := value.(type) {
+	case *ast.StringValue:
+		return v.Value
+	case *ast.BooleanValue:
+		return v.Value
+	case *ast.IntValue:
+		n, _ := strconv.ParseFloat(v.Value, 64)
+		return n
+	case *ast.FloatValue:
+		n, _ := strconv.ParseFloat(v.Value, 64)
+		return n
+	case *ast.ListValue:
+		list := make([]interface{}, len(v.Values))
+		for i, item := range v.Values {
+			list[i] = parseJSONLiteral(item)
+		}
+		return list
+	case *ast.ObjectValue:
+		object := make(map[string]interface{}, len(v.Fields))
+		for _, field := range v.Fields {
+			object[field.Name.Value] = parseJSONLiteral(field.Value)
+		}
+		return object
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Package mail - Development outboxes
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileMailer writes each message as an .eml file, for development and tests
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+// NewFileMailer creates a file mailer, creating the outbox directory
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create mail outbox: %w", err)
+	}
+	return &FileMailer{Dir: dir, From: from}, nil
+}
+
+// Send writes the message to the outbox
+func (m *FileMailer) Send(ctx context.Context, msg *Message) error {
+	if msg.From == "" {
+		msg.From = m.From
+	}
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405.000000000"), slug(msg.Subject))
+	if err := os.WriteFile(filepath.Join(m.Dir, name), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	return nil
+}
+
+// LogMailer writes messages to the server log instead of sending them
+type LogMailer struct {
+	From string
+}
+
+// Send logs the message
+func (m *LogMailer) Send(ctx context.Context, msg *Message) error {
+	if msg.From == "" {
+		msg.From = m.From
+	}
+	log.Printf("mail: from=%q to=%q subject=%q\n%s", msg.From, strings.Join(msg.To, ", "), msg.Subject, msg.Text)
+	return nil
+}
+
+// slug keeps letters and digits of a subject for readable file names
+func slug(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if b.Len() >= 40 {
+			break
+		}
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "-"):
+			b.WriteByte('-')
+		}
+	}
+	return strings.Trim(b.String(), "-")
+}
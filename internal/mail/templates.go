@@ -0,0 +1,198 @@
+// Package mail - Tenant-branded email templates
+package mail
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"net/mail"
+	texttemplate "text/template"
+
+	"github.com/aethra/genesis/internal/models"
+)
+
+// Template names
+const (
+	TemplateVerifyEmail   = "verify_email"
+	TemplatePasswordReset = "password_reset"
+)
+
+// Branding customizes emails per tenant. It is read from the "branding"
+// object of Tenant.Settings; unset values fall back to the tenant name and the
+// server defaults.
+type Branding struct {
+	Name         string `json:"name"`
+	LogoURL      string `json:"logo_url"`
+	PrimaryColor string `json:"primary_color"`
+	SupportEmail string `json:"support_email"`
+	FromAddress  string `json:"from_address"` // e.g. "Acme <no-reply@acme.com>"
+	AppURL       string `json:"app_url"`      // base URL for links in emails
+}
+
+// Template is an email template. Subject and Text use text/template, HTML
+// uses html/template; all receive TemplateData.
+type Template struct {
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html"`
+}
+
+// TemplateData is passed to every template
+type TemplateData struct {
+	Brand     Branding
+	Email     string
+	FirstName string
+	Link      string
+	ExpiresIn string
+}
+
+// TenantBranding returns the tenant's branding with defaults applied
+func TenantBranding(tenant *models.Tenant, defaultAppURL string) Branding {
+	var branding Branding
+	decodeSetting(tenant.Settings, "branding", &branding)
+
+	if branding.Name == "" {
+		branding.Name = tenant.Name
+	}
+	if branding.PrimaryColor == "" {
+		branding.PrimaryColor = "#2563eb"
+	}
+	if branding.AppURL == "" {
+		branding.AppURL = defaultAppURL
+	}
+	if branding.FromAddress != "" {
+		if _, err := mail.ParseAddress(branding.FromAddress); err != nil {
+			branding.FromAddress = ""
+		}
+	}
+	return branding
+}
+
+// TenantTemplate returns the named template, with any parts the tenant
+// overrides in the "email_templates" object of Tenant.Settings:
+//
+//	{"email_templates": {"password_reset": {"subject": "...", "html": "..."}}}
+func TenantTemplate(tenant *models.Tenant, name string) (Template, error) {
+	tmpl, ok := defaultTemplates[name]
+	if !ok {
+		return Template{}, fmt.Errorf("unknown email template '%s'", name)
+	}
+
+	var overrides map[string]Template
+	decodeSetting(tenant.Settings, "email_templates", &overrides)
+	if custom, ok := overrides[name]; ok {
+		if custom.Subject != "" {
+			tmpl.Subject = custom.Subject
+		}
+		if custom.Text != "" {
+			tmpl.Text = custom.Text
+		}
+		if custom.HTML != "" {
+			tmpl.HTML = custom.HTML
+		}
+	}
+	return tmpl, nil
+}
+
+// Render executes the template into a message for one recipient. The sender
+// is the tenant's from address, or the mailer default when unset.
+func (t Template) Render(data TemplateData) (*Message, error) {
+	subject, err := renderText("subject", t.Subject, data)
+	if err != nil {
+		return nil, err
+	}
+	text, err := renderText("text", t.Text, data)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{From: data.Brand.FromAddress, To: []string{data.Email}, Subject: subject, Text: text}
+	if t.HTML != "" {
+		tmpl, err := htmltemplate.New("html").Parse(t.HTML)
+		if err != nil {
+			return nil, fmt.Errorf("invalid html template: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("failed to render html template: %w", err)
+		}
+		msg.HTML = buf.String()
+	}
+	return msg, nil
+}
+
+func renderText(name, source string, data TemplateData) (string, error) {
+	tmpl, err := texttemplate.New(name).Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// decodeSetting decodes one key of a settings object into target. Missing
+// keys are ignored, as are values of the wrong shape.
+func decodeSetting(settings models.JSONB, key string, target interface{}) {
+	raw, ok := settings[key]
+	if !ok {
+		return
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return
+	}
+	json.Unmarshal(data, target)
+}
+
+// htmlLayout wraps the body of every default HTML template
+const htmlLayout = `<!DOCTYPE html>
+<html><body style="margin:0;padding:24px;background:#f4f4f5;font-family:-apple-system,Segoe UI,Helvetica,Arial,sans-serif;color:#18181b">
+<table role="presentation" width="100%%" cellpadding="0" cellspacing="0"><tr><td align="center">
+<table role="presentation" width="560" cellpadding="0" cellspacing="0" style="background:#ffffff;border-radius:8px;padding:32px">
+<tr><td>
+{{if .Brand.LogoURL}}<img src="{{.Brand.LogoURL}}" alt="{{.Brand.Name}}" style="max-height:40px;margin-bottom:24px">{{else}}<h2 style="margin:0 0 24px">{{.Brand.Name}}</h2>{{end}}
+%s
+{{if .Brand.SupportEmail}}<p style="margin-top:32px;font-size:13px;color:#71717a">Questions? Contact <a href="mailto:{{.Brand.SupportEmail}}">{{.Brand.SupportEmail}}</a>.</p>{{end}}
+</td></tr></table>
+</td></tr></table>
+</body></html>`
+
+const htmlButton = `<p style="margin:24px 0"><a href="{{.Link}}" style="display:inline-block;padding:12px 20px;border-radius:6px;background:{{.Brand.PrimaryColor}};color:#ffffff;text-decoration:none">%s</a></p>
+<p style="font-size:13px;color:#71717a">Or paste this link into your browser:<br>{{.Link}}</p>`
+
+var defaultTemplates = map[string]Template{
+	TemplateVerifyEmail: {
+		Subject: `Verify your email for {{.Brand.Name}}`,
+		Text: `Hi{{if .FirstName}} {{.FirstName}}{{end}},
+
+Please confirm your email address for your {{.Brand.Name}} account by opening this link:
+
+{{.Link}}
+
+The link expires in {{.ExpiresIn}}. If you did not create an account, you can ignore this email.
+`,
+		HTML: fmt.Sprintf(htmlLayout, `<p>Hi{{if .FirstName}} {{.FirstName}}{{end}},</p>
+<p>Please confirm your email address for your {{.Brand.Name}} account.</p>
+`+fmt.Sprintf(htmlButton, "Verify email")+`
+<p style="font-size:13px;color:#71717a">The link expires in {{.ExpiresIn}}. If you did not create an account, you can ignore this email.</p>`),
+	},
+	TemplatePasswordReset: {
+		Subject: `Reset your {{.Brand.Name}} password`,
+		Text: `Hi{{if .FirstName}} {{.FirstName}}{{end}},
+
+Someone asked to reset the password for your {{.Brand.Name}} account. To choose a new password, open this link:
+
+{{.Link}}
+
+The link expires in {{.ExpiresIn}} and can be used once. If you did not ask for this, you can ignore this email.
+`,
+		HTML: fmt.Sprintf(htmlLayout, `<p>Hi{{if .FirstName}} {{.FirstName}}{{end}},</p>
+<p>Someone asked to reset the password for your {{.Brand.Name}} account.</p>
+`+fmt.Sprintf(htmlButton, "Choose a new password")+`
+<p style="font-size:13px;color:#71717a">The link expires in {{.ExpiresIn}} and can be used once. If you did not ask for this, you can ignore this email.</p>`),
+	},
+}
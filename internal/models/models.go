@@ -31,18 +31,21 @@ type Tenant struct {
 
 // User represents a system user
 type User struct {
-	ID           uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
-	TenantID     uuid.UUID  `json:"tenant_id" gorm:"type:uuid;index"`
-	Email        string     `json:"email" gorm:"not null;size:255"`
-	PasswordHash string     `json:"-" gorm:"size:255"`
-	FirstName    string     `json:"first_name" gorm:"size:100"`
-	LastName     string     `json:"last_name" gorm:"size:100"`
-	AvatarURL    string     `json:"avatar_url"`
-	Settings     JSONB      `json:"settings" gorm:"type:jsonb;default:'{}'"`
-	IsActive     bool       `json:"is_active" gorm:"default:true"`
-	LastLoginAt  *time.Time `json:"last_login_at"`
-	CreatedAt    time.Time  `json:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at"`
+	ID                uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	TenantID          uuid.UUID  `json:"tenant_id" gorm:"type:uuid;index"`
+	Email             string     `json:"email" gorm:"not null;size:255"`
+	PasswordHash      string     `json:"-" gorm:"size:255"`
+	FirstName         string     `json:"first_name" gorm:"size:100"`
+	LastName          string     `json:"last_name" gorm:"size:100"`
+	AvatarURL         string     `json:"avatar_url"`
+	Settings          JSONB      `json:"settings" gorm:"type:jsonb;default:'{}'"`
+	IsActive          bool       `json:"is_active" gorm:"default:true"`
+	IsServiceAccount  bool       `json:"is_service_account" gorm:"default:false"`
+	EmailVerifiedAt   *time.Time `json:"email_verified_at" gorm:"default:CURRENT_TIMESTAMP"`
+	LastLoginAt       *time.Time `json:"last_login_at"`
+	PasswordChangedAt *time.Time `json:"password_changed_at" gorm:"default:CURRENT_TIMESTAMP"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
 
 	// Relations
 	Tenant *Tenant `json:"tenant,omitempty" gorm:"foreignKey:TenantID"`
@@ -258,42 +261,51 @@ type ViewField struct {
 
 // Role represents a user role
 type Role struct {
-	ID          uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
-	TenantID    uuid.UUID `json:"tenant_id" gorm:"type:uuid;index"`
-	Code        string    `json:"code" gorm:"not null;size:50"`
-	Name        string    `json:"name" gorm:"not null;size:100"`
-	Description string    `json:"description"`
-	IsSystem    bool      `json:"is_system" gorm:"default:false"`
-	IsActive    bool      `json:"is_active" gorm:"default:true"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	TenantID    uuid.UUID  `json:"tenant_id" gorm:"type:uuid;index"`
+	ParentID    *uuid.UUID `json:"parent_id" gorm:"type:uuid;index"` // Inherits the parent's permissions
+	Code        string     `json:"code" gorm:"not null;size:50"`
+	Name        string     `json:"name" gorm:"not null;size:100"`
+	Description string     `json:"description"`
+	IsSystem    bool       `json:"is_system" gorm:"default:false"`
+	IsActive    bool       `json:"is_active" gorm:"default:true"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
 
 	// Relations
 	Tenant      *Tenant      `json:"tenant,omitempty" gorm:"foreignKey:TenantID"`
+	Parent      *Role        `json:"parent,omitempty" gorm:"foreignKey:ParentID"`
 	Permissions []Permission `json:"permissions,omitempty" gorm:"foreignKey:RoleID"`
 	Users       []User       `json:"users,omitempty" gorm:"many2many:user_roles;"`
 }
 
-// Permission represents permissions per entity, per role
+// Permission represents permissions per entity or module, per role. Entries
+// either allow or deny their actions.
 type Permission struct {
-	ID               uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
-	TenantID         uuid.UUID `json:"tenant_id" gorm:"type:uuid;index"`
-	RoleID           uuid.UUID `json:"role_id" gorm:"type:uuid;index"`
-	EntityID         uuid.UUID `json:"entity_id" gorm:"type:uuid;index"`
-	CanView          bool      `json:"can_view" gorm:"default:false"`
-	CanCreate        bool      `json:"can_create" gorm:"default:false"`
-	CanEdit          bool      `json:"can_edit" gorm:"default:false"`
-	CanDelete        bool      `json:"can_delete" gorm:"default:false"`
-	CanExport        bool      `json:"can_export" gorm:"default:false"`
-	CanImport        bool      `json:"can_import" gorm:"default:false"`
-	FieldPermissions JSONB     `json:"field_permissions" gorm:"type:jsonb;default:'{}'"`
-	RowFilter        JSONB     `json:"row_filter" gorm:"type:jsonb"`
-	CreatedAt        time.Time `json:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at"`
+	ID               uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	TenantID         uuid.UUID  `json:"tenant_id" gorm:"type:uuid;index"`
+	RoleID           uuid.UUID  `json:"role_id" gorm:"type:uuid;index"`
+	EntityID         *uuid.UUID `json:"entity_id" gorm:"type:uuid;index"`
+	ModuleID         *uuid.UUID `json:"module_id" gorm:"type:uuid;index"`
+	Effect           string     `json:"effect" gorm:"size:10;default:allow"` // allow, deny
+	CanView          bool       `json:"can_view" gorm:"default:false"`
+	CanCreate        bool       `json:"can_create" gorm:"default:false"`
+	CanEdit          bool       `json:"can_edit" gorm:"default:false"`
+	CanDelete        bool       `json:"can_delete" gorm:"default:false"`
+	CanExport        bool       `json:"can_export" gorm:"default:false"`
+	CanImport        bool       `json:"can_import" gorm:"default:false"`
+	ViewScope        string     `json:"view_scope" gorm:"size:10;default:all"`   // all, own, team
+	EditScope        string     `json:"edit_scope" gorm:"size:10;default:all"`   // all, own, team
+	DeleteScope      string     `json:"delete_scope" gorm:"size:10;default:all"` // all, own, team
+	FieldPermissions JSONB      `json:"field_permissions" gorm:"type:jsonb;default:'{}'"`
+	RowFilter        JSONB      `json:"row_filter" gorm:"type:jsonb"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
 
 	// Relations
 	Role   *Role   `json:"role,omitempty" gorm:"foreignKey:RoleID"`
 	Entity *Entity `json:"entity,omitempty" gorm:"foreignKey:EntityID"`
+	Module *Module `json:"module,omitempty" gorm:"foreignKey:ModuleID"`
 }
 
 // =============================================================================
@@ -412,21 +424,292 @@ type MenuItem struct {
 
 // AuditLog represents an audit trail entry
 type AuditLog struct {
+	ID             uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	TenantID       uuid.UUID  `json:"tenant_id" gorm:"type:uuid;index"`
+	UserID         *uuid.UUID `json:"user_id" gorm:"type:uuid"`
+	ImpersonatorID *uuid.UUID `json:"impersonator_id,omitempty" gorm:"type:uuid"` // admin acting as UserID
+	EntityID       *uuid.UUID `json:"entity_id" gorm:"type:uuid"`
+	EntityCode     string     `json:"entity_code" gorm:"size:50;index"`
+	RecordID       *uuid.UUID `json:"record_id" gorm:"type:uuid"`
+	Action         string     `json:"action" gorm:"not null;size:30"`
+	OldValues      JSONB      `json:"old_values" gorm:"type:jsonb"`
+	NewValues      JSONB      `json:"new_values" gorm:"type:jsonb"`
+	ChangedFields  []string   `json:"changed_fields" gorm:"type:text[]"`
+	IPAddress      string     `json:"ip_address" gorm:"size:45;default:null"`
+	UserAgent      string     `json:"user_agent"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"index"`
+
+	// Relations
+	User   *User   `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Entity *Entity `json:"entity,omitempty" gorm:"foreignKey:EntityID"`
+}
+
+// TableName returns the table name for AuditLog
+func (AuditLog) TableName() string {
+	return "audit_log"
+}
+
+// =============================================================================
+// ENTITY LIFECYCLE
+// =============================================================================
+
+// EntityArchive records a deleted entity whose data table was kept under a new
+// name so it can be restored later
+type EntityArchive struct {
 	ID            uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
 	TenantID      uuid.UUID  `json:"tenant_id" gorm:"type:uuid;index"`
-	UserID        *uuid.UUID `json:"user_id" gorm:"type:uuid"`
-	EntityID      *uuid.UUID `json:"entity_id" gorm:"type:uuid"`
-	EntityCode    string     `json:"entity_code" gorm:"size:50;index"`
-	RecordID      *uuid.UUID `json:"record_id" gorm:"type:uuid"`
-	Action        string     `json:"action" gorm:"not null;size:30"`
-	OldValues     JSONB      `json:"old_values" gorm:"type:jsonb"`
-	NewValues     JSONB      `json:"new_values" gorm:"type:jsonb"`
-	ChangedFields []string   `json:"changed_fields" gorm:"type:text[]"`
-	IPAddress     string     `json:"ip_address" gorm:"size:45"`
+	EntityCode    string     `json:"entity_code" gorm:"not null;size:50"`
+	EntityName    string     `json:"entity_name" gorm:"size:100"`
+	OriginalTable string     `json:"original_table" gorm:"not null;size:100"`
+	ArchivedTable string     `json:"archived_table" gorm:"not null;size:100"`
+	Snapshot      JSONB      `json:"snapshot" gorm:"type:jsonb;not null"`
+	IndexNames    JSONB      `json:"index_names" gorm:"type:jsonb;default:'{}'"`
+	ArchivedBy    *uuid.UUID `json:"archived_by" gorm:"type:uuid"`
+	ArchivedAt    time.Time  `json:"archived_at"`
+	RestoredAt    *time.Time `json:"restored_at"`
+}
+
+// =============================================================================
+// AUTHENTICATION
+// =============================================================================
+
+// RefreshToken is the server-side record of an issued refresh token
+type RefreshToken struct {
+	ID              uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	TenantID        uuid.UUID  `json:"tenant_id" gorm:"type:uuid;index"`
+	UserID          uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	FamilyID        uuid.UUID  `json:"family_id" gorm:"type:uuid;index"`
+	ParentID        *uuid.UUID `json:"parent_id" gorm:"type:uuid"`
+	AccessJTI       uuid.UUID  `json:"-" gorm:"column:access_jti;type:uuid"`
+	AccessExpiresAt time.Time  `json:"-"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	UsedAt          *time.Time `json:"used_at"`
+	RevokedAt       *time.Time `json:"revoked_at"`
+	RevokedReason   string     `json:"revoked_reason,omitempty" gorm:"size:30"`
+	IPAddress       string     `json:"ip_address" gorm:"size:45;default:null"`
+	UserAgent       string     `json:"user_agent"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// RevokedToken is an entry in the access token revocation list
+type RevokedToken struct {
+	JTI       uuid.UUID  `json:"jti" gorm:"column:jti;type:uuid;primary_key"`
+	UserID    *uuid.UUID `json:"user_id" gorm:"type:uuid"`
+	Reason    string     `json:"reason" gorm:"size:30"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt time.Time  `json:"revoked_at"`
+}
+
+// APIKey is a personal access token or service-account key. The secret is
+// only returned once, at creation; KeyHash is its SHA-256.
+type APIKey struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	TenantID   uuid.UUID  `json:"tenant_id" gorm:"type:uuid;index"`
+	UserID     uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	Name       string     `json:"name" gorm:"not null;size:100"`
+	Prefix     string     `json:"prefix" gorm:"not null;size:20;uniqueIndex"`
+	KeyHash    string     `json:"-" gorm:"not null;size:64"`
+	Scopes     JSONB      `json:"scopes" gorm:"type:jsonb"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip" gorm:"size:45;default:null"`
+	CreatedBy  *uuid.UUID `json:"created_by" gorm:"type:uuid"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+
+	// Relations
+	User *User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// UserTwoFactor holds a user's TOTP enrolment. Secret is encrypted at rest.
+type UserTwoFactor struct {
+	UserID       uuid.UUID  `json:"user_id" gorm:"type:uuid;primary_key"`
+	Secret       string     `json:"-" gorm:"not null"`
+	EnabledAt    *time.Time `json:"enabled_at"`
+	LastUsedStep int64      `json:"-" gorm:"default:0"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// TableName returns the table name for UserTwoFactor
+func (UserTwoFactor) TableName() string {
+	return "user_two_factor"
+}
+
+// RecoveryCode is a one-time 2FA recovery code, stored hashed
+type RecoveryCode struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	UserID    uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	CodeHash  string     `json:"-" gorm:"not null;size:64"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TableName returns the table name for RecoveryCode
+func (RecoveryCode) TableName() string {
+	return "user_recovery_codes"
+}
+
+// UserToken is a single-use token sent by email, such as a password reset
+// link. Only its SHA-256 is stored.
+type UserToken struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	UserID    uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	Purpose   string     `json:"purpose" gorm:"not null;size:30"`
+	TokenHash string     `json:"-" gorm:"not null;size:64;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	IPAddress string     `json:"ip_address" gorm:"size:45;default:null"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// OIDCProvider is a tenant's OpenID Connect identity provider. ClientSecret
+// is encrypted at rest.
+type OIDCProvider struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	TenantID     uuid.UUID `json:"tenant_id" gorm:"type:uuid;uniqueIndex"`
+	Issuer       string    `json:"issuer" gorm:"not null;size:500"`
+	ClientID     string    `json:"client_id" gorm:"not null;size:255"`
+	ClientSecret string    `json:"-"`
+	Scopes       string    `json:"scopes" gorm:"not null;size:255"`
+	RedirectURL  string    `json:"redirect_url" gorm:"size:500"`
+	RoleClaim    string    `json:"role_claim" gorm:"size:100"`
+	RoleMapping  JSONB     `json:"role_mapping" gorm:"type:jsonb"`
+	DefaultRole  string    `json:"default_role" gorm:"size:50"`
+	AllowSignup  bool      `json:"allow_signup"`
+	IsEnabled    bool      `json:"is_enabled"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// TableName returns the table name for OIDCProvider
+func (OIDCProvider) TableName() string {
+	return "oidc_providers"
+}
+
+// OIDCLoginState is a pending OpenID Connect authorization request
+type OIDCLoginState struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	TenantID     uuid.UUID `json:"tenant_id" gorm:"type:uuid"`
+	ProviderID   uuid.UUID `json:"provider_id" gorm:"type:uuid"`
+	StateHash    string    `json:"-" gorm:"not null;size:64;uniqueIndex"`
+	CodeVerifier string    `json:"-" gorm:"not null;size:128"`
+	Nonce        string    `json:"-" gorm:"not null;size:64"`
+	RedirectURI  string    `json:"redirect_uri" gorm:"not null;size:500"`
+	ReturnTo     string    `json:"return_to" gorm:"size:500"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TableName returns the table name for OIDCLoginState
+func (OIDCLoginState) TableName() string {
+	return "oidc_login_states"
+}
+
+// UserIdentity links a user to their subject at an identity provider
+type UserIdentity struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	UserID      uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	ProviderID  uuid.UUID  `json:"provider_id" gorm:"type:uuid"`
+	Subject     string     `json:"subject" gorm:"not null;size:255"`
+	Email       string     `json:"email" gorm:"size:255"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Session is one login of a user, shared by every rotation of its refresh
+// token. ID is the token family ID carried in access tokens.
+type Session struct {
+	ID            uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	TenantID      uuid.UUID  `json:"tenant_id" gorm:"type:uuid"`
+	UserID        uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	Device        string     `json:"device" gorm:"size:100"`
+	IPAddress     string     `json:"ip_address" gorm:"size:45;default:null"`
 	UserAgent     string     `json:"user_agent"`
-	CreatedAt     time.Time  `json:"created_at" gorm:"index"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastSeenAt    time.Time  `json:"last_seen_at"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	RevokedAt     *time.Time `json:"revoked_at"`
+	RevokedReason string     `json:"revoked_reason,omitempty" gorm:"size:30"`
+
+	// Impersonation sessions are started by an admin acting as UserID
+	ImpersonatorID      *uuid.UUID `json:"impersonator_id,omitempty" gorm:"type:uuid"`
+	ImpersonationReason string     `json:"impersonation_reason,omitempty"`
 
 	// Relations
+	User         *User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Impersonator *User `json:"impersonator,omitempty" gorm:"foreignKey:ImpersonatorID"`
+}
+
+// LoginThrottle counts failed attempts for one key, such as an account or an
+// IP address, within a tenant
+type LoginThrottle struct {
+	ID             uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	TenantID       uuid.UUID  `json:"tenant_id" gorm:"type:uuid"`
+	Scope          string     `json:"scope" gorm:"not null;size:20"`
+	Key            string     `json:"key" gorm:"not null;size:255"`
+	Failures       int        `json:"failures"`
+	FirstFailureAt *time.Time `json:"first_failure_at"`
+	LastFailureAt  *time.Time `json:"last_failure_at"`
+	LockedUntil    *time.Time `json:"locked_until"`
+	Lockouts       int        `json:"lockouts"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// PasswordHistory keeps the hash of a password a user has set, so the
+// password policy can reject reuse
+type PasswordHistory struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	TenantID     uuid.UUID `json:"tenant_id" gorm:"type:uuid"`
+	UserID       uuid.UUID `json:"user_id" gorm:"type:uuid;index"`
+	PasswordHash string    `json:"-" gorm:"not null;size:255"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (PasswordHistory) TableName() string {
+	return "password_history"
+}
+
+// SigningKey is an asymmetric key that signs tokens. PrivateKey is encrypted
+// with crypto.Service.
+type SigningKey struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	KID        string     `json:"kid" gorm:"column:kid;not null;size:64;uniqueIndex"`
+	Algorithm  string     `json:"algorithm" gorm:"not null;size:10"`
+	PrivateKey string     `json:"-" gorm:"not null"`
+	PublicKey  string     `json:"public_key" gorm:"not null"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RetiredAt  *time.Time `json:"retired_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+// RecordShare gives one user, or every holder of a role, view or edit access
+// to a single record beyond what their permissions' scopes allow
+type RecordShare struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	TenantID  uuid.UUID  `json:"tenant_id" gorm:"type:uuid"`
+	EntityID  uuid.UUID  `json:"entity_id" gorm:"type:uuid"`
+	RecordID  uuid.UUID  `json:"record_id" gorm:"type:uuid"`
+	UserID    *uuid.UUID `json:"user_id" gorm:"type:uuid"`
+	RoleID    *uuid.UUID `json:"role_id" gorm:"type:uuid"`
+	Access    string     `json:"access" gorm:"not null;size:10;default:view"` // view, edit
+	ExpiresAt *time.Time `json:"expires_at"`
+	CreatedBy *uuid.UUID `json:"created_by" gorm:"type:uuid"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// TenantMembership lets a user log in to a tenant other than the one their
+// account was created in. Their roles there are the tenant's roles among
+// their user_roles.
+type TenantMembership struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	TenantID  uuid.UUID  `json:"tenant_id" gorm:"type:uuid"`
+	UserID    uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	CreatedBy *uuid.UUID `json:"created_by" gorm:"type:uuid"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	// Relations
+	Tenant *Tenant `json:"tenant,omitempty" gorm:"foreignKey:TenantID"`
 	User   *User   `json:"user,omitempty" gorm:"foreignKey:UserID"`
-	Entity *Entity `json:"entity,omitempty" gorm:"foreignKey:EntityID"`
 }
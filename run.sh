@@ -39,6 +39,12 @@ EOF
     echo ".env created with secure credentials"
 fi
 
+# Token signing keys and other secrets are stored encrypted with this key
+if ! grep -q '^ENCRYPTION_KEY=' .env; then
+    echo "ENCRYPTION_KEY=$(openssl rand -hex 16)" >> .env
+    echo "ENCRYPTION_KEY added to .env"
+fi
+
 # Start Genesis
 sudo docker compose up -d --build
 echo ""